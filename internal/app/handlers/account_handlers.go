@@ -0,0 +1,450 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/auth"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/mail"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/password"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/store"
+)
+
+// maxNameLength matches the width of the name column.
+const maxNameLength = 255
+
+// localePattern accepts BCP 47 language tags such as "en", "de-DE" or
+// "zh-Hant-TW".
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// @Summary Get the account
+// @Description Retrieve the profile of the authenticated user.
+// @Security ApiKeyAuth
+// @Produce json,plain
+// @Success 200 {object} models.ListCurator
+// @Failure 401 {string} string "Unauthorized"
+// @Router /me [get]
+func (h *Handler) GetAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, user)
+}
+
+// @Summary Update the account
+// @Description Changes the name, time zone and locale of the authenticated user. Fields left out keep their value. The time zone also defines "today" for /todos/today.
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json,plain
+// @Param   profile  body  models.ProfileRequest  true  "Name, IANA time zone and BCP 47 locale"
+// @Success 200 {object} models.ListCurator
+// @Failure 400 {string} string "Invalid request payload"
+// @Failure 401 {string} string "Unauthorized"
+// @Router /me [patch]
+func (h *Handler) UpdateAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return
+	}
+
+	var thisRequest models.ProfileRequest
+	err = json.Unmarshal(body, &thisRequest)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	if thisRequest.Name != nil {
+		name := strings.TrimSpace(*thisRequest.Name)
+		if name == "" || len(name) > maxNameLength {
+			http.Error(w, fmt.Sprintf("Name must be between 1 and %d characters long", maxNameLength), http.StatusBadRequest)
+			return
+		}
+		user.Name = name
+	}
+	if thisRequest.Timezone != nil {
+		// LoadLocation takes "" and "Local" to mean UTC and the server's
+		// zone, neither of which is a zone a user lives in.
+		tz := *thisRequest.Timezone
+		if _, err := time.LoadLocation(tz); err != nil || tz == "" || tz == "Local" {
+			http.Error(w, "Invalid time zone: "+tz, http.StatusBadRequest)
+			return
+		}
+		user.Timezone = tz
+	}
+	if thisRequest.Locale != nil {
+		locale := *thisRequest.Locale
+		if len(locale) > 35 || !localePattern.MatchString(locale) {
+			http.Error(w, "Invalid locale: "+locale, http.StatusBadRequest)
+			return
+		}
+		user.Locale = locale
+	}
+
+	if err := h.store.UpdateProfile(r.Context(), &user); err != nil {
+		http.Error(w, "Failed to update account", http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, user)
+}
+
+// @Summary Change the password
+// @Description Sets a new password after checking the current one. The new password has to pass the password policy. Every other session of the user is logged out: their refresh tokens are revoked, and so are access tokens issued more than a few microseconds before the change, while ones issued within that window stay valid until they expire. The response carries new tokens for the current session.
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json,plain
+// @Param   request  body  models.ChangePasswordRequest  true  "Current and new password"
+// @Success 200 {object} models.TokenResponse
+// @Failure 400 {string} string "Invalid request payload"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Incorrect password"
+// @Failure 429 {string} string "Too many failed login attempts"
+// @Router /me/password [post]
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return
+	}
+
+	var thisRequest models.ChangePasswordRequest
+	err = json.Unmarshal(body, &thisRequest)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if thisRequest.CurrentPassword == "" || thisRequest.NewPassword == "" {
+		http.Error(w, "Current and new password are required", http.StatusBadRequest)
+		return
+	}
+	if !h.confirmPassword(w, r, user, thisRequest.CurrentPassword) {
+		return
+	}
+
+	hashedPassword, ok := h.hashNewPassword(w, thisRequest.NewPassword, password.Account{Email: user.Email, Name: user.Name})
+	if !ok {
+		return
+	}
+	if err := h.store.ChangePassword(r.Context(), user.ID, hashedPassword); err != nil {
+		http.Error(w, "Failed to change password", http.StatusInternalServerError)
+		return
+	}
+	if err := h.revocations.RevokeUserTokens(r.Context(), user.ID); err != nil {
+		http.Error(w, "Failed to revoke tokens", http.StatusInternalServerError)
+		return
+	}
+
+	tokens, err := h.issueTokens(r.Context(), user)
+	if err != nil {
+		http.Error(w, "Failed to generate authentication token", http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, tokens)
+}
+
+// @Summary Change the email address
+// @Description Emails a link confirming the new address to it. The address only changes once the link is opened; until then the current one stays in use.
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce plain
+// @Param   request  body  models.ChangeEmailRequest  true  "New email address and current password"
+// @Success 202 {string} string "A confirmation link has been sent to the new address"
+// @Failure 400 {string} string "Invalid request payload"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Incorrect password"
+// @Failure 409 {string} string "Email already exists"
+// @Failure 429 {string} string "Too many failed login attempts"
+// @Router /me/email [post]
+func (h *Handler) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return
+	}
+
+	var thisRequest models.ChangeEmailRequest
+	err = json.Unmarshal(body, &thisRequest)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if thisRequest.NewEmail == "" || thisRequest.Password == "" {
+		http.Error(w, "New email and password are required", http.StatusBadRequest)
+		return
+	}
+	if !validEmail(thisRequest.NewEmail) {
+		http.Error(w, "Invalid email address", http.StatusBadRequest)
+		return
+	}
+	if thisRequest.NewEmail == user.Email {
+		http.Error(w, "The new email address is the current one", http.StatusBadRequest)
+		return
+	}
+	if !h.confirmPassword(w, r, user, thisRequest.Password) {
+		return
+	}
+
+	_, err = h.store.FindUserByEmail(r.Context(), thisRequest.NewEmail)
+	if err == nil {
+		http.Error(w, "Email already exists", http.StatusConflict)
+		return
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.sendEmailChange(r, user, thisRequest.NewEmail); err != nil {
+		log.Printf("email change for user %d: %v", user.ID, err)
+		http.Error(w, "Failed to send confirmation email", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintln(w, "A confirmation link has been sent to the new address")
+}
+
+// @Summary Confirm an email address change
+// @Description Makes the address a token from /me/email was sent to the verified email address of its user. Links sent to the previous address stop working.
+// @Produce plain
+// @Param   token  query  string  true  "Confirmation token"
+// @Success 200 {string} string "Email address changed"
+// @Failure 400 {string} string "Invalid or expired confirmation token"
+// @Failure 409 {string} string "Email already exists"
+// @Router /me/email/confirm [get]
+func (h *Handler) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	_, err := h.store.ConfirmEmailChange(r.Context(), auth.HashToken(token))
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Invalid or expired confirmation token", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, store.ErrConflict) {
+		http.Error(w, "Email already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to change email address", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "Email address changed")
+}
+
+// @Summary Delete the account
+// @Description Schedules the account of the authenticated user for deletion and logs out every session. Once the grace period in delete_at is over, the account is deleted for good along with its lists and todos; logging in before then restores it.
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json,plain
+// @Param   request  body  models.DeleteAccountRequest  true  "Current password"
+// @Success 202 {object} models.ListCurator
+// @Failure 400 {string} string "Invalid request payload"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Incorrect password"
+// @Failure 429 {string} string "Too many failed login attempts"
+// @Router /me [delete]
+func (h *Handler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return
+	}
+
+	var thisRequest models.DeleteAccountRequest
+	err = json.Unmarshal(body, &thisRequest)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if thisRequest.Password == "" {
+		http.Error(w, "Password is required", http.StatusBadRequest)
+		return
+	}
+	if !h.confirmPassword(w, r, user, thisRequest.Password) {
+		return
+	}
+
+	grace, err := auth.AccountDeletionGrace()
+	if err != nil {
+		http.Error(w, "Failed to schedule account deletion", http.StatusInternalServerError)
+		return
+	}
+	deleteAt := time.Now().Add(grace).UTC()
+	if err := h.store.ScheduleUserDeletion(r.Context(), user.ID, deleteAt); err != nil {
+		http.Error(w, "Failed to schedule account deletion", http.StatusInternalServerError)
+		return
+	}
+	if err := h.revocations.RevokeUserTokens(r.Context(), user.ID); err != nil {
+		http.Error(w, "Failed to revoke tokens", http.StatusInternalServerError)
+		return
+	}
+
+	user.DeleteAt = &deleteAt
+	respondWithJSON(w, http.StatusAccepted, user)
+}
+
+// currentUser returns the authenticated user. It writes the error response
+// itself and reports whether the caller should continue.
+func (h *Handler) currentUser(w http.ResponseWriter, r *http.Request) (models.ListCurator, bool) {
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return models.ListCurator{}, false
+	}
+	user, err := h.store.GetUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve user", http.StatusInternalServerError)
+		return models.ListCurator{}, false
+	}
+	return user, true
+}
+
+// confirmPassword checks given, the password that confirms a change to the
+// account of user. Wrong passwords count as failed logins, so a stolen
+// session cannot be used to guess the password. It writes the error response itself
+// and reports whether the caller should continue.
+func (h *Handler) confirmPassword(w http.ResponseWriter, r *http.Request, user models.ListCurator, given string) bool {
+	if user.Password == "" {
+		http.Error(w, "The account has no password yet; set one through /password/forgot", http.StatusForbidden)
+		return false
+	}
+
+	address := h.throttle.ClientAddress(r)
+	wait, err := h.throttle.Check(r.Context(), user.Email, address)
+	if err != nil {
+		http.Error(w, "Failed to check login attempts", http.StatusInternalServerError)
+		return false
+	}
+	if wait > 0 {
+		tooManyLoginAttempts(w, wait)
+		return false
+	}
+
+	if match, _ := password.Verify(user.Password, given); !match {
+		if err := h.throttle.Fail(r.Context(), user.Email, address); err != nil {
+			http.Error(w, "Failed to record login attempt", http.StatusInternalServerError)
+			return false
+		}
+		http.Error(w, "Incorrect password", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// sendEmailChange stores a token confirming newEmail as the address of user
+// and emails a link to /me/email/confirm with it to the new address. The
+// current address is told about the change.
+func (h *Handler) sendEmailChange(r *http.Request, user models.ListCurator, newEmail string) error {
+	ttl, err := auth.EmailVerificationTTL()
+	if err != nil {
+		return err
+	}
+	token, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return err
+	}
+	change := models.EmailChange{UserID: user.ID, NewEmail: newEmail, TokenHash: hash, ExpiresAt: time.Now().Add(ttl)}
+	if err := h.store.CreateEmailChange(r.Context(), &change); err != nil {
+		return err
+	}
+
+	err = h.mailer.Send(r.Context(), mail.Message{
+		To:      newEmail,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Please confirm that you want to use this address for your account by opening this link within %s:\n\n"+
+			"%s\n\n"+
+			"If you did not ask for this, you can ignore this email.\n",
+			user.Name, humanDuration(ttl), appURL("/me/email/confirm?token="+url.QueryEscape(token))),
+	})
+	if err != nil {
+		return err
+	}
+
+	// The notice is only logged when it fails: the change still needs the
+	// new address to be confirmed.
+	err = h.mailer.Send(r.Context(), mail.Message{
+		To:      user.Email,
+		Subject: "Your email address is being changed",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Someone asked to change the email address of your account to %s. "+
+			"The change takes effect once the new address is confirmed.\n\n"+
+			"If this was not you, reset your password right away.\n",
+			user.Name, newEmail),
+	})
+	if err != nil {
+		log.Printf("email change notice for user %d: %v", user.ID, err)
+	}
+	return nil
+}
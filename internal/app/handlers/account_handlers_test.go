@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+// newPassword passes the password policy of testAPI for every test user.
+const newPassword = "another correct horse"
+
+// tokens logs the user with email in with pw and returns their tokens.
+func (a *testAPI) tokens(email, pw string) models.TokenResponse {
+	a.t.Helper()
+	w := a.login(email, pw)
+	expectStatus(a.t, w, http.StatusOK)
+	var tokens models.TokenResponse
+	decode(a.t, w, &tokens)
+	return tokens
+}
+
+// refresh exchanges refreshToken at /token/refresh.
+func (a *testAPI) refresh(refreshToken string) int {
+	a.t.Helper()
+	return a.do(http.MethodPost, "/token/refresh", "", `{"refresh_token":"`+refreshToken+`"}`).Code
+}
+
+// account returns the profile of the user of token.
+func (a *testAPI) account(token string) models.ListCurator {
+	a.t.Helper()
+	w := a.do(http.MethodGet, "/me", token, "")
+	expectStatus(a.t, w, http.StatusOK)
+	var user models.ListCurator
+	decode(a.t, w, &user)
+	return user
+}
+
+func TestUpdateAccount(t *testing.T) {
+	api := newTestAPI(t)
+	token := api.register("jane@example.com", "Jane Doe")
+
+	user := api.account(token)
+	if user.Email != "jane@example.com" || user.Name != "Jane Doe" || user.Timezone != "UTC" || user.Locale != "en" {
+		t.Errorf("GET /me = %+v", user)
+	}
+
+	for _, body := range []string{
+		`{"name":""}`,
+		`{"timezone":"Mars/Olympus_Mons"}`,
+		`{"locale":"not a locale"}`,
+	} {
+		expectStatus(t, api.do(http.MethodPatch, "/me", token, body), http.StatusBadRequest)
+	}
+
+	// Fields left out keep their value.
+	expectStatus(t, api.do(http.MethodPatch, "/me", token, `{"timezone":"Europe/Berlin"}`), http.StatusOK)
+	expectStatus(t, api.do(http.MethodPatch, "/me", token, `{"name":"Jane Roe","locale":"de-DE"}`), http.StatusOK)
+	user = api.account(token)
+	if user.Name != "Jane Roe" || user.Timezone != "Europe/Berlin" || user.Locale != "de-DE" {
+		t.Errorf("profile after PATCH /me: %+v", user)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	api := newTestAPI(t)
+	api.register("jane@example.com", "Jane Doe")
+	session := api.tokens("jane@example.com", testPassword)
+	other := api.tokens("jane@example.com", testPassword)
+
+	expectStatus(t, api.do(http.MethodPost, "/me/password", session.Token, `{"current_password":"wrong password","new_password":"`+newPassword+`"}`), http.StatusForbidden)
+	expectStatus(t, api.do(http.MethodPost, "/me/password", session.Token, `{"current_password":"`+testPassword+`","new_password":"short"}`), http.StatusBadRequest)
+	expectStatus(t, api.do(http.MethodPost, "/me/password", session.Token, `{"new_password":"`+newPassword+`"}`), http.StatusBadRequest)
+
+	w := api.do(http.MethodPost, "/me/password", session.Token, `{"current_password":"`+testPassword+`","new_password":"`+newPassword+`"}`)
+	expectStatus(t, w, http.StatusOK)
+	var fresh models.TokenResponse
+	decode(t, w, &fresh)
+
+	// Every session ends, the response carries a new one for the caller.
+	expectStatus(t, api.do(http.MethodGet, "/me", session.Token, ""), http.StatusUnauthorized)
+	expectStatus(t, api.do(http.MethodGet, "/me", other.Token, ""), http.StatusUnauthorized)
+	if code := api.refresh(session.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("refreshing the caller's old session: got %d, want 401", code)
+	}
+	if code := api.refresh(other.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("refreshing another session: got %d, want 401", code)
+	}
+	expectStatus(t, api.do(http.MethodGet, "/me", fresh.Token, ""), http.StatusOK)
+	if code := api.refresh(fresh.RefreshToken); code != http.StatusOK {
+		t.Errorf("refreshing the new session: got %d, want 200", code)
+	}
+
+	expectStatus(t, api.login("jane@example.com", testPassword), http.StatusUnauthorized)
+	api.tokens("jane@example.com", newPassword)
+}
+
+func TestChangeEmail(t *testing.T) {
+	api := newTestAPI(t)
+	token := api.register("jane@example.com", "Jane Doe")
+	api.register("john@example.com", "John Roe")
+
+	for _, tt := range []struct {
+		body   string
+		status int
+	}{
+		{`{"new_email":"jane@work.example","password":"wrong password"}`, http.StatusForbidden},
+		{`{"new_email":"not an address","password":"` + testPassword + `"}`, http.StatusBadRequest},
+		{`{"new_email":"jane@example.com","password":"` + testPassword + `"}`, http.StatusBadRequest},
+		{`{"new_email":"john@example.com","password":"` + testPassword + `"}`, http.StatusConflict},
+	} {
+		expectStatus(t, api.do(http.MethodPost, "/me/email", token, tt.body), tt.status)
+	}
+
+	expectStatus(t, api.do(http.MethodPost, "/me/email", token, `{"new_email":"jane@work.example","password":"`+testPassword+`"}`), http.StatusAccepted)
+	// The address only changes once the link is opened.
+	if user := api.account(token); user.Email != "jane@example.com" {
+		t.Errorf("address before confirming: %s", user.Email)
+	}
+	confirm := "/me/email/confirm?token=" + api.mailedToken("/me/email/confirm")
+	expectStatus(t, api.do(http.MethodGet, confirm, "", ""), http.StatusOK)
+	if user := api.account(token); user.Email != "jane@work.example" || !user.EmailVerified {
+		t.Errorf("account after confirming: %+v", user)
+	}
+	expectStatus(t, api.do(http.MethodGet, confirm, "", ""), http.StatusBadRequest)
+	expectStatus(t, api.login("jane@example.com", testPassword), http.StatusUnauthorized)
+	api.tokens("jane@work.example", testPassword)
+
+	// Somebody else can take the address before the link is opened.
+	expectStatus(t, api.do(http.MethodPost, "/me/email", token, `{"new_email":"jane@home.example","password":"`+testPassword+`"}`), http.StatusAccepted)
+	confirm = "/me/email/confirm?token=" + api.mailedToken("/me/email/confirm")
+	api.register("jane@home.example", "Other Jane")
+	expectStatus(t, api.do(http.MethodGet, confirm, "", ""), http.StatusConflict)
+}
+
+func TestDeleteAccount(t *testing.T) {
+	api := newTestAPI(t)
+	api.register("jane@example.com", "Jane Doe")
+	session := api.tokens("jane@example.com", testPassword)
+	api.addTodo(session.Token, "keep me")
+
+	expectStatus(t, api.do(http.MethodDelete, "/me", session.Token, `{"password":"wrong password"}`), http.StatusForbidden)
+	expectStatus(t, api.do(http.MethodDelete, "/me", session.Token, `{}`), http.StatusBadRequest)
+
+	w := api.do(http.MethodDelete, "/me", session.Token, `{"password":"`+testPassword+`"}`)
+	expectStatus(t, w, http.StatusAccepted)
+	var user models.ListCurator
+	decode(t, w, &user)
+	if user.DeleteAt == nil {
+		t.Fatalf("deleted account %+v has no delete_at", user)
+	}
+	expectStatus(t, api.do(http.MethodGet, "/todos", session.Token, ""), http.StatusUnauthorized)
+	if code := api.refresh(session.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("refreshing after deleting the account: got %d, want 401", code)
+	}
+
+	// Logging in during the grace period restores the account with its
+	// todos.
+	restored := api.tokens("jane@example.com", testPassword)
+	if user := api.account(restored.Token); user.DeleteAt != nil {
+		t.Errorf("account after logging in again: %+v", user)
+	}
+	if todos := api.todos(restored.Token); len(todos) != 1 || todos[0].Title != "keep me" {
+		t.Errorf("todos after restoring the account: %+v", todos)
+	}
+}
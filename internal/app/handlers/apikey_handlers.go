@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/auth"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/store"
+)
+
+// @Summary Get API keys
+// @Description Retrieve the personal API keys of the authenticated user. The keys themselves are never shown again after creation.
+// @Security ApiKeyAuth
+// @Produce json,plain
+// @Success 200 {array} models.APIKey
+// @Failure 401 {string} string "Unauthorized"
+// @Router /api-keys [get]
+func (h *Handler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	keys, err := h.store.ListAPIKeys(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve API keys", http.StatusInternalServerError)
+		return
+	}
+	if keys == nil {
+		keys = []models.APIKey{}
+	}
+
+	respondWithJSON(w, http.StatusOK, keys)
+}
+
+// @Summary Create an API key
+// @Description Creates a personal API key limited to the given scopes. Scripts send it like an access token, as "Authorization: Bearer <key>". The key is only shown in this response.
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json,plain
+// @Param   key  body  models.APIKeyRequest  true  "Name, scopes (todos:read, todos:write, lists:admin, account:manage) and optional expiry of the key"
+// @Success 201 {object} models.NewAPIKeyResponse
+// @Failure 400 {string} string "Invalid request payload"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 409 {string} string "API key exists"
+// @Router /api-keys [post]
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return
+	}
+
+	var thisRequest models.APIKeyRequest
+	err = json.Unmarshal(body, &thisRequest)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(thisRequest.Name)
+	if name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+	if len(name) > store.MaxAPIKeyNameLength {
+		http.Error(w, fmt.Sprintf("Name must be at most %d characters long", store.MaxAPIKeyNameLength), http.StatusBadRequest)
+		return
+	}
+	if len(thisRequest.Scopes) == 0 {
+		http.Error(w, "At least one scope is required", http.StatusBadRequest)
+		return
+	}
+	var scopes []string
+	for _, scope := range thisRequest.Scopes {
+		if !auth.ValidScope(scope) {
+			http.Error(w, fmt.Sprintf("Unknown scope %q (expected %s)", scope, strings.Join(auth.AllScopes, ", ")), http.StatusBadRequest)
+			return
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if thisRequest.ExpiresAt != nil && !thisRequest.ExpiresAt.After(time.Now()) {
+		http.Error(w, "expires_at must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	key, record, err := auth.NewAPIKey(userID)
+	if err != nil {
+		http.Error(w, "Failed to generate API key", http.StatusInternalServerError)
+		return
+	}
+	record.Name = name
+	record.Scopes = scopes
+	record.ExpiresAt = thisRequest.ExpiresAt
+	err = h.store.CreateAPIKey(r.Context(), &record)
+	if errors.Is(err, store.ErrConflict) {
+		http.Error(w, "An API key with this name already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to create API key", http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, models.NewAPIKeyResponse{APIKey: record, Key: key})
+}
+
+// @Summary Delete an API key
+// @Description Revokes a personal API key of the authenticated user. Requests made with it fail from then on.
+// @Security ApiKeyAuth
+// @Produce plain
+// @Param   id  path  integer  true  "API key ID"
+// @Success 204
+// @Failure 400 {string} string "Invalid API key ID"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 404 {string} string "API key doesn't exist"
+// @Router /api-keys/{id} [delete]
+func (h *Handler) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	keyID, ok := idFromPath(w, r, "API key")
+	if !ok {
+		return
+	}
+
+	err := h.store.DeleteAPIKey(r.Context(), userID, keyID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "API key not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to delete API key", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
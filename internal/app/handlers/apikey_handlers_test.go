@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/store"
+)
+
+// createAPIKey creates an API key named name with scopes for the user of
+// token.
+func (a *testAPI) createAPIKey(token, name string, scopes ...string) models.NewAPIKeyResponse {
+	a.t.Helper()
+	body := `{"name":"` + name + `","scopes":["` + strings.Join(scopes, `","`) + `"]}`
+	w := a.do(http.MethodPost, "/api-keys", token, body)
+	expectStatus(a.t, w, http.StatusCreated)
+	var key models.NewAPIKeyResponse
+	decode(a.t, w, &key)
+	return key
+}
+
+func TestAPIKeys(t *testing.T) {
+	api := newTestAPI(t)
+	token := api.register("jane@example.com", "Jane Doe")
+	other := api.register("john@example.com", "John Roe")
+
+	key := api.createAPIKey(token, "CI", "todos:read", "todos:write")
+	if !strings.HasPrefix(key.Key, key.Prefix) || key.Prefix == key.Key || key.ID == 0 {
+		t.Errorf("created key %+v", key)
+	}
+
+	// The key works on the todo endpoints like an access token.
+	expectStatus(t, api.do(http.MethodPost, "/todos", key.Key, `{"title":"from CI","description":"nightly"}`), http.StatusCreated)
+	if todos := api.todos(token); len(todos) != 1 || todos[0].Title != "from CI" {
+		t.Errorf("todos created with the key: %+v", todos)
+	}
+
+	// Only the prefix is ever shown again.
+	w := api.do(http.MethodGet, "/api-keys", token, "")
+	expectStatus(t, w, http.StatusOK)
+	if strings.Contains(w.Body.String(), key.Key) {
+		t.Error("GET /api-keys shows the key")
+	}
+	var keys []models.APIKey
+	decode(t, w, &keys)
+	if len(keys) != 1 || keys[0].Prefix != key.Prefix || keys[0].LastUsedAt == nil {
+		t.Errorf("listed keys %+v", keys)
+	}
+
+	// Keys cannot manage keys, even with every scope.
+	admin := api.createAPIKey(token, "admin", "todos:read", "todos:write", "lists:admin", "account:manage")
+	expectStatus(t, api.do(http.MethodGet, "/api-keys", admin.Key, ""), http.StatusForbidden)
+	expectStatus(t, api.do(http.MethodPost, "/api-keys", admin.Key, `{"name":"more","scopes":["todos:read"]}`), http.StatusForbidden)
+
+	path := "/api-keys/" + strconv.Itoa(key.ID)
+	expectStatus(t, api.do(http.MethodDelete, path, other, ""), http.StatusNotFound)
+	expectStatus(t, api.do(http.MethodDelete, path, token, ""), http.StatusNoContent)
+	expectStatus(t, api.do(http.MethodGet, "/todos", key.Key, ""), http.StatusUnauthorized)
+	expectStatus(t, api.do(http.MethodGet, "/todos", "tdk_made-up", ""), http.StatusUnauthorized)
+}
+
+func TestCreateAPIKeyValidation(t *testing.T) {
+	api := newTestAPI(t)
+	token := api.register("jane@example.com", "Jane Doe")
+	api.createAPIKey(token, "CI", "todos:read")
+
+	for _, body := range []string{
+		`{"scopes":["todos:read"]}`,
+		`{"name":"no scopes","scopes":[]}`,
+		`{"name":"unknown","scopes":["todos:admin"]}`,
+		`{"name":"expired","scopes":["todos:read"],"expires_at":"2000-01-01T00:00:00Z"}`,
+		`{"name":"` + strings.Repeat("x", store.MaxAPIKeyNameLength+1) + `","scopes":["todos:read"]}`,
+	} {
+		expectStatus(t, api.do(http.MethodPost, "/api-keys", token, body), http.StatusBadRequest)
+	}
+	expectStatus(t, api.do(http.MethodPost, "/api-keys", token, `{"name":"CI","scopes":["todos:write"]}`), http.StatusConflict)
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	api := newTestAPI(t)
+	token := api.register("jane@example.com", "Jane Doe")
+	todo := api.addTodo(token, "keep me")
+	path := "/todos/" + strconv.Itoa(todo.ID)
+
+	readOnly := api.createAPIKey(token, "dashboard", "todos:read")
+	expectStatus(t, api.do(http.MethodGet, "/todos", readOnly.Key, ""), http.StatusOK)
+	expectStatus(t, api.do(http.MethodGet, path, readOnly.Key, ""), http.StatusOK)
+	for _, request := range []struct{ method, path, body string }{
+		{http.MethodPost, "/todos", `{"title":"spam","description":"x"}`},
+		{http.MethodPut, path, `{"title":"changed","description":"x"}`},
+		{http.MethodPatch, path, `{"title":"changed"}`},
+		{http.MethodDelete, path, ""},
+		{http.MethodPost, path + "/complete", ""},
+	} {
+		w := api.do(request.method, request.path, readOnly.Key, request.body)
+		expectStatus(t, w, http.StatusForbidden)
+		if got := w.Header().Get("WWW-Authenticate"); !strings.Contains(got, `scope="todos:write"`) {
+			t.Errorf("%s %s: WWW-Authenticate %q", request.method, request.path, got)
+		}
+	}
+	if todos := api.todos(token); len(todos) != 1 || todos[0].Title != "keep me" || todos[0].Completed {
+		t.Errorf("todos after the read-only key's attempts: %+v", todos)
+	}
+
+	// Writing does not imply reading.
+	writeOnly := api.createAPIKey(token, "importer", "todos:write")
+	expectStatus(t, api.do(http.MethodGet, "/todos", writeOnly.Key, ""), http.StatusForbidden)
+	expectStatus(t, api.do(http.MethodDelete, path, writeOnly.Key, ""), http.StatusNoContent)
+}
@@ -0,0 +1,339 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/auth"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/store"
+)
+
+// @Summary Get the checklist of a ToDo item
+// @Description Retrieve the checklist items of a to-do item in order
+// @Tags checklist
+// @Security ApiKeyAuth
+// @Produce json,plain
+// @Param   id  path  integer  true  "Todo ID"
+// @Success 200 {array} models.ChecklistItem
+// @Failure 400 {string} string "Invalid todo ID"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 404 {string} string "Todo doesn't exist"
+// @Router /todos/{id}/items [get]
+func (h *Handler) GetChecklist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	todoID, ok := idFromPath(w, r, "Todo")
+	if !ok {
+		return
+	}
+
+	items, err := h.store.ListChecklistItems(r.Context(), userID, todoID)
+	if !handleChecklistError(w, err, "Failed to retrieve checklist") {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, items)
+}
+
+// @Summary Add a checklist item
+// @Description Adds an item to the checklist of a to-do item, at the given position or at the end
+// @Tags checklist
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json,plain
+// @Param   id  path  integer  true  "Todo ID"
+// @Param   item  body  models.ChecklistItemRequest  true  "Checklist item to be added"
+// @Success 201 {object} models.ChecklistItem
+// @Failure 400 {string} string "Invalid request payload"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Todo is read-only"
+// @Failure 404 {string} string "Todo doesn't exist"
+// @Router /todos/{id}/items [post]
+func (h *Handler) AddChecklistItem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	todoID, ok := idFromPath(w, r, "Todo")
+	if !ok {
+		return
+	}
+
+	thisRequest, ok := readChecklistItem(w, r)
+	if !ok {
+		return
+	}
+
+	item := models.ChecklistItem{Title: thisRequest.Title, Position: -1}
+	if thisRequest.Position != nil {
+		if *thisRequest.Position < 0 {
+			http.Error(w, "Position must not be negative", http.StatusBadRequest)
+			return
+		}
+		item.Position = *thisRequest.Position
+	}
+
+	err := h.store.AddChecklistItem(r.Context(), userID, todoID, &item)
+	if !handleChecklistError(w, err, "Failed to add checklist item") {
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, item)
+}
+
+// @Summary Reorder a checklist
+// @Description Puts the checklist items of a to-do item in the given order. Every item must be listed exactly once.
+// @Tags checklist
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json,plain
+// @Param   id  path  integer  true  "Todo ID"
+// @Param   order  body  models.ChecklistOrderRequest  true  "Item IDs in their new order"
+// @Success 200 {array} models.ChecklistItem
+// @Failure 400 {string} string "Invalid request payload"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Todo is read-only"
+// @Failure 404 {string} string "Todo doesn't exist"
+// @Router /todos/{id}/items/order [put]
+func (h *Handler) ReorderChecklist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	todoID, ok := idFromPath(w, r, "Todo")
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return
+	}
+
+	var thisRequest models.ChecklistOrderRequest
+	err = json.Unmarshal(body, &thisRequest)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	items, err := h.store.ReorderChecklist(r.Context(), userID, todoID, thisRequest.ItemIDs)
+	if !handleChecklistError(w, err, "Failed to reorder checklist") {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, items)
+}
+
+// @Summary Rename a checklist item
+// @Description Changes the title of a checklist item
+// @Tags checklist
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json,plain
+// @Param   id  path  integer  true  "Todo ID"
+// @Param   item_id  path  integer  true  "Checklist item ID"
+// @Param   item  body  models.ChecklistItemRequest  true  "New title of the item"
+// @Success 200 {object} models.ChecklistItem
+// @Failure 400 {string} string "Invalid request payload"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Todo is read-only"
+// @Failure 404 {string} string "Todo or item doesn't exist"
+// @Router /todos/{id}/items/{item_id} [put]
+func (h *Handler) RenameChecklistItem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	todoID, ok := idFromPath(w, r, "Todo")
+	if !ok {
+		return
+	}
+	itemID, ok := intPathValue(w, r, "item_id", "Item")
+	if !ok {
+		return
+	}
+
+	thisRequest, ok := readChecklistItem(w, r)
+	if !ok {
+		return
+	}
+
+	item := models.ChecklistItem{ID: itemID, Title: thisRequest.Title}
+	err := h.store.RenameChecklistItem(r.Context(), userID, todoID, &item)
+	if !handleChecklistError(w, err, "Failed to rename checklist item") {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, item)
+}
+
+// @Summary Toggle a checklist item
+// @Description Checks an open checklist item or unchecks a done one. To-do items with auto_complete set are completed once every item is done and reopened when one is unchecked.
+// @Tags checklist
+// @Security ApiKeyAuth
+// @Produce json,plain
+// @Param   id  path  integer  true  "Todo ID"
+// @Param   item_id  path  integer  true  "Checklist item ID"
+// @Success 200 {object} models.ChecklistItem
+// @Failure 400 {string} string "Invalid item ID"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Todo is read-only"
+// @Failure 404 {string} string "Todo or item doesn't exist"
+// @Router /todos/{id}/items/{item_id}/toggle [post]
+func (h *Handler) ToggleChecklistItem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	todoID, ok := idFromPath(w, r, "Todo")
+	if !ok {
+		return
+	}
+	itemID, ok := intPathValue(w, r, "item_id", "Item")
+	if !ok {
+		return
+	}
+
+	item, err := h.store.ToggleChecklistItem(r.Context(), userID, todoID, itemID)
+	if !handleChecklistError(w, err, "Failed to toggle checklist item") {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, item)
+}
+
+// @Summary Delete a checklist item
+// @Description Removes an item from the checklist of a to-do item
+// @Tags checklist
+// @Security ApiKeyAuth
+// @Produce json,plain
+// @Param   id  path  integer  true  "Todo ID"
+// @Param   item_id  path  integer  true  "Checklist item ID"
+// @Success 204
+// @Failure 400 {string} string "Invalid item ID"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Todo is read-only"
+// @Failure 404 {string} string "Todo or item doesn't exist"
+// @Router /todos/{id}/items/{item_id} [delete]
+func (h *Handler) DeleteChecklistItem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	todoID, ok := idFromPath(w, r, "Todo")
+	if !ok {
+		return
+	}
+	itemID, ok := intPathValue(w, r, "item_id", "Item")
+	if !ok {
+		return
+	}
+
+	err := h.store.DeleteChecklistItem(r.Context(), userID, todoID, itemID)
+	if !handleChecklistError(w, err, "Failed to delete checklist item") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readChecklistItem decodes a models.ChecklistItemRequest body and validates
+// its title. It writes the error response itself and reports whether the
+// caller should continue.
+func readChecklistItem(w http.ResponseWriter, r *http.Request) (models.ChecklistItemRequest, bool) {
+	var thisRequest models.ChecklistItemRequest
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return thisRequest, false
+	}
+
+	err = json.Unmarshal(body, &thisRequest)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return thisRequest, false
+	}
+
+	thisRequest.Title = strings.TrimSpace(thisRequest.Title)
+	if thisRequest.Title == "" {
+		http.Error(w, "Item title is required", http.StatusBadRequest)
+		return thisRequest, false
+	}
+	if len(thisRequest.Title) > store.MaxChecklistItemTitleLength {
+		http.Error(w, fmt.Sprintf("Item title must be at most %d characters long", store.MaxChecklistItemTitleLength), http.StatusBadRequest)
+		return thisRequest, false
+	}
+	return thisRequest, true
+}
+
+// handleChecklistError writes the response for an error returned by a
+// store.ChecklistStore method and reports whether the caller should
+// continue.
+func handleChecklistError(w http.ResponseWriter, err error, message string) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, "Todo not found", http.StatusNotFound)
+	case errors.Is(err, store.ErrItemNotFound):
+		http.Error(w, "Checklist item not found", http.StatusNotFound)
+	case errors.Is(err, store.ErrForbidden):
+		http.Error(w, "You can only view the todos of this list", http.StatusForbidden)
+	case errors.Is(err, store.ErrChecklistOrder):
+		http.Error(w, "item_ids must list every checklist item exactly once", http.StatusBadRequest)
+	default:
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+	return false
+}
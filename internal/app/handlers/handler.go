@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"github.com/Kwagmire/go-todo-api/internal/pkg/auth"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/mail"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/oidc"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/password"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/store"
+)
+
+// Handler serves the API endpoints. Its dependencies are injected through
+// NewHandler so the handlers can run against any storage backend.
+type Handler struct {
+	store       store.Store
+	revocations *auth.Revocations
+	mailer      mail.Mailer
+	oidc        *oidc.Provider
+	throttle    *auth.LoginThrottle
+	passwords   password.Policy
+	hasher      password.Hasher
+}
+
+// NewHandler returns a Handler on s. Logging out revokes access tokens
+// through revocations, which should be the one AuthMiddleware uses, and
+// account emails are sent through mailer. Users log in through provider
+// when it is not nil, and password logins are throttled by throttle. New
+// passwords have to pass passwords and are hashed with hasher.
+func NewHandler(s store.Store, revocations *auth.Revocations, mailer mail.Mailer, provider *oidc.Provider, throttle *auth.LoginThrottle, passwords password.Policy, hasher password.Hasher) *Handler {
+	return &Handler{store: s, revocations: revocations, mailer: mailer, oidc: provider, throttle: throttle, passwords: passwords, hasher: hasher}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/auth"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/mail"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/oidc"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/password"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/store"
+)
+
+func TestMain(m *testing.M) {
+	keyring := auth.NewKeyring()
+	keyring.AddHMAC("", []byte("handler test secret"))
+	if err := keyring.SetSigningKey(""); err != nil {
+		panic(err)
+	}
+	auth.UseKeyring(keyring)
+	os.Exit(m.Run())
+}
+
+// testPassword passes the password policy of testAPI for every test user.
+const testPassword = "correct horse battery staple"
+
+// testAPI serves the routes of main.go from a Handler on a memory store.
+type testAPI struct {
+	t     *testing.T
+	store *store.MemoryStore
+	mux   *http.ServeMux
+	// mailbox collects the emails the handlers send.
+	mailbox *bytes.Buffer
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	return newTestAPIWithProvider(t, nil)
+}
+
+// newTestAPIWithProvider is newTestAPI with OIDC logins through provider.
+func newTestAPIWithProvider(t *testing.T, provider *oidc.Provider) *testAPI {
+	t.Helper()
+	st := store.NewMemoryStore()
+	revocations := auth.NewRevocations(st, time.Minute)
+	auth.UseRevocations(revocations)
+	auth.UseAPIKeys(st)
+	throttle := auth.NewLoginThrottle(auth.NewMemoryLoginAttempts(), auth.DefaultAccountPolicy, auth.DefaultAddressPolicy, nil)
+	passwords := password.Policy{password.MinLength(password.DefaultMinLength), password.NoPersonalInfo{}}
+	// The cheapest cost keeps the tests fast.
+	hasher := password.Bcrypt{Cost: 4}
+	mailbox := new(bytes.Buffer)
+	h := NewHandler(st, revocations, mail.NewLogMailer(mailbox, "no-reply@localhost"), provider, throttle, passwords, hasher)
+
+	readTodos := auth.RequireScopes(auth.ScopeTodosRead)
+	writeTodos := auth.RequireScopes(auth.ScopeTodosWrite)
+	manageAccount := auth.RequireScopes(auth.ScopeAccountManage)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /register", h.RegisterUser)
+	mux.HandleFunc("POST /login", h.LoginUser)
+	mux.HandleFunc("POST /login/2fa", h.LoginTwoFactor)
+	mux.HandleFunc("GET /oidc/login", h.OIDCLogin)
+	mux.HandleFunc("GET /oidc/callback", h.OIDCCallback)
+	mux.HandleFunc("POST /token/refresh", h.RefreshToken)
+	mux.HandleFunc("POST /2fa/totp", auth.AuthMiddleware(h.EnrollTOTP, auth.TokensOnly, manageAccount))
+	mux.HandleFunc("POST /2fa/totp/confirm", auth.AuthMiddleware(h.ConfirmTOTP, auth.TokensOnly, manageAccount))
+	mux.HandleFunc("DELETE /2fa/totp", auth.AuthMiddleware(h.DisableTOTP, auth.TokensOnly, manageAccount))
+	mux.HandleFunc("GET /me", auth.AuthMiddleware(h.GetAccount, manageAccount))
+	mux.HandleFunc("PATCH /me", auth.AuthMiddleware(h.UpdateAccount, manageAccount))
+	mux.HandleFunc("DELETE /me", auth.AuthMiddleware(h.DeleteAccount, auth.AllowUnverified, auth.TokensOnly, manageAccount))
+	mux.HandleFunc("POST /me/password", auth.AuthMiddleware(h.ChangePassword, auth.TokensOnly, manageAccount))
+	mux.HandleFunc("POST /me/email", auth.AuthMiddleware(h.ChangeEmail, auth.AllowUnverified, auth.TokensOnly, manageAccount))
+	mux.HandleFunc("GET /me/email/confirm", h.ConfirmEmailChange)
+	mux.HandleFunc("GET /api-keys", auth.AuthMiddleware(h.GetAPIKeys, auth.TokensOnly, manageAccount))
+	mux.HandleFunc("POST /api-keys", auth.AuthMiddleware(h.CreateAPIKey, auth.TokensOnly, manageAccount))
+	mux.HandleFunc("DELETE /api-keys/{id}", auth.AuthMiddleware(h.DeleteAPIKey, auth.TokensOnly, manageAccount))
+	mux.HandleFunc("POST /todos", auth.AuthMiddleware(h.AddTodo, writeTodos))
+	mux.HandleFunc("GET /todos", auth.AuthMiddleware(h.GetTodos, readTodos))
+	mux.HandleFunc("GET /todos/{id}", auth.AuthMiddleware(h.GetTodo, readTodos))
+	mux.HandleFunc("PUT /todos/{id}", auth.AuthMiddleware(h.UpdateTodo, writeTodos))
+	mux.HandleFunc("PATCH /todos/{id}", auth.AuthMiddleware(h.PatchTodo, writeTodos))
+	mux.HandleFunc("DELETE /todos/{id}", auth.AuthMiddleware(h.DeleteTodo, writeTodos))
+	mux.HandleFunc("POST /todos/{id}/complete", auth.AuthMiddleware(h.CompleteTodo, writeTodos))
+	mux.HandleFunc("POST /todos/{id}/reopen", auth.AuthMiddleware(h.ReopenTodo, writeTodos))
+	mux.HandleFunc("GET /todos/{id}/occurrences", auth.AuthMiddleware(h.GetOccurrences, readTodos))
+	return &testAPI{t: t, store: st, mux: mux, mailbox: mailbox}
+}
+
+// do sends a request with body to the API, authenticated with token unless
+// it is empty.
+func (a *testAPI) do(method, path, token, body string) *httptest.ResponseRecorder {
+	a.t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	return a.serve(r, token)
+}
+
+// serve is do for a request built by the test.
+func (a *testAPI) serve(r *http.Request, token string) *httptest.ResponseRecorder {
+	a.t.Helper()
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	a.mux.ServeHTTP(w, r)
+	return w
+}
+
+// register signs up a user with testPassword and returns their access
+// token.
+func (a *testAPI) register(email, name string) string {
+	a.t.Helper()
+	w := a.do(http.MethodPost, "/register", "", `{"email":"`+email+`","name":"`+name+`","password":"`+testPassword+`"}`)
+	if w.Code != http.StatusCreated {
+		a.t.Fatalf("register %s: got %d %s", email, w.Code, w.Body)
+	}
+	var tokens models.TokenResponse
+	decode(a.t, w, &tokens)
+	return tokens.Token
+}
+
+// mailedToken returns the token of the last link to path that was emailed.
+func (a *testAPI) mailedToken(path string) string {
+	a.t.Helper()
+	link := path + "?token="
+	mail := a.mailbox.String()
+	start := strings.LastIndex(mail, link)
+	if start < 0 {
+		a.t.Fatalf("no link to %s was emailed", path)
+	}
+	token, _, _ := strings.Cut(mail[start+len(link):], "\n")
+	token, err := url.QueryUnescape(token)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return token
+}
+
+// expectStatus fails the test unless the response has status.
+func expectStatus(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("got status %d, want %d: %s", w.Code, status, strings.TrimSpace(w.Body.String()))
+	}
+}
+
+// decode unmarshals the JSON body of the response into v.
+func decode(t *testing.T, w *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body, err)
+	}
+}
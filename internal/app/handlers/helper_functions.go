@@ -1,8 +1,15 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/store"
 )
 
 func respondWithJSON(w http.ResponseWriter, status int, payload interface{}) {
@@ -16,3 +23,79 @@ func respondWithJSON(w http.ResponseWriter, status int, payload interface{}) {
 	w.WriteHeader(status)
 	w.Write(response)
 }
+
+// paginationFromQuery reads the page and limit query parameters, falling
+// back to the first page of ten items.
+func paginationFromQuery(r *http.Request) (page, limit int) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err = strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 {
+		limit = 10
+	}
+	return page, limit
+}
+
+// idFromPath reads the {id} wildcard of the matched route pattern. resource
+// names the kind of ID in error messages. It writes the error response itself
+// and reports whether the caller should continue.
+func idFromPath(w http.ResponseWriter, r *http.Request, resource string) (int, bool) {
+	return intPathValue(w, r, "id", resource)
+}
+
+// intPathValue is idFromPath for a wildcard other than {id}.
+func intPathValue(w http.ResponseWriter, r *http.Request, name, resource string) (int, bool) {
+	idStr := r.PathValue(name)
+	if idStr == "" {
+		http.Error(w, resource+" ID missing in URL path", http.StatusBadRequest)
+		return 0, false
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid "+strings.ToLower(resource)+" ID format. Must be an integer.", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// validTagNames reports whether every name fits in the tags table.
+func validTagNames(names []string) bool {
+	for _, name := range names {
+		if len(strings.TrimSpace(name)) > store.MaxTagNameLength {
+			return false
+		}
+	}
+	return true
+}
+
+// todoETag returns the entity tag of todo as GetTodo serves it. It hashes
+// the whole representation, so it also changes with the tags and checklist
+// progress.
+func todoETag(todo models.TodoItem) string {
+	representation, err := json.Marshal(todo)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(representation)
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+}
+
+// ifMatch reports whether the If-Match header of r, if any, names etag.
+// Weak tags never match, as RFC 9110 asks for a strong comparison.
+func ifMatch(r *http.Request, etag string) bool {
+	values := r.Header.Values("If-Match")
+	if len(values) == 0 {
+		return true
+	}
+	for _, value := range values {
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || (tag == etag && etag != "") {
+				return true
+			}
+		}
+	}
+	return false
+}
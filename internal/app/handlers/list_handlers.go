@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/auth"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/store"
+)
+
+// maxListNameLength is the longest list name the lists table accepts.
+const maxListNameLength = 255
+
+// @Summary Get lists
+// @Description Retrieve every list of the authenticated user, starting with the default Inbox, together with the lists shared with them
+// @Tags lists
+// @Security ApiKeyAuth
+// @Produce json,plain
+// @Success 200 {array} models.TodoList
+// @Failure 401 {string} string "Unauthorized"
+// @Router /lists [get]
+func (h *Handler) GetLists(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	lists, err := h.store.ListLists(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve lists: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if lists == nil {
+		lists = []models.TodoList{}
+	}
+
+	respondWithJSON(w, http.StatusOK, lists)
+}
+
+// @Summary Create a list
+// @Description Creates a new list for the authenticated user
+// @Tags lists
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json,plain
+// @Param   list  body  models.ListRequest  true  "List to be created"
+// @Success 201 {object} models.TodoList
+// @Failure 400 {string} string "Invalid request payload"
+// @Failure 401 {string} string "Unauthorized"
+// @Router /lists [post]
+func (h *Handler) CreateList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	name, ok := readListName(w, r)
+	if !ok {
+		return
+	}
+
+	list := models.TodoList{Name: name}
+	if err := h.store.CreateList(r.Context(), userID, &list); err != nil {
+		http.Error(w, "Failed to create list: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, list)
+}
+
+// @Summary Get a list
+// @Description Retrieve one list the authenticated user owns or is a member of
+// @Tags lists
+// @Security ApiKeyAuth
+// @Produce json,plain
+// @Param   id  path  integer  true  "List ID"
+// @Success 200 {object} models.TodoList
+// @Failure 400 {string} string "Invalid list ID"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 404 {string} string "List doesn't exist"
+// @Router /lists/{id} [get]
+func (h *Handler) GetList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	listID, ok := idFromPath(w, r, "List")
+	if !ok {
+		return
+	}
+
+	list, err := h.store.GetList(r.Context(), userID, listID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "List not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to retrieve list", http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, list)
+}
+
+// @Summary Rename a list
+// @Description Changes the name of a list owned by the authenticated user
+// @Tags lists
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json,plain
+// @Param   id  path  integer  true  "List ID"
+// @Param   list  body  models.ListRequest  true  "New name of the list"
+// @Success 200 {object} models.TodoList
+// @Failure 400 {string} string "Invalid request payload"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Not the list owner"
+// @Failure 404 {string} string "List doesn't exist"
+// @Router /lists/{id} [put]
+func (h *Handler) RenameList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	listID, ok := idFromPath(w, r, "List")
+	if !ok {
+		return
+	}
+
+	name, ok := readListName(w, r)
+	if !ok {
+		return
+	}
+
+	list := models.TodoList{ID: listID, Name: name}
+	err := h.store.RenameList(r.Context(), userID, &list)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "List not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, store.ErrForbidden) {
+		http.Error(w, "Only the owner of a list can change it", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to rename list", http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, list)
+}
+
+// @Summary Delete a list
+// @Description Deletes a list owned by the authenticated user. Its to-do items are moved to the list given by move_to, or deleted along with the list when move_to is omitted. The default Inbox cannot be deleted.
+// @Tags lists
+// @Security ApiKeyAuth
+// @Produce json,plain
+// @Param   id  path  integer  true  "List ID"
+// @Param   move_to  query integer false "List that receives the to-do items of the deleted list"
+// @Success 204
+// @Failure 400 {string} string "Invalid list ID"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Not the list owner"
+// @Failure 404 {string} string "List doesn't exist"
+// @Failure 409 {string} string "Default list"
+// @Router /lists/{id} [delete]
+func (h *Handler) DeleteList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	listID, ok := idFromPath(w, r, "List")
+	if !ok {
+		return
+	}
+
+	var moveTo int
+	if moveToStr := r.URL.Query().Get("move_to"); moveToStr != "" {
+		var err error
+		moveTo, err = strconv.Atoi(moveToStr)
+		if err != nil || moveTo < 1 {
+			http.Error(w, "Invalid move_to list ID. Must be a positive integer.", http.StatusBadRequest)
+			return
+		}
+	}
+
+	err := h.store.DeleteList(r.Context(), userID, listID, moveTo)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "List not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, store.ErrForbidden) {
+		http.Error(w, "Only the owner of a list can change it", http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, store.ErrListNotFound) {
+		http.Error(w, "move_to must name another list you can edit", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, store.ErrDefaultList) {
+		http.Error(w, "The default list cannot be deleted", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to delete list", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Get the ToDo items of a list
+// @Description Retrieve the to-do items in one list of the authenticated user. Accepts the same filters as GET /todos.
+// @Tags lists
+// @Security ApiKeyAuth
+// @Produce json,plain
+// @Param   id  path  integer  true  "List ID"
+// @Param   page  query integer false "The page to view"
+// @Param   limit  query integer false "Number of items per page"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {string} string "Invalid filter"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 404 {string} string "List doesn't exist"
+// @Router /lists/{id}/todos [get]
+func (h *Handler) GetListTodos(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	listID, ok := idFromPath(w, r, "List")
+	if !ok {
+		return
+	}
+
+	_, err := h.store.GetList(r.Context(), userID, listID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "List not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to retrieve list", http.StatusInternalServerError)
+		return
+	}
+
+	page, limit := paginationFromQuery(r)
+	filter, err := todoFilterFromQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.ListID = listID
+	filter.Limit = limit
+	filter.Offset = (page - 1) * limit
+
+	h.respondWithTodos(w, r, userID, filter, page, limit)
+}
+
+// readListName decodes a models.ListRequest body and validates its name. It
+// writes the error response itself and reports whether the caller should
+// continue.
+func readListName(w http.ResponseWriter, r *http.Request) (string, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return "", false
+	}
+
+	var thisRequest models.ListRequest
+	err = json.Unmarshal(body, &thisRequest)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return "", false
+	}
+
+	name := strings.TrimSpace(thisRequest.Name)
+	if name == "" {
+		http.Error(w, "List name is required", http.StatusBadRequest)
+		return "", false
+	}
+	if len(name) > maxListNameLength {
+		http.Error(w, "List name must be at most 255 characters long", http.StatusBadRequest)
+		return "", false
+	}
+	return name, true
+}
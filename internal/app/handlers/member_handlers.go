@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/auth"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/store"
+)
+
+// @Summary Get the members of a list
+// @Description Retrieve everybody a list is shared with, starting with its owner. Any member of the list may see them.
+// @Tags lists
+// @Security ApiKeyAuth
+// @Produce json,plain
+// @Param   id  path  integer  true  "List ID"
+// @Success 200 {array} models.ListMember
+// @Failure 400 {string} string "Invalid list ID"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 404 {string} string "List doesn't exist"
+// @Router /lists/{id}/members [get]
+func (h *Handler) GetListMembers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	listID, ok := idFromPath(w, r, "List")
+	if !ok {
+		return
+	}
+
+	members, err := h.store.ListMembers(r.Context(), userID, listID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "List not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to retrieve list members", http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, members)
+}
+
+// @Summary Share a list
+// @Description Invites the registered user with the given email address to a list owned by the authenticated user, as an editor or a viewer. Viewers are used when no role is given.
+// @Tags lists
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json,plain
+// @Param   id  path  integer  true  "List ID"
+// @Param   member  body  models.MemberRequest  true  "User to share the list with"
+// @Success 201 {object} models.ListMember
+// @Failure 400 {string} string "Invalid request payload"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Not the list owner"
+// @Failure 404 {string} string "List or user doesn't exist"
+// @Failure 409 {string} string "Already a member"
+// @Router /lists/{id}/members [post]
+func (h *Handler) InviteListMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	listID, ok := idFromPath(w, r, "List")
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return
+	}
+
+	var thisRequest models.MemberRequest
+	err = json.Unmarshal(body, &thisRequest)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	email := strings.TrimSpace(thisRequest.Email)
+	if email == "" {
+		http.Error(w, "Email is required", http.StatusBadRequest)
+		return
+	}
+	if thisRequest.Role == "" {
+		thisRequest.Role = models.RoleViewer
+	}
+	if !validMemberRole(w, thisRequest.Role) {
+		return
+	}
+
+	invitee, err := h.store.FindUserByEmail(r.Context(), email)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "No user with that email address", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to look up user", http.StatusInternalServerError)
+		return
+	}
+
+	member, err := h.store.AddListMember(r.Context(), userID, listID, invitee.ID, thisRequest.Role)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "List not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, store.ErrForbidden) {
+		http.Error(w, "Only the owner of a list can share it", http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, store.ErrConflict) {
+		http.Error(w, "User is already a member of this list", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to share list", http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, member)
+}
+
+// @Summary Change the role of a list member
+// @Description Makes a member of a list owned by the authenticated user an editor or a viewer
+// @Tags lists
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json,plain
+// @Param   id  path  integer  true  "List ID"
+// @Param   user_id  path  integer  true  "User ID of the member"
+// @Param   role  body  models.RoleRequest  true  "New role of the member"
+// @Success 200 {object} models.ListMember
+// @Failure 400 {string} string "Invalid request payload"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Not the list owner"
+// @Failure 404 {string} string "List or member doesn't exist"
+// @Failure 409 {string} string "Member is the list owner"
+// @Router /lists/{id}/members/{user_id} [put]
+func (h *Handler) UpdateListMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	listID, ok := idFromPath(w, r, "List")
+	if !ok {
+		return
+	}
+	memberID, ok := intPathValue(w, r, "user_id", "User")
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return
+	}
+
+	var thisRequest models.RoleRequest
+	err = json.Unmarshal(body, &thisRequest)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if !validMemberRole(w, thisRequest.Role) {
+		return
+	}
+
+	member, err := h.store.SetListMemberRole(r.Context(), userID, listID, memberID, thisRequest.Role)
+	if !handleMemberError(w, err, "Failed to update list member") {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, member)
+}
+
+// @Summary Remove a list member
+// @Description Stops sharing a list owned by the authenticated user with one of its members. Members may also remove themselves to leave a list shared with them.
+// @Tags lists
+// @Security ApiKeyAuth
+// @Produce json,plain
+// @Param   id  path  integer  true  "List ID"
+// @Param   user_id  path  integer  true  "User ID of the member"
+// @Success 204
+// @Failure 400 {string} string "Invalid list ID"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Not the list owner"
+// @Failure 404 {string} string "List or member doesn't exist"
+// @Failure 409 {string} string "Member is the list owner"
+// @Router /lists/{id}/members/{user_id} [delete]
+func (h *Handler) RemoveListMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	listID, ok := idFromPath(w, r, "List")
+	if !ok {
+		return
+	}
+	memberID, ok := intPathValue(w, r, "user_id", "User")
+	if !ok {
+		return
+	}
+
+	err := h.store.RemoveListMember(r.Context(), userID, listID, memberID)
+	if !handleMemberError(w, err, "Failed to remove list member") {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validMemberRole reports whether role may be given to a list member. The
+// owner role is reserved for the user that created the list. It writes the
+// error response itself.
+func validMemberRole(w http.ResponseWriter, role models.ListRole) bool {
+	if role != models.RoleEditor && role != models.RoleViewer {
+		http.Error(w, "Invalid role. Use editor or viewer.", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// handleMemberError writes the response for an error returned when changing
+// a list member and reports whether the caller should continue.
+func handleMemberError(w http.ResponseWriter, err error, message string) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, "List not found", http.StatusNotFound)
+	case errors.Is(err, store.ErrMemberNotFound):
+		http.Error(w, "Member not found", http.StatusNotFound)
+	case errors.Is(err, store.ErrForbidden):
+		http.Error(w, "Only the owner of a list can manage its members", http.StatusForbidden)
+	case errors.Is(err, store.ErrListOwner):
+		http.Error(w, "The owner of a list cannot be changed or removed", http.StatusConflict)
+	default:
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+	return false
+}
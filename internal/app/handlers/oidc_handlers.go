@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/auth"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/oidc"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/store"
+)
+
+// @Summary Log in with the identity provider
+// @Description Redirects to the login page of the OpenID Connect provider, which sends the user back to /oidc/callback.
+// @Produce plain
+// @Success 302 {string} string "Redirect to the identity provider"
+// @Failure 404 {string} string "OIDC login is not configured"
+// @Router /oidc/login [get]
+func (h *Handler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.oidc == nil {
+		http.Error(w, "OIDC login is not configured", http.StatusNotFound)
+		return
+	}
+
+	state, stateHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+	nonce, err := oidc.RandomString(16)
+	if err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+
+	login := models.OIDCLogin{
+		StateHash:    stateHash,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(oidc.LoginTTL),
+	}
+	if err := h.store.CreateOIDCLogin(r.Context(), &login); err != nil {
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, h.oidc.AuthCodeURL(state, nonce, challenge), http.StatusFound)
+}
+
+// @Summary Complete a login with the identity provider
+// @Description Exchanges the authorization code the OpenID Connect provider redirected back with for tokens. The account at the provider is linked to the user with the same email address on first login, provided both the provider and the API have verified it, and a new user is created when there is none. Two-factor authentication is left to the provider.
+// @Produce json,plain
+// @Param   code   query  string  false  "Authorization code"
+// @Param   state  query  string  true   "State of the login"
+// @Param   error  query  string  false  "Error reported by the provider"
+// @Success 200 {object} models.TokenResponse
+// @Failure 400 {string} string "Invalid or expired login"
+// @Failure 401 {string} string "Login failed at the identity provider"
+// @Failure 403 {string} string "Email address not verified by the identity provider"
+// @Failure 404 {string} string "OIDC login is not configured"
+// @Failure 409 {string} string "Email address belongs to an unverified account"
+// @Failure 502 {string} string "Failed to complete login with the identity provider"
+// @Router /oidc/callback [get]
+func (h *Handler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.oidc == nil {
+		http.Error(w, "OIDC login is not configured", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	state := query.Get("state")
+	if state == "" {
+		http.Error(w, "state is required", http.StatusBadRequest)
+		return
+	}
+	// The login is used up even when the provider reports an error, so a
+	// state can never be replayed.
+	login, err := h.store.ConsumeOIDCLogin(r.Context(), auth.HashToken(state))
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Invalid or expired login", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	if providerError := query.Get("error"); providerError != "" {
+		http.Error(w, "Login failed at the identity provider: "+providerError, http.StatusUnauthorized)
+		return
+	}
+	code := query.Get("code")
+	if code == "" {
+		http.Error(w, "code is required", http.StatusBadRequest)
+		return
+	}
+
+	rawIDToken, err := h.oidc.Exchange(r.Context(), code, login.CodeVerifier)
+	if err != nil {
+		log.Printf("oidc code exchange: %v", err)
+		http.Error(w, "Failed to complete login with the identity provider", http.StatusBadGateway)
+		return
+	}
+	idToken, err := h.oidc.Verify(r.Context(), rawIDToken, login.Nonce)
+	if err != nil {
+		log.Printf("oidc login: %v", err)
+		http.Error(w, "Login failed at the identity provider", http.StatusUnauthorized)
+		return
+	}
+
+	user, ok := h.oidcUser(w, r, idToken)
+	if !ok {
+		return
+	}
+
+	tokens, err := h.issueTokens(r.Context(), user)
+	if err != nil {
+		http.Error(w, "Failed to generate authentication token", http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, tokens)
+}
+
+// oidcUser returns the user the account idToken was issued for is linked
+// to and writes the error response if there is none. Accounts seen for the
+// first time are linked to the user with the same email address, or to a
+// new user when there is none.
+//
+// Linking needs the address to be verified on both sides: otherwise anyone
+// could take over an account by registering its address with the provider,
+// or by registering it here before its owner logs in through the provider.
+func (h *Handler) oidcUser(w http.ResponseWriter, r *http.Request, idToken *oidc.IDToken) (models.ListCurator, bool) {
+	issuer := h.oidc.Issuer()
+	user, err := h.store.FindUserByIdentity(r.Context(), issuer, idToken.Subject)
+	if err == nil {
+		return user, true
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return user, false
+	}
+
+	if idToken.Email == "" || !idToken.EmailVerified {
+		http.Error(w, "Email address not verified by the identity provider", http.StatusForbidden)
+		return user, false
+	}
+	identity := models.UserIdentity{
+		Issuer:  issuer,
+		Subject: idToken.Subject,
+		Email:   idToken.Email,
+	}
+
+	user, err = h.store.FindUserByEmail(r.Context(), idToken.Email)
+	switch {
+	case err == nil:
+		if !user.EmailVerified {
+			http.Error(w, "Email address belongs to an unverified account; log in with its password and verify it first", http.StatusConflict)
+			return user, false
+		}
+		identity.UserID = user.ID
+		err = h.store.LinkIdentity(r.Context(), &identity)
+	case errors.Is(err, store.ErrNotFound):
+		name := idToken.Name
+		if name == "" {
+			name = idToken.Email
+		}
+		// Without a password hash the user can only log in through the
+		// provider until they reset their password.
+		user = models.ListCurator{Email: idToken.Email, Name: name, EmailVerified: true}
+		err = h.store.CreateUserWithIdentity(r.Context(), &user, &identity)
+	}
+	if errors.Is(err, store.ErrConflict) {
+		http.Error(w, "Account was linked by another login, try again", http.StatusConflict)
+		return user, false
+	}
+	if err != nil {
+		http.Error(w, "Failed to link account", http.StatusInternalServerError)
+		return user, false
+	}
+	return user, true
+}
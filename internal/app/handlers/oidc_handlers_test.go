@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/oidc"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/oidc/oidctest"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/store"
+)
+
+// newOIDCTestAPI returns a testAPI whose users log in through a stub
+// provider.
+func newOIDCTestAPI(t *testing.T) (*testAPI, *oidctest.Server) {
+	t.Helper()
+	idp := oidctest.NewServer("todo", "s3cret")
+	t.Cleanup(idp.Close)
+	provider, err := oidc.Discover(context.Background(), idp.Config("http://api.test/oidc/callback"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTestAPIWithProvider(t, provider), idp
+}
+
+// authorizeOIDC starts a login at /oidc/login, lets idp approve it and
+// returns the path and query idp redirects back to. tamper, when not nil,
+// changes the authorization request on its way to idp.
+func (a *testAPI) authorizeOIDC(idp *oidctest.Server, tamper func(url.Values)) string {
+	a.t.Helper()
+	w := a.do(http.MethodGet, "/oidc/login", "", "")
+	expectStatus(a.t, w, http.StatusFound)
+	authorize, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	if tamper != nil {
+		query := authorize.Query()
+		tamper(query)
+		authorize.RawQuery = query.Encode()
+	}
+
+	client := *idp.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := client.Get(authorize.String())
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	resp.Body.Close()
+	callback, err := resp.Location()
+	if err != nil {
+		a.t.Fatalf("provider did not redirect back (status %d): %v", resp.StatusCode, err)
+	}
+	return callback.RequestURI()
+}
+
+func TestOIDCCallbackProvisionsUser(t *testing.T) {
+	api, idp := newOIDCTestAPI(t)
+	idp.SetUser(oidctest.User{Subject: "42", Email: "jane@example.com", EmailVerified: true, Name: "Jane Doe"})
+
+	w := api.do(http.MethodGet, api.authorizeOIDC(idp, nil), "", "")
+	expectStatus(t, w, http.StatusOK)
+	var tokens models.TokenResponse
+	decode(t, w, &tokens)
+	expectStatus(t, api.do(http.MethodGet, "/todos", tokens.Token, ""), http.StatusOK)
+
+	user, err := api.store.FindUserByIdentity(context.Background(), idp.URL, "42")
+	if err != nil {
+		t.Fatalf("identity not linked: %v", err)
+	}
+	if user.Email != "jane@example.com" || user.Name != "Jane Doe" || !user.EmailVerified {
+		t.Errorf("got user %+v", user)
+	}
+
+	// The next login finds the linked user, even after the email address
+	// changed at the provider.
+	idp.SetUser(oidctest.User{Subject: "42", Email: "jane.doe@example.com", EmailVerified: true, Name: "Jane Doe"})
+	expectStatus(t, api.do(http.MethodGet, api.authorizeOIDC(idp, nil), "", ""), http.StatusOK)
+	if _, err := api.store.FindUserByEmail(context.Background(), "jane.doe@example.com"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("second login created another user (err %v)", err)
+	}
+}
+
+func TestOIDCCallbackLinksVerifiedUser(t *testing.T) {
+	api, idp := newOIDCTestAPI(t)
+	user := models.ListCurator{Email: "jane@example.com", Name: "Jane", EmailVerified: true}
+	if err := api.store.CreateUser(context.Background(), &user); err != nil {
+		t.Fatal(err)
+	}
+	idp.SetUser(oidctest.User{Subject: "42", Email: "jane@example.com", EmailVerified: true, Name: "Jane Doe"})
+
+	expectStatus(t, api.do(http.MethodGet, api.authorizeOIDC(idp, nil), "", ""), http.StatusOK)
+	linked, err := api.store.FindUserByIdentity(context.Background(), idp.URL, "42")
+	if err != nil || linked.ID != user.ID {
+		t.Errorf("got user %d (err %v), want the existing user %d", linked.ID, err, user.ID)
+	}
+}
+
+func TestOIDCCallbackRejectsUnverifiedEmail(t *testing.T) {
+	tests := []struct {
+		name string
+		// localVerified is whether the existing account verified the
+		// address, providerVerified whether the provider did.
+		localVerified, providerVerified bool
+		status                          int
+	}{
+		{"unverified at the provider", true, false, http.StatusForbidden},
+		{"unverified account", false, true, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, idp := newOIDCTestAPI(t)
+			user := models.ListCurator{Email: "jane@example.com", Name: "Jane", EmailVerified: tt.localVerified}
+			if err := api.store.CreateUser(context.Background(), &user); err != nil {
+				t.Fatal(err)
+			}
+			idp.SetUser(oidctest.User{Subject: "42", Email: "jane@example.com", EmailVerified: tt.providerVerified, Name: "Mallory"})
+
+			expectStatus(t, api.do(http.MethodGet, api.authorizeOIDC(idp, nil), "", ""), tt.status)
+			if _, err := api.store.FindUserByIdentity(context.Background(), idp.URL, "42"); !errors.Is(err, store.ErrNotFound) {
+				t.Errorf("identity was linked to the account (err %v)", err)
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackRejectsReplayedState(t *testing.T) {
+	api, idp := newOIDCTestAPI(t)
+	callback := api.authorizeOIDC(idp, nil)
+
+	expectStatus(t, api.do(http.MethodGet, callback, "", ""), http.StatusOK)
+	expectStatus(t, api.do(http.MethodGet, callback, "", ""), http.StatusBadRequest)
+
+	// A state is used up by a failed login as well.
+	callback = api.authorizeOIDC(idp, func(q url.Values) { q.Set("response_type", "token") })
+	expectStatus(t, api.do(http.MethodGet, callback, "", ""), http.StatusUnauthorized)
+	expectStatus(t, api.do(http.MethodGet, callback, "", ""), http.StatusBadRequest)
+
+	expectStatus(t, api.do(http.MethodGet, "/oidc/callback?state=made-up&code=abc", "", ""), http.StatusBadRequest)
+}
+
+func TestOIDCCallbackRejectsNonceMismatch(t *testing.T) {
+	api, idp := newOIDCTestAPI(t)
+	callback := api.authorizeOIDC(idp, func(q url.Values) { q.Set("nonce", "forged") })
+
+	expectStatus(t, api.do(http.MethodGet, callback, "", ""), http.StatusUnauthorized)
+	if _, err := api.store.FindUserByIdentity(context.Background(), idp.URL, "1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("login with a forged nonce created a user (err %v)", err)
+	}
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/auth"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/mail"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/password"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/store"
+)
+
+// @Summary Request a password reset
+// @Description Emails a single-use token for choosing a new password to the address, if it belongs to a user. The response is the same either way, so it does not reveal who has an account.
+// @Accept  json
+// @Produce plain
+// @Param   request  body  models.ForgotPasswordRequest  true  "Email address of the account"
+// @Success 202 {string} string "If the address belongs to an account, a reset token has been sent to it"
+// @Failure 400 {string} string "Invalid request payload"
+// @Router /password/forgot [post]
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return
+	}
+
+	var thisRequest models.ForgotPasswordRequest
+	err = json.Unmarshal(body, &thisRequest)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if thisRequest.Email == "" {
+		http.Error(w, "Email is required", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.store.FindUserByEmail(r.Context(), thisRequest.Email)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if err == nil {
+		// Failures are only logged so the response does not tell whether
+		// the account exists.
+		if err := h.sendPasswordReset(r, user); err != nil {
+			log.Printf("password reset for user %d: %v", user.ID, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintln(w, "If the address belongs to an account, a reset token has been sent to it")
+}
+
+// @Summary Reset a password
+// @Description Sets a new password with a token from /password/forgot. The token can be used once, every session of the user is logged out and a lockout after failed logins is lifted.
+// @Accept  json
+// @Produce plain
+// @Param   request  body  models.ResetPasswordRequest  true  "Reset token and new password"
+// @Success 204
+// @Failure 400 {string} string "Invalid or expired reset token, or a password the password policy rejects"
+// @Router /password/reset [post]
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return
+	}
+
+	var thisRequest models.ResetPasswordRequest
+	err = json.Unmarshal(body, &thisRequest)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if thisRequest.Token == "" || thisRequest.Password == "" {
+		http.Error(w, "Token and password are required", http.StatusBadRequest)
+		return
+	}
+
+	// The token is looked up first so the password policy can check the
+	// password against the account.
+	tokenHash := auth.HashToken(thisRequest.Token)
+	reset, err := h.store.FindPasswordReset(r.Context(), tokenHash)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Invalid or expired reset token", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+	user, err := h.store.GetUser(r.Context(), reset.UserID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve user", http.StatusInternalServerError)
+		return
+	}
+
+	hashedPassword, ok := h.hashNewPassword(w, thisRequest.Password, password.Account{Email: user.Email, Name: user.Name})
+	if !ok {
+		return
+	}
+
+	userID, err := h.store.ResetPassword(r.Context(), tokenHash, hashedPassword)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Invalid or expired reset token", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.revocations.RevokeUserTokens(r.Context(), userID); err != nil {
+		http.Error(w, "Failed to revoke tokens", http.StatusInternalServerError)
+		return
+	}
+
+	// The new password lifts a lockout. Failing to lift it is only logged:
+	// the lockout expires on its own.
+	if err := h.throttle.Unlock(r.Context(), user.Email); err != nil {
+		log.Printf("login throttle for user %d: %v", userID, err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// hashNewPassword hashes candidate, the new password of account, if the
+// password policy accepts it. It writes the error response itself and
+// reports whether the caller should continue.
+func (h *Handler) hashNewPassword(w http.ResponseWriter, candidate string, account password.Account) (string, bool) {
+	err := h.passwords.Check(candidate, account)
+	var violation *password.Violation
+	if errors.As(err, &violation) {
+		http.Error(w, violation.Reason, http.StatusBadRequest)
+		return "", false
+	}
+	if err != nil {
+		http.Error(w, "Failed to check password", http.StatusInternalServerError)
+		return "", false
+	}
+
+	hash, err := h.hasher.Hash(candidate)
+	if err != nil {
+		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+		return "", false
+	}
+	return hash, true
+}
+
+// rehashPassword hashes given, the password user just logged in with,
+// again when their hash was made with another algorithm or other
+// parameters than the hasher uses now. Failures are only logged: the old
+// hash keeps working.
+func (h *Handler) rehashPassword(ctx context.Context, user models.ListCurator, given string) {
+	if !h.hasher.NeedsRehash(user.Password) {
+		return
+	}
+	hash, err := h.hasher.Hash(given)
+	if err == nil {
+		err = h.store.RehashPassword(ctx, user.ID, user.Password, hash)
+	}
+	if err != nil {
+		log.Printf("password rehash for user %d: %v", user.ID, err)
+	}
+}
+
+// sendPasswordReset stores a new reset token for user and emails it to them.
+func (h *Handler) sendPasswordReset(r *http.Request, user models.ListCurator) error {
+	ttl, err := auth.PasswordResetTTL()
+	if err != nil {
+		return err
+	}
+	token, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return err
+	}
+	reset := models.PasswordReset{UserID: user.ID, TokenHash: hash, ExpiresAt: time.Now().Add(ttl)}
+	if err := h.store.CreatePasswordReset(r.Context(), &reset); err != nil {
+		return err
+	}
+
+	return h.mailer.Send(r.Context(), mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Someone asked to reset the password of your account. To choose a new one, send this token\n"+
+			"together with your new password to /password/reset within %s:\n\n"+
+			"%s\n\n"+
+			"If that was not you, you can ignore this email. Your password stays unchanged.\n",
+			user.Name, humanDuration(ttl), token),
+	})
+}
+
+// humanDuration formats d for emails, such as "1 hour" or "30 minutes".
+func humanDuration(d time.Duration) string {
+	plural := func(n int64, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s", unit)
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+	switch {
+	case d >= 24*time.Hour && d%(24*time.Hour) == 0:
+		return plural(int64(d/(24*time.Hour)), "day")
+	case d >= time.Hour && d%time.Hour == 0:
+		return plural(int64(d/time.Hour), "hour")
+	case d >= time.Minute && d%time.Minute == 0:
+		return plural(int64(d/time.Minute), "minute")
+	default:
+		return d.String()
+	}
+}
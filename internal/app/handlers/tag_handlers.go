@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/auth"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/store"
+)
+
+// @Summary Get tags
+// @Description Retrieve every tag of the authenticated user
+// @Tags tags
+// @Security ApiKeyAuth
+// @Produce json,plain
+// @Success 200 {array} models.Tag
+// @Failure 401 {string} string "Unauthorized"
+// @Router /tags [get]
+func (h *Handler) GetTags(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	tags, err := h.store.ListTags(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve tags: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if tags == nil {
+		tags = []models.Tag{}
+	}
+
+	respondWithJSON(w, http.StatusOK, tags)
+}
+
+// @Summary Create a tag
+// @Description Creates a new tag for the authenticated user
+// @Tags tags
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json,plain
+// @Param   tag  body  models.TagRequest  true  "Tag to be created"
+// @Success 201 {object} models.Tag
+// @Failure 400 {string} string "Invalid request payload"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 409 {string} string "Tag exists"
+// @Router /tags [post]
+func (h *Handler) CreateTag(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	name, ok := readTagName(w, r)
+	if !ok {
+		return
+	}
+
+	tag := models.Tag{Name: name}
+	err := h.store.CreateTag(r.Context(), userID, &tag)
+	if errors.Is(err, store.ErrConflict) {
+		http.Error(w, "Tag already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to create tag: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, tag)
+}
+
+// @Summary Rename a tag
+// @Description Changes the name of a tag of the authenticated user
+// @Tags tags
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json,plain
+// @Param   id  path  integer  true  "Tag ID"
+// @Param   tag  body  models.TagRequest  true  "New name of the tag"
+// @Success 200 {object} models.Tag
+// @Failure 400 {string} string "Invalid request payload"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 404 {string} string "Tag doesn't exist"
+// @Failure 409 {string} string "Tag exists"
+// @Router /tags/{id} [put]
+func (h *Handler) RenameTag(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	tagID, ok := idFromPath(w, r, "Tag")
+	if !ok {
+		return
+	}
+
+	name, ok := readTagName(w, r)
+	if !ok {
+		return
+	}
+
+	tag := models.Tag{ID: tagID, Name: name}
+	err := h.store.RenameTag(r.Context(), userID, &tag)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Tag not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, store.ErrConflict) {
+		http.Error(w, "Tag already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to rename tag", http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, tag)
+}
+
+// @Summary Delete a tag
+// @Description Deletes a tag of the authenticated user and removes it from every to-do item
+// @Tags tags
+// @Security ApiKeyAuth
+// @Produce json,plain
+// @Param   id  path  integer  true  "Tag ID"
+// @Success 204
+// @Failure 400 {string} string "Invalid tag ID"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 404 {string} string "Tag doesn't exist"
+// @Router /tags/{id} [delete]
+func (h *Handler) DeleteTag(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	tagID, ok := idFromPath(w, r, "Tag")
+	if !ok {
+		return
+	}
+
+	err := h.store.DeleteTag(r.Context(), userID, tagID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Tag not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to delete tag", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readTagName decodes a models.TagRequest body and validates its name. It
+// writes the error response itself and reports whether the caller should
+// continue.
+func readTagName(w http.ResponseWriter, r *http.Request) (string, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return "", false
+	}
+
+	var thisRequest models.TagRequest
+	err = json.Unmarshal(body, &thisRequest)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return "", false
+	}
+
+	name := strings.TrimSpace(thisRequest.Name)
+	if name == "" {
+		http.Error(w, "Tag name is required", http.StatusBadRequest)
+		return "", false
+	}
+	if len(name) > store.MaxTagNameLength {
+		http.Error(w, fmt.Sprintf("Tag name must be at most %d characters long", store.MaxTagNameLength), http.StatusBadRequest)
+		return "", false
+	}
+	return name, true
+}
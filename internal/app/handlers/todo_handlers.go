@@ -1,20 +1,27 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"maps"
+	"mime"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Kwagmire/go-todo-api/internal/pkg/auth"
-	"github.com/Kwagmire/go-todo-api/internal/pkg/db"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/jsonpatch"
 	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/recurrence"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/store"
 )
 
 // @Summary Create a new ToDo item
-// @Description Creates a new to-do item for the authenticated user
+// @Description Creates a new to-do item for the authenticated user, in their Inbox or in any list they own or can edit
 // @Tags todos
 // @Security ApiKeyAuth
 // @Accept  json
@@ -23,8 +30,9 @@ import (
 // @Success 201 {object} models.TodoItem
 // @Failure 400 {string} string "Invalid request payload"
 // @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "List is read-only"
 // @Router /todos [post]
-func AddTodo(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) AddTodo(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
 		return
@@ -53,20 +61,35 @@ func AddTodo(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "All fields are required", http.StatusBadRequest)
 		return
 	}
+	if !validTagNames(thisRequest.Tags) {
+		http.Error(w, fmt.Sprintf("Tag names must be at most %d characters long", store.MaxTagNameLength), http.StatusBadRequest)
+		return
+	}
+	rule, ok := recurrenceFromRequest(w, thisRequest)
+	if !ok {
+		return
+	}
 
 	thisTodo := models.TodoItem{
-		Title: thisRequest.Title,
-		Desc:  thisRequest.Desc,
-	}
-
-	query := `
-		INSERT INTO todos (
-			user_id,
-			title,
-			description
-		) VALUES ($1, $2, $3
-		) RETURNING id`
-	err = db.DB.QueryRow(query, userID, thisTodo.Title, thisTodo.Desc).Scan(&thisTodo.ID)
+		Title:        thisRequest.Title,
+		Desc:         thisRequest.Desc,
+		DueAt:        thisRequest.DueAt,
+		Priority:     thisRequest.Priority,
+		Tags:         thisRequest.Tags,
+		ListID:       thisRequest.ListID,
+		AutoComplete: thisRequest.AutoComplete,
+		Recurrence:   rule,
+	}
+
+	err = h.store.CreateTodo(r.Context(), userID, &thisTodo)
+	if errors.Is(err, store.ErrListNotFound) {
+		http.Error(w, "List not found", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, store.ErrForbidden) {
+		http.Error(w, "You can only view the todos of this list", http.StatusForbidden)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to crate todo: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -75,19 +98,70 @@ func AddTodo(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusCreated, thisTodo)
 }
 
+// @Summary Get a ToDo item
+// @Description Retrieve a single to-do item of the authenticated user, or of a list shared with them, with its tags, checklist progress and recurrence
+// @Tags todos
+// @Security ApiKeyAuth
+// @Produce json,plain
+// @Param   id  path  integer  true  "Todo ID"
+// @Success 200 {object} models.TodoItem
+// @Header  200 {string} ETag "Version of the todo, for If-Match on PUT and PATCH"
+// @Failure 400 {string} string "Invalid todo ID"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 404 {string} string "Todo doesn't exist"
+// @Router /todos/{id} [get]
+func (h *Handler) GetTodo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	todoID, ok := idFromPath(w, r, "Todo")
+	if !ok {
+		return
+	}
+
+	// Todos of other users are reported as missing too, so the response
+	// does not tell which IDs exist.
+	todo, err := h.store.GetTodo(r.Context(), userID, todoID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Todo not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to retrieve todo", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", todoETag(todo))
+	respondWithJSON(w, http.StatusOK, todo)
+}
+
 // @Summary Update a ToDo item
-// @Description Edit an existing to-do item for the authenticated user
+// @Description Edit an existing to-do item for the authenticated user. Title and description are required; every other field that is omitted keeps its current value, and due_at, priority, tags, auto_complete or recurrence set to null is reset. This is the rule of a JSON Merge Patch through PATCH /todos/{id}, except that read-only fields such as id or created_at are ignored, so a todo can be sent back as it was read. With If-Match the todo is only updated while its ETag still matches.
 // @Tags todos
 // @Security ApiKeyAuth
 // @Accept  json
 // @Produce json,plain
+// @Param   id  path  integer  true  "Todo ID"
 // @Param   todo  body  models.CreateRequest  true  "New details for the to-do item"
+// @Param   If-Match  header  string  false  "ETag of the version of the todo the update is based on"
 // @Success 200 {object} models.TodoItem
+// @Header  200 {string} ETag "Version of the updated todo"
 // @Failure 400 {string} string "Invalid request payload"
 // @Failure 401 {string} string "Unauthorized"
-// @Failure 403 {string} string "Todo doesn't exist"
+// @Failure 403 {string} string "Todo is read-only"
+// @Failure 404 {string} string "Todo doesn't exist"
+// @Failure 409 {string} string "Todo kept changing during the update"
+// @Failure 412 {string} string "Todo no longer matches If-Match"
 // @Router /todos/{id} [put]
-func UpdateTodo(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) UpdateTodo(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut {
 		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
 		return
@@ -99,14 +173,8 @@ func UpdateTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pathSegments := strings.Split(r.URL.Path, "/")
-	if len(pathSegments) < 3 || pathSegments[2] == "" {
-		http.Error(w, "Todo ID missing in URL path", http.StatusBadRequest)
-		return
-	}
-	todoID, err := strconv.Atoi(pathSegments[2])
-	if err != nil {
-		http.Error(w, "Invalid todo ID format. Must be an integer.", http.StatusBadRequest)
+	todoID, ok := idFromPath(w, r, "Todo")
+	if !ok {
 		return
 	}
 
@@ -117,52 +185,285 @@ func UpdateTodo(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var thisRequest models.CreateRequest
-	err = json.Unmarshal(body, &thisRequest)
-	if err != nil {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &thisRequest) != nil || json.Unmarshal(body, &fields) != nil {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
-
 	if thisRequest.Title == "" || thisRequest.Desc == "" {
 		http.Error(w, "All fields are required", http.StatusBadRequest)
 		return
 	}
 
-	updatedTodo := models.TodoItem{
-		Title: thisRequest.Title,
-		Desc:  thisRequest.Desc,
+	// The body is merged into the todo like a merge patch, minus the fields
+	// that cannot be changed, which clients often send back as they got
+	// them.
+	patchable := patchableTodo(models.TodoItem{})
+	maps.DeleteFunc(fields, func(name string, _ json.RawMessage) bool {
+		_, ok := patchable[name]
+		return !ok
+	})
+	patch, err := json.Marshal(fields)
+	if err != nil {
+		http.Error(w, "Failed to update todo", http.StatusInternalServerError)
+		return
+	}
+	h.changeTodo(w, r, userID, todoID, func(document []byte) ([]byte, error) {
+		return jsonpatch.MergePatch(document, patch)
+	})
+}
+
+// @Summary Patch a ToDo item
+// @Description Change some fields of an existing to-do item, leaving the others as they are. The body is a JSON Merge Patch (RFC 7396, application/merge-patch+json, also assumed for application/json) or a JSON Patch (RFC 6902, application/json-patch+json) of the fields of models.CreateRequest. Removing due_at, priority, tags, auto_complete or recurrence resets it; title and description cannot be removed. With If-Match the todo is only patched while its ETag still matches.
+// @Tags todos
+// @Security ApiKeyAuth
+// @Accept  json,application/merge-patch+json,application/json-patch+json
+// @Produce json,plain
+// @Param   id  path  integer  true  "Todo ID"
+// @Param   patch  body  models.CreateRequest  true  "Fields to change"
+// @Param   If-Match  header  string  false  "ETag of the version of the todo the patch is based on"
+// @Success 200 {object} models.TodoItem
+// @Header  200 {string} ETag "Version of the patched todo"
+// @Failure 400 {string} string "Invalid patch or field value"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Todo is read-only"
+// @Failure 404 {string} string "Todo doesn't exist"
+// @Failure 409 {string} string "A JSON Patch test operation failed, or the todo kept changing during the patch"
+// @Failure 412 {string} string "Todo no longer matches If-Match"
+// @Failure 415 {string} string "Unsupported patch format"
+// @Router /todos/{id} [patch]
+func (h *Handler) PatchTodo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	todoID, ok := idFromPath(w, r, "Todo")
+	if !ok {
+		return
 	}
 
-	query := `
-		UPDATE todos
-		SET title = $1, description = $2
-		WHERE id = $3 AND user_id = $4
-		RETURNING id`
-	err = db.DB.QueryRow(query, updatedTodo.Title, updatedTodo.Desc, todoID, userID).Scan(&updatedTodo.ID)
-	if err == sql.ErrNoRows {
-		http.Error(w, "Todo not found", http.StatusForbidden)
+	apply := jsonpatch.MergePatch
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "", "application/json", jsonpatch.MergePatchType:
+	case jsonpatch.PatchType:
+		apply = jsonpatch.Apply
+	default:
+		w.Header().Set("Accept-Patch", jsonpatch.MergePatchType+", "+jsonpatch.PatchType)
+		http.Error(w, "Unsupported patch format", http.StatusUnsupportedMediaType)
 		return
 	}
+
+	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, "Failed to update todo", http.StatusInternalServerError)
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
 		return
 	}
 
+	h.changeTodo(w, r, userID, todoID, func(document []byte) ([]byte, error) {
+		return apply(document, body)
+	})
+}
+
+// maxTodoUpdateAttempts is how often changeTodo applies a patch to a todo
+// that concurrent requests keep changing before it gives up.
+const maxTodoUpdateAttempts = 3
+
+// changeTodo sets the todo todoID of userID to what patch makes of its
+// patchableTodo document and responds with the result. UpdateTodo and
+// PatchTodo differ only in the patch.
+//
+// The todo is only written if it did not change since it was read, else
+// the patch is applied again to the new version. Requests with If-Match
+// fail with 412 instead once the todo no longer matches.
+func (h *Handler) changeTodo(w http.ResponseWriter, r *http.Request, userID, todoID int, patch func(document []byte) ([]byte, error)) {
+	var updatedTodo models.TodoItem
+	for attempt := 1; ; attempt++ {
+		todo, err := h.store.GetTodo(r.Context(), userID, todoID)
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Todo not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to retrieve todo", http.StatusInternalServerError)
+			return
+		}
+		if !ifMatch(r, todoETag(todo)) {
+			http.Error(w, "Todo was changed since it was read", http.StatusPreconditionFailed)
+			return
+		}
+
+		document, err := json.Marshal(patchableTodo(todo))
+		if err != nil {
+			http.Error(w, "Failed to patch todo", http.StatusInternalServerError)
+			return
+		}
+		patched, err := patch(document)
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			http.Error(w, "Patch test failed", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Invalid patch: "+strings.TrimPrefix(err.Error(), "jsonpatch: "), http.StatusBadRequest)
+			return
+		}
+
+		thisRequest, ok := patchedTodo(w, patched)
+		if !ok {
+			return
+		}
+		if !validTagNames(thisRequest.Tags) {
+			http.Error(w, fmt.Sprintf("Tag names must be at most %d characters long", store.MaxTagNameLength), http.StatusBadRequest)
+			return
+		}
+		rule, ok := recurrenceFromRequest(w, thisRequest)
+		if !ok {
+			return
+		}
+
+		// Tags are only written when the patch changed them, so tags put on
+		// the todo meanwhile are not lost.
+		tags := thisRequest.Tags
+		if slices.Equal(tags, todo.Tags) {
+			tags = nil
+		}
+
+		updatedTodo = models.TodoItem{
+			ID:           todoID,
+			Title:        thisRequest.Title,
+			Desc:         thisRequest.Desc,
+			DueAt:        thisRequest.DueAt,
+			Priority:     thisRequest.Priority,
+			Tags:         tags,
+			ListID:       thisRequest.ListID,
+			AutoComplete: thisRequest.AutoComplete,
+			Recurrence:   rule,
+			UpdatedAt:    todo.UpdatedAt,
+		}
+
+		err = h.store.UpdateTodo(r.Context(), userID, &updatedTodo)
+		if errors.Is(err, store.ErrModified) && attempt < maxTodoUpdateAttempts {
+			continue
+		}
+		if errors.Is(err, store.ErrModified) {
+			http.Error(w, "Todo is being changed by other requests, try again", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Todo not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, store.ErrForbidden) {
+			http.Error(w, "You can only view the todos of this list", http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, store.ErrListNotFound) {
+			http.Error(w, "List not found", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to update todo", http.StatusInternalServerError)
+			return
+		}
+		break
+	}
+
+	w.Header().Set("ETag", todoETag(updatedTodo))
 	respondWithJSON(w, http.StatusOK, updatedTodo)
 }
 
+// patchableTodo returns the document PatchTodo applies patches to: the
+// fields of todo a models.CreateRequest sets, each of them present so JSON
+// Patch operations can replace them.
+func patchableTodo(todo models.TodoItem) map[string]any {
+	tags := todo.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return map[string]any{
+		"title":         todo.Title,
+		"description":   todo.Desc,
+		"due_at":        todo.DueAt,
+		"priority":      todo.Priority,
+		"tags":          tags,
+		"list_id":       todo.ListID,
+		"auto_complete": todo.AutoComplete,
+		"recurrence":    todo.Recurrence,
+	}
+}
+
+// patchedTodo reads the fields of a todo from patched, the document
+// patchableTodo returned after the patch was applied. Fields that are
+// missing or null keep their zero value. It validates each field, writes
+// the error response itself and reports whether the caller should continue.
+func patchedTodo(w http.ResponseWriter, patched []byte) (models.CreateRequest, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(patched, &fields); err != nil {
+		http.Error(w, "The patched todo must be a JSON object", http.StatusBadRequest)
+		return models.CreateRequest{}, false
+	}
+
+	var thisRequest models.CreateRequest
+	targets := map[string]any{
+		"title":         &thisRequest.Title,
+		"description":   &thisRequest.Desc,
+		"due_at":        &thisRequest.DueAt,
+		"priority":      &thisRequest.Priority,
+		"tags":          &thisRequest.Tags,
+		"list_id":       &thisRequest.ListID,
+		"auto_complete": &thisRequest.AutoComplete,
+		"recurrence":    &thisRequest.Recurrence,
+	}
+	for _, name := range slices.Sorted(maps.Keys(fields)) {
+		target, ok := targets[name]
+		if !ok {
+			http.Error(w, fmt.Sprintf("Field %q cannot be changed", name), http.StatusBadRequest)
+			return models.CreateRequest{}, false
+		}
+		if err := json.Unmarshal(fields[name], target); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid value for field %q", name), http.StatusBadRequest)
+			return models.CreateRequest{}, false
+		}
+	}
+
+	if strings.TrimSpace(thisRequest.Title) == "" {
+		http.Error(w, "Title must not be empty", http.StatusBadRequest)
+		return models.CreateRequest{}, false
+	}
+	if strings.TrimSpace(thisRequest.Desc) == "" {
+		http.Error(w, "Description must not be empty", http.StatusBadRequest)
+		return models.CreateRequest{}, false
+	}
+	if thisRequest.ListID < 0 {
+		http.Error(w, "Invalid value for field \"list_id\"", http.StatusBadRequest)
+		return models.CreateRequest{}, false
+	}
+	if thisRequest.Tags == nil {
+		thisRequest.Tags = []string{}
+	}
+	return thisRequest, true
+}
+
 // @Summary Delete a ToDo item
 // @Description Delete an existing to-do item for the authenticated user
 // @Tags todos
 // @Security ApiKeyAuth
-// @Accept  json
-// @Produce json,plain
+// @Produce plain
+// @Param   id  path  integer  true  "Todo ID"
 // @Success 204
-// @Failure 400 {string} string "Invalid request payload"
+// @Failure 400 {string} string "Invalid todo ID"
 // @Failure 401 {string} string "Unauthorized"
-// @Failure 403 {string} string "Todo doesn't exist"
+// @Failure 403 {string} string "Todo is read-only"
+// @Failure 404 {string} string "Todo doesn't exist"
 // @Router /todos/{id} [delete]
-func DeleteTodo(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) DeleteTodo(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
 		return
@@ -174,25 +475,18 @@ func DeleteTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pathSegments := strings.Split(r.URL.Path, "/")
-	if len(pathSegments) < 3 || pathSegments[2] == "" {
-		http.Error(w, "Todo ID missing in URL path", http.StatusBadRequest)
+	todoID, ok := idFromPath(w, r, "Todo")
+	if !ok {
 		return
 	}
-	todoID, err := strconv.Atoi(pathSegments[2])
-	if err != nil {
-		http.Error(w, "Invalid todo ID format. Must be an integer.", http.StatusBadRequest)
+
+	err := h.store.DeleteTodo(r.Context(), userID, todoID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Todo not found", http.StatusNotFound)
 		return
 	}
-
-	query := `
-		DELETE FROM todos
-		WHERE id = $1 AND user_id = $2
-		RETURNING id`
-	var deletedTodoID int
-	err = db.DB.QueryRow(query, todoID, userID).Scan(&deletedTodoID)
-	if err == sql.ErrNoRows {
-		http.Error(w, "Todo not found", http.StatusForbidden)
+	if errors.Is(err, store.ErrForbidden) {
+		http.Error(w, "You can only view the todos of this list", http.StatusForbidden)
 		return
 	}
 	if err != nil {
@@ -204,16 +498,25 @@ func DeleteTodo(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Get ToDo items
-// @Description Retrieve to-do items for the authenticated user
+// @Description Retrieve to-do items for the authenticated user, including those in lists shared with them
 // @Tags todos
 // @Security ApiKeyAuth
 // @Produce json,plain
 // @Param   page  query integer true "The page to view"
 // @Param   limit  query integer true "Number of items per page"
+// @Param   list_id  query integer false "Only todos in this list"
+// @Param   status  query string false "Filter by completion status" Enums(all, active, completed)
+// @Param   due_before  query string false "Only todos due before this RFC 3339 time"
+// @Param   due_after  query string false "Only todos due after this RFC 3339 time"
+// @Param   overdue  query boolean false "Only open todos whose due date has passed"
+// @Param   tag  query []string false "Only todos carrying these tags" collectionFormat(multi)
+// @Param   tag_match  query string false "Whether todos need any or all of the tags, defaults to any" Enums(any, all)
+// @Param   sort  query string false "Comma-separated sort keys, prefixed with - for descending order (id, title, priority, due_at, completed_at, created_at, updated_at)" example(-priority,due_at)
 // @Success 200 {object} map[string]interface{}
+// @Failure 400 {string} string "Invalid filter"
 // @Failure 401 {string} string "Unauthorized"
 // @Router /todos [get]
-func GetTodos(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetTodos(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
 		return
@@ -225,45 +528,334 @@ func GetTodos(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pageStr := r.URL.Query().Get("page")
-	limitStr := r.URL.Query().Get("limit")
+	page, limit := paginationFromQuery(r)
+	filter, err := todoFilterFromQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.Limit = limit
+	filter.Offset = (page - 1) * limit
 
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page < 1 {
-		page = 1
+	h.respondWithTodos(w, r, userID, filter, page, limit)
+}
+
+// @Summary Get today's ToDo items
+// @Description Retrieve the open to-do items of the authenticated user that are due by the end of today, including overdue ones
+// @Tags todos
+// @Security ApiKeyAuth
+// @Produce json,plain
+// @Param   page  query integer false "The page to view"
+// @Param   limit  query integer false "Number of items per page"
+// @Param   tz  query string false "IANA time zone that defines today, defaults to the time zone of the user"
+// @Param   sort  query string false "Comma-separated sort keys, prefixed with - for descending order" example(-priority,due_at)
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {string} string "Invalid time zone"
+// @Failure 401 {string} string "Unauthorized"
+// @Router /todos/today [get]
+func (h *Handler) GetTodayTodos(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
 	}
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit < 1 {
-		limit = 10
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
 	}
-	offset := (page - 1) * limit
 
-	query := `
-		SELECT id, title, description
-		FROM todos
-		WHERE user_id = $1
-		ORDER BY id ASC
-		LIMIT $2 OFFSET $3`
-	rows, err := db.DB.Query(query, userID, limit, offset)
+	tz := r.URL.Query().Get("tz")
+	if tz == "" {
+		user, err := h.store.GetUser(r.Context(), userID)
+		if err != nil {
+			http.Error(w, "Failed to retrieve user", http.StatusInternalServerError)
+			return
+		}
+		tz = user.Timezone
+	}
+	location, err := time.LoadLocation(tz)
+	if err != nil {
+		http.Error(w, "Invalid time zone: "+tz, http.StatusBadRequest)
+		return
+	}
+	now := time.Now().In(location)
+	endOfToday := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, location)
+
+	sorts, err := store.ParseTodoSort(r.URL.Query().Get("sort"))
+	if err != nil {
+		http.Error(w, "Invalid sort: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, limit := paginationFromQuery(r)
+	completed := false
+	filter := store.TodoFilter{
+		Completed: &completed,
+		DueBefore: &endOfToday,
+		Sort:      sorts,
+		Limit:     limit,
+		Offset:    (page - 1) * limit,
+	}
+
+	h.respondWithTodos(w, r, userID, filter, page, limit)
+}
+
+// respondWithTodos lists the todos of userID matching filter and writes them
+// in the paginated response format shared by the list endpoints.
+func (h *Handler) respondWithTodos(w http.ResponseWriter, r *http.Request, userID int, filter store.TodoFilter, page, limit int) {
+	todos, err := h.store.ListTodos(r.Context(), userID, filter)
 	if err != nil {
 		http.Error(w, "Failed to retrieve todos: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
-	var todos []models.TodoItem
-	for rows.Next() {
-		var todo models.TodoItem
-		if err := rows.Scan(&todo.ID, &todo.Title, &todo.Desc); err != nil {
-			http.Error(w, "Error scanning todo row: "+err.Error(), http.StatusInternalServerError)
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"data": todos, "page": page, "limit": limit, "total": len(todos)})
+}
+
+// todoFilterFromQuery builds a store.TodoFilter from the filter parameters
+// of GET /todos. The returned error is meant to be shown to the client.
+func todoFilterFromQuery(r *http.Request) (store.TodoFilter, error) {
+	var filter store.TodoFilter
+	query := r.URL.Query()
+
+	if value := query.Get("list_id"); value != "" {
+		listID, err := strconv.Atoi(value)
+		if err != nil || listID < 1 {
+			return filter, errors.New("Invalid list_id (expected a positive integer)")
+		}
+		filter.ListID = listID
+	}
+
+	switch query.Get("status") {
+	case "", "all":
+	case "active":
+		completed := false
+		filter.Completed = &completed
+	case "completed":
+		completed := true
+		filter.Completed = &completed
+	default:
+		return filter, errors.New("Invalid status filter (expected 'all', 'active' or 'completed')")
+	}
+
+	for _, param := range []struct {
+		name string
+		dest **time.Time
+	}{
+		{"due_before", &filter.DueBefore},
+		{"due_after", &filter.DueAfter},
+	} {
+		value := query.Get(param.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, fmt.Errorf("Invalid %s (expected an RFC 3339 time such as 2024-05-01T17:00:00Z)", param.name)
+		}
+		*param.dest = &t
+	}
+
+	if value := query.Get("overdue"); value != "" {
+		overdue, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, errors.New("Invalid overdue filter (expected true or false)")
+		}
+		if overdue {
+			if filter.Completed != nil && *filter.Completed {
+				return filter, errors.New("Completed todos are never overdue")
+			}
+			completed := false
+			filter.Completed = &completed
+			now := time.Now()
+			if filter.DueBefore == nil || now.Before(*filter.DueBefore) {
+				filter.DueBefore = &now
+			}
+		}
+	}
+
+	filter.Tags = query["tag"]
+	switch query.Get("tag_match") {
+	case "", "any":
+	case "all":
+		filter.MatchAllTags = true
+	default:
+		return filter, errors.New("Invalid tag_match (expected 'any' or 'all')")
+	}
+
+	sorts, err := store.ParseTodoSort(query.Get("sort"))
+	if err != nil {
+		return filter, errors.New("Invalid sort: " + err.Error())
+	}
+	filter.Sort = sorts
+
+	return filter, nil
+}
+
+// @Summary Complete a ToDo item
+// @Description Mark an existing to-do item of the authenticated user as done. Completing a recurring to-do item creates its next occurrence, see next_occurrence_id.
+// @Tags todos
+// @Security ApiKeyAuth
+// @Produce json,plain
+// @Param   id  path  integer  true  "Todo ID"
+// @Success 200 {object} models.TodoItem
+// @Failure 400 {string} string "Invalid todo ID"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Todo is read-only"
+// @Failure 404 {string} string "Todo doesn't exist"
+// @Router /todos/{id}/complete [post]
+func (h *Handler) CompleteTodo(w http.ResponseWriter, r *http.Request) {
+	h.setTodoCompletion(w, r, true)
+}
+
+// @Summary Reopen a ToDo item
+// @Description Mark a completed to-do item of the authenticated user as not done
+// @Tags todos
+// @Security ApiKeyAuth
+// @Produce json,plain
+// @Param   id  path  integer  true  "Todo ID"
+// @Success 200 {object} models.TodoItem
+// @Failure 400 {string} string "Invalid todo ID"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Todo is read-only"
+// @Failure 404 {string} string "Todo doesn't exist"
+// @Router /todos/{id}/reopen [post]
+func (h *Handler) ReopenTodo(w http.ResponseWriter, r *http.Request) {
+	h.setTodoCompletion(w, r, false)
+}
+
+// setTodoCompletion marks the todo named in the URL path as completed or
+// open and responds with the updated item.
+func (h *Handler) setTodoCompletion(w http.ResponseWriter, r *http.Request, completed bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	todoID, ok := idFromPath(w, r, "Todo")
+	if !ok {
+		return
+	}
+
+	todo, err := h.store.SetTodoCompleted(r.Context(), userID, todoID, completed)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Todo not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, store.ErrForbidden) {
+		http.Error(w, "You can only view the todos of this list", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update todo", http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, todo)
+}
+
+// recurrenceFromRequest validates the recurrence rule of a create or update
+// request and returns it in canonical form. It writes the error response
+// itself and reports whether the caller should continue.
+func recurrenceFromRequest(w http.ResponseWriter, thisRequest models.CreateRequest) (string, bool) {
+	if thisRequest.Recurrence == "" {
+		return "", true
+	}
+	rule, err := recurrence.Parse(thisRequest.Recurrence)
+	if err != nil {
+		http.Error(w, "Invalid recurrence: "+err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	if thisRequest.DueAt == nil {
+		http.Error(w, "A recurring todo needs a due date", http.StatusBadRequest)
+		return "", false
+	}
+	return rule.String(), true
+}
+
+// maxOccurrences is the most occurrences GetOccurrences previews at once.
+const maxOccurrences = 100
+
+// @Summary Preview the occurrences of a recurring ToDo item
+// @Description Lists the due dates of the next occurrences of a recurring to-do item, after its current due date. The rule is expanded in the recurrence_tz of the todo, the time zone of the user who set the recurrence, so BYDAY and local times follow that zone and its daylight saving time.
+// @Tags todos
+// @Security ApiKeyAuth
+// @Produce json,plain
+// @Param   id  path  integer  true  "Todo ID"
+// @Param   count  query integer false "Number of occurrences to preview (default 5, at most 100)"
+// @Success 200 {object} models.OccurrencesResponse
+// @Failure 400 {string} string "Todo doesn't recur"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 404 {string} string "Todo doesn't exist"
+// @Router /todos/{id}/occurrences [get]
+func (h *Handler) GetOccurrences(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	todoID, ok := idFromPath(w, r, "Todo")
+	if !ok {
+		return
+	}
+
+	count := 5
+	if countStr := r.URL.Query().Get("count"); countStr != "" {
+		var err error
+		count, err = strconv.Atoi(countStr)
+		if err != nil || count < 1 || count > maxOccurrences {
+			http.Error(w, fmt.Sprintf("Invalid count. Must be between 1 and %d.", maxOccurrences), http.StatusBadRequest)
 			return
 		}
-		todos = append(todos, todo)
 	}
-	if err := rows.Err(); err != nil {
-		http.Error(w, "Error iterating todo rows: "+err.Error(), http.StatusInternalServerError)
+
+	todo, err := h.store.GetTodo(r.Context(), userID, todoID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Todo not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to retrieve todo", http.StatusInternalServerError)
+		return
+	}
+	if todo.Recurrence == "" || todo.DueAt == nil {
+		http.Error(w, "Todo does not recur", http.StatusBadRequest)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, map[string]interface{}{"data": todos, "page": page, "limit": limit, "total": len(todos)})
+	rule, err := recurrence.Parse(todo.Recurrence)
+	if err != nil {
+		http.Error(w, "Failed to parse recurrence", http.StatusInternalServerError)
+		return
+	}
+	location := recurrence.Location(todo.RecurrenceTZ)
+	start := *todo.DueAt
+	if todo.RecurrenceStart != nil {
+		start = *todo.RecurrenceStart
+	}
+	occurrences := rule.Next(start.In(location), *todo.DueAt, count)
+	for i := range occurrences {
+		occurrences[i] = occurrences[i].UTC()
+	}
+	if occurrences == nil {
+		occurrences = []time.Time{}
+	}
+
+	respondWithJSON(w, http.StatusOK, models.OccurrencesResponse{
+		Recurrence:  todo.Recurrence,
+		Occurrences: occurrences,
+	})
 }
@@ -0,0 +1,615 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/auth"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/store"
+)
+
+// addTodo creates a todo titled title for the user of token.
+func (a *testAPI) addTodo(token, title string) models.TodoItem {
+	a.t.Helper()
+	w := a.do(http.MethodPost, "/todos", token, `{"title":"`+title+`","description":"something to do"}`)
+	expectStatus(a.t, w, http.StatusCreated)
+	var todo models.TodoItem
+	decode(a.t, w, &todo)
+	return todo
+}
+
+// todos returns the first page of todos the user of token sees.
+func (a *testAPI) todos(token string) []models.TodoItem {
+	a.t.Helper()
+	w := a.do(http.MethodGet, "/todos?page=1&limit=100", token, "")
+	expectStatus(a.t, w, http.StatusOK)
+	var page struct {
+		Data []models.TodoItem `json:"data"`
+	}
+	decode(a.t, w, &page)
+	return page.Data
+}
+
+func TestAddTodo(t *testing.T) {
+	api := newTestAPI(t)
+	token := api.register("jane@example.com", "Jane Doe")
+
+	tests := []struct {
+		name   string
+		token  string
+		body   string
+		status int
+	}{
+		{"valid", token, `{"title":"Buy milk","description":"2 litres"}`, http.StatusCreated},
+		{"missing title", token, `{"description":"2 litres"}`, http.StatusBadRequest},
+		{"missing description", token, `{"title":"Buy milk"}`, http.StatusBadRequest},
+		{"invalid priority", token, `{"title":"Buy milk","description":"2 litres","priority":"whenever"}`, http.StatusBadRequest},
+		{"tag name too long", token, `{"title":"Buy milk","description":"2 litres","tags":["` + strings.Repeat("x", store.MaxTagNameLength+1) + `"]}`, http.StatusBadRequest},
+		{"malformed", token, `{"title":`, http.StatusBadRequest},
+		{"unauthenticated", "", `{"title":"Buy milk","description":"2 litres"}`, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectStatus(t, api.do(http.MethodPost, "/todos", tt.token, tt.body), tt.status)
+		})
+	}
+
+	w := api.do(http.MethodPost, "/todos", token, `{"title":"Call mum","description":"Sunday","priority":"urgent","tags":["family"," family ","calls",""]}`)
+	expectStatus(t, w, http.StatusCreated)
+	var todo models.TodoItem
+	decode(t, w, &todo)
+	if todo.ID == 0 || todo.ListID == 0 {
+		t.Errorf("got todo %d in list %d, want both IDs set", todo.ID, todo.ListID)
+	}
+	if todo.Title != "Call mum" || todo.Desc != "Sunday" || todo.Priority != models.PriorityUrgent || todo.Completed || todo.CompletedAt != nil {
+		t.Errorf("got %+v", todo)
+	}
+	if fmt.Sprint(todo.Tags) != "[calls family]" {
+		t.Errorf("got tags %q, want [calls family]", todo.Tags)
+	}
+}
+
+func TestGetTodo(t *testing.T) {
+	api := newTestAPI(t)
+	jane := api.register("jane@example.com", "Jane Doe")
+	john := api.register("john@example.com", "John Roe")
+	todo := api.addTodo(jane, "Buy milk")
+	path := fmt.Sprintf("/todos/%d", todo.ID)
+
+	w := api.do(http.MethodGet, path, jane, "")
+	expectStatus(t, w, http.StatusOK)
+	var got models.TodoItem
+	decode(t, w, &got)
+	if got.ID != todo.ID || got.Title != "Buy milk" {
+		t.Errorf("got %+v, want todo %d", got, todo.ID)
+	}
+
+	// Todos of other users look like todos that do not exist.
+	expectStatus(t, api.do(http.MethodGet, path, john, ""), http.StatusNotFound)
+	expectStatus(t, api.do(http.MethodGet, "/todos/999", jane, ""), http.StatusNotFound)
+	expectStatus(t, api.do(http.MethodGet, "/todos/milk", jane, ""), http.StatusBadRequest)
+	expectStatus(t, api.do(http.MethodGet, path, "", ""), http.StatusUnauthorized)
+}
+
+func TestGetTodos(t *testing.T) {
+	api := newTestAPI(t)
+	jane := api.register("jane@example.com", "Jane Doe")
+	john := api.register("john@example.com", "John Roe")
+	for _, title := range []string{"One", "Two", "Three"} {
+		api.addTodo(jane, title)
+	}
+	api.addTodo(john, "Four")
+
+	tests := []struct {
+		name   string
+		token  string
+		query  string
+		titles []string
+	}{
+		{"first page", jane, "?page=1&limit=2", []string{"One", "Two"}},
+		{"second page", jane, "?page=2&limit=2", []string{"Three"}},
+		{"past the end", jane, "?page=3&limit=2", nil},
+		{"other user", john, "?page=1&limit=10", []string{"Four"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := api.do(http.MethodGet, "/todos"+tt.query, tt.token, "")
+			expectStatus(t, w, http.StatusOK)
+			var page struct {
+				Data []models.TodoItem `json:"data"`
+			}
+			decode(t, w, &page)
+			var titles []string
+			for _, todo := range page.Data {
+				titles = append(titles, todo.Title)
+			}
+			if fmt.Sprint(titles) != fmt.Sprint(tt.titles) {
+				t.Errorf("got %q, want %q", titles, tt.titles)
+			}
+		})
+	}
+
+	expectStatus(t, api.do(http.MethodGet, "/todos?status=maybe", jane, ""), http.StatusBadRequest)
+	expectStatus(t, api.do(http.MethodGet, "/todos", "", ""), http.StatusUnauthorized)
+}
+
+func TestGetTodosSorted(t *testing.T) {
+	api := newTestAPI(t)
+	token := api.register("jane@example.com", "Jane Doe")
+	for _, todo := range []string{
+		`{"title":"Bake","description":"bread","priority":"low"}`,
+		`{"title":"Call","description":"mum","priority":"urgent"}`,
+		`{"title":"Ask","description":"boss"}`,
+		`{"title":"Dust","description":"shelves","priority":"urgent"}`,
+	} {
+		expectStatus(t, api.do(http.MethodPost, "/todos", token, todo), http.StatusCreated)
+	}
+
+	tests := []struct {
+		sort   string
+		titles []string
+	}{
+		{"", []string{"Bake", "Call", "Ask", "Dust"}},
+		{"title", []string{"Ask", "Bake", "Call", "Dust"}},
+		{"-title", []string{"Dust", "Call", "Bake", "Ask"}},
+		{"-priority,title", []string{"Call", "Dust", "Bake", "Ask"}},
+		{"priority,-id", []string{"Ask", "Bake", "Dust", "Call"}},
+	}
+	for _, tt := range tests {
+		w := api.do(http.MethodGet, "/todos?sort="+tt.sort, token, "")
+		expectStatus(t, w, http.StatusOK)
+		var page struct {
+			Data []models.TodoItem `json:"data"`
+		}
+		decode(t, w, &page)
+		var titles []string
+		for _, todo := range page.Data {
+			titles = append(titles, todo.Title)
+		}
+		if fmt.Sprint(titles) != fmt.Sprint(tt.titles) {
+			t.Errorf("sort=%s: got %q, want %q", tt.sort, titles, tt.titles)
+		}
+	}
+
+	for _, sort := range []string{"password_hash", "title;DROP TABLE todos", "title,title", "-"} {
+		expectStatus(t, api.do(http.MethodGet, "/todos?sort="+url.QueryEscape(sort), token, ""), http.StatusBadRequest)
+	}
+}
+
+func TestAddTodoToList(t *testing.T) {
+	api := newTestAPI(t)
+	jane := api.register("jane@example.com", "Jane Doe")
+	john := api.register("john@example.com", "John Roe")
+	inbox := api.addTodo(jane, "Buy milk").ListID
+	johnsInbox := api.addTodo(john, "Call mum").ListID
+
+	w := api.do(http.MethodPost, "/todos", jane, fmt.Sprintf(`{"title":"Buy bread","description":"x","list_id":%d}`, inbox))
+	expectStatus(t, w, http.StatusCreated)
+	var todo models.TodoItem
+	decode(t, w, &todo)
+	if todo.ListID != inbox {
+		t.Errorf("got list %d, want %d", todo.ListID, inbox)
+	}
+
+	// Lists of other users look like lists that do not exist.
+	for _, listID := range []int{johnsInbox, 999} {
+		body := fmt.Sprintf(`{"title":"Buy bread","description":"x","list_id":%d}`, listID)
+		expectStatus(t, api.do(http.MethodPost, "/todos", jane, body), http.StatusBadRequest)
+	}
+	if todos := api.todos(john); len(todos) != 1 {
+		t.Errorf("John has %d todos, want 1", len(todos))
+	}
+
+	w = api.do(http.MethodGet, fmt.Sprintf("/todos?list_id=%d", inbox), jane, "")
+	expectStatus(t, w, http.StatusOK)
+	var page struct {
+		Data []models.TodoItem `json:"data"`
+	}
+	decode(t, w, &page)
+	if len(page.Data) != 2 {
+		t.Errorf("got %d todos in the inbox, want 2", len(page.Data))
+	}
+	expectStatus(t, api.do(http.MethodGet, "/todos?list_id=inbox", jane, ""), http.StatusBadRequest)
+}
+
+func TestGetTodosByTag(t *testing.T) {
+	api := newTestAPI(t)
+	jane := api.register("jane@example.com", "Jane Doe")
+	john := api.register("john@example.com", "John Roe")
+	for _, todo := range []string{
+		`{"title":"One","description":"x","tags":["home"]}`,
+		`{"title":"Two","description":"x","tags":["home","urgent"]}`,
+		`{"title":"Three","description":"x","tags":["work"]}`,
+		`{"title":"Four","description":"x"}`,
+	} {
+		expectStatus(t, api.do(http.MethodPost, "/todos", jane, todo), http.StatusCreated)
+	}
+	// Tags are per user, so John's "home" tag does not match Jane's.
+	expectStatus(t, api.do(http.MethodPost, "/todos", john, `{"title":"Five","description":"x","tags":["home"]}`), http.StatusCreated)
+
+	tests := []struct {
+		query  string
+		titles []string
+	}{
+		{"tag=home", []string{"One", "Two"}},
+		{"tag=+home+", []string{"One", "Two"}},
+		{"tag=Home", nil},
+		{"tag=home&tag=work", []string{"One", "Two", "Three"}},
+		{"tag=home&tag=urgent&tag_match=all", []string{"Two"}},
+		{"tag=home&tag=work&tag_match=all", nil},
+		{"tag=unknown", nil},
+	}
+	for _, tt := range tests {
+		w := api.do(http.MethodGet, "/todos?"+tt.query, jane, "")
+		expectStatus(t, w, http.StatusOK)
+		var page struct {
+			Data []models.TodoItem `json:"data"`
+		}
+		decode(t, w, &page)
+		var titles []string
+		for _, todo := range page.Data {
+			titles = append(titles, todo.Title)
+		}
+		if fmt.Sprint(titles) != fmt.Sprint(tt.titles) {
+			t.Errorf("%s: got %q, want %q", tt.query, titles, tt.titles)
+		}
+	}
+	expectStatus(t, api.do(http.MethodGet, "/todos?tag=home&tag_match=some", jane, ""), http.StatusBadRequest)
+}
+
+func TestUpdateTodo(t *testing.T) {
+	api := newTestAPI(t)
+	jane := api.register("jane@example.com", "Jane Doe")
+	john := api.register("john@example.com", "John Roe")
+	todo := api.addTodo(jane, "Buy milk")
+	path := fmt.Sprintf("/todos/%d", todo.ID)
+
+	w := api.do(http.MethodPut, path, jane, `{"title":"Buy oat milk","description":"2 litres","priority":"low"}`)
+	expectStatus(t, w, http.StatusOK)
+	var updated models.TodoItem
+	decode(t, w, &updated)
+	if updated.ID != todo.ID || updated.Title != "Buy oat milk" || updated.Desc != "2 litres" || updated.Priority != models.PriorityLow {
+		t.Errorf("got %+v", updated)
+	}
+
+	expectStatus(t, api.do(http.MethodPut, path, jane, `{"title":"Buy oat milk"}`), http.StatusBadRequest)
+	// Todos of other users look like todos that do not exist.
+	expectStatus(t, api.do(http.MethodPut, path, john, `{"title":"Mine now","description":"gotcha"}`), http.StatusNotFound)
+	expectStatus(t, api.do(http.MethodPut, "/todos/999", jane, `{"title":"Ghost","description":"boo"}`), http.StatusNotFound)
+	expectStatus(t, api.do(http.MethodPut, "/todos/milk", jane, `{"title":"Ghost","description":"boo"}`), http.StatusBadRequest)
+
+	todos := api.todos(jane)
+	if len(todos) != 1 || todos[0].Title != "Buy oat milk" {
+		t.Errorf("got %+v after the refused updates, want only %q", todos, "Buy oat milk")
+	}
+	if todos := api.todos(john); len(todos) != 0 {
+		t.Errorf("the update of another user's todo created %+v", todos)
+	}
+}
+
+func TestUpdateTodoOmittedFields(t *testing.T) {
+	api := newTestAPI(t)
+	jane := api.register("jane@example.com", "Jane Doe")
+	w := api.do(http.MethodPost, "/todos", jane, `{"title":"Water plants","description":"all of them","due_at":"2024-01-08T09:00:00Z","priority":"high","tags":["home"],"auto_complete":true,"recurrence":"FREQ=WEEKLY"}`)
+	expectStatus(t, w, http.StatusCreated)
+	var todo models.TodoItem
+	decode(t, w, &todo)
+	path := fmt.Sprintf("/todos/%d", todo.ID)
+	put := func(body string) models.TodoItem {
+		t.Helper()
+		w := api.do(http.MethodPut, path, jane, body)
+		expectStatus(t, w, http.StatusOK)
+		var got models.TodoItem
+		decode(t, w, &got)
+		return got
+	}
+
+	// Omitted fields keep their values, like in a merge patch.
+	got := put(`{"title":"Water the plants","description":"all of them"}`)
+	if got.Title != "Water the plants" || got.DueAt == nil || got.Priority != models.PriorityHigh ||
+		fmt.Sprint(got.Tags) != "[home]" || !got.AutoComplete || got.Recurrence != "FREQ=WEEKLY" || got.ListID != todo.ListID {
+		t.Errorf("update without the optional fields got %+v, want them kept from %+v", got, todo)
+	}
+
+	// A todo as GET returns it can be sent back whole.
+	w = api.do(http.MethodGet, path, jane, "")
+	expectStatus(t, w, http.StatusOK)
+	body := strings.Replace(w.Body.String(), `"priority":"high"`, `"priority":"low"`, 1)
+	if got := put(body); got.Priority != models.PriorityLow || got.ID != todo.ID {
+		t.Errorf("update with the todo sent back got %+v", got)
+	}
+
+	// Null resets a field.
+	got = put(`{"title":"Water the plants","description":"all of them","due_at":null,"recurrence":null,"priority":null,"tags":null,"auto_complete":null}`)
+	if got.DueAt != nil || got.Recurrence != "" || got.Priority != models.PriorityNone || len(got.Tags) != 0 || got.AutoComplete {
+		t.Errorf("update with null fields got %+v, want them reset", got)
+	}
+	// Removing the due date of a recurring todo is refused as before.
+	put(`{"title":"Water the plants","description":"all of them","due_at":"2024-01-08T09:00:00Z","recurrence":"FREQ=DAILY"}`)
+	expectStatus(t, api.do(http.MethodPut, path, jane, `{"title":"Water the plants","description":"all of them","due_at":null}`), http.StatusBadRequest)
+}
+
+func TestDeleteTodo(t *testing.T) {
+	api := newTestAPI(t)
+	jane := api.register("jane@example.com", "Jane Doe")
+	john := api.register("john@example.com", "John Roe")
+	todo := api.addTodo(jane, "Buy milk")
+	path := fmt.Sprintf("/todos/%d", todo.ID)
+
+	expectStatus(t, api.do(http.MethodDelete, path, john, ""), http.StatusNotFound)
+	if todos := api.todos(jane); len(todos) != 1 {
+		t.Fatalf("got %d todos after another user's delete, want 1", len(todos))
+	}
+
+	expectStatus(t, api.do(http.MethodDelete, path, jane, ""), http.StatusNoContent)
+	if todos := api.todos(jane); len(todos) != 0 {
+		t.Errorf("got %+v after the delete, want none", todos)
+	}
+	expectStatus(t, api.do(http.MethodDelete, path, jane, ""), http.StatusNotFound)
+	expectStatus(t, api.do(http.MethodDelete, "/todos/milk", jane, ""), http.StatusBadRequest)
+}
+
+func TestCompleteTodo(t *testing.T) {
+	api := newTestAPI(t)
+	jane := api.register("jane@example.com", "Jane Doe")
+	john := api.register("john@example.com", "John Roe")
+	todo := api.addTodo(jane, "Buy milk")
+	path := fmt.Sprintf("/todos/%d", todo.ID)
+
+	w := api.do(http.MethodPost, path+"/complete", jane, "")
+	expectStatus(t, w, http.StatusOK)
+	var got models.TodoItem
+	decode(t, w, &got)
+	if !got.Completed || got.CompletedAt == nil {
+		t.Errorf("after completing got %+v", got)
+	}
+
+	w = api.do(http.MethodPost, path+"/reopen", jane, "")
+	expectStatus(t, w, http.StatusOK)
+	var reopened models.TodoItem
+	decode(t, w, &reopened)
+	if reopened.Completed || reopened.CompletedAt != nil {
+		t.Errorf("after reopening got %+v", reopened)
+	}
+
+	// Todos of other users look like todos that do not exist.
+	expectStatus(t, api.do(http.MethodPost, path+"/complete", john, ""), http.StatusNotFound)
+	expectStatus(t, api.do(http.MethodPost, "/todos/999/complete", jane, ""), http.StatusNotFound)
+	expectStatus(t, api.do(http.MethodPost, "/todos/milk/reopen", jane, ""), http.StatusBadRequest)
+}
+
+func TestPatchTodo(t *testing.T) {
+	api := newTestAPI(t)
+	jane := api.register("jane@example.com", "Jane Doe")
+	john := api.register("john@example.com", "John Roe")
+	w := api.do(http.MethodPost, "/todos", jane, `{"title":"Buy milk","description":"2 litres","priority":"low","tags":["errands","shop"]}`)
+	expectStatus(t, w, http.StatusCreated)
+	var todo models.TodoItem
+	decode(t, w, &todo)
+	path := fmt.Sprintf("/todos/%d", todo.ID)
+
+	patch := func(token, contentType, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodPatch, path, strings.NewReader(body))
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+		return api.serve(r, token)
+	}
+	get := func() models.TodoItem {
+		t.Helper()
+		w := api.do(http.MethodGet, path, jane, "")
+		expectStatus(t, w, http.StatusOK)
+		var got models.TodoItem
+		decode(t, w, &got)
+		return got
+	}
+
+	w = patch(jane, "application/merge-patch+json", `{"title":"Buy oat milk","priority":null}`)
+	expectStatus(t, w, http.StatusOK)
+	got := get()
+	if got.Title != "Buy oat milk" || got.Desc != "2 litres" || got.Priority != models.PriorityNone {
+		t.Errorf("after the merge patch got %+v", got)
+	}
+	if fmt.Sprint(got.Tags) != "[errands shop]" {
+		t.Errorf("merge patch without tags changed them to %q", got.Tags)
+	}
+
+	w = patch(jane, "application/json-patch+json", `[{"op":"test","path":"/title","value":"Buy oat milk"},{"op":"remove","path":"/tags/0"},{"op":"replace","path":"/priority","value":"urgent"}]`)
+	expectStatus(t, w, http.StatusOK)
+	got = get()
+	if got.Priority != models.PriorityUrgent || fmt.Sprint(got.Tags) != "[shop]" {
+		t.Errorf("after the JSON Patch got %+v", got)
+	}
+
+	tests := []struct {
+		name        string
+		token       string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"failed test", jane, "application/json-patch+json", `[{"op":"replace","path":"/title","value":"Lost"},{"op":"test","path":"/title","value":"Buy milk"}]`, http.StatusConflict},
+		{"unsupported format", jane, "text/plain", `title=Lost`, http.StatusUnsupportedMediaType},
+		{"malformed patch", jane, "application/json-patch+json", `{"op":"remove","path":"/title"}`, http.StatusBadRequest},
+		{"removed title", jane, "application/json-patch+json", `[{"op":"remove","path":"/title"}]`, http.StatusBadRequest},
+		{"read-only field", jane, "application/merge-patch+json", `{"id":7}`, http.StatusBadRequest},
+		{"invalid priority", jane, "application/merge-patch+json", `{"priority":"whenever"}`, http.StatusBadRequest},
+		{"other user", john, "application/merge-patch+json", `{"title":"Lost"}`, http.StatusNotFound},
+		{"unauthenticated", "", "application/merge-patch+json", `{"title":"Lost"}`, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := patch(tt.token, tt.contentType, tt.body)
+			expectStatus(t, w, tt.status)
+			if tt.status == http.StatusUnsupportedMediaType && w.Header().Get("Accept-Patch") == "" {
+				t.Error("415 response without Accept-Patch")
+			}
+		})
+	}
+	if got := get(); got.Title != "Buy oat milk" {
+		t.Errorf("refused patches changed the title to %q", got.Title)
+	}
+}
+
+func TestGetOccurrencesTimeZone(t *testing.T) {
+	api := newTestAPI(t)
+	jane := api.register("jane@example.com", "Jane Doe")
+	expectStatus(t, api.do(http.MethodPatch, "/me", jane, `{"timezone":"Australia/Brisbane"}`), http.StatusOK)
+
+	// Monday 09:00 in Brisbane is Sunday 23:00 in UTC.
+	w := api.do(http.MethodPost, "/todos", jane, `{"title":"Standup","description":"weekly","due_at":"2024-01-08T09:00:00+10:00","recurrence":"FREQ=WEEKLY;BYDAY=MO"}`)
+	expectStatus(t, w, http.StatusCreated)
+	var todo models.TodoItem
+	decode(t, w, &todo)
+	if todo.RecurrenceTZ != "Australia/Brisbane" {
+		t.Errorf("got recurrence time zone %q, want Australia/Brisbane", todo.RecurrenceTZ)
+	}
+
+	w = api.do(http.MethodGet, fmt.Sprintf("/todos/%d/occurrences?count=2", todo.ID), jane, "")
+	expectStatus(t, w, http.StatusOK)
+	var preview models.OccurrencesResponse
+	decode(t, w, &preview)
+	want := []string{"2024-01-14T23:00:00Z", "2024-01-21T23:00:00Z"}
+	if len(preview.Occurrences) != len(want) {
+		t.Fatalf("got occurrences %v, want %v", preview.Occurrences, want)
+	}
+	for i, occurrence := range preview.Occurrences {
+		if got := occurrence.Format(time.RFC3339); got != want[i] {
+			t.Errorf("occurrence %d at %s, want %s", i+1, got, want[i])
+		}
+	}
+
+	// Completing the todo creates the occurrence the preview showed.
+	w = api.do(http.MethodPost, fmt.Sprintf("/todos/%d/complete", todo.ID), jane, "")
+	expectStatus(t, w, http.StatusOK)
+	decode(t, w, &todo)
+	if todo.NextOccurrenceID == nil {
+		t.Fatal("completing the todo created no next occurrence")
+	}
+	w = api.do(http.MethodGet, fmt.Sprintf("/todos/%d", *todo.NextOccurrenceID), jane, "")
+	expectStatus(t, w, http.StatusOK)
+	var next models.TodoItem
+	decode(t, w, &next)
+	if next.DueAt == nil || !next.DueAt.Equal(preview.Occurrences[0]) {
+		t.Errorf("next occurrence due %v, want %v", next.DueAt, preview.Occurrences[0])
+	}
+}
+
+func TestPatchTodoIfMatch(t *testing.T) {
+	api := newTestAPI(t)
+	jane := api.register("jane@example.com", "Jane Doe")
+	todo := api.addTodo(jane, "Buy milk")
+	path := fmt.Sprintf("/todos/%d", todo.ID)
+	conditional := func(method, etag, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("If-Match", etag)
+		return api.serve(r, jane)
+	}
+
+	w := api.do(http.MethodGet, path, jane, "")
+	expectStatus(t, w, http.StatusOK)
+	read := w.Header().Get("ETag")
+	if read == "" {
+		t.Fatal("GET response without ETag")
+	}
+
+	w = conditional(http.MethodPatch, read, `{"title":"Buy oat milk"}`)
+	expectStatus(t, w, http.StatusOK)
+	patched := w.Header().Get("ETag")
+	if patched == "" || patched == read {
+		t.Errorf("patch response has ETag %q, want a new one", patched)
+	}
+	w = api.do(http.MethodGet, path, jane, "")
+	if got := w.Header().Get("ETag"); got != patched {
+		t.Errorf("GET after the patch has ETag %q, want %q from the patch", got, patched)
+	}
+
+	// Changes based on the version before the patch would lose it.
+	expectStatus(t, conditional(http.MethodPatch, read, `{"title":"Buy soy milk"}`), http.StatusPreconditionFailed)
+	expectStatus(t, conditional(http.MethodPut, read, `{"title":"Buy soy milk","description":"2 litres"}`), http.StatusPreconditionFailed)
+	expectStatus(t, conditional(http.MethodPatch, "W/"+patched, `{"title":"Buy soy milk"}`), http.StatusPreconditionFailed)
+	if got := api.todos(jane); got[0].Title != "Buy oat milk" {
+		t.Errorf("refused updates changed the title to %q", got[0].Title)
+	}
+
+	expectStatus(t, conditional(http.MethodPut, `"other", `+patched, `{"title":"Buy soy milk","description":"2 litres"}`), http.StatusOK)
+	expectStatus(t, conditional(http.MethodPatch, "*", `{"title":"Buy rice milk"}`), http.StatusOK)
+}
+
+// racingStore changes a todo right after the handler read it, as a
+// concurrent request could, for the next races reads.
+type racingStore struct {
+	*store.MemoryStore
+	races int
+	race  func(round int)
+}
+
+func (s *racingStore) GetTodo(ctx context.Context, userID, todoID int) (models.TodoItem, error) {
+	todo, err := s.MemoryStore.GetTodo(ctx, userID, todoID)
+	if err == nil && s.races > 0 {
+		s.races--
+		s.race(s.races)
+	}
+	return todo, err
+}
+
+func TestPatchTodoConcurrentChange(t *testing.T) {
+	api := newTestAPI(t)
+	jane := api.register("jane@example.com", "Jane Doe")
+	todo := api.addTodo(jane, "Buy milk")
+	path := fmt.Sprintf("/todos/%d", todo.ID)
+	user, err := api.store.FindUserByEmail(context.Background(), "jane@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	racing := &racingStore{MemoryStore: api.store}
+	racing.race = func(round int) {
+		change, err := api.store.GetTodo(context.Background(), user.ID, todo.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		change.Desc = fmt.Sprintf("changed meanwhile %d", round)
+		change.UpdatedAt = time.Time{}
+		if err := api.store.UpdateTodo(context.Background(), user.ID, &change); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := NewHandler(racing, nil, nil, nil, nil, nil, nil)
+	patch := func(body string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodPatch, path, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+jane)
+		r.SetPathValue("id", strconv.Itoa(todo.ID))
+		w := httptest.NewRecorder()
+		auth.AuthMiddleware(h.PatchTodo)(w, r)
+		return w
+	}
+
+	// The patch is applied again on top of the change it raced with.
+	racing.races = 1
+	w := patch(`{"priority":"high"}`)
+	expectStatus(t, w, http.StatusOK)
+	var got models.TodoItem
+	decode(t, w, &got)
+	if got.Desc != "changed meanwhile 0" || got.Priority != models.PriorityHigh {
+		t.Errorf("got %+v, want the patch and the concurrent change", got)
+	}
+
+	// A todo that keeps changing is given up on.
+	racing.races = maxTodoUpdateAttempts
+	expectStatus(t, patch(`{"priority":"low"}`), http.StatusConflict)
+	if got := api.todos(jane)[0]; got.Priority != models.PriorityHigh {
+		t.Errorf("the refused patch changed the priority to %q", got.Priority)
+	}
+}
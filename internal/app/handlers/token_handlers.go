@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/auth"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/store"
+)
+
+// @Summary Refresh an access token
+// @Description Exchanges a refresh token for a new access token and a new refresh token. Every refresh token can be used once; presenting a used one again revokes every token issued since the login it came from.
+// @Accept  json
+// @Produce json,plain
+// @Param   token  body  models.RefreshRequest  true  "Refresh token from the last login or refresh"
+// @Success 200 {object} models.TokenResponse
+// @Failure 400 {string} string "Invalid request payload"
+// @Failure 401 {string} string "Invalid or expired refresh token"
+// @Failure 403 {string} string "Email address not verified"
+// @Router /token/refresh [post]
+func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return
+	}
+
+	var thisRequest models.RefreshRequest
+	err = json.Unmarshal(body, &thisRequest)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if thisRequest.RefreshToken == "" {
+		http.Error(w, "refresh_token is required", http.StatusBadRequest)
+		return
+	}
+
+	refreshToken, next, err := auth.NewRefreshToken(0)
+	if err != nil {
+		http.Error(w, "Failed to generate refresh token", http.StatusInternalServerError)
+		return
+	}
+	err = h.store.RotateRefreshToken(r.Context(), auth.HashToken(thisRequest.RefreshToken), &next)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, "Invalid or expired refresh token", http.StatusUnauthorized)
+		return
+	case errors.Is(err, store.ErrTokenReused):
+		http.Error(w, "Refresh token has already been used. Log in again", http.StatusUnauthorized)
+		return
+	case err != nil:
+		http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := h.store.GetUser(r.Context(), next.UserID)
+	if err != nil {
+		http.Error(w, "Failed to refresh token", http.StatusInternalServerError)
+		return
+	}
+	if auth.CurrentVerificationPolicy() == auth.PolicyBlockLogin && !user.EmailVerified {
+		http.Error(w, "Email address not verified", http.StatusForbidden)
+		return
+	}
+
+	response, err := accessTokenResponse(user, refreshToken)
+	if err != nil {
+		http.Error(w, "Failed to generate authentication token", http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// issueTokens logs user in: it starts a new refresh token family and
+// returns it together with a fresh access token. Logging in restores an
+// account that is scheduled for deletion.
+func (h *Handler) issueTokens(ctx context.Context, user models.ListCurator) (models.TokenResponse, error) {
+	if user.DeleteAt != nil {
+		if err := h.store.CancelUserDeletion(ctx, user.ID); err != nil {
+			return models.TokenResponse{}, err
+		}
+	}
+	refreshToken, record, err := auth.NewRefreshToken(user.ID)
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+	if err := h.store.CreateRefreshToken(ctx, &record); err != nil {
+		return models.TokenResponse{}, err
+	}
+	return accessTokenResponse(user, refreshToken)
+}
+
+func accessTokenResponse(user models.ListCurator, refreshToken string) (models.TokenResponse, error) {
+	token, err := auth.GenerateToken(user)
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+	ttl, _, err := auth.TokenLifetimes()
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+	return models.TokenResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(ttl.Seconds()),
+	}, nil
+}
+
+// @Summary Log out
+// @Description Revokes the access token of the request and, when one is given, the refresh token issued with it along with every token it was rotated into
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce plain
+// @Param   token  body  models.RefreshRequest  false  "Refresh token to revoke as well"
+// @Success 204
+// @Failure 400 {string} string "Invalid request payload"
+// @Failure 401 {string} string "Unauthorized"
+// @Router /logout [post]
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := auth.GetClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return
+	}
+
+	var thisRequest models.RefreshRequest
+	if len(bytes.TrimSpace(body)) > 0 {
+		err = json.Unmarshal(body, &thisRequest)
+		if err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if thisRequest.RefreshToken != "" {
+		// Unknown and already revoked refresh tokens need no revoking.
+		err = h.store.RevokeRefreshToken(r.Context(), claims.UserID, auth.HashToken(thisRequest.RefreshToken))
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Failed to revoke refresh token", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := h.revocations.RevokeToken(r.Context(), claims); err != nil {
+		http.Error(w, "Failed to revoke token", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Log out everywhere
+// @Description Revokes every access and refresh token issued to the user so far, logging out all of their sessions
+// @Security ApiKeyAuth
+// @Produce plain
+// @Success 204
+// @Failure 401 {string} string "Unauthorized"
+// @Router /logout-all [post]
+func (h *Handler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claims, ok := auth.GetClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.store.RevokeUserRefreshTokens(r.Context(), claims.UserID); err != nil {
+		http.Error(w, "Failed to revoke refresh tokens", http.StatusInternalServerError)
+		return
+	}
+
+	// Revoking the user's tokens misses ones issued just before, so the
+	// token of this request is revoked explicitly.
+	if err := h.revocations.RevokeUserTokens(r.Context(), claims.UserID); err != nil {
+		http.Error(w, "Failed to revoke tokens", http.StatusInternalServerError)
+		return
+	}
+	if err := h.revocations.RevokeToken(r.Context(), claims); err != nil {
+		http.Error(w, "Failed to revoke token", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Get the token signing keys
+// @Description Publishes the public keys access tokens are signed with as a JSON Web Key Set, so other services can verify tokens. Tokens name their key in the kid header.
+// @Produce json
+// @Success 200 {object} models.JSONWebKeySet
+// @Router /.well-known/jwks.json [get]
+func (h *Handler) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(w, http.StatusOK, auth.PublicKeys())
+}
@@ -0,0 +1,468 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/auth"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/store"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/totp"
+)
+
+// @Summary Get the two-factor authentication status
+// @Description Reports whether logins need a code from an authenticator app and how many unused recovery codes are left
+// @Security ApiKeyAuth
+// @Produce json,plain
+// @Success 200 {object} models.TwoFactorStatus
+// @Failure 401 {string} string "Unauthorized"
+// @Router /2fa [get]
+func (h *Handler) GetTwoFactorStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	enrollment, err := h.store.GetTOTP(r.Context(), userID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Failed to retrieve two-factor status", http.StatusInternalServerError)
+		return
+	}
+
+	status := models.TwoFactorStatus{}
+	if err == nil && enrollment.ConfirmedAt != nil {
+		status.Enabled = true
+		status.RecoveryCodesLeft = enrollment.RecoveryCodesLeft
+	}
+	respondWithJSON(w, http.StatusOK, status)
+}
+
+// @Summary Start TOTP enrollment
+// @Description Generates a new TOTP secret for the user. Add it to an authenticator app, usually by showing the otpauth:// URI as a QR code, and confirm it with a code at /2fa/totp/confirm. Starting over replaces a secret that was not confirmed yet.
+// @Security ApiKeyAuth
+// @Produce json,plain
+// @Success 200 {object} models.TOTPEnrollmentResponse
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 409 {string} string "Two-factor authentication is already enabled"
+// @Router /2fa/totp [post]
+func (h *Handler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.store.GetUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve user", http.StatusInternalServerError)
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		http.Error(w, "Failed to generate TOTP secret", http.StatusInternalServerError)
+		return
+	}
+	err = h.store.BeginTOTPEnrollment(r.Context(), userID, secret)
+	if errors.Is(err, store.ErrConflict) {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to start TOTP enrollment", http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.TOTPEnrollmentResponse{
+		Secret: secret,
+		URI:    totp.URI(auth.TOTPIssuer(), user.Email, secret),
+	})
+}
+
+// @Summary Confirm TOTP enrollment
+// @Description Turns on two-factor authentication with a code from the authenticator app the new secret was added to. The response carries the recovery codes, which each replace a code once and are never shown again.
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json,plain
+// @Param   code  body  models.TOTPCodeRequest  true  "Code from the authenticator app"
+// @Success 200 {object} models.RecoveryCodesResponse
+// @Failure 400 {string} string "Invalid code"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 409 {string} string "No TOTP enrollment to confirm"
+// @Router /2fa/totp/confirm [post]
+func (h *Handler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	code, ok := readCodeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	enrollment, err := h.store.GetTOTP(r.Context(), userID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && enrollment.ConfirmedAt != nil) {
+		http.Error(w, "No TOTP enrollment to confirm", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to retrieve TOTP enrollment", http.StatusInternalServerError)
+		return
+	}
+
+	step, valid := totp.Validate(enrollment.Secret, code, time.Now())
+	if !valid {
+		http.Error(w, "Invalid code", http.StatusBadRequest)
+		return
+	}
+
+	codes, hashes, err := auth.NewRecoveryCodes()
+	if err != nil {
+		http.Error(w, "Failed to generate recovery codes", http.StatusInternalServerError)
+		return
+	}
+	err = h.store.ConfirmTOTP(r.Context(), userID, step, hashes)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "No TOTP enrollment to confirm", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to confirm TOTP enrollment", http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// @Summary Turn off two-factor authentication
+// @Description Removes the TOTP secret and the recovery codes of the user. It takes a code from the authenticator app or a recovery code.
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce plain
+// @Param   code  body  models.TOTPCodeRequest  true  "Code from the authenticator app or a recovery code"
+// @Success 204
+// @Failure 400 {string} string "Invalid code"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 409 {string} string "Two-factor authentication is not enabled"
+// @Failure 429 {string} string "Too many failed login attempts"
+// @Router /2fa/totp [delete]
+func (h *Handler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	code, ok := readCodeRequest(w, r)
+	if !ok {
+		return
+	}
+	if !h.requireSecondFactor(w, r, userID, code) {
+		return
+	}
+
+	if err := h.store.DisableTOTP(r.Context(), userID); err != nil {
+		http.Error(w, "Failed to turn off two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Regenerate recovery codes
+// @Description Replaces the recovery codes of the user with new ones, which are never shown again. It takes a code from the authenticator app or a recovery code.
+// @Security ApiKeyAuth
+// @Accept  json
+// @Produce json,plain
+// @Param   code  body  models.TOTPCodeRequest  true  "Code from the authenticator app or a recovery code"
+// @Success 200 {object} models.RecoveryCodesResponse
+// @Failure 400 {string} string "Invalid code"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 409 {string} string "Two-factor authentication is not enabled"
+// @Failure 429 {string} string "Too many failed login attempts"
+// @Router /2fa/recovery-codes [post]
+func (h *Handler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := auth.GetUserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "User ID not found in context. Authentication is required", http.StatusUnauthorized)
+		return
+	}
+
+	code, ok := readCodeRequest(w, r)
+	if !ok {
+		return
+	}
+	if !h.requireSecondFactor(w, r, userID, code) {
+		return
+	}
+
+	codes, hashes, err := auth.NewRecoveryCodes()
+	if err != nil {
+		http.Error(w, "Failed to generate recovery codes", http.StatusInternalServerError)
+		return
+	}
+	if err := h.store.ReplaceRecoveryCodes(r.Context(), userID, hashes); err != nil {
+		http.Error(w, "Failed to store recovery codes", http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// @Summary Finish a two-factor login
+// @Description Exchanges the challenge token /login returned for a user with two-factor authentication, together with a code from their authenticator app or a recovery code, for tokens. A challenge expires after five minutes or five wrong codes, and wrong codes count as failed logins of the account.
+// @Accept  json
+// @Produce json,plain
+// @Param   login  body  models.MFALoginRequest  true  "Challenge token and code"
+// @Success 200 {object} models.TokenResponse
+// @Failure 400 {string} string "Invalid request payload"
+// @Failure 401 {string} string "Invalid or expired challenge token"
+// @Failure 429 {string} string "Too many failed login attempts"
+// @Router /login/2fa [post]
+func (h *Handler) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return
+	}
+
+	var thisRequest models.MFALoginRequest
+	err = json.Unmarshal(body, &thisRequest)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if thisRequest.ChallengeToken == "" || thisRequest.Code == "" {
+		http.Error(w, "challenge_token and code are required", http.StatusBadRequest)
+		return
+	}
+
+	challenge, err := h.store.GetMFAChallenge(r.Context(), auth.HashToken(thisRequest.ChallengeToken))
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Invalid or expired challenge token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to retrieve login challenge", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := h.store.GetUser(r.Context(), challenge.UserID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	// Wrong codes count as failed logins: every /login with the password
+	// starts a new challenge, so the attempts of a single challenge do not
+	// limit guessing on their own.
+	address := h.throttle.ClientAddress(r)
+	wait, err := h.throttle.Check(r.Context(), user.Email, address)
+	if err != nil {
+		http.Error(w, "Failed to check login attempts", http.StatusInternalServerError)
+		return
+	}
+	if wait > 0 {
+		tooManyLoginAttempts(w, wait)
+		return
+	}
+
+	valid, err := h.checkSecondFactor(r.Context(), challenge.UserID, thisRequest.Code)
+	if err != nil {
+		http.Error(w, "Failed to check code", http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		if err := h.store.FailMFAChallenge(r.Context(), challenge.ID, auth.MaxMFAAttempts); err != nil {
+			http.Error(w, "Failed to update login challenge", http.StatusInternalServerError)
+			return
+		}
+		if err := h.throttle.Fail(r.Context(), user.Email, address); err != nil {
+			http.Error(w, "Failed to record login attempt", http.StatusInternalServerError)
+			return
+		}
+		http.Error(w, "Invalid code", http.StatusUnauthorized)
+		return
+	}
+
+	// Deleting the challenge fails if a concurrent request completed it.
+	err = h.store.DeleteMFAChallenge(r.Context(), challenge.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Invalid or expired challenge token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to complete login challenge", http.StatusInternalServerError)
+		return
+	}
+
+	h.unlockLogins(r.Context(), user)
+
+	tokens, err := h.issueTokens(r.Context(), user)
+	if err != nil {
+		http.Error(w, "Failed to generate authentication token", http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, tokens)
+}
+
+// twoFactorChallenge returns the challenge /login responds with when user
+// has two-factor authentication enabled, and false when they do not.
+func (h *Handler) twoFactorChallenge(ctx context.Context, user models.ListCurator) (models.MFAChallengeResponse, bool, error) {
+	enrollment, err := h.store.GetTOTP(ctx, user.ID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && enrollment.ConfirmedAt == nil) {
+		return models.MFAChallengeResponse{}, false, nil
+	}
+	if err != nil {
+		return models.MFAChallengeResponse{}, false, err
+	}
+
+	token, record, err := auth.NewMFAChallenge(user.ID)
+	if err != nil {
+		return models.MFAChallengeResponse{}, false, err
+	}
+	if err := h.store.CreateMFAChallenge(ctx, &record); err != nil {
+		return models.MFAChallengeResponse{}, false, err
+	}
+	return models.MFAChallengeResponse{
+		MFARequired:    true,
+		ChallengeToken: token,
+		ExpiresIn:      int(auth.MFAChallengeTTL.Seconds()),
+	}, true, nil
+}
+
+// checkSecondFactor reports whether code is an unused code of the
+// authenticator app of userID or one of their unused recovery codes, and
+// uses it up if so.
+func (h *Handler) checkSecondFactor(ctx context.Context, userID int, code string) (bool, error) {
+	enrollment, err := h.store.GetTOTP(ctx, userID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && enrollment.ConfirmedAt == nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if step, ok := totp.Validate(enrollment.Secret, code, time.Now()); ok {
+		err := h.store.UseTOTPStep(ctx, userID, step)
+		if errors.Is(err, store.ErrCodeReused) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	err = h.store.UseRecoveryCode(ctx, userID, auth.HashRecoveryCode(code))
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// requireSecondFactor checks code like checkSecondFactor for a change to the
+// two-factor settings of userID and writes the error response if it fails.
+// Wrong codes count as failed logins, like wrong passwords in
+// confirmPassword, so a stolen session cannot be used to guess them.
+func (h *Handler) requireSecondFactor(w http.ResponseWriter, r *http.Request, userID int, code string) bool {
+	enrollment, err := h.store.GetTOTP(r.Context(), userID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && enrollment.ConfirmedAt == nil) {
+		http.Error(w, "Two-factor authentication is not enabled", http.StatusConflict)
+		return false
+	}
+	if err != nil {
+		http.Error(w, "Failed to retrieve TOTP enrollment", http.StatusInternalServerError)
+		return false
+	}
+
+	user, err := h.store.GetUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve user", http.StatusInternalServerError)
+		return false
+	}
+	address := h.throttle.ClientAddress(r)
+	wait, err := h.throttle.Check(r.Context(), user.Email, address)
+	if err != nil {
+		http.Error(w, "Failed to check login attempts", http.StatusInternalServerError)
+		return false
+	}
+	if wait > 0 {
+		tooManyLoginAttempts(w, wait)
+		return false
+	}
+
+	valid, err := h.checkSecondFactor(r.Context(), userID, code)
+	if err != nil {
+		http.Error(w, "Failed to check code", http.StatusInternalServerError)
+		return false
+	}
+	if !valid {
+		if err := h.throttle.Fail(r.Context(), user.Email, address); err != nil {
+			http.Error(w, "Failed to record login attempt", http.StatusInternalServerError)
+			return false
+		}
+		http.Error(w, "Invalid code", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// readCodeRequest reads a models.TOTPCodeRequest and writes the error
+// response if it is malformed.
+func readCodeRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		return "", false
+	}
+
+	var thisRequest models.TOTPCodeRequest
+	err = json.Unmarshal(body, &thisRequest)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return "", false
+	}
+	code := strings.TrimSpace(thisRequest.Code)
+	if code == "" {
+		http.Error(w, "code is required", http.StatusBadRequest)
+		return "", false
+	}
+	return code, true
+}
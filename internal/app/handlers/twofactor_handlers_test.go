@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/totp"
+)
+
+// wrongCode is never a valid TOTP or recovery code.
+const wrongCode = "not-a-code"
+
+// enableTOTP turns on two-factor authentication for the user of token and
+// returns their recovery codes.
+func (a *testAPI) enableTOTP(token string) []string {
+	a.t.Helper()
+	w := a.do(http.MethodPost, "/2fa/totp", token, "")
+	expectStatus(a.t, w, http.StatusOK)
+	var enrollment models.TOTPEnrollmentResponse
+	decode(a.t, w, &enrollment)
+
+	code, err := totp.Code(enrollment.Secret, totp.Step(time.Now()))
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	w = a.do(http.MethodPost, "/2fa/totp/confirm", token, `{"code":"`+code+`"}`)
+	expectStatus(a.t, w, http.StatusOK)
+	var recovery models.RecoveryCodesResponse
+	decode(a.t, w, &recovery)
+	return recovery.RecoveryCodes
+}
+
+// startTwoFactorLogin logs in with testPassword and returns the challenge
+// token the response has to carry.
+func (a *testAPI) startTwoFactorLogin(email string) string {
+	a.t.Helper()
+	w := a.login(email, testPassword)
+	expectStatus(a.t, w, http.StatusOK)
+	var challenge models.MFAChallengeResponse
+	decode(a.t, w, &challenge)
+	if !challenge.MFARequired || challenge.ChallengeToken == "" {
+		a.t.Fatalf("got %+v, want a two-factor challenge", challenge)
+	}
+	return challenge.ChallengeToken
+}
+
+// loginTwoFactor answers the challenge token challenge with code.
+func (a *testAPI) loginTwoFactor(challenge, code string) *httptest.ResponseRecorder {
+	a.t.Helper()
+	return a.do(http.MethodPost, "/login/2fa", "", `{"challenge_token":"`+challenge+`","code":"`+code+`"}`)
+}
+
+// expectThrottled fails the test unless the response refuses a throttled
+// login.
+func expectThrottled(t *testing.T, w *httptest.ResponseRecorder) {
+	t.Helper()
+	expectStatus(t, w, http.StatusTooManyRequests)
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("429 response without Retry-After")
+	}
+}
+
+func TestLoginTwoFactor(t *testing.T) {
+	api := newTestAPI(t)
+	api.register("jane@example.com", "Jane Doe")
+	token := api.register("john@example.com", "John Roe")
+	recovery := api.enableTOTP(token)
+
+	challenge := api.startTwoFactorLogin("john@example.com")
+	expectStatus(t, api.loginTwoFactor(challenge, wrongCode), http.StatusUnauthorized)
+	w := api.loginTwoFactor(challenge, recovery[0])
+	expectStatus(t, w, http.StatusOK)
+	var tokens models.TokenResponse
+	decode(t, w, &tokens)
+	if tokens.Token == "" {
+		t.Error("got no access token")
+	}
+
+	// Challenges and recovery codes only work once.
+	expectStatus(t, api.loginTwoFactor(challenge, recovery[1]), http.StatusUnauthorized)
+	challenge = api.startTwoFactorLogin("john@example.com")
+	expectStatus(t, api.loginTwoFactor(challenge, recovery[0]), http.StatusUnauthorized)
+	expectStatus(t, api.loginTwoFactor(challenge, recovery[1]), http.StatusOK)
+
+	// Users without two-factor authentication get their tokens right away.
+	w = api.login("jane@example.com", testPassword)
+	expectStatus(t, w, http.StatusOK)
+	decode(t, w, &tokens)
+	if tokens.Token == "" {
+		t.Error("got no access token without two-factor authentication")
+	}
+}
+
+func TestLoginTwoFactorThrottle(t *testing.T) {
+	api := newTestAPI(t)
+	recovery := api.enableTOTP(api.register("jane@example.com", "Jane Doe"))
+
+	// Every login with the password starts a new challenge, so the wrong
+	// codes of all challenges count together.
+	first := api.startTwoFactorLogin("jane@example.com")
+	expectStatus(t, api.loginTwoFactor(first, wrongCode), http.StatusUnauthorized)
+	expectStatus(t, api.loginTwoFactor(first, wrongCode), http.StatusUnauthorized)
+	second := api.startTwoFactorLogin("jane@example.com")
+	expectStatus(t, api.loginTwoFactor(second, wrongCode), http.StatusUnauthorized)
+	expectStatus(t, api.loginTwoFactor(second, wrongCode), http.StatusUnauthorized)
+
+	// The four failures passed the free attempts of the account, though
+	// neither challenge ran out of attempts.
+	expectThrottled(t, api.loginTwoFactor(second, recovery[0]))
+	expectThrottled(t, api.loginTwoFactor(first, recovery[0]))
+	expectThrottled(t, api.login("jane@example.com", testPassword))
+}
+
+func TestDisableTOTP(t *testing.T) {
+	api := newTestAPI(t)
+	token := api.register("jane@example.com", "Jane Doe")
+	recovery := api.enableTOTP(token)
+
+	expectStatus(t, api.do(http.MethodDelete, "/2fa/totp", token, `{"code":"`+wrongCode+`"}`), http.StatusBadRequest)
+	expectStatus(t, api.do(http.MethodDelete, "/2fa/totp", token, `{"code":"`+recovery[0]+`"}`), http.StatusNoContent)
+	expectStatus(t, api.do(http.MethodDelete, "/2fa/totp", token, `{"code":"`+recovery[1]+`"}`), http.StatusConflict)
+
+	w := api.login("jane@example.com", testPassword)
+	expectStatus(t, w, http.StatusOK)
+	var tokens models.TokenResponse
+	decode(t, w, &tokens)
+	if tokens.Token == "" {
+		t.Error("login after turning off two-factor authentication got no access token")
+	}
+}
+
+func TestDisableTOTPThrottle(t *testing.T) {
+	api := newTestAPI(t)
+	token := api.register("jane@example.com", "Jane Doe")
+	recovery := api.enableTOTP(token)
+
+	// Wrong codes count as failed logins, so a stolen session cannot guess
+	// its way to turning two-factor authentication off.
+	for range 4 {
+		expectStatus(t, api.do(http.MethodDelete, "/2fa/totp", token, `{"code":"`+wrongCode+`"}`), http.StatusBadRequest)
+	}
+	expectThrottled(t, api.do(http.MethodDelete, "/2fa/totp", token, `{"code":"`+recovery[0]+`"}`))
+	expectThrottled(t, api.login("jane@example.com", testPassword))
+
+	user, err := api.store.FindUserByEmail(context.Background(), "jane@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enrollment, err := api.store.GetTOTP(context.Background(), user.ID); err != nil || enrollment.ConfirmedAt == nil {
+		t.Errorf("two-factor authentication is off after the throttled request: %+v, %v", enrollment, err)
+	}
+}
@@ -1,30 +1,34 @@
 package handlers
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
+	"log"
+	"math"
 	"net/http"
+	netmail "net/mail"
+	"strconv"
+	"time"
 
 	"github.com/Kwagmire/go-todo-api/internal/pkg/auth"
-	"github.com/Kwagmire/go-todo-api/internal/pkg/db"
 	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
-
-	"github.com/lib/pq"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/password"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/store"
 )
 
 // @Summary Register a new user
-// @Description Creates a new user with the provided credentials
+// @Description Creates a new user with the provided credentials and emails them a link to verify their address. The password has to pass the password policy, which asks for a minimum length and rejects passwords containing the email address or name and, when configured, passwords known from data breaches. When unverified accounts may not log in, the new user is returned instead of tokens.
 // @Security ApiKeyAuth
 // @Accept  json
 // @Produce json,plain
 // @Param   user  body  models.RegisterRequest  true  "Credentials for new user"
-// @Success 201 {object} map[string]string
+// @Success 201 {object} models.TokenResponse
 // @Failure 400 {string} string "Invalid request payload"
 // @Failure 403 {string} string "User exists"
 // @Router /register [post]
-func RegisterUser(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
 		return
@@ -47,55 +51,63 @@ func RegisterUser(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "All fields are required", http.StatusBadRequest)
 		return
 	}
-	if len(thisRequest.Password) < 8 {
-		http.Error(w, "Password must be at least 8 characters long", http.StatusBadRequest)
+	if !validEmail(thisRequest.Email) {
+		http.Error(w, "Invalid email address", http.StatusBadRequest)
 		return
 	}
-
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(thisRequest.Password), bcrypt.DefaultCost)
-	if err != nil {
-		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+	hashedPassword, ok := h.hashNewPassword(w, thisRequest.Password, password.Account{Email: thisRequest.Email, Name: thisRequest.Name})
+	if !ok {
 		return
 	}
 
-	query := `
-		INSERT INTO users (
-			email,
-			name,
-			password_hash
-		) VALUES ($1, $2, $3
-		) RETURNING id`
-	var userID int
-	err = db.DB.QueryRow(query, thisRequest.Email, thisRequest.Name, string(hashedPassword)).Scan(&userID)
+	user := models.ListCurator{
+		Email:    thisRequest.Email,
+		Name:     thisRequest.Name,
+		Password: hashedPassword,
+	}
+	err = h.store.CreateUser(r.Context(), &user)
+	if errors.Is(err, store.ErrConflict) {
+		http.Error(w, "Email already exists", http.StatusConflict)
+		return
+	}
 	if err != nil {
-		if dbError, ok := err.(*pq.Error); ok && dbError.Code.Name() == "unique_violation" {
-			http.Error(w, "Email already exists", http.StatusConflict)
-			return
-		}
 		http.Error(w, "Failed to register user: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	token, err := auth.GenerateToken(userID)
+	// A failed email is only logged: the user can ask for another one at
+	// /verify/resend.
+	if err := h.sendVerification(r, user); err != nil {
+		log.Printf("email verification for user %d: %v", user.ID, err)
+	}
+
+	if auth.CurrentVerificationPolicy() == auth.PolicyBlockLogin {
+		respondWithJSON(w, http.StatusCreated, user)
+		return
+	}
+
+	tokens, err := h.issueTokens(r.Context(), user)
 	if err != nil {
 		http.Error(w, "Failed to generate authentication token", http.StatusInternalServerError)
 		return
 	}
 
-	respondWithJSON(w, http.StatusCreated, map[string]string{"token": token})
+	respondWithJSON(w, http.StatusCreated, tokens)
 }
 
 // @Summary Log a user in
-// @Description Authenticate a user with provided credentials
+// @Description Authenticate a user with provided credentials. For users with two-factor authentication the response is a models.MFAChallengeResponse instead of tokens, whose challenge token is exchanged for the tokens at /login/2fa. Failed logins are counted per email address and client address; after a few of them further logins are refused with an exponentially growing delay and, eventually, a temporary lockout, which a password reset lifts.
 // @Security ApiKeyAuth
 // @Accept  json
 // @Produce json,plain
 // @Param   user  body  models.LoginRequest  true  "User login credentials"
-// @Success 201 {object} map[string]string
+// @Success 200 {object} models.TokenResponse
 // @Failure 400 {string} string "Invalid request payload"
 // @Failure 401 {string} string "Unauthorized"
+// @Failure 403 {string} string "Email address not verified"
+// @Failure 429 {string} string "Too many failed login attempts"
 // @Router /login [post]
-func LoginUser(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) LoginUser(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Unaccepted method", http.StatusMethodNotAllowed)
 		return
@@ -119,15 +131,20 @@ func LoginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `
-		SELECT id, password_hash
-		FROM users
-		WHERE email = $1`
-	var userID int
-	var hashedPassword string
-	err = db.DB.QueryRow(query, thisRequest.Email).Scan(&userID, &hashedPassword)
-	if err == sql.ErrNoRows {
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+	address := h.throttle.ClientAddress(r)
+	wait, err := h.throttle.Check(r.Context(), thisRequest.Email, address)
+	if err != nil {
+		http.Error(w, "Failed to check login attempts", http.StatusInternalServerError)
+		return
+	}
+	if wait > 0 {
+		tooManyLoginAttempts(w, wait)
+		return
+	}
+
+	user, err := h.store.FindUserByEmail(r.Context(), thisRequest.Email)
+	if errors.Is(err, store.ErrNotFound) {
+		h.loginFailed(w, r, thisRequest.Email, address)
 		return
 	}
 	if err != nil {
@@ -135,16 +152,67 @@ func LoginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(thisRequest.Password)); err != nil {
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+	if match, _ := password.Verify(user.Password, thisRequest.Password); !match {
+		h.loginFailed(w, r, thisRequest.Email, address)
 		return
 	}
+	h.rehashPassword(r.Context(), user, thisRequest.Password)
 
-	token, err := auth.GenerateToken(userID)
+	if auth.CurrentVerificationPolicy() == auth.PolicyBlockLogin && !user.EmailVerified {
+		http.Error(w, "Email address not verified", http.StatusForbidden)
+		return
+	}
+
+	challenge, required, err := h.twoFactorChallenge(r.Context(), user)
+	if err != nil {
+		http.Error(w, "Failed to start two-factor login", http.StatusInternalServerError)
+		return
+	}
+	if required {
+		// The failures stay counted until the second factor is checked,
+		// so the password alone cannot mint challenges without limit.
+		respondWithJSON(w, http.StatusOK, challenge)
+		return
+	}
+	h.unlockLogins(r.Context(), user)
+
+	tokens, err := h.issueTokens(r.Context(), user)
 	if err != nil {
 		http.Error(w, "Failed to generate authentication token", http.StatusInternalServerError)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, map[string]string{"token": token})
+	respondWithJSON(w, http.StatusOK, tokens)
+}
+
+// loginFailed counts a failed login to email from address and writes the
+// error response.
+func (h *Handler) loginFailed(w http.ResponseWriter, r *http.Request, email, address string) {
+	if err := h.throttle.Fail(r.Context(), email, address); err != nil {
+		http.Error(w, "Failed to record login attempt", http.StatusInternalServerError)
+		return
+	}
+	http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+}
+
+// unlockLogins forgets the failed logins to user once they fully logged
+// in. Failures are only logged: the failures expire on their own.
+func (h *Handler) unlockLogins(ctx context.Context, user models.ListCurator) {
+	if err := h.throttle.Unlock(ctx, user.Email); err != nil {
+		log.Printf("login throttle for user %d: %v", user.ID, err)
+	}
+}
+
+// tooManyLoginAttempts refuses a throttled login, telling the client to
+// wait for wait before trying again.
+func tooManyLoginAttempts(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	http.Error(w, "Too many failed login attempts, try again later", http.StatusTooManyRequests)
+}
+
+// validEmail reports whether email is a bare address such as
+// "jane@example.com".
+func validEmail(email string) bool {
+	addr, err := netmail.ParseAddress(email)
+	return err == nil && addr.Address == email
 }
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/password"
+)
+
+// login logs the user with email in with pw.
+func (a *testAPI) login(email, pw string) *httptest.ResponseRecorder {
+	a.t.Helper()
+	return a.do(http.MethodPost, "/login", "", `{"email":"`+email+`","password":"`+pw+`"}`)
+}
+
+func TestRegisterPasswordPolicy(t *testing.T) {
+	api := newTestAPI(t)
+	tests := []struct {
+		name, password, reason string
+	}{
+		{"too short", "abc123", "Password must be at least 8 characters long"},
+		{"contains the name", "i am smith!!", "Password must not contain your name"},
+		{"contains the email address", "jane@example.com1", "Password must not contain your email address"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := api.do(http.MethodPost, "/register", "", `{"email":"jane@example.com","name":"Jane Smith","password":"`+tt.password+`"}`)
+			expectStatus(t, w, http.StatusBadRequest)
+			if got := strings.TrimSpace(w.Body.String()); got != tt.reason {
+				t.Errorf("got %q, want %q", got, tt.reason)
+			}
+		})
+	}
+	if _, err := api.store.FindUserByEmail(context.Background(), "jane@example.com"); err == nil {
+		t.Error("a rejected registration created the user")
+	}
+}
+
+func TestLoginRehashesPassword(t *testing.T) {
+	api := newTestAPI(t)
+	// The hasher of testAPI is bcrypt at the lowest cost, so hashes made
+	// with Argon2id or another cost are rehashed.
+	tests := []struct {
+		name   string
+		hasher password.Hasher
+		rehash bool
+	}{
+		{"argon2id", password.Argon2id{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}, true},
+		{"bcrypt at another cost", password.Bcrypt{Cost: 5}, true},
+		{"bcrypt at the same cost", password.Bcrypt{Cost: 4}, false},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := tt.hasher.Hash(testPassword)
+			if err != nil {
+				t.Fatal(err)
+			}
+			user := models.ListCurator{Email: fmt.Sprintf("user%d@example.com", i), Name: "User", Password: hash}
+			if err := api.store.CreateUser(context.Background(), &user); err != nil {
+				t.Fatal(err)
+			}
+
+			expectStatus(t, api.login(user.Email, "wrong password"), http.StatusUnauthorized)
+			if stored, _ := api.store.GetUser(context.Background(), user.ID); stored.Password != hash {
+				t.Fatal("a failed login rehashed the password")
+			}
+
+			expectStatus(t, api.login(user.Email, testPassword), http.StatusOK)
+			stored, err := api.store.GetUser(context.Background(), user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rehashed := stored.Password != hash; rehashed != tt.rehash {
+				t.Errorf("rehashed %t, want %t", rehashed, tt.rehash)
+			}
+			if ok, err := password.Verify(stored.Password, testPassword); err != nil || !ok {
+				t.Errorf("the stored hash does not verify the password: %t, %v", ok, err)
+			}
+
+			// The new hash works for the next login.
+			expectStatus(t, api.login(user.Email, testPassword), http.StatusOK)
+		})
+	}
+}
+
+func TestLoginUnlocksAfterSecondFactor(t *testing.T) {
+	api := newTestAPI(t)
+	api.enableTOTP(api.register("jane@example.com", "Jane Doe"))
+	recovery := api.enableTOTP(api.register("john@example.com", "John Roe"))
+
+	// The password alone does not forget the failures of an account with
+	// two-factor authentication, or it could mint challenges without limit.
+	for range 3 {
+		expectStatus(t, api.login("jane@example.com", "wrong password"), http.StatusUnauthorized)
+	}
+	api.startTwoFactorLogin("jane@example.com")
+	expectStatus(t, api.login("jane@example.com", "wrong password"), http.StatusUnauthorized)
+	expectThrottled(t, api.login("jane@example.com", testPassword))
+
+	// Finishing the two-factor login does.
+	for range 3 {
+		expectStatus(t, api.login("john@example.com", "wrong password"), http.StatusUnauthorized)
+	}
+	expectStatus(t, api.loginTwoFactor(api.startTwoFactorLogin("john@example.com"), recovery[0]), http.StatusOK)
+	for range 3 {
+		expectStatus(t, api.login("john@example.com", "wrong password"), http.StatusUnauthorized)
+	}
+	api.startTwoFactorLogin("john@example.com")
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+// APIKeyPrefix starts every API key, so AuthMiddleware can tell them from
+// JWTs and secret scanners can spot leaked keys.
+const APIKeyPrefix = "tdk_"
+
+// apiKeyDisplayLength is how much of a key is kept in the clear to tell keys
+// apart.
+const apiKeyDisplayLength = len(APIKeyPrefix) + 6
+
+// APIKeyStore looks up API keys. store.Store implements it.
+type APIKeyStore interface {
+	AuthenticateAPIKey(ctx context.Context, keyHash string) (models.APIKey, error)
+}
+
+// apiKeys is consulted by AuthMiddleware once UseAPIKeys is called.
+var apiKeys APIKeyStore
+
+// UseAPIKeys makes AuthMiddleware accept the API keys in s as bearer
+// tokens. It must be called before the server starts.
+func UseAPIKeys(s APIKeyStore) {
+	apiKeys = s
+}
+
+// NewAPIKey returns a random API key for userID together with the record to
+// store for it, which only carries the hash of the key.
+func NewAPIKey(userID int) (string, models.APIKey, error) {
+	token, err := randomString(32)
+	if err != nil {
+		return "", models.APIKey{}, err
+	}
+	key := APIKeyPrefix + token
+	record := models.APIKey{
+		UserID:  userID,
+		Prefix:  key[:apiKeyDisplayLength],
+		KeyHash: HashToken(key),
+	}
+	return key, record, nil
+}
+
+func isAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
@@ -2,67 +2,138 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/store"
 )
 
 type UserClaims struct {
 	UserID int `json:"user_id"`
+	// EmailVerified records whether the user had verified their email
+	// address when the token was issued.
+	EmailVerified bool `json:"email_verified"`
+	// Scope lists the scopes the token grants, separated by spaces as in
+	// RFC 9068. Tokens issued before scopes existed have none and keep full
+	// access until they expire.
+	Scope string `json:"scope,omitempty"`
+	// APIKeyID is set instead of the registered claims when the request
+	// authenticated with an API key rather than a token.
+	APIKeyID int `json:"-"`
 	jwt.RegisteredClaims
 }
 
 type userIDContextKey string
 
-const contextKey userIDContextKey = "userID"
+const (
+	contextKey       userIDContextKey = "userID"
+	claimsContextKey userIDContextKey = "claims"
+)
+
+// revocations is consulted by AuthMiddleware once UseRevocations is called.
+var revocations *Revocations
 
-func GenerateToken(userID int) (string, error) {
-	secretKey := os.Getenv("JWT_SECRET_KEY")
-	if secretKey == "" {
-		return "", fmt.Errorf("JWT_SECRET_KEY environment variable not set")
+// UseRevocations makes AuthMiddleware reject the tokens revoked through r.
+// It must be called before the server starts. Without it, tokens are valid
+// until they expire.
+func UseRevocations(r *Revocations) {
+	revocations = r
+}
+
+// GenerateToken returns a signed access token for user. The keyring must
+// have been set with UseKeyring.
+func GenerateToken(user models.ListCurator) (string, error) {
+	if keyring == nil {
+		return "", fmt.Errorf("no JWT keyring configured")
+	}
+	ttl, _, err := TokenLifetimes()
+	if err != nil {
+		return "", err
+	}
+	tokenID, err := randomString(16)
+	if err != nil {
+		return "", err
 	}
 
+	// Sessions act on the user's behalf in every way; narrower access is
+	// what API keys are for.
 	claims := UserClaims{
-		userID,
-		jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(2 * time.Hour)),
+		UserID:        user.ID,
+		EmailVerified: user.EmailVerified,
+		Scope:         strings.Join(AllScopes, " "),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secretKey))
+	return keyring.Sign(claims)
 }
 
 func ValidateToken(jwtString string) (*UserClaims, error) {
-	secretKey := os.Getenv("JWT_SECRET_KEY")
-	if secretKey == "" {
-		return nil, fmt.Errorf("JWT_SECRET_KEY environment variable not set")
+	if keyring == nil {
+		return nil, fmt.Errorf("no JWT keyring configured")
 	}
 
-	token, err := jwt.ParseWithClaims(jwtString, &UserClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(secretKey), nil
-	})
+	token, err := jwt.ParseWithClaims(jwtString, &UserClaims{}, keyring.verificationKey)
 
 	if err != nil {
 		return nil, fmt.Errorf("token parsing error: %w", err)
 	}
 
-	if claims, ok := token.Claims.(*UserClaims); ok && token.Valid {
+	// Tokens without an ID could not be revoked, so they are not accepted.
+	if claims, ok := token.Claims.(*UserClaims); ok && token.Valid && claims.ID != "" {
 		return claims, nil
 	}
 
 	return nil, fmt.Errorf("invalid or expired token")
 }
 
-func AuthMiddleware(todoHandler http.HandlerFunc) http.HandlerFunc {
+// MiddlewareOption adjusts the checks AuthMiddleware makes for one route.
+type MiddlewareOption func(*middlewareConfig)
+
+type middlewareConfig struct {
+	allowUnverified bool
+	tokensOnly      bool
+	scopes          []string
+}
+
+// AllowUnverified exempts a route from the read-only verification policy.
+// It is meant for the endpoints that end a session.
+func AllowUnverified(c *middlewareConfig) {
+	c.allowUnverified = true
+}
+
+// TokensOnly rejects API keys on a route. It is meant for the endpoints that
+// manage sessions and credentials, which a leaked key must not reach.
+func TokensOnly(c *middlewareConfig) {
+	c.tokensOnly = true
+}
+
+// RequireScopes rejects tokens and API keys that lack any of scopes on a
+// route.
+func RequireScopes(scopes ...string) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.scopes = append(c.scopes, scopes...)
+	}
+}
+
+// AuthMiddleware authenticates requests with a Bearer access token or, once
+// UseAPIKeys is called, a Bearer API key.
+func AuthMiddleware(todoHandler http.HandlerFunc, options ...MiddlewareOption) http.HandlerFunc {
+	var config middlewareConfig
+	for _, option := range options {
+		option(&config)
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
@@ -76,18 +147,113 @@ func AuthMiddleware(todoHandler http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		claims, err := ValidateToken(tokenString)
-		if err != nil {
-			http.Error(w, "Invalid or expired token: "+err.Error(), http.StatusUnauthorized)
+		var claims *UserClaims
+		var ok bool
+		if apiKeys != nil && isAPIKey(tokenString) {
+			if config.tokensOnly {
+				http.Error(w, "API keys cannot be used for this endpoint", http.StatusForbidden)
+				return
+			}
+			claims, ok = authenticateAPIKey(w, r, tokenString)
+		} else {
+			claims, ok = authenticateToken(w, r, tokenString)
+		}
+		if !ok {
+			return
+		}
+
+		if missing := claims.missingScopes(config.scopes); len(missing) > 0 {
+			scope := strings.Join(missing, " ")
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, scope))
+			http.Error(w, "Insufficient scope: requires "+scope, http.StatusForbidden)
+			return
+		}
+
+		if verificationPolicy == PolicyReadOnly && !claims.EmailVerified && !config.allowUnverified && !isSafeMethod(r.Method) {
+			http.Error(w, "Verify your email address to make changes", http.StatusForbidden)
 			return
 		}
 
 		ctx := context.WithValue(r.Context(), contextKey, claims.UserID)
+		ctx = context.WithValue(ctx, claimsContextKey, claims)
 		todoHandler.ServeHTTP(w, r.WithContext(ctx))
 	}
 }
 
+// HasScope reports whether the token grants scope.
+func (c *UserClaims) HasScope(scope string) bool {
+	if c.Scope == "" && c.APIKeyID == 0 {
+		return true
+	}
+	return slices.Contains(strings.Fields(c.Scope), scope)
+}
+
+// missingScopes returns the scopes out of required the token does not grant.
+func (c *UserClaims) missingScopes(required []string) []string {
+	var missing []string
+	for _, scope := range required {
+		if !c.HasScope(scope) {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
+
+// authenticateToken validates an access token and checks that it has not
+// been revoked. It writes the error response itself and reports whether the
+// request may continue.
+func authenticateToken(w http.ResponseWriter, r *http.Request, tokenString string) (*UserClaims, bool) {
+	claims, err := ValidateToken(tokenString)
+	if err != nil {
+		http.Error(w, "Invalid or expired token: "+err.Error(), http.StatusUnauthorized)
+		return nil, false
+	}
+
+	if revocations != nil {
+		revoked, err := revocations.IsRevoked(r.Context(), claims)
+		if err != nil {
+			http.Error(w, "Failed to check token revocation", http.StatusInternalServerError)
+			return nil, false
+		}
+		if revoked {
+			http.Error(w, "Invalid or expired token: token has been revoked", http.StatusUnauthorized)
+			return nil, false
+		}
+	}
+	return claims, true
+}
+
+// authenticateAPIKey looks up an API key like authenticateToken does for
+// access tokens.
+func authenticateAPIKey(w http.ResponseWriter, r *http.Request, key string) (*UserClaims, bool) {
+	stored, err := apiKeys.AuthenticateAPIKey(r.Context(), HashToken(key))
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Invalid or expired API key", http.StatusUnauthorized)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "Failed to check API key", http.StatusInternalServerError)
+		return nil, false
+	}
+	// Deleting a key revokes it, so there is no revocation to check. Keys
+	// count as verified: unverified users under the read-only policy cannot
+	// create them.
+	return &UserClaims{
+		UserID:        stored.UserID,
+		EmailVerified: true,
+		Scope:         strings.Join(stored.Scopes, " "),
+		APIKeyID:      stored.ID,
+	}, true
+}
+
 func GetUserIDFromContext(ctx context.Context) (int, bool) {
 	userID, ok := ctx.Value(contextKey).(int)
 	return userID, ok
 }
+
+// GetClaimsFromContext returns the claims of the token a request
+// authenticated with.
+func GetClaimsFromContext(ctx context.Context) (*UserClaims, bool) {
+	claims, ok := ctx.Value(claimsContextKey).(*UserClaims)
+	return claims, ok
+}
@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+// minRSABits is the smallest RSA modulus accepted for signing keys.
+const minRSABits = 2048
+
+// Keyring holds the key tokens are signed with and every key they are
+// verified with. Keys are identified by the kid header of the tokens they
+// sign, so a new signing key can be rolled out while tokens signed with the
+// previous one stay valid until they expire.
+type Keyring struct {
+	signing *keyringKey
+	keys    map[string]*keyringKey
+}
+
+type keyringKey struct {
+	id     string
+	method jwt.SigningMethod
+	// private signs tokens and is nil for verification-only keys.
+	private any
+	public  any
+}
+
+// keyring is used by GenerateToken and ValidateToken once UseKeyring is
+// called.
+var keyring *Keyring
+
+// UseKeyring makes GenerateToken and ValidateToken sign and verify tokens
+// with k. It must be called before the server starts.
+func UseKeyring(k *Keyring) {
+	keyring = k
+}
+
+func NewKeyring() *Keyring {
+	return &Keyring{keys: make(map[string]*keyringKey)}
+}
+
+// LoadKeyring builds the keyring configured in the environment.
+//
+// JWT_KEYS_DIR names a directory of PEM files, each holding one RSA or
+// Ed25519 key whose ID is the file name without its .pem extension. Private
+// keys sign and verify tokens, public keys only verify them, which keeps the
+// tokens of a retired key valid until they expire. JWT_SIGNING_KEY_ID picks
+// the signing key and may be left out when there is a single private key.
+//
+// JWT_SECRET_KEY adds an HS256 key without an ID. It signs tokens when
+// JWT_KEYS_DIR is not set and otherwise only verifies the tokens signed
+// with it before the switch to asymmetric keys.
+func LoadKeyring() (*Keyring, error) {
+	k := NewKeyring()
+	secret := os.Getenv("JWT_SECRET_KEY")
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		if secret == "" {
+			return nil, fmt.Errorf("either JWT_KEYS_DIR or JWT_SECRET_KEY must be set")
+		}
+		k.AddHMAC("", []byte(secret))
+		return k, k.SetSigningKey("")
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list JWT keys: %w", err)
+	}
+	var signers []string
+	for _, path := range paths {
+		id := strings.TrimSuffix(filepath.Base(path), ".pem")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT key: %w", err)
+		}
+		private, err := k.AddPEM(id, data)
+		if err != nil {
+			return nil, fmt.Errorf("JWT key %s: %w", path, err)
+		}
+		if private {
+			signers = append(signers, id)
+		}
+	}
+	if secret != "" {
+		// Without its private half the HMAC key only verifies tokens.
+		k.keys[""] = &keyringKey{method: jwt.SigningMethodHS256, public: []byte(secret)}
+	}
+
+	signingID := os.Getenv("JWT_SIGNING_KEY_ID")
+	if signingID == "" {
+		if len(signers) != 1 {
+			return nil, fmt.Errorf("JWT_SIGNING_KEY_ID must be set when JWT_KEYS_DIR holds %d private keys", len(signers))
+		}
+		signingID = signers[0]
+	}
+	return k, k.SetSigningKey(signingID)
+}
+
+// AddHMAC adds an HS256 key. HMAC keys are never published in the JWKS.
+func (k *Keyring) AddHMAC(id string, secret []byte) {
+	k.keys[id] = &keyringKey{id: id, method: jwt.SigningMethodHS256, private: secret, public: secret}
+}
+
+// AddPEM adds the RSA or Ed25519 key in data under id and reports whether
+// it is a private key that can sign tokens.
+func (k *Keyring) AddPEM(id string, data []byte) (bool, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return false, fmt.Errorf("no PEM data found")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return false, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		return true, k.AddKey(id, key)
+	case ed25519.PrivateKey:
+		return true, k.AddKey(id, key)
+	case *rsa.PublicKey, ed25519.PublicKey:
+		return false, k.AddPublicKey(id, key)
+	default:
+		return false, fmt.Errorf("unsupported key type %T (expected RSA or Ed25519)", parsed)
+	}
+}
+
+// AddKey adds an RSA (RS256) or Ed25519 (EdDSA) private key that can sign
+// and verify tokens.
+func (k *Keyring) AddKey(id string, private crypto.Signer) error {
+	if err := k.AddPublicKey(id, private.Public()); err != nil {
+		return err
+	}
+	k.keys[id].private = private
+	return nil
+}
+
+// AddPublicKey adds an RSA or Ed25519 public key that only verifies tokens.
+func (k *Keyring) AddPublicKey(id string, public crypto.PublicKey) error {
+	if id == "" {
+		return fmt.Errorf("asymmetric keys need an ID")
+	}
+	var method jwt.SigningMethod
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < minRSABits {
+			return fmt.Errorf("RSA keys must have at least %d bits", minRSABits)
+		}
+		method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return fmt.Errorf("unsupported key type %T (expected RSA or Ed25519)", public)
+	}
+	k.keys[id] = &keyringKey{id: id, method: method, public: public}
+	return nil
+}
+
+// SetSigningKey makes the private key id sign new tokens.
+func (k *Keyring) SetSigningKey(id string) error {
+	key, ok := k.keys[id]
+	if !ok || key.private == nil {
+		return fmt.Errorf("no private JWT key with ID %q", id)
+	}
+	k.signing = key
+	return nil
+}
+
+// Sign returns claims as a token signed with the signing key, with its ID
+// in the kid header.
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	if k.signing == nil {
+		return "", fmt.Errorf("no JWT signing key set")
+	}
+	token := jwt.NewWithClaims(k.signing.method, claims)
+	if k.signing.id != "" {
+		token.Header["kid"] = k.signing.id
+	}
+	return token.SignedString(k.signing.private)
+}
+
+// verificationKey is the jwt.Keyfunc of the keyring. It picks the key named
+// by the kid header and only accepts tokens signed with that key's
+// algorithm.
+func (k *Keyring) verificationKey(token *jwt.Token) (any, error) {
+	id, _ := token.Header["kid"].(string)
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", id)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
+
+// JWKS returns the public keys of the keyring as an RFC 7517 key set.
+func (k *Keyring) JWKS() models.JSONWebKeySet {
+	set := models.JSONWebKeySet{Keys: []models.JSONWebKey{}}
+	for _, key := range k.keys {
+		jwk := models.JSONWebKey{KeyID: key.id, Use: "sig", Algorithm: key.method.Alg()}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+	return set
+}
+
+// PublicKeys returns the JWKS of the keyring passed to UseKeyring.
+func PublicKeys() models.JSONWebKeySet {
+	if keyring == nil {
+		return models.JSONWebKeySet{Keys: []models.JSONWebKey{}}
+	}
+	return keyring.JWKS()
+}
@@ -0,0 +1,325 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testRSAKey is shared by the tests since generating RSA keys is slow.
+var testRSAKey = func() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, minRSABits)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}()
+
+func newEd25519Key(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// writePEM stores key, a private or public key, as dir/id.pem.
+func writePEM(t *testing.T, dir, id string, key any) {
+	t.Helper()
+	var block *pem.Block
+	switch key := key.(type) {
+	case *rsa.PublicKey, ed25519.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	default:
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+	if err := os.WriteFile(filepath.Join(dir, id+".pem"), pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testClaims() UserClaims {
+	return UserClaims{
+		UserID: 7,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "token",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+}
+
+// verify parses token with the keys of k like ValidateToken does.
+func verify(k *Keyring, token string) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(token, &UserClaims{}, k.verificationKey)
+}
+
+// signWith signs claims with key and method, putting kid in the header
+// unless it is empty.
+func signWith(t *testing.T, method jwt.SigningMethod, key any, kid string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, testClaims())
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestLoadKeyringSecretOnly(t *testing.T) {
+	t.Setenv("JWT_SECRET_KEY", "s3cret")
+	t.Setenv("JWT_KEYS_DIR", "")
+	k, err := LoadKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := k.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := verify(k, signed)
+	if err != nil {
+		t.Fatalf("verifying own token: %v", err)
+	}
+	if token.Method.Alg() != "HS256" || token.Header["kid"] != nil {
+		t.Errorf("got alg %s and kid %v, want HS256 without kid", token.Method.Alg(), token.Header["kid"])
+	}
+	if keys := k.JWKS().Keys; len(keys) != 0 {
+		t.Errorf("JWKS published the HMAC secret: %+v", keys)
+	}
+
+	if _, err := verify(k, signWith(t, jwt.SigningMethodHS256, []byte("other"), "")); err == nil {
+		t.Error("accepted a token signed with another secret")
+	}
+}
+
+func TestLoadKeyringErrors(t *testing.T) {
+	empty := t.TempDir()
+	twoKeys := t.TempDir()
+	writePEM(t, twoKeys, "a", testRSAKey)
+	writePEM(t, twoKeys, "b", newEd25519Key(t))
+	publicOnly := t.TempDir()
+	writePEM(t, publicOnly, "old", testRSAKey.Public())
+	invalid := t.TempDir()
+	if err := os.WriteFile(filepath.Join(invalid, "broken.pem"), []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	weak := t.TempDir()
+	writePEM(t, weak, "weak", small)
+
+	tests := []struct {
+		name                 string
+		secret, dir, signing string
+	}{
+		{"nothing configured", "", "", ""},
+		{"empty directory", "", empty, ""},
+		{"empty directory with a secret", "s3cret", empty, ""},
+		{"several private keys without a signing key", "", twoKeys, ""},
+		{"unknown signing key", "", twoKeys, "c"},
+		{"public signing key", "", publicOnly, "old"},
+		{"secret as the only signing key", "s3cret", publicOnly, ""},
+		{"malformed PEM file", "", invalid, ""},
+		{"short RSA key", "", weak, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("JWT_SECRET_KEY", tt.secret)
+			t.Setenv("JWT_KEYS_DIR", tt.dir)
+			t.Setenv("JWT_SIGNING_KEY_ID", tt.signing)
+			if _, err := LoadKeyring(); err == nil {
+				t.Error("LoadKeyring succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestLoadKeyringRotation(t *testing.T) {
+	ed := newEd25519Key(t)
+	retired := newEd25519Key(t)
+	dir := t.TempDir()
+	writePEM(t, dir, "2025-rsa", testRSAKey)
+	writePEM(t, dir, "2026-ed", ed)
+	writePEM(t, dir, "2024-ed", retired.Public())
+	t.Setenv("JWT_KEYS_DIR", dir)
+	t.Setenv("JWT_SECRET_KEY", "s3cret")
+	t.Setenv("JWT_SIGNING_KEY_ID", "2026-ed")
+
+	k, err := LoadKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := k.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := verify(k, signed)
+	if err != nil {
+		t.Fatalf("verifying own token: %v", err)
+	}
+	if token.Method.Alg() != "EdDSA" || token.Header["kid"] != "2026-ed" {
+		t.Errorf("got alg %s and kid %v, want EdDSA with kid 2026-ed", token.Method.Alg(), token.Header["kid"])
+	}
+
+	valid := []struct {
+		name  string
+		token string
+	}{
+		{"previous RSA key", signWith(t, jwt.SigningMethodRS256, testRSAKey, "2025-rsa")},
+		{"retired key", signWith(t, jwt.SigningMethodEdDSA, retired, "2024-ed")},
+		{"HS256 token from before the switch", signWith(t, jwt.SigningMethodHS256, []byte("s3cret"), "")},
+	}
+	for _, tt := range valid {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := verify(k, tt.token); err != nil {
+				t.Errorf("rejected: %v", err)
+			}
+		})
+	}
+
+	// The public half of the RSA key is no secret, so a token "signed" with
+	// it as an HMAC secret must not pass for an RS256 token.
+	rsaPublic, err := x509.MarshalPKIXPublicKey(testRSAKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	invalid := []struct {
+		name  string
+		token string
+	}{
+		{"unknown kid", signWith(t, jwt.SigningMethodEdDSA, ed, "2027-ed")},
+		{"kid of another key", signWith(t, jwt.SigningMethodEdDSA, ed, "2024-ed")},
+		{"algorithm of another key", signWith(t, jwt.SigningMethodHS256, rsaPublic, "2025-rsa")},
+		{"HS256 with a kid", signWith(t, jwt.SigningMethodHS256, []byte("s3cret"), "2026-ed")},
+		{"HS256 with another secret", signWith(t, jwt.SigningMethodHS256, []byte("guess"), "")},
+		{"unsigned", signWith(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "")},
+		{"unsigned with a kid", signWith(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "2026-ed")},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := verify(k, tt.token); err == nil {
+				t.Error("accepted")
+			}
+		})
+	}
+
+	// Only the keys that can sign may become the signing key; the secret
+	// only verifies once asymmetric keys are configured.
+	for _, id := range []string{"2024-ed", "", "2027-ed"} {
+		if err := k.SetSigningKey(id); err == nil {
+			t.Errorf("SetSigningKey(%q) succeeded", id)
+		}
+	}
+	if err := k.SetSigningKey("2025-rsa"); err != nil {
+		t.Fatal(err)
+	}
+	signed, err = k.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err = verify(k, signed)
+	if err != nil {
+		t.Fatalf("verifying own token after switching keys: %v", err)
+	}
+	if token.Method.Alg() != "RS256" || token.Header["kid"] != "2025-rsa" {
+		t.Errorf("after switching keys got alg %s and kid %v, want RS256 with kid 2025-rsa", token.Method.Alg(), token.Header["kid"])
+	}
+}
+
+func TestLoadKeyringSingleKey(t *testing.T) {
+	dir := t.TempDir()
+	writePEM(t, dir, "only", testRSAKey)
+	writePEM(t, dir, "old", newEd25519Key(t).Public())
+	t.Setenv("JWT_KEYS_DIR", dir)
+	t.Setenv("JWT_SECRET_KEY", "")
+	t.Setenv("JWT_SIGNING_KEY_ID", "")
+
+	k, err := LoadKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := k.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := verify(k, signed)
+	if err != nil {
+		t.Fatalf("verifying own token: %v", err)
+	}
+	if token.Header["kid"] != "only" {
+		t.Errorf("got kid %v, want only", token.Header["kid"])
+	}
+	if _, err := verify(k, signWith(t, jwt.SigningMethodHS256, []byte(""), "")); err == nil {
+		t.Error("accepted an HS256 token without a secret configured")
+	}
+}
+
+func TestAddPublicKey(t *testing.T) {
+	k := NewKeyring()
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		id   string
+		key  crypto.PublicKey
+	}{
+		{"no ID", "", testRSAKey.Public()},
+		{"short RSA key", "small", small.Public()},
+		{"unsupported key type", "hmac", []byte("s3cret")},
+	}
+	for _, tt := range tests {
+		if err := k.AddPublicKey(tt.id, tt.key); err == nil {
+			t.Errorf("%s: AddPublicKey succeeded", tt.name)
+		}
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	ed := newEd25519Key(t)
+	k := NewKeyring()
+	k.AddHMAC("hmac", []byte("s3cret"))
+	if err := k.AddKey("b-rsa", testRSAKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.AddPublicKey("a-ed", ed.Public()); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := k.JWKS().Keys
+	if len(keys) != 2 {
+		t.Fatalf("got %d keys, want the 2 asymmetric ones: %+v", len(keys), keys)
+	}
+	if got := keys[0]; got.KeyID != "a-ed" || got.KeyType != "OKP" || got.Curve != "Ed25519" || got.Algorithm != "EdDSA" || got.X == "" || got.Use != "sig" {
+		t.Errorf("got Ed25519 key %+v", got)
+	}
+	if got := keys[1]; got.KeyID != "b-rsa" || got.KeyType != "RSA" || got.Algorithm != "RS256" || got.N == "" || got.E != "AQAB" {
+		t.Errorf("got RSA key %+v", got)
+	}
+}
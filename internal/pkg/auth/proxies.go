@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// TrustedProxies lists the reverse proxies whose X-Forwarded-For header is
+// believed. Without any, the client address of a request is the address it
+// came from.
+type TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies parses a list of IP addresses and CIDR ranges
+// separated by commas or spaces, such as "10.0.0.0/8, 192.168.1.10".
+func ParseTrustedProxies(value string) (TrustedProxies, error) {
+	var proxies TrustedProxies
+	fields := strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
+	for _, field := range fields {
+		if prefix, err := netip.ParsePrefix(field); err == nil {
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(field)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or a CIDR range", field)
+		}
+		addr = addr.Unmap().WithZone("")
+		proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return proxies, nil
+}
+
+func (p TrustedProxies) trusts(addr netip.Addr) bool {
+	for _, prefix := range p {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientAddress returns the IP address r came from. When it came through
+// trusted proxies, that is the rightmost address in X-Forwarded-For that is
+// not a trusted proxy itself: the ones left of it were written by the
+// client and can be anything.
+func (p TrustedProxies) ClientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	addr = addr.Unmap().WithZone("")
+	if !p.trusts(addr) {
+		return addr.String()
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// A hop that is not an address cannot be told apart from
+			// anything else, so the last trusted proxy stands in for it.
+			break
+		}
+		addr = hop.Unmap().WithZone("")
+		if !p.trusts(addr) {
+			break
+		}
+	}
+	return addr.String()
+}
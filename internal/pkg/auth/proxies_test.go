@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8, 192.168.1.10 ::ffff:172.16.0.1,fd00::/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.0/8", "192.168.1.10/32", "172.16.0.1/32", "fd00::/8"}
+	if len(proxies) != len(want) {
+		t.Fatalf("got %v, want %v", proxies, want)
+	}
+	for i, prefix := range proxies {
+		if prefix.String() != want[i] {
+			t.Errorf("proxy %d: got %v, want %s", i, prefix, want[i])
+		}
+	}
+
+	if proxies, err := ParseTrustedProxies(""); err != nil || len(proxies) != 0 {
+		t.Errorf("ParseTrustedProxies(\"\") = %v, %v, want no proxies", proxies, err)
+	}
+	for _, value := range []string{"proxy.internal", "10.0.0.0/33", "10.0.0.1/8/8"} {
+		if _, err := ParseTrustedProxies(value); err == nil {
+			t.Errorf("ParseTrustedProxies(%q) succeeded", value)
+		}
+	}
+}
+
+func TestClientAddress(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		proxies    TrustedProxies
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{"direct", proxies, "203.0.113.7:5123", nil, "203.0.113.7"},
+		{"no trusted proxies", nil, "10.0.0.1:5123", []string{"203.0.113.7"}, "10.0.0.1"},
+		// Only trusted proxies get to say where a request came from.
+		{"untrusted sender", proxies, "198.51.100.1:5123", []string{"203.0.113.7"}, "198.51.100.1"},
+		{"through a proxy", proxies, "10.0.0.1:5123", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"through two proxies", proxies, "10.0.0.1:5123", []string{"203.0.113.7, 10.0.0.2"}, "203.0.113.7"},
+		{"several headers", proxies, "10.0.0.1:5123", []string{"203.0.113.7", "10.0.0.2"}, "203.0.113.7"},
+		// The client can put anything left of its own address.
+		{"forged hops", proxies, "10.0.0.1:5123", []string{"192.0.2.1, 203.0.113.7"}, "203.0.113.7"},
+		{"forged garbage", proxies, "10.0.0.1:5123", []string{"203.0.113.7, unknown, 10.0.0.2"}, "10.0.0.2"},
+		{"missing header", proxies, "10.0.0.1:5123", nil, "10.0.0.1"},
+		{"only proxies", proxies, "10.0.0.1:5123", []string{"10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"mapped IPv4", proxies, "[::ffff:10.0.0.1]:5123", []string{"::ffff:203.0.113.7"}, "203.0.113.7"},
+		{"IPv6", proxies, "[10::1]:5123", nil, "10::1"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		for _, value := range tt.forwarded {
+			r.Header.Add("X-Forwarded-For", value)
+		}
+		if got := tt.proxies.ClientAddress(r); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLoginThrottleAddresses(t *testing.T) {
+	ctx := context.Background()
+	fail := func(throttle *LoginThrottle, address string) {
+		t.Helper()
+		// Failures on ever-new accounts leave only the address to throttle.
+		for i := range DefaultAddressPolicy.FreeAttempts + 1 {
+			email := string(rune('a'+i)) + "@example.com"
+			if err := throttle.Fail(ctx, email, address); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	throttle := NewLoginThrottle(NewMemoryLoginAttempts(), DefaultAccountPolicy, DefaultAddressPolicy, nil)
+	fail(throttle, "203.0.113.7")
+	if wait, err := throttle.Check(ctx, "jane@example.com", "203.0.113.7"); err != nil || wait == 0 {
+		t.Errorf("Check from the failing address = %v, %v, want a delay", wait, err)
+	}
+	if wait, err := throttle.Check(ctx, "jane@example.com", "198.51.100.1"); err != nil || wait != 0 {
+		t.Errorf("Check from another address = %v, %v, want no delay", wait, err)
+	}
+
+	throttle = NewLoginThrottle(NewMemoryLoginAttempts(), DefaultAccountPolicy, ThrottlePolicy{}, nil)
+	fail(throttle, "203.0.113.7")
+	if wait, err := throttle.Check(ctx, "jane@example.com", "203.0.113.7"); err != nil || wait != 0 {
+		t.Errorf("Check without the address policy = %v, %v, want no delay", wait, err)
+	}
+}
+
+func TestLoginThrottleFromEnv(t *testing.T) {
+	t.Setenv("LOGIN_THROTTLE_STORE", "memory")
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/8")
+	t.Setenv("LOGIN_THROTTLE_ADDRESSES", "false")
+	throttle, err := LoginThrottleFromEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !throttle.address.off() || len(throttle.proxies) != 1 {
+		t.Errorf("got address policy %+v and proxies %v", throttle.address, throttle.proxies)
+	}
+
+	t.Setenv("LOGIN_THROTTLE_ADDRESSES", "sometimes")
+	if _, err := LoginThrottleFromEnv(nil); err == nil {
+		t.Error("invalid LOGIN_THROTTLE_ADDRESSES accepted")
+	}
+	t.Setenv("LOGIN_THROTTLE_ADDRESSES", "")
+	t.Setenv("TRUSTED_PROXIES", "proxy.internal")
+	if _, err := LoginThrottleFromEnv(nil); err == nil {
+		t.Error("invalid TRUSTED_PROXIES accepted")
+	}
+}
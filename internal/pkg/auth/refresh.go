@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+const (
+	// DefaultAccessTokenTTL is the lifetime of access tokens when
+	// ACCESS_TOKEN_TTL is not set.
+	DefaultAccessTokenTTL = 2 * time.Hour
+	// DefaultRefreshTokenTTL is the lifetime of refresh tokens when
+	// REFRESH_TOKEN_TTL is not set.
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+	// DefaultPasswordResetTTL is how long password reset tokens stay valid
+	// when PASSWORD_RESET_TTL is not set.
+	DefaultPasswordResetTTL = time.Hour
+	// DefaultAccountDeletionGrace is how long deleted accounts can still be
+	// restored when ACCOUNT_DELETION_GRACE is not set.
+	DefaultAccountDeletionGrace = 30 * 24 * time.Hour
+)
+
+// TokenLifetimes returns the lifetimes of access and refresh tokens, read
+// from ACCESS_TOKEN_TTL and REFRESH_TOKEN_TTL as Go durations such as "15m"
+// or "720h".
+func TokenLifetimes() (access, refresh time.Duration, err error) {
+	access, err = durationFromEnv("ACCESS_TOKEN_TTL", DefaultAccessTokenTTL)
+	if err != nil {
+		return 0, 0, err
+	}
+	refresh, err = durationFromEnv("REFRESH_TOKEN_TTL", DefaultRefreshTokenTTL)
+	if err != nil {
+		return 0, 0, err
+	}
+	return access, refresh, nil
+}
+
+// PasswordResetTTL returns how long password reset tokens stay valid, read
+// from PASSWORD_RESET_TTL.
+func PasswordResetTTL() (time.Duration, error) {
+	return durationFromEnv("PASSWORD_RESET_TTL", DefaultPasswordResetTTL)
+}
+
+// AccountDeletionGrace returns how long after a user deletes their account
+// it is deleted for good, read from ACCOUNT_DELETION_GRACE. Logging in
+// before then restores the account.
+func AccountDeletionGrace() (time.Duration, error) {
+	return durationFromEnv("ACCOUNT_DELETION_GRACE", DefaultAccountDeletionGrace)
+}
+
+func durationFromEnv(name string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value %q: %w", name, value, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid %s value %q: must be positive", name, value)
+	}
+	return d, nil
+}
+
+// NewRefreshToken returns a random opaque refresh token for userID together
+// with the record to store for it, which starts a new token family and only
+// carries the hash of the token.
+func NewRefreshToken(userID int) (string, models.RefreshToken, error) {
+	_, ttl, err := TokenLifetimes()
+	if err != nil {
+		return "", models.RefreshToken{}, err
+	}
+	token, hash, err := NewOpaqueToken()
+	if err != nil {
+		return "", models.RefreshToken{}, err
+	}
+	family, err := randomString(16)
+	if err != nil {
+		return "", models.RefreshToken{}, err
+	}
+	record := models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  family,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	return token, record, nil
+}
@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+// RevocationStore persists revoked access tokens. store.Store implements
+// it.
+type RevocationStore interface {
+	RevokeAccessTokens(ctx context.Context, revocation *models.TokenRevocation) error
+	ListTokenRevocations(ctx context.Context, since time.Time) ([]models.TokenRevocation, error)
+	PruneTokenRevocations(ctx context.Context, before time.Time) error
+}
+
+// syncOverlap is how far before the previous sync a sync looks for new
+// revocations, so that ones committed late or by a server with a slightly
+// different clock are not missed.
+const syncOverlap = time.Minute
+
+// issuedAtPrecision is the precision of the issue times of access tokens.
+// Whole seconds, the default of the jwt package, would let every token
+// issued earlier in the second of a revocation through.
+const issuedAtPrecision = time.Microsecond
+
+// revocationSlack is how much earlier than a user revocation a token has to
+// be issued to be covered by it. It makes up for truncating the issue time
+// when the token is signed, for the error of parsing it back as a float and
+// for PostgreSQL rounding the revocation time, so that tokens issued right
+// after a revocation are never caught by it.
+const revocationSlack = 3 * issuedAtPrecision
+
+func init() {
+	jwt.TimePrecision = issuedAtPrecision
+}
+
+// Revocations keeps the unexpired revocations of a RevocationStore in memory
+// so AuthMiddleware can check every request without a database round trip.
+// Revocations made through it apply immediately; ones made by other servers
+// are picked up every syncInterval, when expired revocations are pruned
+// from the cache and the store as well.
+type Revocations struct {
+	store        RevocationStore
+	syncInterval time.Duration
+
+	mu sync.Mutex
+	// tokens maps revoked token IDs to when the revocation expires.
+	tokens map[string]time.Time
+	// users holds the revocations of all tokens of a user issued before a
+	// cutoff.
+	users    map[int]userRevocation
+	syncedAt time.Time
+}
+
+type userRevocation struct {
+	issuedBefore time.Time
+	expiresAt    time.Time
+}
+
+func NewRevocations(store RevocationStore, syncInterval time.Duration) *Revocations {
+	return &Revocations{
+		store:        store,
+		syncInterval: syncInterval,
+		tokens:       make(map[string]time.Time),
+		users:        make(map[int]userRevocation),
+	}
+}
+
+// RevokeToken revokes the access token claims were read from.
+func (r *Revocations) RevokeToken(ctx context.Context, claims *UserClaims) error {
+	revocation := models.TokenRevocation{
+		TokenID:   claims.ID,
+		UserID:    claims.UserID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}
+	return r.revoke(ctx, &revocation)
+}
+
+// RevokeUserTokens revokes every access token issued to userID so far.
+func (r *Revocations) RevokeUserTokens(ctx context.Context, userID int) error {
+	ttl, _, err := TokenLifetimes()
+	if err != nil {
+		return err
+	}
+	revocation := models.TokenRevocation{
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(ttl + time.Second),
+	}
+	return r.revoke(ctx, &revocation)
+}
+
+func (r *Revocations) revoke(ctx context.Context, revocation *models.TokenRevocation) error {
+	if err := r.store.RevokeAccessTokens(ctx, revocation); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.add(*revocation)
+	return nil
+}
+
+// IsRevoked reports whether the token claims were read from has been
+// revoked.
+func (r *Revocations) IsRevoked(ctx context.Context, claims *UserClaims) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.syncedAt) >= r.syncInterval {
+		if err := r.sync(ctx); err != nil {
+			return false, err
+		}
+	}
+
+	now := time.Now()
+	if expiresAt, ok := r.tokens[claims.ID]; ok && expiresAt.After(now) {
+		return true, nil
+	}
+	if revocation, ok := r.users[claims.UserID]; ok && revocation.expiresAt.After(now) {
+		// Tokens issued within revocationSlack before the revocation are
+		// let through, so the token a password change hands out right
+		// after revoking the others stays valid.
+		if claims.IssuedAt == nil || claims.IssuedAt.Before(revocation.issuedBefore.Add(-revocationSlack)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// sync loads the revocations made since the previous sync and prunes the
+// expired ones. Callers must hold r.mu.
+func (r *Revocations) sync(ctx context.Context) error {
+	started := time.Now()
+	since := time.Time{}
+	if !r.syncedAt.IsZero() {
+		since = r.syncedAt.Add(-syncOverlap)
+	}
+	revocations, err := r.store.ListTokenRevocations(ctx, since)
+	if err != nil {
+		return fmt.Errorf("failed to load token revocations: %w", err)
+	}
+	for _, revocation := range revocations {
+		r.add(revocation)
+	}
+
+	for tokenID, expiresAt := range r.tokens {
+		if !expiresAt.After(started) {
+			delete(r.tokens, tokenID)
+		}
+	}
+	for userID, revocation := range r.users {
+		if !revocation.expiresAt.After(started) {
+			delete(r.users, userID)
+		}
+	}
+	if err := r.store.PruneTokenRevocations(ctx, started); err != nil {
+		return err
+	}
+	r.syncedAt = started
+	return nil
+}
+
+// add caches revocation. Callers must hold r.mu.
+func (r *Revocations) add(revocation models.TokenRevocation) {
+	if revocation.TokenID != "" {
+		r.tokens[revocation.TokenID] = revocation.ExpiresAt
+		return
+	}
+	// A later cutoff covers every token an earlier one did.
+	if current, ok := r.users[revocation.UserID]; ok && !revocation.CreatedAt.After(current.issuedBefore) {
+		return
+	}
+	r.users[revocation.UserID] = userRevocation{
+		issuedBefore: revocation.CreatedAt,
+		expiresAt:    revocation.ExpiresAt,
+	}
+}
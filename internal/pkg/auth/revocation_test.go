@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/store"
+)
+
+// issuedClaims returns the claims of token tokenID of userID, issued at
+// issuedAt and valid for an hour.
+func issuedClaims(userID int, tokenID string, issuedAt time.Time) *UserClaims {
+	return &UserClaims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(issuedAt.Add(time.Hour)),
+		},
+	}
+}
+
+func expectRevoked(t *testing.T, r *Revocations, claims *UserClaims, want bool) {
+	t.Helper()
+	revoked, err := r.IsRevoked(context.Background(), claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked != want {
+		t.Errorf("token %s of user %d issued at %v: revoked %v, want %v", claims.ID, claims.UserID, claims.IssuedAt, revoked, want)
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	r := NewRevocations(store.NewMemoryStore(), time.Hour)
+	ctx := context.Background()
+	issued := time.Now().Add(-time.Minute)
+	first := issuedClaims(1, "first", issued)
+	second := issuedClaims(1, "second", issued)
+
+	expectRevoked(t, r, first, false)
+	if err := r.RevokeToken(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	expectRevoked(t, r, first, true)
+	expectRevoked(t, r, second, false)
+
+	// Revocations of tokens that already expired are not kept.
+	old := issuedClaims(1, "old", time.Now().Add(-2*time.Hour))
+	if err := r.RevokeToken(ctx, old); err != nil {
+		t.Fatal(err)
+	}
+	expectRevoked(t, r, old, false)
+}
+
+func TestRevokeUserTokens(t *testing.T) {
+	r := NewRevocations(store.NewMemoryStore(), time.Hour)
+	before := issuedClaims(1, "before", time.Now().Add(-time.Minute))
+	other := issuedClaims(2, "other", time.Now().Add(-time.Minute))
+
+	if err := r.RevokeUserTokens(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	expectRevoked(t, r, before, true)
+	expectRevoked(t, r, other, false)
+	// Logging in again after the revocation works.
+	expectRevoked(t, r, issuedClaims(1, "after", time.Now().Add(time.Minute)), false)
+	// Tokens without an issue time cannot prove they are newer.
+	undated := issuedClaims(1, "undated", time.Now())
+	undated.IssuedAt = nil
+	expectRevoked(t, r, undated, true)
+}
+
+func TestRevocationsSync(t *testing.T) {
+	shared := store.NewMemoryStore()
+	ctx := context.Background()
+	// Two servers on the same store, the second one syncing on every check.
+	first := NewRevocations(shared, time.Hour)
+	second := NewRevocations(shared, 0)
+	token := issuedClaims(1, "token", time.Now().Add(-time.Minute))
+	user := issuedClaims(2, "user", time.Now().Add(-time.Minute))
+
+	expectRevoked(t, second, token, false)
+	if err := first.RevokeToken(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.RevokeUserTokens(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	expectRevoked(t, second, token, true)
+	expectRevoked(t, second, user, true)
+	expectRevoked(t, second, issuedClaims(3, "unrelated", time.Now()), false)
+
+	// A fresh server loads every unexpired revocation.
+	third := NewRevocations(shared, time.Hour)
+	expectRevoked(t, third, token, true)
+	expectRevoked(t, third, user, true)
+}
+
+func TestRevokeUserTokensPrecision(t *testing.T) {
+	useTestKeyring(t)
+	r := NewRevocations(store.NewMemoryStore(), time.Hour)
+	if err := r.RevokeUserTokens(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	cutoff := r.users[1].issuedBefore
+
+	// The claims go through a signed token, where the issue time is a
+	// fraction of seconds.
+	signed := func(issuedAt time.Time) *UserClaims {
+		t.Helper()
+		token, err := keyring.Sign(issuedClaims(1, "token", issuedAt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims, err := ValidateToken(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return claims
+	}
+	for _, ago := range []time.Duration{time.Second, 500 * time.Millisecond, 10 * time.Microsecond} {
+		expectRevoked(t, r, signed(cutoff.Add(-ago)), true)
+	}
+	for _, later := range []time.Duration{0, 999 * time.Nanosecond, time.Millisecond} {
+		expectRevoked(t, r, signed(cutoff.Add(later)), false)
+	}
+
+	// The token a password change issues right after revoking the others
+	// stays valid.
+	token, err := GenerateToken(models.ListCurator{ID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ValidateToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRevoked(t, r, claims, false)
+}
@@ -0,0 +1,29 @@
+package auth
+
+// The scopes access tokens and API keys grant. Routes declare the ones
+// they need with RequireScopes.
+const (
+	// ScopeTodosRead reads lists and their members, todos, their checklists
+	// and tags.
+	ScopeTodosRead = "todos:read"
+	// ScopeTodosWrite creates, changes and deletes todos, their checklists
+	// and tags.
+	ScopeTodosWrite = "todos:write"
+	// ScopeListsAdmin creates, renames, deletes and shares lists.
+	ScopeListsAdmin = "lists:admin"
+	// ScopeAccountManage changes the account itself.
+	ScopeAccountManage = "account:manage"
+)
+
+// AllScopes lists every scope.
+var AllScopes = []string{ScopeTodosRead, ScopeTodosWrite, ScopeListsAdmin, ScopeAccountManage}
+
+// ValidScope reports whether scope is one of AllScopes.
+func ValidScope(scope string) bool {
+	for _, known := range AllScopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/store"
+)
+
+// useTestKeyring makes the package sign tokens with an HMAC key for the
+// duration of the test.
+func useTestKeyring(t *testing.T) {
+	t.Helper()
+	k := NewKeyring()
+	k.AddHMAC("", []byte("scope test secret"))
+	if err := k.SetSigningKey(""); err != nil {
+		t.Fatal(err)
+	}
+	previous := keyring
+	UseKeyring(k)
+	t.Cleanup(func() { UseKeyring(previous) })
+}
+
+// signedToken returns an access token for user 1 granting scope.
+func signedToken(t *testing.T, scope string) string {
+	t.Helper()
+	token, err := keyring.Sign(UserClaims{
+		UserID:        1,
+		EmailVerified: true,
+		Scope:         scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        "token",
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// serveAuthenticated sends a request authenticated with token to a handler
+// wrapped in AuthMiddleware with options.
+func serveAuthenticated(token string, options ...MiddlewareOption) *httptest.ResponseRecorder {
+	handler := AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}, options...)
+	r := httptest.NewRequest(http.MethodDelete, "/todos/1", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestHasScope(t *testing.T) {
+	tests := []struct {
+		claims UserClaims
+		scope  string
+		want   bool
+	}{
+		{UserClaims{Scope: "todos:read todos:write"}, ScopeTodosWrite, true},
+		{UserClaims{Scope: "todos:read"}, ScopeTodosWrite, false},
+		{UserClaims{Scope: "todos:read"}, "todos", false},
+		// Tokens from before scopes existed keep full access.
+		{UserClaims{}, ScopeAccountManage, true},
+		// API keys always name their scopes.
+		{UserClaims{APIKeyID: 1}, ScopeTodosRead, false},
+	}
+	for _, tt := range tests {
+		if got := tt.claims.HasScope(tt.scope); got != tt.want {
+			t.Errorf("%+v HasScope(%s) = %v, want %v", tt.claims, tt.scope, got, tt.want)
+		}
+	}
+}
+
+func TestRequireScopes(t *testing.T) {
+	useTestKeyring(t)
+	write := RequireScopes(ScopeTodosWrite)
+
+	if w := serveAuthenticated(signedToken(t, "todos:read todos:write"), write); w.Code != http.StatusNoContent {
+		t.Errorf("token with the scope: got %d %s", w.Code, w.Body)
+	}
+	if w := serveAuthenticated(signedToken(t, ""), write); w.Code != http.StatusNoContent {
+		t.Errorf("token without scopes: got %d %s", w.Code, w.Body)
+	}
+
+	w := serveAuthenticated(signedToken(t, "todos:read"), write, RequireScopes(ScopeListsAdmin))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("token lacking the scopes: got %d %s", w.Code, w.Body)
+	}
+	want := `Bearer error="insufficient_scope", scope="todos:write lists:admin"`
+	if got := w.Header().Get("WWW-Authenticate"); got != want {
+		t.Errorf("WWW-Authenticate = %q, want %q", got, want)
+	}
+
+	// Routes without requirements take any valid token.
+	if w := serveAuthenticated(signedToken(t, "todos:read")); w.Code != http.StatusNoContent {
+		t.Errorf("route without scopes: got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestRequireScopesAPIKey(t *testing.T) {
+	keys := store.NewMemoryStore()
+	previous := apiKeys
+	UseAPIKeys(keys)
+	t.Cleanup(func() { UseAPIKeys(previous) })
+
+	readOnly, record, err := NewAPIKey(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record.Name = "read-only"
+	record.Scopes = []string{ScopeTodosRead}
+	if err := keys.CreateAPIKey(context.Background(), &record); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := serveAuthenticated(readOnly, RequireScopes(ScopeTodosRead)); w.Code != http.StatusNoContent {
+		t.Errorf("key with the scope: got %d %s", w.Code, w.Body)
+	}
+	w := serveAuthenticated(readOnly, RequireScopes(ScopeTodosWrite))
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Header().Get("WWW-Authenticate"), "insufficient_scope") {
+		t.Errorf("key lacking the scope: got %d %s", w.Code, w.Body)
+	}
+	if w := serveAuthenticated(readOnly, TokensOnly); w.Code != http.StatusForbidden {
+		t.Errorf("key on a tokens-only route: got %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,319 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoginAttemptStore persists the failed logins LoginThrottle counts. Keys
+// name an account or a client address. store.SQLStore implements it for
+// deployments running several servers, NewMemoryLoginAttempts for single
+// servers.
+type LoginAttemptStore interface {
+	// LoginFailures returns how many failures were counted for key and when
+	// the last one happened. Keys without failures return zero values.
+	LoginFailures(ctx context.Context, key string) (int, time.Time, error)
+	// RecordLoginFailure counts a failure for key at at. A count whose last
+	// failure happened more than resetAfter before at starts over.
+	RecordLoginFailure(ctx context.Context, key string, at time.Time, resetAfter time.Duration) error
+	// ResetLoginFailures forgets the failures of key.
+	ResetLoginFailures(ctx context.Context, key string) error
+	// PruneLoginFailures forgets the keys whose last failure happened before
+	// before.
+	PruneLoginFailures(ctx context.Context, before time.Time) error
+}
+
+// pruneInterval is how often LoginThrottle forgets the failures that no
+// longer count.
+const pruneInterval = 10 * time.Minute
+
+// ThrottlePolicy decides how long logins are refused after a number of
+// consecutive failures.
+type ThrottlePolicy struct {
+	// FreeAttempts is the number of failures allowed without delay.
+	FreeAttempts int
+	// BaseDelay is the delay after the first failure past FreeAttempts. It
+	// doubles with every further failure up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockoutAfter failures lock logins out for LockoutDuration. Zero turns
+	// the lockout off.
+	LockoutAfter    int
+	LockoutDuration time.Duration
+	// ResetAfter is how long after the last failure the count starts over.
+	// It should be longer than LockoutDuration.
+	ResetAfter time.Duration
+}
+
+// off reports whether p is the zero policy, which throttles nothing.
+func (p ThrottlePolicy) off() bool {
+	return p == ThrottlePolicy{}
+}
+
+// Delay returns how long after the last of failures logins are refused.
+func (p ThrottlePolicy) Delay(failures int) time.Duration {
+	if p.LockoutAfter > 0 && failures >= p.LockoutAfter {
+		return p.LockoutDuration
+	}
+	if failures <= p.FreeAttempts {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+const (
+	// DefaultLoginMaxFailures is the number of failed logins that lock an
+	// account out when LOGIN_MAX_FAILURES is not set.
+	DefaultLoginMaxFailures = 10
+	// DefaultLoginLockout is how long accounts stay locked out when
+	// LOGIN_LOCKOUT_DURATION is not set.
+	DefaultLoginLockout = 15 * time.Minute
+)
+
+// DefaultAccountPolicy throttles the logins of one account.
+// LoginThrottleFromEnv overrides its lockout.
+var DefaultAccountPolicy = ThrottlePolicy{
+	FreeAttempts:    3,
+	BaseDelay:       time.Second,
+	MaxDelay:        5 * time.Minute,
+	LockoutAfter:    DefaultLoginMaxFailures,
+	LockoutDuration: DefaultLoginLockout,
+	ResetAfter:      time.Hour,
+}
+
+// DefaultAddressPolicy throttles the logins from one client address. It is
+// more lenient than DefaultAccountPolicy since many users can share an
+// address behind NAT. LoginThrottleFromEnv turns it off when
+// LOGIN_THROTTLE_ADDRESSES is false.
+var DefaultAddressPolicy = ThrottlePolicy{
+	FreeAttempts:    20,
+	BaseDelay:       time.Second,
+	MaxDelay:        5 * time.Minute,
+	LockoutAfter:    100,
+	LockoutDuration: DefaultLoginLockout,
+	ResetAfter:      time.Hour,
+}
+
+// LoginThrottle counts failed logins per account and per client address
+// and refuses further attempts for a while, which grows exponentially with
+// the failures until the account or address is locked out. Accounts are
+// identified by the email address the login was tried with, whether a user
+// has it or not, so throttling does not tell which addresses are
+// registered. Client addresses are told by ClientAddress.
+type LoginThrottle struct {
+	store   LoginAttemptStore
+	account ThrottlePolicy
+	address ThrottlePolicy
+	proxies TrustedProxies
+
+	mu       sync.Mutex
+	prunedAt time.Time
+}
+
+// NewLoginThrottle returns a throttle counting failures in store. The zero
+// address policy throttles by account only. Requests coming through
+// proxies have their client address read from X-Forwarded-For.
+func NewLoginThrottle(store LoginAttemptStore, account, address ThrottlePolicy, proxies TrustedProxies) *LoginThrottle {
+	return &LoginThrottle{store: store, account: account, address: address, proxies: proxies}
+}
+
+// LoginThrottleFromEnv builds the throttle configured in the environment.
+// LOGIN_THROTTLE_STORE picks where failures are counted: "database" (the
+// default) counts them in the database, shared by every server, and "memory"
+// in the memory of this server. LOGIN_MAX_FAILURES and
+// LOGIN_LOCKOUT_DURATION set when and for how long accounts are locked out.
+// TRUSTED_PROXIES lists the reverse proxies in front of the server, see
+// ParseTrustedProxies, and LOGIN_THROTTLE_ADDRESSES=false stops throttling
+// client addresses for deployments that cannot tell their clients apart.
+func LoginThrottleFromEnv(database LoginAttemptStore) (*LoginThrottle, error) {
+	var store LoginAttemptStore
+	switch value := os.Getenv("LOGIN_THROTTLE_STORE"); value {
+	case "", "database":
+		store = database
+	case "memory":
+		store = NewMemoryLoginAttempts()
+	default:
+		return nil, fmt.Errorf("invalid LOGIN_THROTTLE_STORE value %q (expected database or memory)", value)
+	}
+
+	account := DefaultAccountPolicy
+	if value := os.Getenv("LOGIN_MAX_FAILURES"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= account.FreeAttempts {
+			return nil, fmt.Errorf("invalid LOGIN_MAX_FAILURES value %q: must be a number above %d", value, account.FreeAttempts)
+		}
+		account.LockoutAfter = n
+	}
+	lockout, err := durationFromEnv("LOGIN_LOCKOUT_DURATION", DefaultLoginLockout)
+	if err != nil {
+		return nil, err
+	}
+	account.LockoutDuration = lockout
+	account.ResetAfter = max(account.ResetAfter, 2*lockout)
+
+	address := DefaultAddressPolicy
+	if value := os.Getenv("LOGIN_THROTTLE_ADDRESSES"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LOGIN_THROTTLE_ADDRESSES value %q (expected true or false)", value)
+		}
+		if !enabled {
+			address = ThrottlePolicy{}
+		}
+	}
+	proxies, err := ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+	return NewLoginThrottle(store, account, address, proxies), nil
+}
+
+// ClientAddress returns the address r came from as the throttle counts it.
+func (t *LoginThrottle) ClientAddress(r *http.Request) string {
+	return t.proxies.ClientAddress(r)
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(email)
+}
+
+func addressKey(address string) string {
+	return "ip:" + address
+}
+
+// Check returns how long logins to email from address are still refused,
+// or zero when they are allowed.
+func (t *LoginThrottle) Check(ctx context.Context, email, address string) (time.Duration, error) {
+	wait, err := t.wait(ctx, accountKey(email), t.account)
+	if err != nil {
+		return 0, err
+	}
+	addressWait, err := t.wait(ctx, addressKey(address), t.address)
+	if err != nil {
+		return 0, err
+	}
+	return max(wait, addressWait), nil
+}
+
+func (t *LoginThrottle) wait(ctx context.Context, key string, policy ThrottlePolicy) (time.Duration, error) {
+	if policy.off() {
+		return 0, nil
+	}
+	failures, last, err := t.store.LoginFailures(ctx, key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check login failures: %w", err)
+	}
+	if failures == 0 || time.Since(last) >= policy.ResetAfter {
+		return 0, nil
+	}
+	return max(policy.Delay(failures)-time.Since(last), 0), nil
+}
+
+// Fail counts a failed login to email from address.
+func (t *LoginThrottle) Fail(ctx context.Context, email, address string) error {
+	now := time.Now()
+	if err := t.store.RecordLoginFailure(ctx, accountKey(email), now, t.account.ResetAfter); err != nil {
+		return fmt.Errorf("failed to record login failure: %w", err)
+	}
+	if !t.address.off() {
+		if err := t.store.RecordLoginFailure(ctx, addressKey(address), now, t.address.ResetAfter); err != nil {
+			return fmt.Errorf("failed to record login failure: %w", err)
+		}
+	}
+	return t.prune(ctx, now)
+}
+
+// prune forgets the failures older than the longest ResetAfter, at most
+// once every pruneInterval.
+func (t *LoginThrottle) prune(ctx context.Context, now time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if now.Sub(t.prunedAt) < pruneInterval {
+		return nil
+	}
+	before := now.Add(-max(t.account.ResetAfter, t.address.ResetAfter))
+	if err := t.store.PruneLoginFailures(ctx, before); err != nil {
+		return fmt.Errorf("failed to prune login failures: %w", err)
+	}
+	t.prunedAt = now
+	return nil
+}
+
+// Unlock forgets the failed logins to email, after a successful login or a
+// password reset. The failures of client addresses are left to expire so
+// an attacker cannot clear them by logging in to their own account.
+func (t *LoginThrottle) Unlock(ctx context.Context, email string) error {
+	if err := t.store.ResetLoginFailures(ctx, accountKey(email)); err != nil {
+		return fmt.Errorf("failed to reset login failures: %w", err)
+	}
+	return nil
+}
+
+// MemoryLoginAttempts is a LoginAttemptStore that keeps the failures in
+// process memory. It suits deployments with a single server.
+type MemoryLoginAttempts struct {
+	mu       sync.Mutex
+	failures map[string]loginFailures
+}
+
+type loginFailures struct {
+	count int
+	last  time.Time
+}
+
+func NewMemoryLoginAttempts() *MemoryLoginAttempts {
+	return &MemoryLoginAttempts{failures: make(map[string]loginFailures)}
+}
+
+func (m *MemoryLoginAttempts) LoginFailures(_ context.Context, key string) (int, time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f := m.failures[key]
+	return f.count, f.last, nil
+}
+
+func (m *MemoryLoginAttempts) RecordLoginFailure(_ context.Context, key string, at time.Time, resetAfter time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f := m.failures[key]
+	if f.last.Before(at.Add(-resetAfter)) {
+		f.count = 0
+	}
+	f.count++
+	f.last = at
+	m.failures[key] = f
+	return nil
+}
+
+func (m *MemoryLoginAttempts) ResetLoginFailures(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.failures, key)
+	return nil
+}
+
+func (m *MemoryLoginAttempts) PruneLoginFailures(_ context.Context, before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, f := range m.failures {
+		if f.last.Before(before) {
+			delete(m.failures, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+const (
+	// MFAChallengeTTL is how long a login challenge can be completed with a
+	// code after the password was entered.
+	MFAChallengeTTL = 5 * time.Minute
+	// MaxMFAAttempts is how many wrong codes a login challenge takes before
+	// it is discarded and the user has to enter their password again.
+	MaxMFAAttempts = 5
+	// RecoveryCodeCount is how many recovery codes a user gets at a time.
+	RecoveryCodeCount = 10
+)
+
+// recoveryCodeAlphabet leaves out the letters and digits that are easily
+// mistaken for one another.
+const recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// TOTPIssuer returns the name authenticator apps list accounts under, read
+// from TOTP_ISSUER.
+func TOTPIssuer() string {
+	if issuer := os.Getenv("TOTP_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "Todo API"
+}
+
+// NewRecoveryCodes returns RecoveryCodeCount random recovery codes such as
+// "k3m9-x2pq-7trw" together with the hashes to store for them.
+func NewRecoveryCodes() (codes, hashes []string, err error) {
+	for range RecoveryCodeCount {
+		b := make([]byte, 12)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		var code strings.Builder
+		for i, c := range b {
+			if i > 0 && i%4 == 0 {
+				code.WriteByte('-')
+			}
+			// The modulo bias of a 31 letter alphabet is negligible here.
+			code.WriteByte(recoveryCodeAlphabet[int(c)%len(recoveryCodeAlphabet)])
+		}
+		codes = append(codes, code.String())
+		hashes = append(hashes, HashRecoveryCode(code.String()))
+	}
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode returns the hash a recovery code is stored by. Case,
+// dashes and spaces are ignored so codes can be typed in loosely.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return HashToken(code)
+}
+
+// NewMFAChallenge returns a random login challenge token for userID
+// together with the record to store for it.
+func NewMFAChallenge(userID int) (string, models.MFAChallenge, error) {
+	token, hash, err := NewOpaqueToken()
+	if err != nil {
+		return "", models.MFAChallenge{}, err
+	}
+	record := models.MFAChallenge{
+		UserID:    userID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(MFAChallengeTTL),
+	}
+	return token, record, nil
+}
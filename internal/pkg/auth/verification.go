@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// VerificationPolicy decides what users may do before they verify their
+// email address.
+type VerificationPolicy string
+
+const (
+	// PolicyNone does not restrict unverified accounts.
+	PolicyNone VerificationPolicy = "none"
+	// PolicyReadOnly lets unverified users log in but only read.
+	PolicyReadOnly VerificationPolicy = "read-only"
+	// PolicyBlockLogin keeps unverified users from logging in.
+	PolicyBlockLogin VerificationPolicy = "block-login"
+)
+
+// DefaultEmailVerificationTTL is how long email verification tokens stay
+// valid when EMAIL_VERIFICATION_TTL is not set.
+const DefaultEmailVerificationTTL = 48 * time.Hour
+
+// verificationPolicy is enforced by AuthMiddleware once
+// UseVerificationPolicy is called.
+var verificationPolicy = PolicyNone
+
+// UseVerificationPolicy makes AuthMiddleware and the login endpoints
+// enforce p. It must be called before the server starts.
+func UseVerificationPolicy(p VerificationPolicy) {
+	verificationPolicy = p
+}
+
+// CurrentVerificationPolicy returns the policy passed to
+// UseVerificationPolicy.
+func CurrentVerificationPolicy() VerificationPolicy {
+	return verificationPolicy
+}
+
+// VerificationPolicyFromEnv reads EMAIL_VERIFICATION_POLICY, which defaults
+// to none.
+func VerificationPolicyFromEnv() (VerificationPolicy, error) {
+	switch p := VerificationPolicy(os.Getenv("EMAIL_VERIFICATION_POLICY")); p {
+	case "":
+		return PolicyNone, nil
+	case PolicyNone, PolicyReadOnly, PolicyBlockLogin:
+		return p, nil
+	default:
+		return "", fmt.Errorf("invalid EMAIL_VERIFICATION_POLICY value %q (expected none, read-only or block-login)", p)
+	}
+}
+
+// EmailVerificationTTL returns how long email verification tokens stay
+// valid, read from EMAIL_VERIFICATION_TTL.
+func EmailVerificationTTL() (time.Duration, error) {
+	return durationFromEnv("EMAIL_VERIFICATION_TTL", DefaultEmailVerificationTTL)
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
@@ -1,23 +1,91 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 )
 
+// Supported values of the DB_DRIVER environment variable.
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// defaultSQLitePath is the database file used when DB_DRIVER is sqlite and
+// DB_CONNECTION_STRING is not set.
+const defaultSQLitePath = "todo.db"
+
 var DB *sql.DB
 
+// Driver is the DB_DRIVER that DB was opened with.
+var Driver string
+
+// InitDB connects to the database and, unless DB_AUTO_MIGRATE is set to
+// false, applies every pending migration.
 func InitDB() error {
-	connStr := os.Getenv("DB_CONNECTION_STRING")
-	if connStr == "" {
-		log.Fatal("Error: DB_CONNECTION_STRING environment variable not set.")
+	if err := Open(); err != nil {
+		return err
+	}
+
+	autoMigrate := true
+	if value := os.Getenv("DB_AUTO_MIGRATE"); value != "" {
+		var err error
+		autoMigrate, err = strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid DB_AUTO_MIGRATE value %q: %w", value, err)
+		}
+	}
+	if !autoMigrate {
+		return nil
+	}
+
+	migrator, err := NewMigrator(DB, Driver)
+	if err != nil {
+		return err
+	}
+	applied, err := migrator.Up(context.Background())
+	if err != nil {
+		return err
+	}
+	if applied > 0 {
+		fmt.Printf("Applied %d database migration(s)\n", applied)
 	}
+	return nil
+}
+
+// Open connects to the database selected by DB_DRIVER and
+// DB_CONNECTION_STRING without touching its schema.
+func Open() error {
+	Driver = strings.ToLower(os.Getenv("DB_DRIVER"))
+	if Driver == "" {
+		Driver = DriverPostgres
+	}
+	connStr := os.Getenv("DB_CONNECTION_STRING")
+
 	var err error
-	DB, err = sql.Open("postgres", connStr)
+	switch Driver {
+	case DriverPostgres:
+		if connStr == "" {
+			log.Fatal("Error: DB_CONNECTION_STRING environment variable not set.")
+		}
+		DB, err = sql.Open("postgres", connStr)
+	case DriverSQLite, "sqlite3":
+		Driver = DriverSQLite
+		if connStr == "" {
+			connStr = defaultSQLitePath
+		}
+		DB, err = openSQLite(connStr)
+	default:
+		return fmt.Errorf("unsupported DB_DRIVER %q (expected %q or %q)", Driver, DriverPostgres, DriverSQLite)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to open database connection: %w", err)
 	}
@@ -27,6 +95,27 @@ func InitDB() error {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	if Driver == DriverSQLite {
+		fmt.Println("Successfully opened SQLite database!")
+		return nil
+	}
+
 	fmt.Println("Successfully connected to PostgreSQL!")
 	return nil
 }
+
+// openSQLite opens the database file at path with foreign keys enforced.
+// SQLite only allows one writer at a time, so the pool is limited to a
+// single connection instead of failing with "database is locked".
+func openSQLite(path string) (*sql.DB, error) {
+	dsn := path
+	if !strings.Contains(dsn, "?") {
+		dsn += "?_foreign_keys=on&_busy_timeout=5000"
+	}
+	conn, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetMaxOpenConns(1)
+	return conn, nil
+}
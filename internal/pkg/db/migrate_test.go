@@ -0,0 +1,287 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Kwagmire/go-todo-api/migrations"
+)
+
+// openTestSQLite opens a fresh SQLite database like Open does.
+func openTestSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	conn, err := openSQLite(filepath.Join(t.TempDir(), "todo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// testMigrator returns a migrator that applies the scripts in files to conn.
+func testMigrator(t *testing.T, conn *sql.DB, driver string, files fstest.MapFS) *Migrator {
+	t.Helper()
+	loaded, err := LoadMigrations(files, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Migrator{db: conn, driver: driver, migrations: loaded}
+}
+
+// tables returns the names of the tables in the SQLite database conn.
+func tables(t *testing.T, conn *sql.DB) map[string]bool {
+	t.Helper()
+	rows, err := conn.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	names := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return names
+}
+
+func script(sql string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(sql)}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	files := fstest.MapFS{
+		"0002_tags.up.sql":            script("CREATE TABLE tags (id INTEGER);"),
+		"0002_tags.down.sql":          script("DROP TABLE tags;"),
+		"0001_create_tables.up.sql":   script("CREATE TABLE users (id INTEGER);"),
+		"0001_create_tables.down.sql": script("DROP TABLE users;"),
+		"0010_no_down.up.sql":         script("SELECT 1;"),
+		"README.md":                   script("not a migration"),
+	}
+	loaded, err := LoadMigrations(files, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		version int
+		name    string
+		down    bool
+	}{
+		{1, "create_tables", true},
+		{2, "tags", true},
+		{10, "no_down", false},
+	}
+	if len(loaded) != len(want) {
+		t.Fatalf("got %d migrations, want %d: %+v", len(loaded), len(want), loaded)
+	}
+	for i, w := range want {
+		m := loaded[i]
+		if m.Version != w.version || m.Name != w.name || m.Up == "" || (m.Down != "") != w.down {
+			t.Errorf("migration %d: got %+v, want version %d named %s", i, m, w.version, w.name)
+		}
+	}
+}
+
+func TestLoadMigrationsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{"missing version", fstest.MapFS{"tags.up.sql": script("SELECT 1;")}},
+		{"version zero", fstest.MapFS{"0000_tags.up.sql": script("SELECT 1;")}},
+		{"missing name", fstest.MapFS{"0001.up.sql": script("SELECT 1;")}},
+		{"down script only", fstest.MapFS{"0001_tags.down.sql": script("SELECT 1;")}},
+		{"conflicting names", fstest.MapFS{
+			"0001_tags.up.sql":  script("SELECT 1;"),
+			"0001_lists.up.sql": script("SELECT 1;"),
+		}},
+	}
+	for _, tt := range tests {
+		if _, err := LoadMigrations(tt.files, "."); err == nil {
+			t.Errorf("%s: LoadMigrations succeeded", tt.name)
+		}
+	}
+	if _, err := LoadMigrations(fstest.MapFS{}, "mysql"); err == nil {
+		t.Error("LoadMigrations of a missing directory succeeded")
+	}
+}
+
+func TestMigratorUpAndDown(t *testing.T) {
+	conn := openTestSQLite(t)
+	m := testMigrator(t, conn, DriverSQLite, fstest.MapFS{
+		"0001_users.up.sql":   script("CREATE TABLE users (id INTEGER PRIMARY KEY);"),
+		"0001_users.down.sql": script("DROP TABLE users;"),
+		"0002_tags.up.sql":    script("CREATE TABLE tags (id INTEGER PRIMARY KEY);\nCREATE TABLE todo_tags (tag_id INTEGER);"),
+		"0002_tags.down.sql":  script("DROP TABLE todo_tags;\nDROP TABLE tags;"),
+	})
+	ctx := context.Background()
+
+	applied, err := m.Up(ctx)
+	if err != nil || applied != 2 {
+		t.Fatalf("Up = %d, %v, want 2 migrations", applied, err)
+	}
+	if got := tables(t, conn); !got["users"] || !got["tags"] || !got["todo_tags"] {
+		t.Errorf("after Up got tables %v", got)
+	}
+	if applied, err := m.Up(ctx); err != nil || applied != 0 {
+		t.Errorf("second Up = %d, %v, want nothing to do", applied, err)
+	}
+
+	reverted, err := m.Down(ctx, 1)
+	if err != nil || reverted != 1 {
+		t.Fatalf("Down(1) = %d, %v, want 1 migration", reverted, err)
+	}
+	if got := tables(t, conn); !got["users"] || got["tags"] || got["todo_tags"] {
+		t.Errorf("after Down(1) got tables %v, want only the first migration left", got)
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 || !statuses[0].Applied || statuses[1].Applied {
+		t.Errorf("Status after Down(1) = %+v", statuses)
+	}
+
+	// Up picks up where Down left off.
+	if applied, err := m.Up(ctx); err != nil || applied != 1 {
+		t.Errorf("Up after Down(1) = %d, %v, want 1 migration", applied, err)
+	}
+	if reverted, err := m.Down(ctx, 10); err != nil || reverted != 2 {
+		t.Errorf("Down(10) = %d, %v, want 2 migrations", reverted, err)
+	}
+	if got := tables(t, conn); len(got) != 1 || !got["schema_migrations"] {
+		t.Errorf("after reverting everything got tables %v", got)
+	}
+}
+
+func TestMigratorRollsBackFailedMigration(t *testing.T) {
+	conn := openTestSQLite(t)
+	m := testMigrator(t, conn, DriverSQLite, fstest.MapFS{
+		"0001_users.up.sql": script("CREATE TABLE users (id INTEGER PRIMARY KEY);"),
+		// The second statement fails after the first one created a table.
+		"0002_broken.up.sql":   script("CREATE TABLE tags (id INTEGER PRIMARY KEY);\nALTER TABLE missing ADD COLUMN name TEXT;"),
+		"0002_broken.down.sql": script("DROP TABLE tags;"),
+	})
+	ctx := context.Background()
+
+	if _, err := m.Up(ctx); err == nil {
+		t.Fatal("Up with a broken migration succeeded")
+	}
+	if got := tables(t, conn); !got["users"] || got["tags"] {
+		t.Errorf("got tables %v, want the first migration applied and the second rolled back", got)
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !statuses[0].Applied || statuses[1].Applied {
+		t.Errorf("Status = %+v, want only the first migration recorded", statuses)
+	}
+}
+
+func TestMigratorDownWithoutScript(t *testing.T) {
+	conn := openTestSQLite(t)
+	m := testMigrator(t, conn, DriverSQLite, fstest.MapFS{
+		"0001_users.up.sql": script("CREATE TABLE users (id INTEGER PRIMARY KEY);"),
+	})
+	ctx := context.Background()
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Down(ctx, 1); err == nil {
+		t.Error("Down of a migration without a down script succeeded")
+	}
+	if got := tables(t, conn); !got["users"] {
+		t.Error("the failed Down dropped the table")
+	}
+}
+
+// TestEmbeddedSQLiteMigrations applies every migration shipped in the binary,
+// reverts them all and applies them again, which catches down scripts that
+// do not undo their up script.
+func TestEmbeddedSQLiteMigrations(t *testing.T) {
+	conn := openTestSQLite(t)
+	m, err := NewMigrator(conn, DriverSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	loaded, err := LoadMigrations(migrations.FS, DriverSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for round := range 2 {
+		applied, err := m.Up(ctx)
+		if err != nil || applied != len(loaded) {
+			t.Fatalf("round %d: Up = %d, %v, want %d migrations", round, applied, err, len(loaded))
+		}
+		reverted, err := m.Down(ctx, len(loaded))
+		if err != nil || reverted != len(loaded) {
+			t.Fatalf("round %d: Down = %d, %v, want %d migrations", round, reverted, err, len(loaded))
+		}
+		if got := tables(t, conn); len(got) != 1 {
+			t.Fatalf("round %d: tables left after reverting everything: %v", round, got)
+		}
+	}
+}
+
+// TestMigratorLockPostgres starts several migrators on the same PostgreSQL
+// database at once. It needs a disposable database named by
+// TEST_POSTGRES_URL and is skipped otherwise.
+func TestMigratorLockPostgres(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_URL")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_URL not set")
+	}
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// The sleep keeps the first migrator inside the migration long enough
+	// for the others to try it too. Without the lock they would fail on
+	// the existing table or the schema_migrations primary key.
+	files := fstest.MapFS{
+		"0001_lock_test.up.sql":   script("SELECT pg_sleep(0.2);\nCREATE TABLE migration_lock_test (id INTEGER);"),
+		"0001_lock_test.down.sql": script("DROP TABLE migration_lock_test;"),
+	}
+	ctx := context.Background()
+	t.Cleanup(func() {
+		testMigrator(t, conn, DriverPostgres, files).Down(ctx, 1)
+	})
+
+	var wg sync.WaitGroup
+	results := make([]int, 3)
+	errs := make([]error, len(results))
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = testMigrator(t, conn, DriverPostgres, files).Up(ctx)
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("migrator %d: %v", i, err)
+		}
+		total += results[i]
+	}
+	if total != 1 {
+		t.Errorf("the migration ran %d times, want once", total)
+	}
+}
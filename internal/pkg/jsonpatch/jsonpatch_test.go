@@ -0,0 +1,351 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// equalJSON reports whether a and b hold the same JSON value.
+func equalJSON(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var x, y any
+	if err := json.Unmarshal(a, &x); err != nil {
+		t.Fatalf("invalid JSON %s: %v", a, err)
+	}
+	if err := json.Unmarshal(b, &y); err != nil {
+		t.Fatalf("invalid JSON %s: %v", b, err)
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+// TestMergePatchRFC7396 applies the examples of RFC 7396, appendix A.
+func TestMergePatchRFC7396(t *testing.T) {
+	tests := []struct {
+		doc, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		// Removing a member that is not there is no error.
+		{`{"a":"b"}`, `{"c":null}`, `{"a":"b"}`},
+	}
+	for _, tt := range tests {
+		got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+		if err != nil {
+			t.Errorf("MergePatch(%s, %s): %v", tt.doc, tt.patch, err)
+			continue
+		}
+		if !equalJSON(t, got, []byte(tt.want)) {
+			t.Errorf("MergePatch(%s, %s) = %s, want %s", tt.doc, tt.patch, got, tt.want)
+		}
+	}
+}
+
+func TestMergePatchErrors(t *testing.T) {
+	if _, err := MergePatch([]byte(`{"a":`), []byte(`{}`)); err == nil {
+		t.Error("MergePatch accepted a malformed document")
+	}
+	if _, err := MergePatch([]byte(`{}`), []byte(`{"a":`)); err == nil {
+		t.Error("MergePatch accepted a malformed patch")
+	}
+}
+
+// TestApplyRFC6902 applies the examples of RFC 6902, appendix A.
+func TestApplyRFC6902(t *testing.T) {
+	tests := []struct {
+		name, doc, patch, want string
+	}{
+		{
+			"A.1 adding an object member",
+			`{"foo":"bar"}`,
+			`[{"op":"add","path":"/baz","value":"qux"}]`,
+			`{"baz":"qux","foo":"bar"}`,
+		},
+		{
+			"A.2 adding an array element",
+			`{"foo":["bar","baz"]}`,
+			`[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			`{"foo":["bar","qux","baz"]}`,
+		},
+		{
+			"A.3 removing an object member",
+			`{"baz":"qux","foo":"bar"}`,
+			`[{"op":"remove","path":"/baz"}]`,
+			`{"foo":"bar"}`,
+		},
+		{
+			"A.4 removing an array element",
+			`{"foo":["bar","qux","baz"]}`,
+			`[{"op":"remove","path":"/foo/1"}]`,
+			`{"foo":["bar","baz"]}`,
+		},
+		{
+			"A.5 replacing a value",
+			`{"baz":"qux","foo":"bar"}`,
+			`[{"op":"replace","path":"/baz","value":"boo"}]`,
+			`{"baz":"boo","foo":"bar"}`,
+		},
+		{
+			"A.6 moving a value",
+			`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`,
+		},
+		{
+			"A.7 moving an array element",
+			`{"foo":["all","grass","cows","eat"]}`,
+			`[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			`{"foo":["all","cows","eat","grass"]}`,
+		},
+		{
+			"A.8 testing a value: success",
+			`{"baz":"qux","foo":["a",2,"c"]}`,
+			`[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			`{"baz":"qux","foo":["a",2,"c"]}`,
+		},
+		{
+			"A.10 adding a nested member object",
+			`{"foo":"bar"}`,
+			`[{"op":"add","path":"/child","value":{"grandchild":{}}}]`,
+			`{"foo":"bar","child":{"grandchild":{}}}`,
+		},
+		{
+			"A.11 ignoring unrecognized elements",
+			`{"foo":"bar"}`,
+			`[{"op":"add","path":"/baz","value":"qux","xyz":123}]`,
+			`{"foo":"bar","baz":"qux"}`,
+		},
+		{
+			"A.14 ~ escape ordering",
+			`{"/":9,"~1":10}`,
+			`[{"op":"test","path":"/~01","value":10}]`,
+			`{"/":9,"~1":10}`,
+		},
+		{
+			"A.16 adding an array value",
+			`{"foo":["bar"]}`,
+			`[{"op":"add","path":"/foo/-","value":["abc","def"]}]`,
+			`{"foo":["bar",["abc","def"]]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if !equalJSON(t, got, []byte(tt.want)) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyRFC6902Errors(t *testing.T) {
+	tests := []struct {
+		name, doc, patch string
+		testFailed       bool
+	}{
+		{
+			"A.9 testing a value: error",
+			`{"baz":"qux"}`,
+			`[{"op":"test","path":"/baz","value":"bar"}]`,
+			true,
+		},
+		{
+			"A.12 adding to a nonexistent target",
+			`{"foo":"bar"}`,
+			`[{"op":"add","path":"/baz/bat","value":"qux"}]`,
+			false,
+		},
+		{
+			// Of the duplicate members the last one wins, and there is no
+			// /baz to remove.
+			"A.13 invalid JSON Patch document",
+			`{"foo":"bar"}`,
+			`[{"op":"add","path":"/baz","value":"qux","op":"remove"}]`,
+			false,
+		},
+		{
+			"A.15 comparing strings and numbers",
+			`{"/":9,"~1":10}`,
+			`[{"op":"test","path":"/~01","value":"10"}]`,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply([]byte(tt.doc), []byte(tt.patch))
+			if err == nil {
+				t.Fatalf("got %s, want an error", got)
+			}
+			if errors.Is(err, ErrTestFailed) != tt.testFailed {
+				t.Errorf("got error %v, want ErrTestFailed: %t", err, tt.testFailed)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name, doc, patch, want string
+	}{
+		{
+			"pointer escapes",
+			`{"a/b":1,"m~n":2,"~1":3}`,
+			`[{"op":"replace","path":"/a~1b","value":10},{"op":"replace","path":"/m~0n","value":20},{"op":"remove","path":"/~01"}]`,
+			`{"a/b":10,"m~n":20}`,
+		},
+		{
+			"empty member name",
+			`{"":1,"a":2}`,
+			`[{"op":"replace","path":"/","value":0}]`,
+			`{"":0,"a":2}`,
+		},
+		{
+			"adding to the end of an array by index",
+			`{"foo":["bar"]}`,
+			`[{"op":"add","path":"/foo/1","value":"baz"}]`,
+			`{"foo":["bar","baz"]}`,
+		},
+		{
+			"- names an object member",
+			`{"foo":{}}`,
+			`[{"op":"add","path":"/foo/-","value":1}]`,
+			`{"foo":{"-":1}}`,
+		},
+		{
+			"adding a null value",
+			`{"foo":"bar"}`,
+			`[{"op":"add","path":"/baz","value":null},{"op":"test","path":"/baz","value":null}]`,
+			`{"foo":"bar","baz":null}`,
+		},
+		{
+			"replacing the last array element",
+			`{"foo":["a","b","c"]}`,
+			`[{"op":"replace","path":"/foo/2","value":"z"}]`,
+			`{"foo":["a","b","z"]}`,
+		},
+		{
+			"replacing the whole document",
+			`{"foo":"bar"}`,
+			`[{"op":"replace","path":"","value":[1]}]`,
+			`[1]`,
+		},
+		{
+			"moving a value onto itself",
+			`{"foo":{"bar":1}}`,
+			`[{"op":"move","from":"/foo","path":"/foo"}]`,
+			`{"foo":{"bar":1}}`,
+		},
+		{
+			"moving a value to a sibling with a longer name",
+			`{"foo":1}`,
+			`[{"op":"move","from":"/foo","path":"/foobar"}]`,
+			`{"foobar":1}`,
+		},
+		{
+			"copies are independent",
+			`{"foo":{"bar":1}}`,
+			`[{"op":"copy","from":"/foo","path":"/baz"},{"op":"replace","path":"/baz/bar","value":2}]`,
+			`{"foo":{"bar":1},"baz":{"bar":2}}`,
+		},
+		{
+			"test compares objects regardless of member order",
+			`{"foo":{"a":1,"b":[1,2]}}`,
+			`[{"op":"test","path":"/foo","value":{"b":[1,2],"a":1.0}}]`,
+			`{"foo":{"a":1,"b":[1,2]}}`,
+		},
+		{
+			"operations see the results of the earlier ones",
+			`{"todo":{"tags":["a"]}}`,
+			`[{"op":"add","path":"/todo/tags/-","value":"b"},{"op":"test","path":"/todo/tags/1","value":"b"},{"op":"remove","path":"/todo/tags/0"}]`,
+			`{"todo":{"tags":["b"]}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if !equalJSON(t, got, []byte(tt.want)) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	tests := []struct {
+		name, patch string
+	}{
+		{"patch is not an array", `{"op":"remove","path":"/foo"}`},
+		{"malformed patch", `[{"op":"remove",`},
+		{"unknown op", `[{"op":"delete","path":"/foo"}]`},
+		{"missing path", `[{"op":"remove"}]`},
+		{"missing value", `[{"op":"add","path":"/bar"}]`},
+		{"missing from", `[{"op":"copy","path":"/bar"}]`},
+		{"pointer without a leading slash", `[{"op":"remove","path":"foo"}]`},
+		{"removing a missing member", `[{"op":"remove","path":"/bar"}]`},
+		{"replacing a missing member", `[{"op":"replace","path":"/bar","value":1}]`},
+		{"leading zero index", `[{"op":"replace","path":"/list/01","value":1}]`},
+		{"leading zero index on add", `[{"op":"add","path":"/list/00","value":1}]`},
+		{"negative index", `[{"op":"remove","path":"/list/-1"}]`},
+		{"index past the end", `[{"op":"add","path":"/list/3","value":1}]`},
+		{"index at the end on remove", `[{"op":"remove","path":"/list/2"}]`},
+		{"- on remove", `[{"op":"remove","path":"/list/-"}]`},
+		{"- on replace", `[{"op":"replace","path":"/list/-","value":1}]`},
+		{"- on test", `[{"op":"test","path":"/list/-","value":1}]`},
+		{"non-numeric index", `[{"op":"remove","path":"/list/one"}]`},
+		{"descending into a string", `[{"op":"add","path":"/foo/bar/baz","value":1}]`},
+		{"moving a value into its own child", `[{"op":"move","from":"/obj","path":"/obj/child"}]`},
+		{"moving a value into its own grandchild", `[{"op":"move","from":"/obj","path":"/obj/a/b"}]`},
+		{"moving a missing value", `[{"op":"move","from":"/missing","path":"/bar"}]`},
+	}
+	doc := `{"foo":"bar","list":["a","b"],"obj":{"a":{}}}`
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := Apply([]byte(doc), []byte(tt.patch)); err == nil {
+				t.Errorf("got %s, want an error", got)
+			}
+		})
+	}
+}
+
+// TestApplyIsAtomic checks that a failing operation leaves the document as
+// it was, including the changes of the operations before it.
+func TestApplyIsAtomic(t *testing.T) {
+	doc := []byte(`{"foo":{"bar":[1,2]},"baz":"qux"}`)
+	original := string(doc)
+	for _, patch := range []string{
+		`[{"op":"add","path":"/foo/bar/-","value":3},{"op":"remove","path":"/baz"},{"op":"test","path":"/foo/bar/0","value":2}]`,
+		`[{"op":"replace","path":"/baz","value":"x"},{"op":"move","from":"/foo","path":"/foo/bar/0"}]`,
+		`[{"op":"remove","path":"/foo/bar/0"},{"op":"remove","path":"/nothing"}]`,
+	} {
+		got, err := Apply(doc, []byte(patch))
+		if err == nil {
+			t.Errorf("Apply(%s) = %s, want an error", patch, got)
+		}
+		if got != nil {
+			t.Errorf("Apply(%s) returned %s with its error", patch, got)
+		}
+		if string(doc) != original {
+			t.Fatalf("Apply(%s) changed the document to %s", patch, doc)
+		}
+	}
+}
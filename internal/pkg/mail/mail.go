@@ -0,0 +1,73 @@
+// Package mail sends the emails of the account flows, such as password
+// resets, through a pluggable Mailer.
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers emails.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// FromEnv returns the mailer selected by MAIL_DRIVER:
+//
+//   - "log" (the default) writes every email to MAIL_LOG_FILE, or to
+//     standard output when it is not set, for local development and tests.
+//   - "smtp" sends them through SMTP_HOST and SMTP_PORT (default 587),
+//     authenticating with SMTP_USERNAME and SMTP_PASSWORD when they are set.
+//
+// MAIL_FROM is the sender address of every email.
+func FromEnv() (Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+
+	switch driver := strings.ToLower(os.Getenv("MAIL_DRIVER")); driver {
+	case "", "log":
+		path := os.Getenv("MAIL_LOG_FILE")
+		if path == "" {
+			return NewLogMailer(os.Stdout, from), nil
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open MAIL_LOG_FILE: %w", err)
+		}
+		return NewLogMailer(f, from), nil
+	case "smtp":
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			return nil, fmt.Errorf("SMTP_HOST must be set when MAIL_DRIVER is smtp")
+		}
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		return &SMTPMailer{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_DRIVER %q (expected log or smtp)", driver)
+	}
+}
+
+// headerValue strips line breaks so a value cannot add headers of its own.
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
@@ -1,30 +1,458 @@
 package models
 
+import (
+	"fmt"
+	"time"
+)
+
 type ListCurator struct {
-	ID       int    `json:"id"`
-	Email    string `json:"email"`
-	Name     string `json:"name"`
-	Password string `json:"-"`
+	ID            int    `json:"id"`
+	Email         string `json:"email"`
+	Name          string `json:"name"`
+	EmailVerified bool   `json:"email_verified"`
+	// Timezone is an IANA time zone name such as "Europe/Berlin".
+	Timezone string `json:"timezone" example:"Europe/Berlin"`
+	// Locale is a BCP 47 language tag such as "de-DE".
+	Locale string `json:"locale" example:"de-DE"`
+	// DeleteAt is when the account is deleted for good, set while a
+	// deletion is pending.
+	DeleteAt *time.Time `json:"delete_at,omitempty"`
+	Password string     `json:"-"`
 }
 
 type TodoItem struct {
-	ID    int    `json:"id"`
+	ID          int        `json:"id"`
+	ListID      int        `json:"list_id"`
+	Title       string     `json:"title"`
+	Desc        string     `json:"description"`
+	Completed   bool       `json:"completed"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+	Priority    Priority   `json:"priority" swaggertype:"string" enums:"none,low,medium,high,urgent"`
+	Tags        []string   `json:"tags,omitempty"`
+	// AutoComplete completes the todo once every checklist item is done and
+	// reopens it when an item is added or unchecked again.
+	AutoComplete bool               `json:"auto_complete"`
+	Checklist    *ChecklistProgress `json:"checklist,omitempty"`
+	// Recurrence is an RFC 5545 RRULE. Completing a recurring todo creates
+	// its next occurrence, whose ID is kept in NextOccurrenceID.
+	Recurrence       string `json:"recurrence,omitempty" example:"FREQ=WEEKLY;BYDAY=MO"`
+	NextOccurrenceID *int   `json:"next_occurrence_id,omitempty"`
+	// RecurrenceStart is the due date of the first occurrence, which the
+	// rule is expanded from.
+	RecurrenceStart *time.Time `json:"-"`
+	// RecurrenceTZ is the time zone the rule is expanded in, so a todo due
+	// at 09:00 on Mondays stays there across offsets and daylight saving
+	// time. It is the time zone of the user who set the recurrence.
+	RecurrenceTZ string    `json:"recurrence_tz,omitempty" example:"Europe/Berlin"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type OccurrencesResponse struct {
+	Recurrence  string      `json:"recurrence"`
+	Occurrences []time.Time `json:"occurrences"`
+}
+
+// ChecklistProgress counts the checklist items of a todo. It is left out of
+// todos without a checklist.
+type ChecklistProgress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+type ChecklistItem struct {
+	ID        int       `json:"id"`
+	TodoID    int       `json:"todo_id"`
+	Title     string    `json:"title"`
+	Done      bool      `json:"done"`
+	Position  int       `json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ChecklistItemRequest struct {
 	Title string `json:"title"`
-	Desc  string `json:"description"`
+	// Position is the zero-based place to insert a new item at. The item is
+	// appended when it is omitted.
+	Position *int `json:"position,omitempty"`
+}
+
+type ChecklistOrderRequest struct {
+	ItemIDs []int `json:"item_ids"`
 }
 
 type RegisterRequest struct {
 	Name     string `json:"name"`
 	Email    string `json:"email"`
-	Password string `json: "password"`
+	Password string `json:"password"`
 }
 
 type LoginRequest struct {
 	Email    string `json:"email"`
-	Password string `json: "password"`
+	Password string `json:"password"`
+}
+
+// TokenResponse is returned by every endpoint that logs a user in. Token is
+// the short-lived access token; RefreshToken can be exchanged once for a
+// new pair at /token/refresh.
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	// ExpiresIn is the lifetime of Token in seconds.
+	ExpiresIn int `json:"expires_in"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// ProfileRequest changes the profile of the user. Fields left out keep
+// their value.
+type ProfileRequest struct {
+	Name     *string `json:"name,omitempty"`
+	Timezone *string `json:"timezone,omitempty" example:"Europe/Berlin"`
+	Locale   *string `json:"locale,omitempty" example:"de-DE"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email"`
+	Password string `json:"password"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+// EmailChange is the stored record of a token that confirms the new email
+// address of a user. Only the hash of the token is kept.
+type EmailChange struct {
+	ID        int
+	UserID    int
+	NewEmail  string
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// PasswordReset is the stored record of a password reset token. Only the
+// hash of the token is kept, and it can be used once.
+type PasswordReset struct {
+	ID        int
+	UserID    int
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// EmailVerification is the stored record of a token that verifies the email
+// address of a user. Only the hash of the token is kept.
+type EmailVerification struct {
+	ID        int
+	UserID    int
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email"`
+}
+
+// TOTPEnrollment is the stored TOTP secret of a user. It only protects
+// logins once ConfirmedAt is set. LastStep is the time step of the last
+// accepted code, so a code cannot be used twice.
+type TOTPEnrollment struct {
+	UserID      int
+	Secret      string
+	ConfirmedAt *time.Time
+	LastStep    int64
+	// RecoveryCodesLeft counts the unused recovery codes.
+	RecoveryCodesLeft int
+	CreatedAt         time.Time
+}
+
+// MFAChallenge is the stored record of a challenge token, handed out when a
+// user with two-factor authentication enters the right password. Only the
+// hash of the token is kept.
+type MFAChallenge struct {
+	ID        int
+	UserID    int
+	TokenHash string
+	// Attempts counts the wrong codes entered for the challenge.
+	Attempts  int
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// TOTPEnrollmentResponse carries a new TOTP secret. URI is the otpauth://
+// URI authenticator apps enroll it from, usually shown as a QR code.
+type TOTPEnrollmentResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri" example:"otpauth://totp/Todo%20API:jane@example.com?algorithm=SHA1&digits=6&issuer=Todo+API&period=30&secret=JBSWY3DPEHPK3PXP"`
+}
+
+// TOTPCodeRequest carries a code from the authenticator app or, where
+// accepted, a recovery code.
+type TOTPCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// RecoveryCodesResponse carries new recovery codes. They are only ever
+// shown once.
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type TwoFactorStatus struct {
+	Enabled           bool `json:"enabled"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// MFAChallengeResponse is returned by /login instead of tokens when the user
+// has two-factor authentication enabled. ChallengeToken is exchanged for the
+// tokens at /login/2fa together with a code.
+type MFAChallengeResponse struct {
+	MFARequired    bool   `json:"mfa_required"`
+	ChallengeToken string `json:"challenge_token"`
+	// ExpiresIn is the lifetime of ChallengeToken in seconds.
+	ExpiresIn int `json:"expires_in"`
+}
+
+type MFALoginRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	// Code is a code from the authenticator app or an unused recovery code.
+	Code string `json:"code"`
 }
 
+// APIKey is a personal API key that authenticates scripts as its user.
+// Only the hash of the key is stored; Prefix is the start of the key, which
+// tells keys apart in listings.
+type APIKey struct {
+	ID     int      `json:"id"`
+	Name   string   `json:"name"`
+	Prefix string   `json:"prefix" example:"tdk_Xb3kQ9"`
+	Scopes []string `json:"scopes" example:"todos:read"`
+	// ExpiresAt is when the key stops working. Keys without it stay valid
+	// until they are deleted.
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UserID     int        `json:"-"`
+	KeyHash    string     `json:"-"`
+}
+
+type APIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes" example:"todos:read,todos:write"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// NewAPIKeyResponse carries a new API key. Key is only ever shown here.
+type NewAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// UserIdentity links a user to the account they sign in with at an OpenID
+// Connect provider, which is identified by its issuer and the subject it
+// gives the account.
+type UserIdentity struct {
+	ID      int
+	UserID  int
+	Issuer  string
+	Subject string
+	// Email is the address the provider reported when the identity was
+	// linked.
+	Email     string
+	CreatedAt time.Time
+}
+
+// OIDCLogin is the stored record of an OpenID Connect login waiting for the
+// provider to redirect back. Only the hash of the state parameter is kept;
+// the nonce and PKCE code verifier never leave the server.
+type OIDCLogin struct {
+	ID           int
+	StateHash    string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+}
+
+// JSONWebKeySet publishes the public keys tokens are signed with, see
+// RFC 7517.
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JSONWebKey is an RSA (kty RSA) or Ed25519 (kty OKP) public key. Its
+// parameters are base64url encoded.
+type JSONWebKey struct {
+	KeyType   string `json:"kty" enums:"RSA,OKP"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg" enums:"RS256,EdDSA"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshToken is the stored record of an issued refresh token. Only the
+// hash of the token itself is kept. Tokens rotated from the same login share
+// a FamilyID so they can be revoked together.
+type RefreshToken struct {
+	ID        int
+	UserID    int
+	FamilyID  string
+	TokenHash string
+	ExpiresAt time.Time
+	// UsedAt is set once the token has been exchanged for its successor.
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// TokenRevocation rejects access tokens before they expire: the token with
+// ID TokenID or, when TokenID is empty, every token of UserID issued before
+// CreatedAt. It is kept until ExpiresAt, when the tokens it covers have
+// expired anyway.
+type TokenRevocation struct {
+	ID        int
+	TokenID   string
+	UserID    int
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// CreateRequest is the body of POST and PUT /todos. On updates the fields
+// it omits keep their current values and null resets them.
 type CreateRequest struct {
-	Title string `json:"title"`
-	Desc  string `json:"description"`
+	Title    string     `json:"title"`
+	Desc     string     `json:"description"`
+	DueAt    *time.Time `json:"due_at,omitempty"`
+	Priority Priority   `json:"priority" swaggertype:"string" enums:"none,low,medium,high,urgent"`
+	// Tags names the tags to put on the todo. Missing tags are created; on
+	// updates an omitted list keeps the current tags.
+	Tags []string `json:"tags,omitempty"`
+	// ListID is the list to put the todo in. It defaults to the user's
+	// Inbox on creation and to the current list on updates.
+	ListID       int  `json:"list_id,omitempty"`
+	AutoComplete bool `json:"auto_complete,omitempty"`
+	// Recurrence makes the todo repeat, see TodoItem.Recurrence. It needs a
+	// due date.
+	Recurrence string `json:"recurrence,omitempty" example:"FREQ=WEEKLY;BYDAY=MO"`
+}
+
+type TodoList struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	IsDefault bool      `json:"is_default"`
+	Role      ListRole  `json:"role" enums:"owner,editor,viewer"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ListRequest struct {
+	Name string `json:"name"`
+}
+
+type ListMember struct {
+	UserID    int       `json:"user_id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+	Role      ListRole  `json:"role" enums:"owner,editor,viewer"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type MemberRequest struct {
+	Email string   `json:"email"`
+	Role  ListRole `json:"role" enums:"editor,viewer"`
+}
+
+type RoleRequest struct {
+	Role ListRole `json:"role" enums:"editor,viewer"`
+}
+
+type Tag struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type TagRequest struct {
+	Name string `json:"name"`
+}
+
+// Priority ranks todos by importance. It is stored as a number so it sorts
+// naturally and is exchanged with clients by name.
+type Priority int
+
+const (
+	PriorityNone Priority = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+	PriorityUrgent
+)
+
+var priorityNames = []string{"none", "low", "medium", "high", "urgent"}
+
+func (p Priority) String() string {
+	if p < PriorityNone || p > PriorityUrgent {
+		return fmt.Sprintf("Priority(%d)", int(p))
+	}
+	return priorityNames[p]
+}
+
+func (p Priority) MarshalText() ([]byte, error) {
+	if p < PriorityNone || p > PriorityUrgent {
+		return nil, fmt.Errorf("invalid priority %d", int(p))
+	}
+	return []byte(priorityNames[p]), nil
+}
+
+func (p *Priority) UnmarshalText(text []byte) error {
+	for i, name := range priorityNames {
+		if string(text) == name {
+			*p = Priority(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid priority %q (expected none, low, medium, high or urgent)", text)
+}
+
+// ListRole is the access a member has to a shared list. Owners manage the
+// list and its members, editors change its todos and viewers only read them.
+type ListRole string
+
+const (
+	RoleOwner  ListRole = "owner"
+	RoleEditor ListRole = "editor"
+	RoleViewer ListRole = "viewer"
+)
+
+// Valid reports whether r is one of the known roles.
+func (r ListRole) Valid() bool {
+	return r == RoleOwner || r == RoleEditor || r == RoleViewer
+}
+
+// CanEdit reports whether r may create, change and delete todos.
+func (r ListRole) CanEdit() bool {
+	return r == RoleOwner || r == RoleEditor
 }
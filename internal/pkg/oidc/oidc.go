@@ -0,0 +1,247 @@
+// Package oidc logs users in through an external OpenID Connect identity
+// provider with the authorization code flow and PKCE (RFC 7636).
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoginTTL is how long users have to log in at the provider before the
+// login they started expires.
+const LoginTTL = 10 * time.Minute
+
+// Config identifies the API as a client of an identity provider.
+type Config struct {
+	// IssuerURL is the issuer identifier of the provider. Its metadata is
+	// discovered at IssuerURL + "/.well-known/openid-configuration".
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback the provider sends users back to. It must
+	// be registered with the provider.
+	RedirectURL string
+	Scopes      []string
+	// HTTPClient talks to the provider. It defaults to a client with a ten
+	// second timeout.
+	HTTPClient *http.Client
+}
+
+// ConfigFromEnv reads the provider from OIDC_ISSUER_URL, OIDC_CLIENT_ID,
+// OIDC_CLIENT_SECRET (left out for public clients), OIDC_REDIRECT_URL,
+// which defaults to /oidc/callback on APP_BASE_URL, and OIDC_SCOPES, which
+// defaults to "openid email profile". It reports false when
+// OIDC_ISSUER_URL is not set.
+func ConfigFromEnv() (Config, bool, error) {
+	issuer := os.Getenv("OIDC_ISSUER_URL")
+	if issuer == "" {
+		return Config{}, false, nil
+	}
+	config := Config{
+		IssuerURL:    issuer,
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:       strings.Fields(os.Getenv("OIDC_SCOPES")),
+	}
+	if config.ClientID == "" {
+		return Config{}, false, fmt.Errorf("OIDC_CLIENT_ID must be set when OIDC_ISSUER_URL is set")
+	}
+	if config.RedirectURL == "" {
+		baseURL := os.Getenv("APP_BASE_URL")
+		if baseURL == "" {
+			baseURL = "http://localhost:8080"
+		}
+		config.RedirectURL = strings.TrimSuffix(baseURL, "/") + "/oidc/callback"
+	}
+	return config, true, nil
+}
+
+// Provider is an identity provider whose metadata has been discovered.
+type Provider struct {
+	config   Config
+	metadata metadata
+
+	mu sync.Mutex
+	// keys caches the signing keys of the provider by key ID.
+	keys          map[string]any
+	keysFetchedAt time.Time
+}
+
+// metadata is the part of the provider metadata the login flow needs, see
+// OpenID Connect Discovery 1.0 section 3.
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Discover fetches the metadata of the provider config names.
+func Discover(ctx context.Context, config Config) (*Provider, error) {
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	p := &Provider{config: config}
+	wellKnown := strings.TrimSuffix(config.IssuerURL, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, wellKnown, &p.metadata); err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+	// The issuer has to match exactly so tokens of other issuers served
+	// from the same host are not accepted, see section 4.3.
+	if p.metadata.Issuer != config.IssuerURL {
+		return nil, fmt.Errorf("OIDC discovery failed: issuer %q does not match %q", p.metadata.Issuer, config.IssuerURL)
+	}
+	if p.metadata.AuthorizationEndpoint == "" || p.metadata.TokenEndpoint == "" || p.metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("OIDC discovery failed: provider metadata is incomplete")
+	}
+	return p, nil
+}
+
+// Issuer returns the issuer identifier of the provider.
+func (p *Provider) Issuer() string {
+	return p.metadata.Issuer
+}
+
+// AuthCodeURL returns the URL of the provider's login page. state is
+// returned to the callback unchanged, nonce ends up in the ID token and
+// codeChallenge is the S256 challenge of the verifier Exchange is given.
+func (p *Provider) AuthCodeURL(state, nonce, codeChallenge string) string {
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.config.ClientID)
+	params.Set("redirect_uri", p.config.RedirectURL)
+	params.Set("scope", strings.Join(p.config.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", codeChallenge)
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(p.metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.metadata.AuthorizationEndpoint + separator + params.Encode()
+}
+
+// tokenResponse is the successful response of the token endpoint.
+type tokenResponse struct {
+	IDToken string `json:"id_token"`
+}
+
+// errorResponse is the error response of the token endpoint, see RFC 6749
+// section 5.2.
+type errorResponse struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// Exchange redeems an authorization code together with the PKCE verifier
+// it was requested with and returns the raw ID token. The token still has
+// to be checked with Verify.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+	if p.config.ClientSecret == "" {
+		form.Set("client_id", p.config.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.config.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var failure errorResponse
+		if json.Unmarshal(body, &failure) == nil && failure.Error != "" {
+			if failure.Description != "" {
+				return "", fmt.Errorf("token request failed: %s: %s", failure.Error, failure.Description)
+			}
+			return "", fmt.Errorf("token request failed: %s", failure.Error)
+		}
+		return "", fmt.Errorf("token request failed: %s", resp.Status)
+	}
+	var tokens tokenResponse
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return "", fmt.Errorf("invalid token response: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return "", fmt.Errorf("token response carries no ID token")
+	}
+	return tokens.IDToken, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.config.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// NewPKCE returns a random PKCE code verifier and its S256 challenge.
+func NewPKCE() (verifier, challenge string, err error) {
+	verifier, err = RandomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	return verifier, CodeChallenge(verifier), nil
+}
+
+// CodeChallenge returns the S256 challenge of a PKCE code verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// RandomString returns n random bytes, base64url encoded, for use as state,
+// nonce or code verifier.
+func RandomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random value: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,198 @@
+// Package password decides which passwords users may choose and hashes
+// them for storage.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hasher hashes passwords for storage. The hashes carry their algorithm and
+// parameters, so Verify checks passwords against the hashes of any Hasher.
+type Hasher interface {
+	// Hash returns the hash of password.
+	Hash(password string) (string, error)
+	// NeedsRehash reports whether hash was made by another algorithm or
+	// with other parameters than Hash uses now.
+	NeedsRehash(hash string) bool
+}
+
+// ErrUnknownHash is returned by Verify for hashes no Hasher made.
+var ErrUnknownHash = errors.New("password: unknown hash format")
+
+// Verify reports whether hash is the hash of password.
+func Verify(hash, password string) (bool, error) {
+	switch {
+	case strings.HasPrefix(hash, argon2idPrefix):
+		params, salt, key, err := parseArgon2id(hash)
+		if err != nil {
+			return false, err
+		}
+		other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1, nil
+	case isBcrypt(hash):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	default:
+		return false, ErrUnknownHash
+	}
+}
+
+// Bcrypt hashes passwords with bcrypt at Cost.
+type Bcrypt struct {
+	Cost int
+}
+
+func (b Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+func (b Bcrypt) NeedsRehash(hash string) bool {
+	if !isBcrypt(hash) {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != b.Cost
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+const argon2idPrefix = "$argon2id$"
+
+// Argon2id hashes passwords with Argon2id. Its hashes use the PHC string
+// format, such as "$argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>".
+type Argon2id struct {
+	// Memory is the memory used in KiB.
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  int
+	KeyLength   uint32
+}
+
+// DefaultArgon2id follows the second recommended option of RFC 9106.
+var DefaultArgon2id = Argon2id{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 4,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+var b64 = base64.RawStdEncoding
+
+func (a Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, a.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, a.Iterations, a.Memory, a.Parallelism, a.KeyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version, a.Memory, a.Iterations, a.Parallelism, b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+func (a Argon2id) NeedsRehash(hash string) bool {
+	if !strings.HasPrefix(hash, argon2idPrefix) {
+		return true
+	}
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	return params.Memory != a.Memory || params.Iterations != a.Iterations || params.Parallelism != a.Parallelism ||
+		len(salt) != a.SaltLength || uint32(len(key)) != a.KeyLength
+}
+
+// parseArgon2id splits a hash made by Argon2id.Hash into its parameters,
+// salt and key.
+func parseArgon2id(hash string) (Argon2id, []byte, []byte, error) {
+	invalid := errors.New("password: invalid argon2id hash")
+	parts := strings.Split(strings.TrimPrefix(hash, argon2idPrefix), "$")
+	if len(parts) != 4 {
+		return Argon2id{}, nil, nil, invalid
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[0], "v=%d", &version); err != nil {
+		return Argon2id{}, nil, nil, invalid
+	}
+	if version != argon2.Version {
+		return Argon2id{}, nil, nil, fmt.Errorf("password: unsupported argon2id version %d", version)
+	}
+	var params Argon2id
+	if _, err := fmt.Sscanf(parts[1], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return Argon2id{}, nil, nil, invalid
+	}
+	salt, err := b64.DecodeString(parts[2])
+	if err != nil {
+		return Argon2id{}, nil, nil, invalid
+	}
+	key, err := b64.DecodeString(parts[3])
+	if err != nil || len(key) == 0 {
+		return Argon2id{}, nil, nil, invalid
+	}
+	return params, salt, key, nil
+}
+
+// HasherFromEnv returns the hasher PASSWORD_HASHER selects: "argon2id" (the
+// default) or "bcrypt". ARGON2_MEMORY (in KiB), ARGON2_ITERATIONS and
+// ARGON2_PARALLELISM tune Argon2id, BCRYPT_COST tunes bcrypt. Passwords
+// hashed with another algorithm or other parameters are rehashed when their
+// users log in.
+func HasherFromEnv() (Hasher, error) {
+	switch value := os.Getenv("PASSWORD_HASHER"); value {
+	case "", "argon2id":
+		a := DefaultArgon2id
+		parallelism, err := intFromEnv("ARGON2_PARALLELISM", int(a.Parallelism), 1, 255)
+		if err != nil {
+			return nil, err
+		}
+		memory, err := intFromEnv("ARGON2_MEMORY", int(a.Memory), 8*parallelism, 1<<32-1)
+		if err != nil {
+			return nil, err
+		}
+		iterations, err := intFromEnv("ARGON2_ITERATIONS", int(a.Iterations), 1, 1<<32-1)
+		if err != nil {
+			return nil, err
+		}
+		a.Memory, a.Iterations, a.Parallelism = uint32(memory), uint32(iterations), uint8(parallelism)
+		return a, nil
+	case "bcrypt":
+		cost, err := intFromEnv("BCRYPT_COST", bcrypt.DefaultCost, bcrypt.MinCost, bcrypt.MaxCost)
+		if err != nil {
+			return nil, err
+		}
+		return Bcrypt{Cost: cost}, nil
+	default:
+		return nil, fmt.Errorf("invalid PASSWORD_HASHER value %q (expected argon2id or bcrypt)", value)
+	}
+}
+
+func intFromEnv(name string, fallback, least, most int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < least || n > most {
+		return 0, fmt.Errorf("invalid %s value %q: must be a number from %d to %d", name, value, least, most)
+	}
+	return n, nil
+}
@@ -0,0 +1,205 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cheapArgon2id keeps the tests fast.
+var cheapArgon2id = Argon2id{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func mustHash(t *testing.T, h Hasher, password string) string {
+	t.Helper()
+	hash, err := h.Hash(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestHashAndVerify(t *testing.T) {
+	for _, h := range []Hasher{cheapArgon2id, Bcrypt{Cost: bcrypt.MinCost}} {
+		t.Run(fmt.Sprintf("%T", h), func(t *testing.T) {
+			hash := mustHash(t, h, "correct horse")
+			if other := mustHash(t, h, "correct horse"); other == hash {
+				t.Error("two hashes of the same password are equal, the salt is missing")
+			}
+
+			for password, want := range map[string]bool{
+				"correct horse":  true,
+				"correct horse ": false,
+				"Correct horse":  false,
+				"":               false,
+			} {
+				got, err := Verify(hash, password)
+				if err != nil {
+					t.Fatalf("Verify(%q): %v", password, err)
+				}
+				if got != want {
+					t.Errorf("Verify(%q) = %t, want %t", password, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestArgon2idFormat(t *testing.T) {
+	hash := mustHash(t, cheapArgon2id, "secret")
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("got %s, want the PHC string format", hash)
+	}
+
+	// Hashes made elsewhere with other parameters verify as well.
+	salt := []byte("0123456789abcdef")
+	key := argon2.IDKey([]byte("secret"), salt, 2, 128, 2, 24)
+	external := fmt.Sprintf("$argon2id$v=19$m=128,t=2,p=2$%s$%s", b64.EncodeToString(salt), b64.EncodeToString(key))
+	if ok, err := Verify(external, "secret"); err != nil || !ok {
+		t.Errorf("Verify(%s) = %t, %v, want true", external, ok, err)
+	}
+	if ok, err := Verify(external, "Secret"); err != nil || ok {
+		t.Errorf("Verify(%s) with the wrong password = %t, %v, want false", external, ok, err)
+	}
+}
+
+func TestVerifyBcryptVersions(t *testing.T) {
+	hash := mustHash(t, Bcrypt{Cost: bcrypt.MinCost}, "secret")
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		variant := prefix + hash[4:]
+		if ok, err := Verify(variant, "secret"); err != nil || !ok {
+			t.Errorf("Verify(%s) = %t, %v, want true", variant, ok, err)
+		}
+	}
+}
+
+func TestVerifyErrors(t *testing.T) {
+	salt, key := b64.EncodeToString([]byte("0123456789abcdef")), b64.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	tests := []struct {
+		name, hash string
+	}{
+		{"empty", ""},
+		{"plain text", "secret"},
+		{"argon2i", "$argon2i$v=19$m=64,t=1,p=1$" + salt + "$" + key},
+		{"unsupported argon2id version", "$argon2id$v=16$m=64,t=1,p=1$" + salt + "$" + key},
+		{"missing version", "$argon2id$m=64,t=1,p=1$" + salt + "$" + key},
+		{"malformed parameters", "$argon2id$v=19$m=64;t=1;p=1$" + salt + "$" + key},
+		{"missing key", "$argon2id$v=19$m=64,t=1,p=1$" + salt},
+		{"empty key", "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$"},
+		{"invalid salt", "$argon2id$v=19$m=64,t=1,p=1$!!$" + key},
+		{"truncated bcrypt", "$2a$04$short"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := Verify(tt.hash, "secret")
+			if err == nil || ok {
+				t.Errorf("Verify = %t, %v, want an error", ok, err)
+			}
+		})
+	}
+	if _, err := Verify("secret", "secret"); !errors.Is(err, ErrUnknownHash) {
+		t.Errorf("Verify of a plain text password: got %v, want ErrUnknownHash", err)
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	argon := mustHash(t, cheapArgon2id, "secret")
+	cheapBcrypt := mustHash(t, Bcrypt{Cost: bcrypt.MinCost}, "secret")
+	bcrypt5 := mustHash(t, Bcrypt{Cost: bcrypt.MinCost + 1}, "secret")
+
+	changed := func(change func(*Argon2id)) Argon2id {
+		a := cheapArgon2id
+		change(&a)
+		return a
+	}
+	tests := []struct {
+		name   string
+		hasher Hasher
+		hash   string
+		want   bool
+	}{
+		{"argon2id with the same parameters", cheapArgon2id, argon, false},
+		{"argon2id with more memory", changed(func(a *Argon2id) { a.Memory = 128 }), argon, true},
+		{"argon2id with more iterations", changed(func(a *Argon2id) { a.Iterations = 2 }), argon, true},
+		{"argon2id with more parallelism", changed(func(a *Argon2id) { a.Parallelism = 2 }), argon, true},
+		{"argon2id with a longer salt", changed(func(a *Argon2id) { a.SaltLength = 32 }), argon, true},
+		{"argon2id with a longer key", changed(func(a *Argon2id) { a.KeyLength = 64 }), argon, true},
+		{"argon2id replacing bcrypt", cheapArgon2id, cheapBcrypt, true},
+		{"argon2id with a malformed hash", cheapArgon2id, "$argon2id$v=19$m=64", true},
+		{"bcrypt with the same cost", Bcrypt{Cost: bcrypt.MinCost}, cheapBcrypt, false},
+		{"bcrypt with a higher cost", Bcrypt{Cost: bcrypt.MinCost + 1}, cheapBcrypt, true},
+		{"bcrypt with a lower cost", Bcrypt{Cost: bcrypt.MinCost}, bcrypt5, true},
+		{"bcrypt replacing argon2id", Bcrypt{Cost: bcrypt.MinCost}, argon, true},
+		{"bcrypt with a malformed hash", Bcrypt{Cost: bcrypt.MinCost}, "$2a$xx", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hasher.NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("NeedsRehash = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasherFromEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want Hasher
+	}{
+		{"default", nil, DefaultArgon2id},
+		{"argon2id", map[string]string{"PASSWORD_HASHER": "argon2id"}, DefaultArgon2id},
+		{
+			"tuned argon2id",
+			map[string]string{"ARGON2_MEMORY": "19456", "ARGON2_ITERATIONS": "2", "ARGON2_PARALLELISM": "1"},
+			Argon2id{Memory: 19456, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+		},
+		{"bcrypt", map[string]string{"PASSWORD_HASHER": "bcrypt"}, Bcrypt{Cost: bcrypt.DefaultCost}},
+		{"tuned bcrypt", map[string]string{"PASSWORD_HASHER": "bcrypt", "BCRYPT_COST": "12"}, Bcrypt{Cost: 12}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setHasherEnv(t, tt.env)
+			got, err := HasherFromEnv()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasherFromEnvErrors(t *testing.T) {
+	for _, env := range []map[string]string{
+		{"PASSWORD_HASHER": "scrypt"},
+		{"PASSWORD_HASHER": "bcrypt", "BCRYPT_COST": "3"},
+		{"PASSWORD_HASHER": "bcrypt", "BCRYPT_COST": "32"},
+		{"PASSWORD_HASHER": "bcrypt", "BCRYPT_COST": "high"},
+		{"ARGON2_PARALLELISM": "0"},
+		{"ARGON2_PARALLELISM": "256"},
+		{"ARGON2_ITERATIONS": "0"},
+		{"ARGON2_MEMORY": "-1"},
+		// Argon2 needs at least 8 KiB per lane.
+		{"ARGON2_PARALLELISM": "4", "ARGON2_MEMORY": "31"},
+		{"ARGON2_MEMORY": "4294967296"},
+	} {
+		setHasherEnv(t, env)
+		if h, err := HasherFromEnv(); err == nil {
+			t.Errorf("HasherFromEnv with %v = %#v, want an error", env, h)
+		}
+	}
+}
+
+// setHasherEnv sets the variables HasherFromEnv reads to env, clearing the
+// others.
+func setHasherEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	for _, name := range []string{"PASSWORD_HASHER", "ARGON2_MEMORY", "ARGON2_ITERATIONS", "ARGON2_PARALLELISM", "BCRYPT_COST"} {
+		t.Setenv(name, env[name])
+	}
+}
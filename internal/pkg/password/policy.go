@@ -0,0 +1,179 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Account is what a Rule knows about the user choosing a password.
+type Account struct {
+	Email string
+	Name  string
+}
+
+// Violation is the error a Rule rejects a password with. Its message is
+// meant for the user.
+type Violation struct {
+	Reason string
+}
+
+func (v *Violation) Error() string {
+	return v.Reason
+}
+
+// Rule decides whether account may choose a password. It returns a
+// *Violation for passwords it rejects and other errors when it could not
+// decide.
+type Rule interface {
+	Check(password string, account Account) error
+}
+
+// Policy is the set of rules a password has to pass.
+type Policy []Rule
+
+// Check returns the error of the first rule that rejects password.
+func (p Policy) Check(password string, account Account) error {
+	for _, rule := range p {
+		if err := rule.Check(password, account); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MinLength rejects passwords shorter than its number of characters.
+type MinLength int
+
+func (n MinLength) Check(password string, _ Account) error {
+	if utf8.RuneCountInString(password) < int(n) {
+		return &Violation{Reason: fmt.Sprintf("Password must be at least %d characters long", n)}
+	}
+	return nil
+}
+
+// minPersonalLength is the length below which NoPersonalInfo ignores parts
+// of the email address and name, which would otherwise rule out too many
+// passwords.
+const minPersonalLength = 3
+
+// NoPersonalInfo rejects passwords that contain the email address of the
+// account, the part of it before the @, its name or a word of the name,
+// ignoring case.
+type NoPersonalInfo struct{}
+
+func (NoPersonalInfo) Check(password string, account Account) error {
+	password = strings.ToLower(password)
+	email := strings.ToLower(account.Email)
+	local, _, _ := strings.Cut(email, "@")
+	for _, part := range []string{email, local} {
+		if utf8.RuneCountInString(part) >= minPersonalLength && strings.Contains(password, part) {
+			return &Violation{Reason: "Password must not contain your email address"}
+		}
+	}
+	name := strings.ToLower(account.Name)
+	for _, part := range append(strings.Fields(name), name) {
+		if utf8.RuneCountInString(part) >= minPersonalLength && strings.Contains(password, part) {
+			return &Violation{Reason: "Password must not contain your name"}
+		}
+	}
+	return nil
+}
+
+// BreachedList rejects passwords known from data breaches. Like the range
+// API of Have I Been Pwned, it groups the SHA-1 hashes of the passwords by
+// their first five hex digits and only compares the rest within a group.
+type BreachedList struct {
+	ranges map[string][]string
+}
+
+// rangePrefix is the length of the hash prefix BreachedList groups by.
+const rangePrefix = 5
+
+// LoadBreachedList reads the list at path. Each line holds the uppercase or
+// lowercase hex SHA-1 hash of a password, optionally followed by a colon
+// and how often it was seen, as in the files of the Pwned Passwords
+// downloader. Blank lines and lines starting with # are skipped.
+func LoadBreachedList(path string) (*BreachedList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password list: %w", err)
+	}
+	defer f.Close()
+
+	list := &BreachedList{ranges: make(map[string][]string)}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		hash, _, _ := strings.Cut(text, ":")
+		hash = strings.ToUpper(hash)
+		if len(hash) != 2*sha1.Size {
+			return nil, fmt.Errorf("breached password list %s:%d: not a SHA-1 hash", path, line)
+		}
+		if _, err := hex.DecodeString(hash); err != nil {
+			return nil, fmt.Errorf("breached password list %s:%d: not a SHA-1 hash", path, line)
+		}
+		list.ranges[hash[:rangePrefix]] = append(list.ranges[hash[:rangePrefix]], hash[rangePrefix:])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read breached password list: %w", err)
+	}
+	for _, suffixes := range list.ranges {
+		sort.Strings(suffixes)
+	}
+	return list, nil
+}
+
+// Contains reports whether password is on the list.
+func (l *BreachedList) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	suffixes := l.ranges[hash[:rangePrefix]]
+	i := sort.SearchStrings(suffixes, hash[rangePrefix:])
+	return i < len(suffixes) && suffixes[i] == hash[rangePrefix:]
+}
+
+func (l *BreachedList) Check(password string, _ Account) error {
+	if l.Contains(password) {
+		return &Violation{Reason: "This password has appeared in a data breach, choose another one"}
+	}
+	return nil
+}
+
+// DefaultMinLength is the minimum password length when PASSWORD_MIN_LENGTH
+// is not set.
+const DefaultMinLength = 8
+
+// PolicyFromEnv builds the policy configured in the environment. Passwords
+// need at least PASSWORD_MIN_LENGTH characters and may not contain the
+// email address or name of their user. When PASSWORD_BREACHED_LIST names a
+// file, see LoadBreachedList, the passwords on it are rejected as well.
+func PolicyFromEnv() (Policy, error) {
+	minLength := DefaultMinLength
+	if value := os.Getenv("PASSWORD_MIN_LENGTH"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid PASSWORD_MIN_LENGTH value %q: must be a positive number", value)
+		}
+		minLength = n
+	}
+	policy := Policy{MinLength(minLength), NoPersonalInfo{}}
+
+	if path := os.Getenv("PASSWORD_BREACHED_LIST"); path != "" {
+		list, err := LoadBreachedList(path)
+		if err != nil {
+			return nil, err
+		}
+		policy = append(policy, list)
+	}
+	return policy, nil
+}
@@ -0,0 +1,230 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// checkRule reports the reason rule rejects password for account with, or
+// "" when it accepts it. Errors other than violations fail the test.
+func checkRule(t *testing.T, rule Rule, password string, account Account) string {
+	t.Helper()
+	err := rule.Check(password, account)
+	if err == nil {
+		return ""
+	}
+	var violation *Violation
+	if !errors.As(err, &violation) {
+		t.Fatalf("Check(%q) returned %v, want a *Violation", password, err)
+	}
+	return violation.Reason
+}
+
+func TestMinLength(t *testing.T) {
+	tests := []struct {
+		password string
+		ok       bool
+	}{
+		{"", false},
+		{"1234567", false},
+		{"12345678", true},
+		{"123456789", true},
+		// Length is counted in characters, not bytes.
+		{"äöüäöüä", false},
+		{"äöüäöüäö", true},
+	}
+	for _, tt := range tests {
+		reason := checkRule(t, MinLength(8), tt.password, Account{})
+		if (reason == "") != tt.ok {
+			t.Errorf("MinLength(8).Check(%q) = %q, want ok %t", tt.password, reason, tt.ok)
+		}
+		if reason != "" && reason != "Password must be at least 8 characters long" {
+			t.Errorf("got reason %q", reason)
+		}
+	}
+}
+
+func TestNoPersonalInfo(t *testing.T) {
+	jane := Account{Email: "Jane.Doe@example.com", Name: "Jane van Doe"}
+	const (
+		email = "Password must not contain your email address"
+		name  = "Password must not contain your name"
+	)
+	tests := []struct {
+		password string
+		account  Account
+		want     string
+	}{
+		{"correct horse battery", jane, ""},
+		{"my jane.doe@example.com!", jane, email},
+		{"JANE.DOE@EXAMPLE.COM", jane, email},
+		{"xxjane.doexx", jane, email},
+		{"jane van doe 2024", jane, name},
+		{"i am JANE!!", jane, name},
+		{"doe-a-deer", jane, name},
+		{"example.com rules", jane, ""},
+		// Parts shorter than three characters are ignored.
+		{"Aloha from al, bob", Account{Email: "al@example.com", Name: "Al Bo"}, ""},
+		{"Al Bo rocks", Account{Email: "al@example.com", Name: "Al Bo"}, name},
+		{"anything goes", Account{}, ""},
+	}
+	for _, tt := range tests {
+		if got := checkRule(t, NoPersonalInfo{}, tt.password, tt.account); got != tt.want {
+			t.Errorf("Check(%q, %+v) = %q, want %q", tt.password, tt.account, got, tt.want)
+		}
+	}
+}
+
+// writeList writes lines to a breached password list and returns its path.
+func writeList(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestBreachedList(t *testing.T) {
+	path := writeList(t,
+		"# Pwned Passwords sample",
+		"",
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824", // "password"
+		strings.ToLower(sha1Hex("letmein")),
+		"  "+strings.ToUpper(sha1Hex("trustno1"))+":12  ",
+	)
+	list, err := LoadBreachedList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A password whose hash shares the prefix of a listed one but not the
+	// rest must not match.
+	samePrefix := "5BAA6" + strings.Repeat("0", 35)
+	list.ranges["5BAA6"] = append([]string{samePrefix[5:]}, list.ranges["5BAA6"]...)
+
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"password", true},
+		{"letmein", true},
+		{"trustno1", true},
+		{"Password", false},
+		{"correct horse battery staple", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := list.Contains(tt.password); got != tt.want {
+			t.Errorf("Contains(%q) = %t, want %t", tt.password, got, tt.want)
+		}
+		reason := checkRule(t, list, tt.password, Account{})
+		if (reason != "") != tt.want {
+			t.Errorf("Check(%q) = %q, want rejected %t", tt.password, reason, tt.want)
+		}
+	}
+}
+
+func TestLoadBreachedListErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"missing file", filepath.Join(t.TempDir(), "missing.txt")},
+		{"short hash", writeList(t, sha1Hex("password")[:39])},
+		{"long hash", writeList(t, sha1Hex("password")+"0")},
+		{"not hex", writeList(t, strings.Repeat("Z", 40))},
+		{"plain text password", writeList(t, sha1Hex("a"), "hunter2")},
+	}
+	for _, tt := range tests {
+		if _, err := LoadBreachedList(tt.path); err == nil {
+			t.Errorf("%s: LoadBreachedList succeeded", tt.name)
+		}
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	policy := Policy{MinLength(8), NoPersonalInfo{}}
+	jane := Account{Email: "jane@example.com", Name: "Jane"}
+	tests := []struct {
+		password string
+		want     string
+	}{
+		{"correct horse", ""},
+		{"jane", "Password must be at least 8 characters long"},
+		{"jane1234", "Password must not contain your email address"},
+	}
+	for _, tt := range tests {
+		if got := checkRule(t, policy, tt.password, jane); got != tt.want {
+			t.Errorf("Check(%q) = %q, want %q", tt.password, got, tt.want)
+		}
+	}
+	if err := (Policy{}).Check("", Account{}); err != nil {
+		t.Errorf("empty policy rejected a password: %v", err)
+	}
+}
+
+func TestPolicyFromEnv(t *testing.T) {
+	list := writeList(t, sha1Hex("correct horse battery"))
+	tests := []struct {
+		name      string
+		minLength string
+		breached  string
+		// rejected and accepted are passwords the policy has to reject and
+		// accept for jane@example.com.
+		rejected, accepted []string
+	}{
+		{"defaults", "", "", []string{"1234567", "jane1234"}, []string{"12345678", "correct horse battery"}},
+		{"longer minimum", "12", "", []string{"12345678901"}, []string{"123456789012"}},
+		{"breached list", "", list, []string{"correct horse battery"}, []string{"correct horse battery staple"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PASSWORD_MIN_LENGTH", tt.minLength)
+			t.Setenv("PASSWORD_BREACHED_LIST", tt.breached)
+			policy, err := PolicyFromEnv()
+			if err != nil {
+				t.Fatal(err)
+			}
+			jane := Account{Email: "jane@example.com", Name: "Jane"}
+			for _, password := range tt.rejected {
+				if checkRule(t, policy, password, jane) == "" {
+					t.Errorf("accepted %q", password)
+				}
+			}
+			for _, password := range tt.accepted {
+				if reason := checkRule(t, policy, password, jane); reason != "" {
+					t.Errorf("rejected %q: %s", password, reason)
+				}
+			}
+		})
+	}
+}
+
+func TestPolicyFromEnvErrors(t *testing.T) {
+	tests := []struct {
+		minLength, breached string
+	}{
+		{"0", ""},
+		{"-8", ""},
+		{"eight", ""},
+		{"", filepath.Join(t.TempDir(), "missing.txt")},
+	}
+	for _, tt := range tests {
+		t.Setenv("PASSWORD_MIN_LENGTH", tt.minLength)
+		t.Setenv("PASSWORD_BREACHED_LIST", tt.breached)
+		if _, err := PolicyFromEnv(); err == nil {
+			t.Errorf("PolicyFromEnv with PASSWORD_MIN_LENGTH=%q PASSWORD_BREACHED_LIST=%q succeeded", tt.minLength, tt.breached)
+		}
+	}
+}
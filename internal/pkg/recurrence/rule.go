@@ -0,0 +1,403 @@
+// Package recurrence parses and expands the subset of RFC 5545 recurrence
+// rules (RRULE) used for repeating todos.
+//
+// Supported parts are FREQ (DAILY, WEEKLY, MONTHLY or YEARLY), INTERVAL,
+// BYDAY, BYMONTHDAY, UNTIL and COUNT. BYDAY filters the days of DAILY rules,
+// picks the days of the week for WEEKLY rules and, optionally with an
+// ordinal such as 2TU or -1FR, the days of the month for MONTHLY rules.
+// BYMONTHDAY is only accepted for MONTHLY rules. Weeks start on Monday.
+package recurrence
+
+import (
+	"fmt"
+	"iter"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency int
+
+const (
+	Daily Frequency = iota
+	Weekly
+	Monthly
+	Yearly
+)
+
+var frequencyNames = []string{"DAILY", "WEEKLY", "MONTHLY", "YEARLY"}
+
+func (f Frequency) String() string {
+	if f < Daily || f > Yearly {
+		return fmt.Sprintf("Frequency(%d)", int(f))
+	}
+	return frequencyNames[f]
+}
+
+// WeekdayNum is one BYDAY entry: a weekday, optionally restricted to its
+// Nth occurrence in the month (counted from the end when N is negative).
+type WeekdayNum struct {
+	Weekday time.Weekday
+	N       int
+}
+
+var weekdayNames = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+func (d WeekdayNum) String() string {
+	if d.N == 0 {
+		return weekdayNames[d.Weekday]
+	}
+	return strconv.Itoa(d.N) + weekdayNames[d.Weekday]
+}
+
+// Rule is a parsed recurrence rule. The zero value of Interval is treated as
+// 1, and a zero Count means the rule is not limited by count.
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	Until      *time.Time
+	Count      int
+}
+
+// maxPeriods bounds how many days, weeks, months or years are scanned for
+// occurrences, so rules that never match again do not loop forever.
+const maxPeriods = 100000
+
+// Parse parses an RRULE value such as "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH".
+// The "RRULE:" prefix is optional and the parts are case-insensitive.
+func Parse(s string) (Rule, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimPrefix(s, "RRULE:")
+	if s == "" {
+		return Rule{}, fmt.Errorf("recurrence: empty rule")
+	}
+
+	var rule Rule
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return Rule{}, fmt.Errorf("recurrence: malformed part %q", part)
+		}
+		if seen[name] {
+			return Rule{}, fmt.Errorf("recurrence: %s given more than once", name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			i := slices.Index(frequencyNames, value)
+			if i < 0 {
+				return Rule{}, fmt.Errorf("recurrence: unsupported FREQ %q (expected DAILY, WEEKLY, MONTHLY or YEARLY)", value)
+			}
+			rule.Freq = Frequency(i)
+		case "INTERVAL":
+			rule.Interval, err = parsePositive(name, value)
+		case "COUNT":
+			rule.Count, err = parsePositive(name, value)
+		case "UNTIL":
+			var until time.Time
+			until, err = parseUntil(value)
+			rule.Until = &until
+		case "BYDAY":
+			rule.ByDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseByMonthDay(value)
+		case "WKST":
+			if value != "MO" {
+				err = fmt.Errorf("recurrence: only WKST=MO is supported")
+			}
+		default:
+			err = fmt.Errorf("recurrence: unsupported part %s", name)
+		}
+		if err != nil {
+			return Rule{}, err
+		}
+	}
+
+	if !seen["FREQ"] {
+		return Rule{}, fmt.Errorf("recurrence: FREQ is required")
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return Rule{}, fmt.Errorf("recurrence: COUNT and UNTIL cannot be combined")
+	}
+	for _, day := range rule.ByDay {
+		if day.N != 0 && rule.Freq != Monthly {
+			return Rule{}, fmt.Errorf("recurrence: BYDAY ordinals such as %s need FREQ=MONTHLY", day)
+		}
+	}
+	if len(rule.ByDay) > 0 && rule.Freq == Yearly {
+		return Rule{}, fmt.Errorf("recurrence: BYDAY is not supported with FREQ=YEARLY")
+	}
+	if len(rule.ByMonthDay) > 0 && rule.Freq != Monthly {
+		return Rule{}, fmt.Errorf("recurrence: BYMONTHDAY needs FREQ=MONTHLY")
+	}
+	return rule, nil
+}
+
+func parsePositive(name, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("recurrence: %s must be a positive integer", name)
+	}
+	return n, nil
+}
+
+// parseUntil accepts the UTC date-time and date forms of UNTIL. A date
+// covers the whole day.
+func parseUntil(value string) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("20060102", value); err == nil {
+		return t.Add(24*time.Hour - time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("recurrence: UNTIL must look like 20261231 or 20261231T235959Z")
+}
+
+func parseByDay(value string) ([]WeekdayNum, error) {
+	var days []WeekdayNum
+	for _, item := range strings.Split(value, ",") {
+		if len(item) < 2 {
+			return nil, fmt.Errorf("recurrence: invalid BYDAY entry %q", item)
+		}
+		weekday := slices.Index(weekdayNames, item[len(item)-2:])
+		if weekday < 0 {
+			return nil, fmt.Errorf("recurrence: invalid BYDAY entry %q", item)
+		}
+		day := WeekdayNum{Weekday: time.Weekday(weekday)}
+		if ordinal := item[:len(item)-2]; ordinal != "" {
+			n, err := strconv.Atoi(ordinal)
+			if err != nil || n == 0 || n < -5 || n > 5 {
+				return nil, fmt.Errorf("recurrence: invalid BYDAY entry %q", item)
+			}
+			day.N = n
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
+
+func parseByMonthDay(value string) ([]int, error) {
+	var days []int
+	for _, item := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(item)
+		if err != nil || n == 0 || n < -31 || n > 31 {
+			return nil, fmt.Errorf("recurrence: invalid BYMONTHDAY entry %q", item)
+		}
+		days = append(days, n)
+	}
+	return days, nil
+}
+
+// String formats the rule in its canonical RRULE form, without the
+// "RRULE:" prefix.
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + r.Freq.String()}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = day.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, day := range r.ByMonthDay {
+			days[i] = strconv.Itoa(day)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Location returns the IANA time zone tzid that rules are expanded in, such
+// as "Europe/Berlin". It falls back to UTC when tzid is empty or unknown.
+func Location(tzid string) *time.Location {
+	location, err := time.LoadLocation(tzid)
+	if err != nil || tzid == "Local" {
+		return time.UTC
+	}
+	return location
+}
+
+// All returns the occurrences of the rule in order, starting with start
+// itself as RFC 5545 does for DTSTART. The expansion happens in the
+// location of start.
+func (r Rule) All(start time.Time) iter.Seq[time.Time] {
+	return func(yield func(time.Time) bool) {
+		emitted := 0
+		emit := func(t time.Time) bool {
+			if r.Until != nil && t.After(*r.Until) {
+				return false
+			}
+			if r.Count > 0 && emitted >= r.Count {
+				return false
+			}
+			emitted++
+			return yield(t)
+		}
+
+		if !emit(start) {
+			return
+		}
+		for period := 0; period < maxPeriods; period++ {
+			for _, t := range r.expand(start, period) {
+				if t.After(start) && !emit(t) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// After returns the first occurrence of the rule started at start that
+// falls strictly after t, and false when the rule ends before that.
+func (r Rule) After(start, t time.Time) (time.Time, bool) {
+	for occurrence := range r.All(start) {
+		if occurrence.After(t) {
+			return occurrence, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Next returns up to n occurrences of the rule started at start that fall
+// strictly after t.
+func (r Rule) Next(start, t time.Time, n int) []time.Time {
+	var occurrences []time.Time
+	for occurrence := range r.All(start) {
+		if len(occurrences) >= n {
+			break
+		}
+		if occurrence.After(t) {
+			occurrences = append(occurrences, occurrence)
+		}
+	}
+	return occurrences
+}
+
+// expand returns the candidate occurrences in the given period after start,
+// counted in units of the rule's frequency and interval, in order.
+func (r Rule) expand(start time.Time, period int) []time.Time {
+	step := period * max(r.Interval, 1)
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+	}
+
+	switch r.Freq {
+	case Daily:
+		t := at(start.Year(), start.Month(), start.Day()+step)
+		if len(r.ByDay) > 0 && !slices.ContainsFunc(r.ByDay, func(d WeekdayNum) bool { return d.Weekday == t.Weekday() }) {
+			return nil
+		}
+		return []time.Time{t}
+
+	case Weekly:
+		monday := start.Day() - daysSinceMonday(start.Weekday()) + 7*step
+		if len(r.ByDay) == 0 {
+			return []time.Time{at(start.Year(), start.Month(), start.Day()+7*step)}
+		}
+		var out []time.Time
+		for _, d := range r.ByDay {
+			out = append(out, at(start.Year(), start.Month(), monday+daysSinceMonday(d.Weekday)))
+		}
+		slices.SortFunc(out, time.Time.Compare)
+		return slices.CompactFunc(out, time.Time.Equal)
+
+	case Monthly:
+		first := at(start.Year(), start.Month()+time.Month(step), 1)
+		var days []int
+		switch {
+		case len(r.ByDay) > 0:
+			days = r.monthDaysByWeekday(first)
+		case len(r.ByMonthDay) > 0:
+			days = r.monthDays(first)
+		default:
+			if start.Day() <= daysIn(first) {
+				days = []int{start.Day()}
+			}
+		}
+		slices.Sort(days)
+		days = slices.Compact(days)
+		out := make([]time.Time, len(days))
+		for i, day := range days {
+			out[i] = at(first.Year(), first.Month(), day)
+		}
+		return out
+
+	case Yearly:
+		t := at(start.Year()+step, start.Month(), start.Day())
+		if t.Month() != start.Month() {
+			// February 29th only occurs in leap years.
+			return nil
+		}
+		return []time.Time{t}
+	}
+	return nil
+}
+
+// monthDays resolves BYMONTHDAY in the month starting at first.
+func (r Rule) monthDays(first time.Time) []int {
+	n := daysIn(first)
+	var days []int
+	for _, d := range r.ByMonthDay {
+		if d < 0 {
+			d = n + d + 1
+		}
+		if d >= 1 && d <= n {
+			days = append(days, d)
+		}
+	}
+	return days
+}
+
+// monthDaysByWeekday resolves BYDAY in the month starting at first, keeping
+// only the days also named by BYMONTHDAY when it is set.
+func (r Rule) monthDaysByWeekday(first time.Time) []int {
+	monthDays := r.monthDays(first)
+
+	n := daysIn(first)
+	var days []int
+	for _, d := range r.ByDay {
+		var matches []int
+		for day := 1 + (int(d.Weekday)-int(first.Weekday())+7)%7; day <= n; day += 7 {
+			matches = append(matches, day)
+		}
+		switch {
+		case d.N > 0 && d.N <= len(matches):
+			matches = matches[d.N-1 : d.N]
+		case d.N < 0 && -d.N <= len(matches):
+			matches = matches[len(matches)+d.N : len(matches)+d.N+1]
+		case d.N != 0:
+			matches = nil
+		}
+		for _, day := range matches {
+			if len(r.ByMonthDay) == 0 || slices.Contains(monthDays, day) {
+				days = append(days, day)
+			}
+		}
+	}
+	return days
+}
+
+func daysSinceMonday(d time.Weekday) int {
+	return (int(d) + 6) % 7
+}
+
+func daysIn(first time.Time) int {
+	return time.Date(first.Year(), first.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
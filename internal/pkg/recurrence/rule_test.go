@@ -0,0 +1,437 @@
+package recurrence
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// localLayout formats occurrences like the local DATE-TIME values of RFC
+// 5545.
+const localLayout = "20060102T150405"
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+// expand returns up to limit occurrences of rule started at start, which is
+// given in localLayout in loc.
+func expand(t *testing.T, rule, start string, loc *time.Location, limit int) []string {
+	t.Helper()
+	r, err := Parse(rule)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", rule, err)
+	}
+	dtstart, err := time.ParseInLocation(localLayout, start, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var occurrences []string
+	for occurrence := range r.All(dtstart) {
+		if len(occurrences) == limit {
+			break
+		}
+		if occurrence.Location() != loc {
+			t.Fatalf("occurrence %v is not in %v", occurrence, loc)
+		}
+		occurrences = append(occurrences, occurrence.Format(localLayout))
+	}
+	return occurrences
+}
+
+// TestAllRFC5545 expands the examples of RFC 5545, section 3.8.5.3, that
+// fit the supported subset. Examples with WKST=SU are only used where the
+// week start makes no difference.
+func TestAllRFC5545(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+	tests := []struct {
+		name  string
+		rule  string
+		start string
+		limit int
+		want  []string
+	}{
+		{
+			name:  "daily for 10 occurrences",
+			rule:  "RRULE:FREQ=DAILY;COUNT=10",
+			start: "19970902T090000",
+			want: []string{
+				"19970902T090000", "19970903T090000", "19970904T090000", "19970905T090000", "19970906T090000",
+				"19970907T090000", "19970908T090000", "19970909T090000", "19970910T090000", "19970911T090000",
+			},
+		},
+		{
+			name:  "every 10 days, 5 occurrences",
+			rule:  "FREQ=DAILY;INTERVAL=10;COUNT=5",
+			start: "19970902T090000",
+			want:  []string{"19970902T090000", "19970912T090000", "19970922T090000", "19971002T090000", "19971012T090000"},
+		},
+		{
+			name:  "weekly for 10 occurrences across the end of daylight saving time",
+			rule:  "FREQ=WEEKLY;COUNT=10",
+			start: "19970902T090000",
+			want: []string{
+				"19970902T090000", "19970909T090000", "19970916T090000", "19970923T090000", "19970930T090000",
+				"19971007T090000", "19971014T090000", "19971021T090000", "19971028T090000", "19971104T090000",
+			},
+		},
+		{
+			name:  "weekly on Tuesday and Thursday for 10 occurrences",
+			rule:  "FREQ=WEEKLY;COUNT=10;BYDAY=TU,TH",
+			start: "19970902T090000",
+			want: []string{
+				"19970902T090000", "19970904T090000", "19970909T090000", "19970911T090000", "19970916T090000",
+				"19970918T090000", "19970923T090000", "19970925T090000", "19970930T090000", "19971002T090000",
+			},
+		},
+		{
+			name:  "every other week on Monday, Wednesday and Friday until December 24",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;UNTIL=19971224T000000Z;BYDAY=MO,WE,FR",
+			start: "19970901T090000",
+			want: []string{
+				"19970901T090000", "19970903T090000", "19970905T090000", "19970915T090000", "19970917T090000",
+				"19970919T090000", "19970929T090000", "19971001T090000", "19971003T090000", "19971013T090000",
+				"19971015T090000", "19971017T090000", "19971027T090000", "19971029T090000", "19971031T090000",
+				"19971110T090000", "19971112T090000", "19971114T090000", "19971124T090000", "19971126T090000",
+				"19971128T090000", "19971208T090000", "19971210T090000", "19971212T090000", "19971222T090000",
+			},
+		},
+		{
+			name:  "every other week on Tuesday and Thursday for 8 occurrences",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;COUNT=8;BYDAY=TU,TH",
+			start: "19970902T090000",
+			want: []string{
+				"19970902T090000", "19970904T090000", "19970916T090000", "19970918T090000",
+				"19970930T090000", "19971002T090000", "19971014T090000", "19971016T090000",
+			},
+		},
+		{
+			name:  "every other week on Tuesday and Sunday with weeks starting on Monday",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=MO",
+			start: "19970805T090000",
+			want:  []string{"19970805T090000", "19970810T090000", "19970819T090000", "19970824T090000"},
+		},
+		{
+			name:  "monthly on the first Friday for 10 occurrences",
+			rule:  "FREQ=MONTHLY;COUNT=10;BYDAY=1FR",
+			start: "19970905T090000",
+			want: []string{
+				"19970905T090000", "19971003T090000", "19971107T090000", "19971205T090000", "19980102T090000",
+				"19980206T090000", "19980306T090000", "19980403T090000", "19980501T090000", "19980605T090000",
+			},
+		},
+		{
+			name:  "every other month on the first and last Sunday for 10 occurrences",
+			rule:  "FREQ=MONTHLY;INTERVAL=2;COUNT=10;BYDAY=1SU,-1SU",
+			start: "19970907T090000",
+			want: []string{
+				"19970907T090000", "19970928T090000", "19971102T090000", "19971130T090000", "19980104T090000",
+				"19980125T090000", "19980301T090000", "19980329T090000", "19980503T090000", "19980531T090000",
+			},
+		},
+		{
+			name:  "monthly on the second-to-last Monday for 6 months",
+			rule:  "FREQ=MONTHLY;COUNT=6;BYDAY=-2MO",
+			start: "19970922T090000",
+			want:  []string{"19970922T090000", "19971020T090000", "19971117T090000", "19971222T090000", "19980119T090000", "19980216T090000"},
+		},
+		{
+			name:  "monthly on the third-to-last day",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=-3",
+			start: "19970928T090000",
+			limit: 6,
+			want:  []string{"19970928T090000", "19971029T090000", "19971128T090000", "19971229T090000", "19980129T090000", "19980226T090000"},
+		},
+		{
+			name:  "monthly on the 2nd and 15th for 10 occurrences",
+			rule:  "FREQ=MONTHLY;COUNT=10;BYMONTHDAY=2,15",
+			start: "19970902T090000",
+			want: []string{
+				"19970902T090000", "19970915T090000", "19971002T090000", "19971015T090000", "19971102T090000",
+				"19971115T090000", "19971202T090000", "19971215T090000", "19980102T090000", "19980115T090000",
+			},
+		},
+		{
+			name:  "monthly on the first and last day for 10 occurrences",
+			rule:  "FREQ=MONTHLY;COUNT=10;BYMONTHDAY=1,-1",
+			start: "19970930T090000",
+			want: []string{
+				"19970930T090000", "19971001T090000", "19971031T090000", "19971101T090000", "19971130T090000",
+				"19971201T090000", "19971231T090000", "19980101T090000", "19980131T090000", "19980201T090000",
+			},
+		},
+		{
+			// The RFC excludes DTSTART with an EXDATE, which is not
+			// supported here.
+			name:  "every Friday the 13th",
+			rule:  "FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13",
+			start: "19970902T090000",
+			limit: 6,
+			want:  []string{"19970902T090000", "19980213T090000", "19980313T090000", "19981113T090000", "19990813T090000", "20001013T090000"},
+		},
+		{
+			name:  "every 4 years, 3 occurrences",
+			rule:  "FREQ=YEARLY;INTERVAL=4;COUNT=3",
+			start: "19961105T090000",
+			want:  []string{"19961105T090000", "20001105T090000", "20041105T090000"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit := tt.limit
+			if limit == 0 {
+				limit = 1000
+			}
+			got := expand(t, tt.rule, tt.start, newYork, limit)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllEdgeCases(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		start string
+		limit int
+		want  []string
+	}{
+		{
+			// Invalid dates such as April 31st are skipped, they do not
+			// move to the end of the month.
+			name:  "monthly on the 31st skips shorter months",
+			rule:  "FREQ=MONTHLY;COUNT=5",
+			start: "20250131T080000",
+			want:  []string{"20250131T080000", "20250331T080000", "20250531T080000", "20250731T080000", "20250831T080000"},
+		},
+		{
+			name:  "monthly on the 30th skips February",
+			rule:  "FREQ=MONTHLY;INTERVAL=1;COUNT=3",
+			start: "20240130T080000",
+			want:  []string{"20240130T080000", "20240330T080000", "20240430T080000"},
+		},
+		{
+			name:  "yearly on February 29th only in leap years",
+			rule:  "FREQ=YEARLY;COUNT=3",
+			start: "20240229T080000",
+			want:  []string{"20240229T080000", "20280229T080000", "20320229T080000"},
+		},
+		{
+			name:  "yearly on February 29th skips 2100",
+			rule:  "FREQ=YEARLY;COUNT=2",
+			start: "20960229T080000",
+			want:  []string{"20960229T080000", "21040229T080000"},
+		},
+		{
+			name:  "yearly on February 28th every year",
+			rule:  "FREQ=YEARLY;COUNT=3",
+			start: "20230228T080000",
+			want:  []string{"20230228T080000", "20240228T080000", "20250228T080000"},
+		},
+		{
+			name:  "last Friday, including five-Friday months",
+			rule:  "FREQ=MONTHLY;COUNT=4;BYDAY=-1FR",
+			start: "20250131T080000",
+			want:  []string{"20250131T080000", "20250228T080000", "20250328T080000", "20250425T080000"},
+		},
+		{
+			// Months with four Fridays have no fifth-to-last one.
+			name:  "fifth-to-last Friday only in five-Friday months",
+			rule:  "FREQ=MONTHLY;COUNT=3;BYDAY=-5FR",
+			start: "20250103T080000",
+			want:  []string{"20250103T080000", "20250502T080000", "20250801T080000"},
+		},
+		{
+			name:  "last weekday of the month by BYMONTHDAY and BYDAY",
+			rule:  "FREQ=MONTHLY;COUNT=3;BYDAY=MO,TU,WE,TH,FR;BYMONTHDAY=-1,-2,-3",
+			start: "20250131T080000",
+			limit: 5,
+			want:  []string{"20250131T080000", "20250226T080000", "20250227T080000"},
+		},
+		{
+			// A BYMONTHDAY that does not exist in a month rules out every
+			// day of it.
+			name:  "Friday the 31st skips months without a 31st",
+			rule:  "FREQ=MONTHLY;COUNT=3;BYDAY=FR;BYMONTHDAY=31",
+			start: "20250131T080000",
+			want:  []string{"20250131T080000", "20251031T080000", "20260731T080000"},
+		},
+		{
+			// The Monday before the Wednesday start is in the first week
+			// but before DTSTART.
+			name:  "weekly with BYDAY before the start weekday",
+			rule:  "FREQ=WEEKLY;COUNT=4;BYDAY=MO,WE",
+			start: "20250101T080000",
+			want:  []string{"20250101T080000", "20250106T080000", "20250108T080000", "20250113T080000"},
+		},
+		{
+			name:  "every other week with BYDAY before the start weekday",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=MO,FR",
+			start: "20250101T080000",
+			want:  []string{"20250101T080000", "20250103T080000", "20250113T080000", "20250117T080000"},
+		},
+		{
+			name:  "weekly on Sunday starting on Sunday",
+			rule:  "FREQ=WEEKLY;COUNT=3;BYDAY=SU",
+			start: "20250105T080000",
+			want:  []string{"20250105T080000", "20250112T080000", "20250119T080000"},
+		},
+		{
+			// DTSTART always counts as the first occurrence, even when the
+			// rule does not match it.
+			name:  "COUNT includes a DTSTART the rule does not match",
+			rule:  "FREQ=WEEKLY;COUNT=3;BYDAY=FR",
+			start: "20250101T080000",
+			want:  []string{"20250101T080000", "20250103T080000", "20250110T080000"},
+		},
+		{
+			name:  "COUNT=1 is DTSTART alone",
+			rule:  "FREQ=DAILY;COUNT=1",
+			start: "20250101T080000",
+			want:  []string{"20250101T080000"},
+		},
+		{
+			name:  "daily on weekdays",
+			rule:  "FREQ=DAILY;COUNT=4;BYDAY=MO,TU,WE,TH,FR",
+			start: "20250102T080000",
+			want:  []string{"20250102T080000", "20250103T080000", "20250106T080000", "20250107T080000"},
+		},
+		{
+			name:  "UNTIL as a date includes that day",
+			rule:  "FREQ=DAILY;UNTIL=20250103",
+			start: "20250101T230000",
+			want:  []string{"20250101T230000", "20250102T230000", "20250103T230000"},
+		},
+		{
+			name:  "UNTIL before DTSTART",
+			rule:  "FREQ=DAILY;UNTIL=20241231",
+			start: "20250101T080000",
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit := tt.limit
+			if limit == 0 {
+				limit = 1000
+			}
+			got := expand(t, tt.rule, tt.start, time.UTC, limit)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllUntilRFC5545(t *testing.T) {
+	// Daily until December 24, 1997: 113 occurrences, the last on December
+	// 23rd since UNTIL is midnight UTC.
+	got := expand(t, "FREQ=DAILY;UNTIL=19971224T000000Z", "19970902T090000", mustLoadLocation(t, "America/New_York"), 1000)
+	if len(got) != 113 || got[len(got)-1] != "19971223T090000" {
+		t.Errorf("got %d occurrences ending %s, want 113 ending 19971223T090000", len(got), got[len(got)-1])
+	}
+}
+
+func TestNext(t *testing.T) {
+	rule, err := Parse("FREQ=WEEKLY;COUNT=5;BYDAY=MO,WE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2025, 1, 1, 8, 0, 0, 0, time.UTC)
+
+	got := rule.Next(start, start, 10)
+	want := []time.Time{
+		time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC),
+		time.Date(2025, 1, 8, 8, 0, 0, 0, time.UTC),
+		time.Date(2025, 1, 13, 8, 0, 0, 0, time.UTC),
+		time.Date(2025, 1, 15, 8, 0, 0, 0, time.UTC),
+	}
+	if !slices.EqualFunc(got, want, time.Time.Equal) {
+		t.Errorf("Next after DTSTART: got %v, want %v", got, want)
+	}
+
+	next, ok := rule.After(start, want[1])
+	if !ok || !next.Equal(want[2]) {
+		t.Errorf("After(%v) = %v, %t, want %v", want[1], next, ok, want[2])
+	}
+	if next, ok := rule.After(start, want[3]); ok {
+		t.Errorf("After the last occurrence = %v, want none", next)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY"},
+		{"rrule:freq=weekly;interval=2;byday=mo,th", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH"},
+		{"FREQ=WEEKLY;INTERVAL=1", "FREQ=WEEKLY"},
+		{"FREQ=MONTHLY;BYDAY=2TU,-1FR;COUNT=4", "FREQ=MONTHLY;BYDAY=2TU,-1FR;COUNT=4"},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,-1;WKST=MO", "FREQ=MONTHLY;BYMONTHDAY=1,-1"},
+		{"FREQ=YEARLY;UNTIL=20261231T235959Z", "FREQ=YEARLY;UNTIL=20261231T235959Z"},
+		{"FREQ=DAILY;UNTIL=20261231", "FREQ=DAILY;UNTIL=20261231T235959Z"},
+	}
+	for _, tt := range tests {
+		rule, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if got := rule.String(); got != tt.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"RRULE:",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=-1",
+		"FREQ=DAILY;COUNT=3;UNTIL=20261231",
+		"FREQ=DAILY;UNTIL=2026-12-31",
+		"FREQ=DAILY;BYMONTH=1",
+		"FREQ=DAILY;COUNT",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=0MO",
+		"FREQ=MONTHLY;BYDAY=6MO",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=YEARLY;BYDAY=MO",
+		"FREQ=WEEKLY;WKST=SU",
+	} {
+		if rule, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) = %v, want an error", in, rule)
+		}
+	}
+}
+
+func TestLocation(t *testing.T) {
+	tests := []struct {
+		tzid, want string
+	}{
+		{"Europe/Berlin", "Europe/Berlin"},
+		{"", "UTC"},
+		{"Local", "UTC"},
+		{"Mars/Olympus_Mons", "UTC"},
+	}
+	for _, tt := range tests {
+		if got := Location(tt.tzid).String(); got != tt.want {
+			t.Errorf("Location(%q) = %s, want %s", tt.tzid, got, tt.want)
+		}
+	}
+}
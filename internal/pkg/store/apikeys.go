@@ -0,0 +1,11 @@
+package store
+
+import "time"
+
+// MaxAPIKeyNameLength is the longest API key name the api_keys table
+// accepts.
+const MaxAPIKeyNameLength = 100
+
+// apiKeyUseInterval is how often the last use of an API key is written, so
+// busy keys do not cause a write on every request.
+const apiKeyUseInterval = time.Minute
@@ -0,0 +1,26 @@
+package store
+
+import (
+	"slices"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+// MaxChecklistItemTitleLength is the longest title the checklist_items
+// table accepts.
+const MaxChecklistItemTitleLength = 255
+
+// isPermutation reports whether ids names every item of items exactly once.
+func isPermutation(items []models.ChecklistItem, ids []int) bool {
+	if len(ids) != len(items) {
+		return false
+	}
+	want := make([]int, len(items))
+	for i, item := range items {
+		want[i] = item.ID
+	}
+	got := slices.Clone(ids)
+	slices.Sort(want)
+	slices.Sort(got)
+	return slices.Equal(want, got)
+}
@@ -0,0 +1,12 @@
+package store
+
+// dialect hides the differences between the SQL databases SQLStore can run
+// on. Queries are written in PostgreSQL syntax and adapted by the dialect.
+type dialect interface {
+	// rebind rewrites the $N placeholders of query into the database's own
+	// placeholder syntax.
+	rebind(query string) string
+	// isUniqueViolation reports whether err was caused by a unique
+	// constraint.
+	isUniqueViolation(err error) bool
+}
@@ -0,0 +1,423 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+// MemoryStore is a thread-safe Store that keeps everything in process
+// memory. It is meant for tests and throwaway local runs; nothing survives a
+// restart.
+type MemoryStore struct {
+	mu                 sync.RWMutex
+	users              map[int]models.ListCurator
+	todos              map[int]memoryTodo
+	tags               map[int]memoryTag
+	lists              map[int]memoryList
+	nextUserID         int
+	nextTodoID         int
+	nextTagID          int
+	nextListID         int
+	nextItemID         int
+	nextTokenID        int
+	nextRevocationID   int
+	nextResetID        int
+	nextVerificationID int
+	nextChallengeID    int
+	nextAPIKeyID       int
+	nextIdentityID     int
+	nextOIDCLoginID    int
+	nextEmailChangeID  int
+
+	// refreshTokens is keyed by token hash.
+	refreshTokens map[string]models.RefreshToken
+	// tokenRevocations is kept in the order the revocations were made.
+	tokenRevocations []models.TokenRevocation
+	// passwordResets is keyed by token hash.
+	passwordResets map[string]models.PasswordReset
+	// emailVerifications is keyed by token hash.
+	emailVerifications map[string]models.EmailVerification
+	// totp is keyed by user ID.
+	totp map[int]models.TOTPEnrollment
+	// recoveryCodes maps user IDs to the hashes of their recovery codes and
+	// whether each has been used.
+	recoveryCodes map[int]map[string]bool
+	// mfaChallenges is keyed by token hash.
+	mfaChallenges map[string]models.MFAChallenge
+	// apiKeys is keyed by key hash.
+	apiKeys map[string]models.APIKey
+	// identities is keyed by provider and subject.
+	identities map[identityKey]models.UserIdentity
+	// oidcLogins is keyed by state hash.
+	oidcLogins map[string]models.OIDCLogin
+	// emailChanges is keyed by token hash.
+	emailChanges map[string]models.EmailChange
+}
+
+// memoryTodo is a stored todo together with the user that created it. Tags
+// are kept as IDs so renaming a tag shows up on every todo carrying it, and
+// checklist items are kept in order.
+type memoryTodo struct {
+	userID    int
+	item      models.TodoItem
+	tagIDs    []int
+	checklist []models.ChecklistItem
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:              make(map[int]models.ListCurator),
+		todos:              make(map[int]memoryTodo),
+		tags:               make(map[int]memoryTag),
+		lists:              make(map[int]memoryList),
+		refreshTokens:      make(map[string]models.RefreshToken),
+		passwordResets:     make(map[string]models.PasswordReset),
+		emailVerifications: make(map[string]models.EmailVerification),
+		totp:               make(map[int]models.TOTPEnrollment),
+		recoveryCodes:      make(map[int]map[string]bool),
+		mfaChallenges:      make(map[string]models.MFAChallenge),
+		apiKeys:            make(map[string]models.APIKey),
+		identities:         make(map[identityKey]models.UserIdentity),
+		oidcLogins:         make(map[string]models.OIDCLogin),
+		emailChanges:       make(map[string]models.EmailChange),
+		nextUserID:         1,
+		nextTodoID:         1,
+		nextTagID:          1,
+		nextListID:         1,
+		nextItemID:         1,
+		nextTokenID:        1,
+		nextRevocationID:   1,
+		nextResetID:        1,
+		nextVerificationID: 1,
+		nextChallengeID:    1,
+		nextAPIKeyID:       1,
+		nextIdentityID:     1,
+		nextOIDCLoginID:    1,
+		nextEmailChangeID:  1,
+	}
+}
+
+// lookupTodo returns the stored todo if it exists and is in one of the lists
+// userID is a member of. Callers must hold s.mu.
+func (s *MemoryStore) lookupTodo(userID, todoID int) (memoryTodo, bool) {
+	stored, ok := s.todos[todoID]
+	if !ok {
+		return memoryTodo{}, false
+	}
+	if _, ok := s.listRole(userID, stored.item.ListID); !ok {
+		return memoryTodo{}, false
+	}
+	return stored, true
+}
+
+// editableTodo is lookupTodo for changes: it also checks that userID may
+// edit the todos of its list. Callers must hold s.mu.
+func (s *MemoryStore) editableTodo(userID, todoID int) (memoryTodo, error) {
+	stored, ok := s.lookupTodo(userID, todoID)
+	if !ok {
+		return memoryTodo{}, ErrNotFound
+	}
+	if role, _ := s.listRole(userID, stored.item.ListID); !role.CanEdit() {
+		return memoryTodo{}, ErrForbidden
+	}
+	return stored, nil
+}
+
+func (s *MemoryStore) CreateTodo(_ context.Context, userID int, todo *models.TodoItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	listID, err := s.resolveList(userID, todo.ListID)
+	if err != nil {
+		return err
+	}
+
+	todo.ID = s.nextTodoID
+	todo.ListID = listID
+	todo.Completed = false
+	todo.CompletedAt = nil
+	todo.NextOccurrenceID = nil
+	todo.RecurrenceStart = nil
+	todo.RecurrenceTZ = ""
+	if todo.Recurrence != "" {
+		todo.RecurrenceStart = todo.DueAt
+		todo.RecurrenceTZ = s.users[userID].Timezone
+	}
+	todo.CreatedAt = time.Now()
+	todo.UpdatedAt = todo.CreatedAt
+	s.nextTodoID++
+	stored := memoryTodo{userID: userID, item: *todo, tagIDs: s.ensureTags(userID, todo.Tags)}
+	s.todos[todo.ID] = stored
+	*todo = s.todoItem(stored)
+	return nil
+}
+
+func (s *MemoryStore) GetTodo(_ context.Context, userID, todoID int) (models.TodoItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stored, ok := s.lookupTodo(userID, todoID)
+	if !ok {
+		return models.TodoItem{}, ErrNotFound
+	}
+	return s.todoItem(stored), nil
+}
+
+func (s *MemoryStore) UpdateTodo(_ context.Context, userID int, todo *models.TodoItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.editableTodo(userID, todo.ID)
+	if err != nil {
+		return err
+	}
+	if !todo.UpdatedAt.IsZero() && !todo.UpdatedAt.Equal(stored.item.UpdatedAt) {
+		return ErrModified
+	}
+	if todo.ListID != 0 {
+		listID, err := s.resolveList(userID, todo.ListID)
+		if err != nil {
+			return err
+		}
+		stored.item.ListID = listID
+	}
+	stored.item.Title = todo.Title
+	stored.item.Desc = todo.Desc
+	stored.item.DueAt = todo.DueAt
+	stored.item.Priority = todo.Priority
+	stored.item.AutoComplete = todo.AutoComplete
+	if todo.Recurrence == "" {
+		stored.item.RecurrenceStart = nil
+		stored.item.RecurrenceTZ = ""
+	} else if todo.Recurrence != stored.item.Recurrence {
+		stored.item.RecurrenceStart = todo.DueAt
+		stored.item.RecurrenceTZ = s.users[userID].Timezone
+	}
+	stored.item.Recurrence = todo.Recurrence
+	stored.item.UpdatedAt = time.Now()
+	if todo.Tags != nil {
+		stored.tagIDs = s.ensureTags(stored.userID, todo.Tags)
+	}
+	syncChecklistCompletion(&stored)
+	s.advanceRecurrence(&stored)
+	s.todos[todo.ID] = stored
+	*todo = s.todoItem(stored)
+	return nil
+}
+
+func (s *MemoryStore) SetTodoCompleted(_ context.Context, userID, todoID int, completed bool) (models.TodoItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.editableTodo(userID, todoID)
+	if err != nil {
+		return models.TodoItem{}, err
+	}
+	now := time.Now()
+	stored.item.Completed = completed
+	stored.item.UpdatedAt = now
+	if !completed {
+		stored.item.CompletedAt = nil
+	} else if stored.item.CompletedAt == nil {
+		stored.item.CompletedAt = &now
+	}
+	s.advanceRecurrence(&stored)
+	s.todos[todoID] = stored
+	return s.todoItem(stored), nil
+}
+
+func (s *MemoryStore) DeleteTodo(_ context.Context, userID, todoID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.editableTodo(userID, todoID); err != nil {
+		return err
+	}
+	delete(s.todos, todoID)
+	return nil
+}
+
+func (s *MemoryStore) ListTodos(_ context.Context, userID int, filter TodoFilter) ([]models.TodoItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var todos []models.TodoItem
+	for _, stored := range s.todos {
+		if _, ok := s.listRole(userID, stored.item.ListID); !ok {
+			continue
+		}
+		todo := s.todoItem(stored)
+		if !matchesFilter(todo, filter) {
+			continue
+		}
+		todos = append(todos, todo)
+	}
+	sortTodos(todos, filter.Sort)
+
+	return paginate(todos, filter.Limit, filter.Offset), nil
+}
+
+// matchesFilter reports whether todo passes every condition of filter,
+// mirroring the WHERE clause built by SQLStore.ListTodos.
+func matchesFilter(todo models.TodoItem, filter TodoFilter) bool {
+	if filter.ListID != 0 && todo.ListID != filter.ListID {
+		return false
+	}
+	if filter.Completed != nil && todo.Completed != *filter.Completed {
+		return false
+	}
+	if filter.DueBefore != nil && (todo.DueAt == nil || !todo.DueAt.Before(*filter.DueBefore)) {
+		return false
+	}
+	if filter.DueAfter != nil && (todo.DueAt == nil || !todo.DueAt.After(*filter.DueAfter)) {
+		return false
+	}
+	if tags := normalizeTagNames(filter.Tags); len(tags) > 0 {
+		matched := 0
+		for _, name := range tags {
+			if slices.Contains(todo.Tags, name) {
+				matched++
+			}
+		}
+		if matched == 0 || (filter.MatchAllTags && matched < len(tags)) {
+			return false
+		}
+	}
+	return true
+}
+
+// sortTodos orders todos the same way todoOrderBy orders SQL results:
+// by each key in turn with NULLs last, then by ID.
+func sortTodos(todos []models.TodoItem, sorts []TodoSort) {
+	sort.Slice(todos, func(i, j int) bool {
+		for _, key := range sorts {
+			c := compareTodoField(todos[i], todos[j], key.Field)
+			if c == 0 {
+				continue
+			}
+			if key.Desc && !isNullOrdering(todos[i], todos[j], key.Field) {
+				c = -c
+			}
+			return c < 0
+		}
+		return todos[i].ID < todos[j].ID
+	})
+}
+
+// compareTodoField compares one sortable field of a and b, placing unset
+// optional timestamps after set ones.
+func compareTodoField(a, b models.TodoItem, field string) int {
+	switch field {
+	case "id":
+		return cmp.Compare(a.ID, b.ID)
+	case "title":
+		return strings.Compare(a.Title, b.Title)
+	case "priority":
+		return cmp.Compare(a.Priority, b.Priority)
+	case "due_at":
+		return compareOptionalTimes(a.DueAt, b.DueAt)
+	case "completed_at":
+		return compareOptionalTimes(a.CompletedAt, b.CompletedAt)
+	case "created_at":
+		return a.CreatedAt.Compare(b.CreatedAt)
+	case "updated_at":
+		return a.UpdatedAt.Compare(b.UpdatedAt)
+	}
+	return 0
+}
+
+// isNullOrdering reports whether exactly one of a and b lacks the optional
+// field, in which case the order must not flip for descending sorts.
+func isNullOrdering(a, b models.TodoItem, field string) bool {
+	switch field {
+	case "due_at":
+		return (a.DueAt == nil) != (b.DueAt == nil)
+	case "completed_at":
+		return (a.CompletedAt == nil) != (b.CompletedAt == nil)
+	}
+	return false
+}
+
+func compareOptionalTimes(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	return a.Compare(*b)
+}
+
+// paginate returns the limit items of items that follow the first offset.
+func paginate[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
+
+func (s *MemoryStore) CreateUser(_ context.Context, user *models.ListCurator) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.insertUser(user)
+}
+
+// insertUser stores user together with their default list. Callers must
+// hold s.mu for writing.
+func (s *MemoryStore) insertUser(user *models.ListCurator) error {
+	for _, existing := range s.users {
+		if existing.Email == user.Email {
+			return ErrConflict
+		}
+	}
+	if user.Timezone == "" {
+		user.Timezone = DefaultTimezone
+	}
+	if user.Locale == "" {
+		user.Locale = DefaultLocale
+	}
+	user.ID = s.nextUserID
+	s.nextUserID++
+	s.users[user.ID] = *user
+	s.insertList(user.ID, &models.TodoList{Name: DefaultListName, IsDefault: true})
+	return nil
+}
+
+func (s *MemoryStore) GetUser(_ context.Context, userID int) (models.ListCurator, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return models.ListCurator{}, ErrNotFound
+	}
+	return user, nil
+}
+
+func (s *MemoryStore) FindUserByEmail(_ context.Context, email string) (models.ListCurator, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return models.ListCurator{}, ErrNotFound
+}
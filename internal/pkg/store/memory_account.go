@@ -0,0 +1,208 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+func (s *MemoryStore) UpdateProfile(_ context.Context, user *models.ListCurator) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.users[user.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	stored.Name = user.Name
+	stored.Timezone = user.Timezone
+	stored.Locale = user.Locale
+	s.users[user.ID] = stored
+	*user = stored
+	return nil
+}
+
+func (s *MemoryStore) ChangePassword(_ context.Context, userID int, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	user.Password = passwordHash
+	s.users[userID] = user
+	s.revokeRefreshTokens(func(stored models.RefreshToken) bool { return stored.UserID == userID })
+	return nil
+}
+
+func (s *MemoryStore) RehashPassword(_ context.Context, userID int, oldHash, newHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	if user.Password == oldHash {
+		user.Password = newHash
+		s.users[userID] = user
+	}
+	return nil
+}
+
+func (s *MemoryStore) CreateEmailChange(_ context.Context, change *models.EmailChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, stored := range s.emailChanges {
+		if stored.UserID == change.UserID {
+			delete(s.emailChanges, hash)
+		}
+	}
+	change.ID = s.nextEmailChangeID
+	change.CreatedAt = time.Now()
+	s.nextEmailChangeID++
+	s.emailChanges[change.TokenHash] = *change
+	return nil
+}
+
+func (s *MemoryStore) ConfirmEmailChange(_ context.Context, tokenHash string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	change, ok := s.emailChanges[tokenHash]
+	if !ok || !change.ExpiresAt.After(time.Now()) {
+		return 0, ErrNotFound
+	}
+	user, ok := s.users[change.UserID]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	for _, existing := range s.users {
+		if existing.Email == change.NewEmail && existing.ID != user.ID {
+			return 0, ErrConflict
+		}
+	}
+
+	delete(s.emailChanges, tokenHash)
+	user.Email = change.NewEmail
+	user.EmailVerified = true
+	s.users[user.ID] = user
+	// Tokens mailed to the previous address must not act on the account any
+	// more.
+	s.deleteEmailVerifications(user.ID)
+	for hash, reset := range s.passwordResets {
+		if reset.UserID == user.ID && reset.UsedAt == nil {
+			delete(s.passwordResets, hash)
+		}
+	}
+	return user.ID, nil
+}
+
+func (s *MemoryStore) ScheduleUserDeletion(_ context.Context, userID int, deleteAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	user.DeleteAt = &deleteAt
+	s.users[userID] = user
+	s.revokeRefreshTokens(func(stored models.RefreshToken) bool { return stored.UserID == userID })
+	return nil
+}
+
+func (s *MemoryStore) CancelUserDeletion(_ context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user, ok := s.users[userID]; ok {
+		user.DeleteAt = nil
+		s.users[userID] = user
+	}
+	return nil
+}
+
+func (s *MemoryStore) PurgeDeletedUsers(_ context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for userID, user := range s.users {
+		if user.DeleteAt != nil && !user.DeleteAt.After(before) {
+			s.deleteUser(userID)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// deleteUser deletes userID with their lists, their todos and every other
+// record of theirs. Callers must hold s.mu for writing.
+func (s *MemoryStore) deleteUser(userID int) {
+	for listID, list := range s.lists {
+		if list.userID == userID {
+			delete(s.lists, listID)
+			continue
+		}
+		delete(list.members, userID)
+	}
+	for todoID, todo := range s.todos {
+		if _, ok := s.lists[todo.item.ListID]; todo.userID == userID || !ok {
+			delete(s.todos, todoID)
+		}
+	}
+	var tagIDs []int
+	for tagID, tag := range s.tags {
+		if tag.userID == userID {
+			tagIDs = append(tagIDs, tagID)
+			delete(s.tags, tagID)
+		}
+	}
+	for todoID, todo := range s.todos {
+		todo.tagIDs = slices.DeleteFunc(todo.tagIDs, func(id int) bool { return slices.Contains(tagIDs, id) })
+		s.todos[todoID] = todo
+	}
+
+	for hash, token := range s.refreshTokens {
+		if token.UserID == userID {
+			delete(s.refreshTokens, hash)
+		}
+	}
+	for hash, reset := range s.passwordResets {
+		if reset.UserID == userID {
+			delete(s.passwordResets, hash)
+		}
+	}
+	s.deleteEmailVerifications(userID)
+	for hash, change := range s.emailChanges {
+		if change.UserID == userID {
+			delete(s.emailChanges, hash)
+		}
+	}
+	delete(s.totp, userID)
+	delete(s.recoveryCodes, userID)
+	for hash, challenge := range s.mfaChallenges {
+		if challenge.UserID == userID {
+			delete(s.mfaChallenges, hash)
+		}
+	}
+	for hash, key := range s.apiKeys {
+		if key.UserID == userID {
+			delete(s.apiKeys, hash)
+		}
+	}
+	for key, identity := range s.identities {
+		if identity.UserID == userID {
+			delete(s.identities, key)
+		}
+	}
+	s.tokenRevocations = slices.DeleteFunc(s.tokenRevocations, func(revocation models.TokenRevocation) bool {
+		return revocation.UserID == userID
+	})
+	delete(s.users, userID)
+}
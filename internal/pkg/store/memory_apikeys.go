@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+func (s *MemoryStore) CreateAPIKey(_ context.Context, key *models.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stored := range s.apiKeys {
+		if stored.UserID == key.UserID && stored.Name == key.Name {
+			return ErrConflict
+		}
+	}
+	key.ID = s.nextAPIKeyID
+	key.Scopes = slices.Clone(key.Scopes)
+	key.LastUsedAt = nil
+	key.CreatedAt = time.Now()
+	s.nextAPIKeyID++
+	s.apiKeys[key.KeyHash] = *key
+	return nil
+}
+
+func (s *MemoryStore) ListAPIKeys(_ context.Context, userID int) ([]models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []models.APIKey
+	for _, stored := range s.apiKeys {
+		if stored.UserID == userID {
+			stored.Scopes = slices.Clone(stored.Scopes)
+			keys = append(keys, stored)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys, nil
+}
+
+func (s *MemoryStore) DeleteAPIKey(_ context.Context, userID, keyID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, stored := range s.apiKeys {
+		if stored.ID == keyID && stored.UserID == userID {
+			delete(s.apiKeys, hash)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *MemoryStore) AuthenticateAPIKey(_ context.Context, keyHash string) (models.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.apiKeys[keyHash]
+	now := time.Now()
+	if !ok || (key.ExpiresAt != nil && !key.ExpiresAt.After(now)) || s.users[key.UserID].DeleteAt != nil {
+		return models.APIKey{}, ErrNotFound
+	}
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyUseInterval {
+		key.LastUsedAt = &now
+		s.apiKeys[keyHash] = key
+	}
+	key.Scopes = slices.Clone(key.Scopes)
+	return key, nil
+}
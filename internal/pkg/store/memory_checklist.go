@@ -0,0 +1,168 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+func (s *MemoryStore) ListChecklistItems(_ context.Context, userID, todoID int) ([]models.ChecklistItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stored, ok := s.lookupTodo(userID, todoID)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]models.ChecklistItem{}, stored.checklist...), nil
+}
+
+func (s *MemoryStore) AddChecklistItem(_ context.Context, userID, todoID int, item *models.ChecklistItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.editableTodo(userID, todoID)
+	if err != nil {
+		return err
+	}
+	if item.Position < 0 || item.Position > len(stored.checklist) {
+		item.Position = len(stored.checklist)
+	}
+	item.ID = s.nextItemID
+	item.TodoID = todoID
+	item.Done = false
+	item.CreatedAt = time.Now()
+	item.UpdatedAt = item.CreatedAt
+	s.nextItemID++
+
+	stored.checklist = slices.Insert(slices.Clone(stored.checklist), item.Position, *item)
+	s.saveChecklist(stored)
+	return nil
+}
+
+func (s *MemoryStore) RenameChecklistItem(_ context.Context, userID, todoID int, item *models.ChecklistItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, i, err := s.lookupChecklistItem(userID, todoID, item.ID)
+	if err != nil {
+		return err
+	}
+	stored.checklist[i].Title = item.Title
+	stored.checklist[i].UpdatedAt = time.Now()
+	*item = stored.checklist[i]
+	s.saveChecklist(stored)
+	return nil
+}
+
+func (s *MemoryStore) ToggleChecklistItem(_ context.Context, userID, todoID, itemID int) (models.ChecklistItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, i, err := s.lookupChecklistItem(userID, todoID, itemID)
+	if err != nil {
+		return models.ChecklistItem{}, err
+	}
+	stored.checklist[i].Done = !stored.checklist[i].Done
+	stored.checklist[i].UpdatedAt = time.Now()
+	s.saveChecklist(stored)
+	return stored.checklist[i], nil
+}
+
+func (s *MemoryStore) ReorderChecklist(_ context.Context, userID, todoID int, itemIDs []int) ([]models.ChecklistItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.editableTodo(userID, todoID)
+	if err != nil {
+		return nil, err
+	}
+	if !isPermutation(stored.checklist, itemIDs) {
+		return nil, ErrChecklistOrder
+	}
+	now := time.Now()
+	checklist := make([]models.ChecklistItem, 0, len(itemIDs))
+	for _, itemID := range itemIDs {
+		i := slices.IndexFunc(stored.checklist, func(item models.ChecklistItem) bool { return item.ID == itemID })
+		item := stored.checklist[i]
+		item.UpdatedAt = now
+		checklist = append(checklist, item)
+	}
+	stored.checklist = checklist
+	s.saveChecklist(stored)
+	return append([]models.ChecklistItem{}, stored.checklist...), nil
+}
+
+func (s *MemoryStore) DeleteChecklistItem(_ context.Context, userID, todoID, itemID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, i, err := s.lookupChecklistItem(userID, todoID, itemID)
+	if err != nil {
+		return err
+	}
+	stored.checklist = slices.Delete(stored.checklist, i, i+1)
+	s.saveChecklist(stored)
+	return nil
+}
+
+// lookupChecklistItem returns the editable todoID together with a private
+// copy of its checklist and the index of itemID in it. Callers must hold
+// s.mu for writing.
+func (s *MemoryStore) lookupChecklistItem(userID, todoID, itemID int) (memoryTodo, int, error) {
+	stored, err := s.editableTodo(userID, todoID)
+	if err != nil {
+		return memoryTodo{}, 0, err
+	}
+	stored.checklist = slices.Clone(stored.checklist)
+	i := slices.IndexFunc(stored.checklist, func(item models.ChecklistItem) bool { return item.ID == itemID })
+	if i < 0 {
+		return memoryTodo{}, 0, ErrItemNotFound
+	}
+	return stored, i, nil
+}
+
+// saveChecklist renumbers the checklist of stored, applies the completion
+// rules and writes the todo back. Callers must hold s.mu for writing.
+func (s *MemoryStore) saveChecklist(stored memoryTodo) {
+	for i := range stored.checklist {
+		stored.checklist[i].Position = i
+	}
+	stored.item.UpdatedAt = time.Now()
+	syncChecklistCompletion(&stored)
+	s.advanceRecurrence(&stored)
+	s.todos[stored.item.ID] = stored
+}
+
+// syncChecklistCompletion completes a todo that completes automatically
+// when every checklist item is done and reopens it when one is not.
+func syncChecklistCompletion(stored *memoryTodo) {
+	progress := checklistProgress(stored.checklist)
+	if !stored.item.AutoComplete || progress == nil {
+		return
+	}
+	stored.item.Completed = progress.Done == progress.Total
+	if !stored.item.Completed {
+		stored.item.CompletedAt = nil
+	} else if stored.item.CompletedAt == nil {
+		now := stored.item.UpdatedAt
+		stored.item.CompletedAt = &now
+	}
+}
+
+// checklistProgress counts the items of checklist, or returns nil when it is
+// empty.
+func checklistProgress(checklist []models.ChecklistItem) *models.ChecklistProgress {
+	if len(checklist) == 0 {
+		return nil
+	}
+	progress := &models.ChecklistProgress{Total: len(checklist)}
+	for _, item := range checklist {
+		if item.Done {
+			progress.Done++
+		}
+	}
+	return progress
+}
@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+func (s *MemoryStore) CreateEmailVerification(_ context.Context, verification *models.EmailVerification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteEmailVerifications(verification.UserID)
+	verification.ID = s.nextVerificationID
+	verification.CreatedAt = time.Now()
+	s.nextVerificationID++
+	s.emailVerifications[verification.TokenHash] = *verification
+	return nil
+}
+
+func (s *MemoryStore) VerifyEmail(_ context.Context, tokenHash string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	verification, ok := s.emailVerifications[tokenHash]
+	if !ok || !verification.ExpiresAt.After(time.Now()) {
+		return 0, ErrNotFound
+	}
+	user, ok := s.users[verification.UserID]
+	if !ok {
+		return 0, ErrNotFound
+	}
+
+	user.EmailVerified = true
+	s.users[user.ID] = user
+	s.deleteEmailVerifications(user.ID)
+	return user.ID, nil
+}
+
+// deleteEmailVerifications forgets every token sent to userID. Callers must
+// hold s.mu for writing.
+func (s *MemoryStore) deleteEmailVerifications(userID int) {
+	for hash, stored := range s.emailVerifications {
+		if stored.UserID == userID {
+			delete(s.emailVerifications, hash)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+// memoryList is a stored list together with the user that owns it and the
+// members it is shared with, the owner included.
+type memoryList struct {
+	userID  int
+	list    models.TodoList
+	members map[int]memoryMember
+}
+
+func (s *MemoryStore) CreateList(_ context.Context, userID int, list *models.TodoList) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list.IsDefault = false
+	s.insertList(userID, list)
+	return nil
+}
+
+// insertList stores list as a new list owned by userID. Callers must hold
+// s.mu for writing.
+func (s *MemoryStore) insertList(userID int, list *models.TodoList) {
+	list.ID = s.nextListID
+	list.Role = models.RoleOwner
+	list.CreatedAt = time.Now()
+	list.UpdatedAt = list.CreatedAt
+	s.nextListID++
+	s.lists[list.ID] = memoryList{
+		userID:  userID,
+		list:    *list,
+		members: map[int]memoryMember{userID: {role: models.RoleOwner, createdAt: list.CreatedAt}},
+	}
+}
+
+// listFor returns the stored list as seen by userID, which must be one of
+// its members. Callers must hold s.mu.
+func (stored memoryList) listFor(userID int) models.TodoList {
+	list := stored.list
+	list.IsDefault = list.IsDefault && stored.userID == userID
+	list.Role = stored.members[userID].role
+	return list
+}
+
+func (s *MemoryStore) GetList(_ context.Context, userID, listID int) (models.TodoList, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.listRole(userID, listID); !ok {
+		return models.TodoList{}, ErrNotFound
+	}
+	return s.lists[listID].listFor(userID), nil
+}
+
+func (s *MemoryStore) ListLists(_ context.Context, userID int) ([]models.TodoList, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var lists []models.TodoList
+	for _, stored := range s.lists {
+		if _, ok := stored.members[userID]; ok {
+			lists = append(lists, stored.listFor(userID))
+		}
+	}
+	sort.Slice(lists, func(i, j int) bool {
+		if lists[i].IsDefault != lists[j].IsDefault {
+			return lists[i].IsDefault
+		}
+		if lists[i].Name != lists[j].Name {
+			return lists[i].Name < lists[j].Name
+		}
+		return lists[i].ID < lists[j].ID
+	})
+	return lists, nil
+}
+
+func (s *MemoryStore) RenameList(_ context.Context, userID int, list *models.TodoList) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.requireListOwner(userID, list.ID); err != nil {
+		return err
+	}
+	stored := s.lists[list.ID]
+	stored.list.Name = list.Name
+	stored.list.UpdatedAt = time.Now()
+	s.lists[list.ID] = stored
+	*list = stored.listFor(userID)
+	return nil
+}
+
+func (s *MemoryStore) DeleteList(_ context.Context, userID, listID, moveTo int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.requireListOwner(userID, listID); err != nil {
+		return err
+	}
+	if s.lists[listID].list.IsDefault {
+		return ErrDefaultList
+	}
+	if moveTo != 0 {
+		if moveTo == listID {
+			return ErrListNotFound
+		}
+		if _, err := s.resolveList(userID, moveTo); errors.Is(err, ErrForbidden) {
+			return ErrListNotFound
+		} else if err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	for todoID, todo := range s.todos {
+		if todo.item.ListID != listID {
+			continue
+		}
+		if moveTo == 0 {
+			delete(s.todos, todoID)
+			continue
+		}
+		todo.item.ListID = moveTo
+		todo.item.UpdatedAt = now
+		s.todos[todoID] = todo
+	}
+	delete(s.lists, listID)
+	return nil
+}
+
+// resolveList returns listID if userID may add todos to it, or the ID of the
+// user's default list when listID is zero. Callers must hold s.mu.
+func (s *MemoryStore) resolveList(userID, listID int) (int, error) {
+	if listID == 0 {
+		for id, stored := range s.lists {
+			if stored.userID == userID && stored.list.IsDefault {
+				return id, nil
+			}
+		}
+		return 0, ErrListNotFound
+	}
+
+	role, ok := s.listRole(userID, listID)
+	if !ok {
+		return 0, ErrListNotFound
+	}
+	if !role.CanEdit() {
+		return 0, ErrForbidden
+	}
+	return listID, nil
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+// memoryMember is the membership of one user in a memoryList.
+type memoryMember struct {
+	role      models.ListRole
+	createdAt time.Time
+}
+
+func (s *MemoryStore) ListMembers(_ context.Context, userID, listID int) ([]models.ListMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.listRole(userID, listID); !ok {
+		return nil, ErrNotFound
+	}
+	var members []models.ListMember
+	for memberID := range s.lists[listID].members {
+		members = append(members, s.listMember(listID, memberID))
+	}
+	rank := map[models.ListRole]int{models.RoleOwner: 0, models.RoleEditor: 1, models.RoleViewer: 2}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Role != members[j].Role {
+			return rank[members[i].Role] < rank[members[j].Role]
+		}
+		if members[i].Name != members[j].Name {
+			return members[i].Name < members[j].Name
+		}
+		return members[i].UserID < members[j].UserID
+	})
+	return members, nil
+}
+
+func (s *MemoryStore) AddListMember(_ context.Context, userID, listID, memberID int, role models.ListRole) (models.ListMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.requireListOwner(userID, listID); err != nil {
+		return models.ListMember{}, err
+	}
+	if _, ok := s.users[memberID]; !ok {
+		return models.ListMember{}, ErrNotFound
+	}
+	members := s.lists[listID].members
+	if _, ok := members[memberID]; ok {
+		return models.ListMember{}, ErrConflict
+	}
+	members[memberID] = memoryMember{role: role, createdAt: time.Now()}
+	return s.listMember(listID, memberID), nil
+}
+
+func (s *MemoryStore) SetListMemberRole(_ context.Context, userID, listID, memberID int, role models.ListRole) (models.ListMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.requireListOwner(userID, listID); err != nil {
+		return models.ListMember{}, err
+	}
+	if err := s.requireNonOwnerMember(listID, memberID); err != nil {
+		return models.ListMember{}, err
+	}
+	members := s.lists[listID].members
+	member := members[memberID]
+	member.role = role
+	members[memberID] = member
+	return s.listMember(listID, memberID), nil
+}
+
+func (s *MemoryStore) RemoveListMember(_ context.Context, userID, listID, memberID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Members may leave a list on their own, everybody else needs the owner
+	// to remove them.
+	if memberID == userID {
+		if _, ok := s.listRole(userID, listID); !ok {
+			return ErrNotFound
+		}
+	} else if err := s.requireListOwner(userID, listID); err != nil {
+		return err
+	}
+	if err := s.requireNonOwnerMember(listID, memberID); err != nil {
+		return err
+	}
+	delete(s.lists[listID].members, memberID)
+	return nil
+}
+
+// listMember returns memberID's membership of listID. Callers must hold s.mu.
+func (s *MemoryStore) listMember(listID, memberID int) models.ListMember {
+	user := s.users[memberID]
+	member := s.lists[listID].members[memberID]
+	return models.ListMember{
+		UserID:    memberID,
+		Email:     user.Email,
+		Name:      user.Name,
+		Role:      member.role,
+		CreatedAt: member.createdAt,
+	}
+}
+
+// listRole returns the role userID has on listID, if any. Callers must hold
+// s.mu.
+func (s *MemoryStore) listRole(userID, listID int) (models.ListRole, bool) {
+	member, ok := s.lists[listID].members[userID]
+	return member.role, ok
+}
+
+// requireListOwner fails with ErrNotFound unless userID is a member of
+// listID, and with ErrForbidden unless they own it. Callers must hold s.mu.
+func (s *MemoryStore) requireListOwner(userID, listID int) error {
+	role, ok := s.listRole(userID, listID)
+	if !ok {
+		return ErrNotFound
+	}
+	if role != models.RoleOwner {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// requireNonOwnerMember fails unless memberID is a member of listID other
+// than its owner. Callers must hold s.mu.
+func (s *MemoryStore) requireNonOwnerMember(listID, memberID int) error {
+	role, ok := s.listRole(memberID, listID)
+	if !ok {
+		return ErrMemberNotFound
+	}
+	if role == models.RoleOwner {
+		return ErrListOwner
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+// identityKey identifies an account at an OpenID Connect provider.
+type identityKey struct {
+	issuer  string
+	subject string
+}
+
+func (s *MemoryStore) CreateOIDCLogin(_ context.Context, login *models.OIDCLogin) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for hash, stored := range s.oidcLogins {
+		if stored.ExpiresAt.Before(now) {
+			delete(s.oidcLogins, hash)
+		}
+	}
+	login.ID = s.nextOIDCLoginID
+	login.CreatedAt = now
+	s.nextOIDCLoginID++
+	s.oidcLogins[login.StateHash] = *login
+	return nil
+}
+
+func (s *MemoryStore) ConsumeOIDCLogin(_ context.Context, stateHash string) (models.OIDCLogin, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	login, ok := s.oidcLogins[stateHash]
+	if !ok {
+		return models.OIDCLogin{}, ErrNotFound
+	}
+	delete(s.oidcLogins, stateHash)
+	if !login.ExpiresAt.After(time.Now()) {
+		return models.OIDCLogin{}, ErrNotFound
+	}
+	return login, nil
+}
+
+func (s *MemoryStore) FindUserByIdentity(_ context.Context, issuer, subject string) (models.ListCurator, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	identity, ok := s.identities[identityKey{issuer, subject}]
+	if !ok {
+		return models.ListCurator{}, ErrNotFound
+	}
+	user, ok := s.users[identity.UserID]
+	if !ok {
+		return models.ListCurator{}, ErrNotFound
+	}
+	return user, nil
+}
+
+func (s *MemoryStore) LinkIdentity(_ context.Context, identity *models.UserIdentity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[identity.UserID]; !ok {
+		return ErrNotFound
+	}
+	return s.insertIdentity(identity)
+}
+
+func (s *MemoryStore) CreateUserWithIdentity(_ context.Context, user *models.ListCurator, identity *models.UserIdentity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Check the identity first so a conflict leaves no user behind.
+	if _, ok := s.identities[identityKey{identity.Issuer, identity.Subject}]; ok {
+		return ErrConflict
+	}
+	if err := s.insertUser(user); err != nil {
+		return err
+	}
+	identity.UserID = user.ID
+	return s.insertIdentity(identity)
+}
+
+// insertIdentity links identity to identity.UserID. Callers must hold s.mu
+// for writing.
+func (s *MemoryStore) insertIdentity(identity *models.UserIdentity) error {
+	key := identityKey{identity.Issuer, identity.Subject}
+	if _, ok := s.identities[key]; ok {
+		return ErrConflict
+	}
+	identity.ID = s.nextIdentityID
+	identity.CreatedAt = time.Now()
+	s.nextIdentityID++
+	s.identities[key] = *identity
+	return nil
+}
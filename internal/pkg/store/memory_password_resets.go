@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+func (s *MemoryStore) CreatePasswordReset(_ context.Context, reset *models.PasswordReset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, stored := range s.passwordResets {
+		if stored.UserID == reset.UserID && stored.UsedAt == nil {
+			delete(s.passwordResets, hash)
+		}
+	}
+	reset.ID = s.nextResetID
+	reset.UsedAt = nil
+	reset.CreatedAt = time.Now()
+	s.nextResetID++
+	s.passwordResets[reset.TokenHash] = *reset
+	return nil
+}
+
+func (s *MemoryStore) FindPasswordReset(_ context.Context, tokenHash string) (models.PasswordReset, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reset, ok := s.passwordResets[tokenHash]
+	if !ok || reset.UsedAt != nil || !reset.ExpiresAt.After(time.Now()) {
+		return models.PasswordReset{}, ErrNotFound
+	}
+	return reset, nil
+}
+
+func (s *MemoryStore) ResetPassword(_ context.Context, tokenHash, passwordHash string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reset, ok := s.passwordResets[tokenHash]
+	now := time.Now()
+	if !ok || reset.UsedAt != nil || !reset.ExpiresAt.After(now) {
+		return 0, ErrNotFound
+	}
+	user, ok := s.users[reset.UserID]
+	if !ok {
+		return 0, ErrNotFound
+	}
+
+	reset.UsedAt = &now
+	s.passwordResets[tokenHash] = reset
+	user.Password = passwordHash
+	s.users[user.ID] = user
+	s.revokeRefreshTokens(func(stored models.RefreshToken) bool { return stored.UserID == user.ID })
+	return user.ID, nil
+}
@@ -0,0 +1,35 @@
+package store
+
+import (
+	"slices"
+	"time"
+)
+
+// advanceRecurrence creates the next occurrence of stored if it is a
+// completed recurring todo that has not been advanced yet, like
+// sqlConn.advanceRecurrence. Callers must hold s.mu for writing and save
+// stored afterwards.
+func (s *MemoryStore) advanceRecurrence(stored *memoryTodo) {
+	next, ok, err := nextOccurrence(s.todoItem(*stored))
+	if err != nil || !ok {
+		return
+	}
+
+	now := time.Now()
+	next.ID = s.nextTodoID
+	next.CreatedAt = now
+	next.UpdatedAt = now
+	s.nextTodoID++
+	occurrence := memoryTodo{userID: stored.userID, item: next, tagIDs: slices.Clone(stored.tagIDs)}
+	for _, item := range stored.checklist {
+		item.ID = s.nextItemID
+		item.TodoID = next.ID
+		item.Done = false
+		item.CreatedAt = now
+		item.UpdatedAt = now
+		s.nextItemID++
+		occurrence.checklist = append(occurrence.checklist, item)
+	}
+	s.todos[next.ID] = occurrence
+	stored.item.NextOccurrenceID = &next.ID
+}
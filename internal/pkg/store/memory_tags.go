@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"sort"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+// memoryTag is a stored tag together with the user that owns it.
+type memoryTag struct {
+	userID int
+	tag    models.Tag
+}
+
+func (s *MemoryStore) CreateTag(_ context.Context, userID int, tag *models.Tag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.findTag(userID, tag.Name); ok {
+		return ErrConflict
+	}
+	tag.ID = s.nextTagID
+	s.nextTagID++
+	s.tags[tag.ID] = memoryTag{userID: userID, tag: *tag}
+	return nil
+}
+
+func (s *MemoryStore) ListTags(_ context.Context, userID int) ([]models.Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var tags []models.Tag
+	for _, stored := range s.tags {
+		if stored.userID == userID {
+			tags = append(tags, stored.tag)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+func (s *MemoryStore) RenameTag(_ context.Context, userID int, tag *models.Tag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.tags[tag.ID]
+	if !ok || stored.userID != userID {
+		return ErrNotFound
+	}
+	if existingID, ok := s.findTag(userID, tag.Name); ok && existingID != tag.ID {
+		return ErrConflict
+	}
+	stored.tag.Name = tag.Name
+	s.tags[tag.ID] = stored
+	return nil
+}
+
+func (s *MemoryStore) DeleteTag(_ context.Context, userID, tagID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.tags[tagID]
+	if !ok || stored.userID != userID {
+		return ErrNotFound
+	}
+	delete(s.tags, tagID)
+	for todoID, todo := range s.todos {
+		if i := slices.Index(todo.tagIDs, tagID); i >= 0 {
+			todo.tagIDs = slices.Delete(slices.Clone(todo.tagIDs), i, i+1)
+			s.todos[todoID] = todo
+		}
+	}
+	return nil
+}
+
+// findTag returns the ID of userID's tag called name. Callers must hold s.mu.
+func (s *MemoryStore) findTag(userID int, name string) (int, bool) {
+	for id, stored := range s.tags {
+		if stored.userID == userID && stored.tag.Name == name {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// ensureTags returns the IDs of userID's tags called names, creating the
+// missing ones. Callers must hold s.mu for writing.
+func (s *MemoryStore) ensureTags(userID int, names []string) []int {
+	var ids []int
+	for _, name := range normalizeTagNames(names) {
+		id, ok := s.findTag(userID, name)
+		if !ok {
+			id = s.nextTagID
+			s.nextTagID++
+			s.tags[id] = memoryTag{userID: userID, tag: models.Tag{ID: id, Name: name}}
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// todoItem returns the stored todo with its tag names and checklist
+// progress filled in. Callers must hold s.mu.
+func (s *MemoryStore) todoItem(stored memoryTodo) models.TodoItem {
+	todo := stored.item
+	todo.Tags = nil
+	for _, id := range stored.tagIDs {
+		todo.Tags = append(todo.Tags, s.tags[id].tag.Name)
+	}
+	sort.Strings(todo.Tags)
+	todo.Checklist = checklistProgress(stored.checklist)
+	return todo
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+func (s *MemoryStore) CreateRefreshToken(_ context.Context, token *models.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for hash, stored := range s.refreshTokens {
+		if stored.UserID == token.UserID && stored.ExpiresAt.Before(now) {
+			delete(s.refreshTokens, hash)
+		}
+	}
+	s.insertRefreshToken(token)
+	return nil
+}
+
+func (s *MemoryStore) RotateRefreshToken(_ context.Context, tokenHash string, next *models.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.refreshTokens[tokenHash]
+	now := time.Now()
+	if !ok || current.RevokedAt != nil || !current.ExpiresAt.After(now) {
+		return ErrNotFound
+	}
+	if current.UsedAt != nil {
+		s.revokeRefreshTokens(func(stored models.RefreshToken) bool { return stored.FamilyID == current.FamilyID })
+		return ErrTokenReused
+	}
+
+	current.UsedAt = &now
+	s.refreshTokens[tokenHash] = current
+	next.UserID = current.UserID
+	next.FamilyID = current.FamilyID
+	s.insertRefreshToken(next)
+	return nil
+}
+
+// insertRefreshToken stores token under a new ID. Callers must hold s.mu for
+// writing.
+func (s *MemoryStore) insertRefreshToken(token *models.RefreshToken) {
+	token.ID = s.nextTokenID
+	token.CreatedAt = time.Now()
+	token.UsedAt = nil
+	token.RevokedAt = nil
+	s.nextTokenID++
+	s.refreshTokens[token.TokenHash] = *token
+}
+
+func (s *MemoryStore) RevokeRefreshToken(_ context.Context, userID int, tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.refreshTokens[tokenHash]
+	if !ok || token.UserID != userID {
+		return ErrNotFound
+	}
+	if s.revokeRefreshTokens(func(stored models.RefreshToken) bool { return stored.FamilyID == token.FamilyID }) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MemoryStore) RevokeUserRefreshTokens(_ context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revokeRefreshTokens(func(stored models.RefreshToken) bool { return stored.UserID == userID })
+	return nil
+}
+
+// revokeRefreshTokens revokes the unrevoked tokens matching match and returns
+// how many there were. Callers must hold s.mu for writing.
+func (s *MemoryStore) revokeRefreshTokens(match func(models.RefreshToken) bool) int {
+	now := time.Now()
+	revoked := 0
+	for hash, stored := range s.refreshTokens {
+		if stored.RevokedAt == nil && match(stored) {
+			stored.RevokedAt = &now
+			s.refreshTokens[hash] = stored
+			revoked++
+		}
+	}
+	return revoked
+}
+
+func (s *MemoryStore) RevokeAccessTokens(_ context.Context, revocation *models.TokenRevocation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revocation.ID = s.nextRevocationID
+	revocation.CreatedAt = time.Now()
+	s.nextRevocationID++
+	s.tokenRevocations = append(s.tokenRevocations, *revocation)
+	return nil
+}
+
+func (s *MemoryStore) ListTokenRevocations(_ context.Context, since time.Time) ([]models.TokenRevocation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	var revocations []models.TokenRevocation
+	for _, revocation := range s.tokenRevocations {
+		if !revocation.CreatedAt.Before(since) && revocation.ExpiresAt.After(now) {
+			revocations = append(revocations, revocation)
+		}
+	}
+	return revocations, nil
+}
+
+func (s *MemoryStore) PruneTokenRevocations(_ context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokenRevocations = slices.DeleteFunc(s.tokenRevocations, func(revocation models.TokenRevocation) bool {
+		return revocation.ExpiresAt.Before(before)
+	})
+	return nil
+}
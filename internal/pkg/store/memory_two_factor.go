@@ -0,0 +1,169 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+func (s *MemoryStore) GetTOTP(_ context.Context, userID int) (models.TOTPEnrollment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	enrollment, ok := s.totp[userID]
+	if !ok {
+		return models.TOTPEnrollment{}, ErrNotFound
+	}
+	enrollment.RecoveryCodesLeft = 0
+	for _, used := range s.recoveryCodes[userID] {
+		if !used {
+			enrollment.RecoveryCodesLeft++
+		}
+	}
+	return enrollment, nil
+}
+
+func (s *MemoryStore) BeginTOTPEnrollment(_ context.Context, userID int, secret string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if current, ok := s.totp[userID]; ok && current.ConfirmedAt != nil {
+		return ErrConflict
+	}
+	s.totp[userID] = models.TOTPEnrollment{
+		UserID:    userID,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}
+	return nil
+}
+
+func (s *MemoryStore) ConfirmTOTP(_ context.Context, userID int, step int64, codeHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	enrollment, ok := s.totp[userID]
+	if !ok || enrollment.ConfirmedAt != nil {
+		return ErrNotFound
+	}
+	now := time.Now()
+	enrollment.ConfirmedAt = &now
+	enrollment.LastStep = step
+	s.totp[userID] = enrollment
+	s.replaceRecoveryCodes(userID, codeHashes)
+	return nil
+}
+
+func (s *MemoryStore) UseTOTPStep(_ context.Context, userID int, step int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	enrollment, ok := s.totp[userID]
+	if !ok || enrollment.ConfirmedAt == nil || enrollment.LastStep >= step {
+		return ErrCodeReused
+	}
+	enrollment.LastStep = step
+	s.totp[userID] = enrollment
+	return nil
+}
+
+func (s *MemoryStore) UseRecoveryCode(_ context.Context, userID int, codeHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	used, ok := s.recoveryCodes[userID][codeHash]
+	if !ok || used {
+		return ErrNotFound
+	}
+	s.recoveryCodes[userID][codeHash] = true
+	return nil
+}
+
+func (s *MemoryStore) ReplaceRecoveryCodes(_ context.Context, userID int, codeHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.replaceRecoveryCodes(userID, codeHashes)
+	return nil
+}
+
+// replaceRecoveryCodes stores codeHashes as the unused recovery codes of
+// userID. Callers must hold s.mu for writing.
+func (s *MemoryStore) replaceRecoveryCodes(userID int, codeHashes []string) {
+	codes := make(map[string]bool, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes[hash] = false
+	}
+	s.recoveryCodes[userID] = codes
+}
+
+func (s *MemoryStore) DisableTOTP(_ context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.totp, userID)
+	delete(s.recoveryCodes, userID)
+	return nil
+}
+
+func (s *MemoryStore) CreateMFAChallenge(_ context.Context, challenge *models.MFAChallenge) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for hash, stored := range s.mfaChallenges {
+		if stored.UserID == challenge.UserID && stored.ExpiresAt.Before(now) {
+			delete(s.mfaChallenges, hash)
+		}
+	}
+	challenge.ID = s.nextChallengeID
+	challenge.Attempts = 0
+	challenge.CreatedAt = now
+	s.nextChallengeID++
+	s.mfaChallenges[challenge.TokenHash] = *challenge
+	return nil
+}
+
+func (s *MemoryStore) GetMFAChallenge(_ context.Context, tokenHash string) (models.MFAChallenge, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	challenge, ok := s.mfaChallenges[tokenHash]
+	if !ok || !challenge.ExpiresAt.After(time.Now()) {
+		return models.MFAChallenge{}, ErrNotFound
+	}
+	return challenge, nil
+}
+
+func (s *MemoryStore) FailMFAChallenge(_ context.Context, challengeID, maxAttempts int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, stored := range s.mfaChallenges {
+		if stored.ID != challengeID {
+			continue
+		}
+		stored.Attempts++
+		if stored.Attempts >= maxAttempts {
+			delete(s.mfaChallenges, hash)
+		} else {
+			s.mfaChallenges[hash] = stored
+		}
+		return nil
+	}
+	return nil
+}
+
+func (s *MemoryStore) DeleteMFAChallenge(_ context.Context, challengeID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, stored := range s.mfaChallenges {
+		if stored.ID == challengeID {
+			delete(s.mfaChallenges, hash)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
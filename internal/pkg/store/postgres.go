@@ -0,0 +1,24 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// NewPostgresStore returns a Store backed by a lib/pq connection pool.
+func NewPostgresStore(db *sql.DB) *SQLStore {
+	return newSQLStore(db, postgresDialect{})
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) rebind(query string) string {
+	return query
+}
+
+func (postgresDialect) isUniqueViolation(err error) bool {
+	var dbError *pq.Error
+	return errors.As(err, &dbError) && dbError.Code.Name() == "unique_violation"
+}
@@ -0,0 +1,46 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/recurrence"
+)
+
+// nextOccurrence returns the todo that follows todo in its recurring series,
+// or false when todo is not a completed recurring todo, already has a next
+// occurrence or was the last one of its series. Rules are expanded in the
+// time zone of the series.
+func nextOccurrence(todo models.TodoItem) (models.TodoItem, bool, error) {
+	if !todo.Completed || todo.Recurrence == "" || todo.NextOccurrenceID != nil || todo.DueAt == nil {
+		return models.TodoItem{}, false, nil
+	}
+	rule, err := recurrence.Parse(todo.Recurrence)
+	if err != nil {
+		return models.TodoItem{}, false, fmt.Errorf("invalid recurrence of todo %d: %w", todo.ID, err)
+	}
+
+	location := recurrence.Location(todo.RecurrenceTZ)
+	start := todo.DueAt.In(location)
+	if todo.RecurrenceStart != nil {
+		start = todo.RecurrenceStart.In(location)
+	}
+	due, ok := rule.After(start, *todo.DueAt)
+	if !ok {
+		return models.TodoItem{}, false, nil
+	}
+	due = due.UTC()
+	start = start.UTC()
+	return models.TodoItem{
+		ListID:          todo.ListID,
+		Title:           todo.Title,
+		Desc:            todo.Desc,
+		DueAt:           &due,
+		Priority:        todo.Priority,
+		Tags:            todo.Tags,
+		AutoComplete:    todo.AutoComplete,
+		Recurrence:      todo.Recurrence,
+		RecurrenceStart: &start,
+		RecurrenceTZ:    todo.RecurrenceTZ,
+	}, true, nil
+}
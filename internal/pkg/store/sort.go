@@ -0,0 +1,81 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TodoSort is one key of the ordering applied by TodoStore.ListTodos.
+type TodoSort struct {
+	Field string
+	Desc  bool
+}
+
+// todoSortFields whitelists the fields todos can be ordered by. Only these
+// names ever reach an ORDER BY clause, so sort parameters taken from a
+// request cannot inject SQL. Nullable fields always sort their NULLs last.
+var todoSortFields = map[string]struct {
+	nullable bool
+}{
+	"id":           {},
+	"title":        {},
+	"priority":     {},
+	"due_at":       {nullable: true},
+	"completed_at": {nullable: true},
+	"created_at":   {},
+	"updated_at":   {},
+}
+
+// ParseTodoSort parses a comma-separated list of sort keys such as
+// "-priority,due_at,title", where a leading '-' sorts that key descending
+// and an optional '+' ascending.
+func ParseTodoSort(spec string) ([]TodoSort, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	var sorts []TodoSort
+	seen := make(map[string]bool)
+	for _, key := range strings.Split(spec, ",") {
+		key = strings.TrimSpace(key)
+		var sort TodoSort
+		switch {
+		case strings.HasPrefix(key, "-"):
+			sort = TodoSort{Field: key[1:], Desc: true}
+		case strings.HasPrefix(key, "+"):
+			sort = TodoSort{Field: key[1:]}
+		default:
+			sort = TodoSort{Field: key}
+		}
+		if _, ok := todoSortFields[sort.Field]; !ok {
+			return nil, fmt.Errorf("cannot sort todos by %q", sort.Field)
+		}
+		if seen[sort.Field] {
+			return nil, fmt.Errorf("todos are already sorted by %q", sort.Field)
+		}
+		seen[sort.Field] = true
+		sorts = append(sorts, sort)
+	}
+	return sorts, nil
+}
+
+// todoOrderBy renders sorts as the body of an ORDER BY clause. The todo ID
+// is always appended so pagination is stable.
+func todoOrderBy(sorts []TodoSort) string {
+	var terms []string
+	for _, sort := range sorts {
+		field, ok := todoSortFields[sort.Field]
+		if !ok {
+			continue
+		}
+		if field.nullable {
+			terms = append(terms, sort.Field+" IS NULL")
+		}
+		direction := " ASC"
+		if sort.Desc {
+			direction = " DESC"
+		}
+		terms = append(terms, sort.Field+direction)
+	}
+	return strings.Join(append(terms, "id ASC"), ", ")
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseTodoSort(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []TodoSort
+	}{
+		{"", nil},
+		{"title", []TodoSort{{Field: "title"}}},
+		{"-priority,due_at,+title", []TodoSort{{Field: "priority", Desc: true}, {Field: "due_at"}, {Field: "title"}}},
+		{" -created_at , id ", []TodoSort{{Field: "created_at", Desc: true}, {Field: "id"}}},
+	}
+	for _, tt := range tests {
+		got, err := ParseTodoSort(tt.spec)
+		if err != nil {
+			t.Errorf("ParseTodoSort(%q): %v", tt.spec, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ParseTodoSort(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+
+	for _, spec := range []string{
+		"user_id",
+		"title,",
+		"--title",
+		"title,-title",
+		"title; DROP TABLE todos",
+		"(SELECT password FROM users)",
+	} {
+		if got, err := ParseTodoSort(spec); err == nil {
+			t.Errorf("ParseTodoSort(%q) = %+v, want an error", spec, got)
+		}
+	}
+}
+
+func TestTodoOrderBy(t *testing.T) {
+	tests := []struct {
+		sorts []TodoSort
+		want  string
+	}{
+		{nil, "id ASC"},
+		{[]TodoSort{{Field: "priority", Desc: true}, {Field: "title"}}, "priority DESC, title ASC, id ASC"},
+		// NULL due dates sort last in either direction.
+		{[]TodoSort{{Field: "due_at", Desc: true}}, "due_at IS NULL, due_at DESC, id ASC"},
+		// Fields that did not come through ParseTodoSort are dropped.
+		{[]TodoSort{{Field: "1; DROP TABLE todos"}}, "id ASC"},
+	}
+	for _, tt := range tests {
+		if got := todoOrderBy(tt.sorts); got != tt.want {
+			t.Errorf("todoOrderBy(%+v) = %q, want %q", tt.sorts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,451 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+// SQLStore implements Store on top of a database/sql connection pool. The
+// queries are written for PostgreSQL and translated by the store's dialect
+// for other databases.
+type SQLStore struct {
+	sqlConn
+	db *sql.DB
+}
+
+var _ Store = (*SQLStore)(nil)
+
+func newSQLStore(db *sql.DB, d dialect) *SQLStore {
+	return &SQLStore{sqlConn: sqlConn{q: db, dialect: d}, db: db}
+}
+
+// querier is the part of the API shared by *sql.DB and *sql.Tx.
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// sqlConn runs queries through the store's dialect, either directly on the
+// connection pool or inside a transaction started by SQLStore.inTx.
+type sqlConn struct {
+	q       querier
+	dialect dialect
+}
+
+func (c sqlConn) queryRow(ctx context.Context, query string, args ...any) *sql.Row {
+	return c.q.QueryRowContext(ctx, c.dialect.rebind(query), args...)
+}
+
+func (c sqlConn) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return c.q.QueryContext(ctx, c.dialect.rebind(query), args...)
+}
+
+func (c sqlConn) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return c.q.ExecContext(ctx, c.dialect.rebind(query), args...)
+}
+
+// inTx runs fn inside a transaction that is committed when fn returns nil
+// and rolled back otherwise.
+func (s *SQLStore) inTx(ctx context.Context, fn func(tx sqlConn) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(sqlConn{q: tx, dialect: s.dialect}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// placeholders returns n comma-separated placeholders numbered from first,
+// for use in IN lists.
+func placeholders(first, n int) string {
+	list := make([]string, n)
+	for i := range list {
+		list[i] = fmt.Sprintf("$%d", first+i)
+	}
+	return strings.Join(list, ", ")
+}
+
+// now returns the timestamp written by the store. Timestamps are generated
+// here rather than by the database so every dialect stores them in UTC and
+// in the same format.
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+// utc normalizes an optional timestamp before it is written so SQLite,
+// which stores timestamps as text, can compare them correctly.
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+const todoColumns = "id, list_id, title, description, completed, completed_at, due_at, priority, auto_complete, " +
+	"recurrence, recurrence_start, recurrence_tz, next_occurrence_id, created_at, updated_at"
+
+func scanTodo(row interface{ Scan(...any) error }, todo *models.TodoItem) error {
+	return row.Scan(&todo.ID, &todo.ListID, &todo.Title, &todo.Desc, &todo.Completed, &todo.CompletedAt,
+		&todo.DueAt, &todo.Priority, &todo.AutoComplete, &todo.Recurrence, &todo.RecurrenceStart,
+		&todo.RecurrenceTZ, &todo.NextOccurrenceID, &todo.CreatedAt, &todo.UpdatedAt)
+}
+
+func (s *SQLStore) CreateTodo(ctx context.Context, userID int, todo *models.TodoItem) error {
+	return s.inTx(ctx, func(tx sqlConn) error {
+		listID, err := tx.resolveList(ctx, userID, todo.ListID)
+		if err != nil {
+			return err
+		}
+		todo.ListID = listID
+		todo.RecurrenceStart = nil
+		todo.RecurrenceTZ = ""
+		return tx.insertTodo(ctx, userID, todo)
+	})
+}
+
+// insertTodo stores todo as created by userID in list todo.ListID, which
+// must already be resolved. A recurring todo starts its series at its due
+// date, in the time zone of userID, unless todo.RecurrenceStart and
+// todo.RecurrenceTZ say otherwise.
+func (c sqlConn) insertTodo(ctx context.Context, userID int, todo *models.TodoItem) error {
+	recurrenceStart := todo.RecurrenceStart
+	if todo.Recurrence == "" {
+		recurrenceStart = nil
+	} else if recurrenceStart == nil {
+		recurrenceStart = todo.DueAt
+	}
+
+	query := `
+		INSERT INTO todos (
+			user_id,
+			list_id,
+			title,
+			description,
+			due_at,
+			priority,
+			auto_complete,
+			recurrence,
+			recurrence_start,
+			recurrence_tz,
+			created_at,
+			updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9,
+			CASE WHEN $8 = '' THEN '' ELSE COALESCE(NULLIF($11, ''), (SELECT timezone FROM users WHERE id = $1), '') END,
+			$10, $10
+		) RETURNING ` + todoColumns
+	tags := todo.Tags
+	err := scanTodo(c.queryRow(ctx, query, userID, todo.ListID, todo.Title, todo.Desc, utc(todo.DueAt), todo.Priority,
+		todo.AutoComplete, todo.Recurrence, utc(recurrenceStart), now(), todo.RecurrenceTZ), todo)
+	if err != nil {
+		return fmt.Errorf("failed to create todo: %w", err)
+	}
+	todo.Tags, err = c.setTodoTags(ctx, userID, todo.ID, tags)
+	return err
+}
+
+func (s *SQLStore) GetTodo(ctx context.Context, userID, todoID int) (models.TodoItem, error) {
+	query := `
+		SELECT ` + todoColumns + `
+		FROM todos
+		WHERE id = $1 AND list_id IN (SELECT list_id FROM list_members WHERE user_id = $2)`
+	todos, err := s.queryTodos(ctx, query, todoID, userID)
+	if err != nil {
+		return models.TodoItem{}, fmt.Errorf("failed to get todo: %w", err)
+	}
+	if len(todos) == 0 {
+		return models.TodoItem{}, ErrNotFound
+	}
+	return todos[0], nil
+}
+
+func (s *SQLStore) UpdateTodo(ctx context.Context, userID int, todo *models.TodoItem) error {
+	return s.inTx(ctx, func(tx sqlConn) error {
+		creatorID, err := tx.editableTodo(ctx, userID, todo.ID)
+		if err != nil {
+			return err
+		}
+		var listID any
+		if todo.ListID != 0 {
+			resolved, err := tx.resolveList(ctx, userID, todo.ListID)
+			if err != nil {
+				return err
+			}
+			listID = resolved
+		}
+
+		query := `
+			UPDATE todos
+			SET title = $1, description = $2, due_at = $3, priority = $4, auto_complete = $5,
+				updated_at = $6, list_id = COALESCE($7, list_id), recurrence = $9,
+				recurrence_start = CASE WHEN $9 = '' THEN NULL WHEN recurrence = $9 THEN recurrence_start ELSE $3 END,
+				recurrence_tz = CASE WHEN $9 = '' THEN '' WHEN recurrence = $9 THEN recurrence_tz
+					ELSE (SELECT timezone FROM users WHERE id = $10) END
+			WHERE id = $8`
+		args := []any{todo.Title, todo.Desc, utc(todo.DueAt), todo.Priority, todo.AutoComplete, now(), listID,
+			todo.ID, todo.Recurrence, userID}
+		if !todo.UpdatedAt.IsZero() {
+			query += " AND updated_at = $11"
+			args = append(args, todo.UpdatedAt.UTC())
+		}
+		result, err := tx.exec(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to update todo: %w", err)
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return fmt.Errorf("failed to update todo: %w", err)
+		} else if n == 0 {
+			return ErrModified
+		}
+		if todo.Tags != nil {
+			if _, err := tx.setTodoTags(ctx, creatorID, todo.ID, todo.Tags); err != nil {
+				return err
+			}
+		}
+		if err := tx.syncChecklistCompletion(ctx, todo.ID); err != nil {
+			return err
+		}
+
+		query = "SELECT " + todoColumns + " FROM todos WHERE id = $1"
+		todos, err := tx.queryTodos(ctx, query, todo.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get todo: %w", err)
+		}
+		*todo = todos[0]
+		return nil
+	})
+}
+
+func (s *SQLStore) SetTodoCompleted(ctx context.Context, userID, todoID int, completed bool) (models.TodoItem, error) {
+	var todo models.TodoItem
+	err := s.inTx(ctx, func(tx sqlConn) error {
+		if _, err := tx.editableTodo(ctx, userID, todoID); err != nil {
+			return err
+		}
+		query := `
+			UPDATE todos
+			SET completed = $2, completed_at = CASE WHEN $2 THEN COALESCE(completed_at, $3) END, updated_at = $3
+			WHERE id = $1`
+		if _, err := tx.exec(ctx, query, todoID, completed, now()); err != nil {
+			return fmt.Errorf("failed to update todo: %w", err)
+		}
+		if err := tx.advanceRecurrence(ctx, todoID); err != nil {
+			return err
+		}
+
+		query = "SELECT " + todoColumns + " FROM todos WHERE id = $1"
+		todos, err := tx.queryTodos(ctx, query, todoID)
+		if err != nil {
+			return fmt.Errorf("failed to get todo: %w", err)
+		}
+		todo = todos[0]
+		return nil
+	})
+	return todo, err
+}
+
+func (s *SQLStore) DeleteTodo(ctx context.Context, userID, todoID int) error {
+	return s.inTx(ctx, func(tx sqlConn) error {
+		if _, err := tx.editableTodo(ctx, userID, todoID); err != nil {
+			return err
+		}
+		if _, err := tx.exec(ctx, "DELETE FROM todos WHERE id = $1", todoID); err != nil {
+			return fmt.Errorf("failed to delete todo: %w", err)
+		}
+		return nil
+	})
+}
+
+// editableTodo returns the user that created todoID after checking that
+// userID may change it.
+func (c sqlConn) editableTodo(ctx context.Context, userID, todoID int) (int, error) {
+	query := `
+		SELECT t.user_id, m.role
+		FROM todos t
+		JOIN list_members m ON m.list_id = t.list_id
+		WHERE t.id = $1 AND m.user_id = $2`
+	var creatorID int
+	var role models.ListRole
+	err := c.queryRow(ctx, query, todoID, userID).Scan(&creatorID, &role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get todo: %w", err)
+	}
+	if !role.CanEdit() {
+		return 0, ErrForbidden
+	}
+	return creatorID, nil
+}
+
+func (s *SQLStore) ListTodos(ctx context.Context, userID int, filter TodoFilter) ([]models.TodoItem, error) {
+	conditions := []string{"list_id IN (SELECT list_id FROM list_members WHERE user_id = $1)"}
+	args := []any{userID}
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.ListID != 0 {
+		where("list_id = $%d", filter.ListID)
+	}
+	if filter.Completed != nil {
+		where("completed = $%d", *filter.Completed)
+	}
+	if filter.DueBefore != nil {
+		where("due_at < $%d", filter.DueBefore.UTC())
+	}
+	if filter.DueAfter != nil {
+		where("due_at > $%d", filter.DueAfter.UTC())
+	}
+	if tags := normalizeTagNames(filter.Tags); len(tags) > 0 {
+		in := placeholders(len(args)+1, len(tags))
+		for _, name := range tags {
+			args = append(args, name)
+		}
+		if filter.MatchAllTags {
+			conditions = append(conditions, fmt.Sprintf(`(
+				SELECT COUNT(*) FROM todo_tags tt JOIN tags t ON t.id = tt.tag_id
+				WHERE tt.todo_id = todos.id AND t.name IN (%s)) = %d`, in, len(tags)))
+		} else {
+			conditions = append(conditions, fmt.Sprintf(`id IN (
+				SELECT tt.todo_id FROM todo_tags tt JOIN tags t ON t.id = tt.tag_id
+				WHERE t.name IN (%s))`, in))
+		}
+	}
+	args = append(args, filter.Limit, filter.Offset)
+
+	query := fmt.Sprintf(`
+		SELECT `+todoColumns+`
+		FROM todos
+		WHERE %s
+		ORDER BY %s
+		LIMIT $%d OFFSET $%d`, strings.Join(conditions, " AND "), todoOrderBy(filter.Sort), len(args)-1, len(args))
+	todos, err := s.queryTodos(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve todos: %w", err)
+	}
+	return todos, nil
+}
+
+// queryTodos runs a query returning todoColumns and fills in the tags and
+// checklist progress of every todo it returns. The rows are closed before
+// those are loaded so it also works on single-connection SQLite pools.
+func (c sqlConn) queryTodos(ctx context.Context, query string, args ...any) ([]models.TodoItem, error) {
+	rows, err := c.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var todos []models.TodoItem
+	for rows.Next() {
+		var todo models.TodoItem
+		if err := scanTodo(rows, &todo); err != nil {
+			return nil, fmt.Errorf("error scanning todo row: %w", err)
+		}
+		todos = append(todos, todo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating todo rows: %w", err)
+	}
+	rows.Close()
+
+	refs := make([]*models.TodoItem, len(todos))
+	for i := range todos {
+		refs[i] = &todos[i]
+	}
+	if err := c.loadTodoTags(ctx, refs); err != nil {
+		return nil, err
+	}
+	if err := c.loadChecklistProgress(ctx, refs); err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+func (s *SQLStore) CreateUser(ctx context.Context, user *models.ListCurator) error {
+	return s.inTx(ctx, func(tx sqlConn) error {
+		return tx.insertUser(ctx, user)
+	})
+}
+
+// insertUser stores user together with their default list. It must run
+// inside a transaction.
+func (c sqlConn) insertUser(ctx context.Context, user *models.ListCurator) error {
+	if user.Timezone == "" {
+		user.Timezone = DefaultTimezone
+	}
+	if user.Locale == "" {
+		user.Locale = DefaultLocale
+	}
+	query := `
+		INSERT INTO users (
+			email,
+			name,
+			password_hash,
+			email_verified,
+			timezone,
+			locale
+		) VALUES ($1, $2, $3, $4, $5, $6
+		) RETURNING id`
+	err := c.queryRow(ctx, query, user.Email, user.Name, user.Password, user.EmailVerified, user.Timezone, user.Locale).Scan(&user.ID)
+	if err != nil {
+		if c.dialect.isUniqueViolation(err) {
+			return ErrConflict
+		}
+		return fmt.Errorf("failed to register user: %w", err)
+	}
+
+	inbox := models.TodoList{Name: DefaultListName, IsDefault: true}
+	return c.insertList(ctx, user.ID, &inbox)
+}
+
+const userColumns = "id, email, name, email_verified, timezone, locale, delete_at, password_hash"
+
+func scanUser(row interface{ Scan(...any) error }, user *models.ListCurator) error {
+	return row.Scan(&user.ID, &user.Email, &user.Name, &user.EmailVerified, &user.Timezone, &user.Locale, &user.DeleteAt, &user.Password)
+}
+
+func (s *SQLStore) GetUser(ctx context.Context, userID int) (models.ListCurator, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE id = $1`
+	var user models.ListCurator
+	err := scanUser(s.queryRow(ctx, query, userID), &user)
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, ErrNotFound
+	}
+	if err != nil {
+		return user, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
+
+func (s *SQLStore) FindUserByEmail(ctx context.Context, email string) (models.ListCurator, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE email = $1`
+	var user models.ListCurator
+	err := scanUser(s.queryRow(ctx, query, email), &user)
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, ErrNotFound
+	}
+	if err != nil {
+		return user, fmt.Errorf("failed to find user: %w", err)
+	}
+	return user, nil
+}
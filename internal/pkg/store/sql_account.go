@@ -0,0 +1,180 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+func (s *SQLStore) UpdateProfile(ctx context.Context, user *models.ListCurator) error {
+	query := `
+		UPDATE users
+		SET name = $1, timezone = $2, locale = $3
+		WHERE id = $4
+		RETURNING ` + userColumns
+	err := scanUser(s.queryRow(ctx, query, user.Name, user.Timezone, user.Locale, user.ID), user)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update profile: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) ChangePassword(ctx context.Context, userID int, passwordHash string) error {
+	return s.inTx(ctx, func(tx sqlConn) error {
+		result, err := tx.exec(ctx, "UPDATE users SET password_hash = $1 WHERE id = $2", passwordHash, userID)
+		if err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		} else if n == 0 {
+			return ErrNotFound
+		}
+		return tx.revokeUserRefreshTokens(ctx, userID)
+	})
+}
+
+func (s *SQLStore) RehashPassword(ctx context.Context, userID int, oldHash, newHash string) error {
+	query := "UPDATE users SET password_hash = $1 WHERE id = $2 AND password_hash = $3"
+	if _, err := s.exec(ctx, query, newHash, userID, oldHash); err != nil {
+		return fmt.Errorf("failed to rehash password: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) CreateEmailChange(ctx context.Context, change *models.EmailChange) error {
+	return s.inTx(ctx, func(tx sqlConn) error {
+		if _, err := tx.exec(ctx, "DELETE FROM email_changes WHERE user_id = $1", change.UserID); err != nil {
+			return fmt.Errorf("failed to replace email changes: %w", err)
+		}
+		query := `
+			INSERT INTO email_changes (
+				user_id,
+				new_email,
+				token_hash,
+				expires_at,
+				created_at
+			) VALUES ($1, $2, $3, $4, $5
+			) RETURNING id, created_at`
+		err := tx.queryRow(ctx, query, change.UserID, change.NewEmail, change.TokenHash, change.ExpiresAt.UTC(), now()).Scan(&change.ID, &change.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create email change: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *SQLStore) ConfirmEmailChange(ctx context.Context, tokenHash string) (int, error) {
+	var userID int
+	err := s.inTx(ctx, func(tx sqlConn) error {
+		query := `
+			DELETE FROM email_changes
+			WHERE token_hash = $1 AND expires_at > $2
+			RETURNING user_id, new_email`
+		var newEmail string
+		err := tx.queryRow(ctx, query, tokenHash, now()).Scan(&userID, &newEmail)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to use email change: %w", err)
+		}
+
+		_, err = tx.exec(ctx, "UPDATE users SET email = $1, email_verified = TRUE WHERE id = $2", newEmail, userID)
+		if s.dialect.isUniqueViolation(err) {
+			return ErrConflict
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update email: %w", err)
+		}
+		// Tokens mailed to the previous address must not act on the account
+		// any more.
+		if _, err := tx.exec(ctx, "DELETE FROM email_verifications WHERE user_id = $1", userID); err != nil {
+			return fmt.Errorf("failed to delete email verifications: %w", err)
+		}
+		if _, err := tx.exec(ctx, "DELETE FROM password_resets WHERE user_id = $1 AND used_at IS NULL", userID); err != nil {
+			return fmt.Errorf("failed to delete password resets: %w", err)
+		}
+		return nil
+	})
+	return userID, err
+}
+
+func (s *SQLStore) ScheduleUserDeletion(ctx context.Context, userID int, deleteAt time.Time) error {
+	return s.inTx(ctx, func(tx sqlConn) error {
+		result, err := tx.exec(ctx, "UPDATE users SET delete_at = $1 WHERE id = $2", deleteAt.UTC(), userID)
+		if err != nil {
+			return fmt.Errorf("failed to schedule account deletion: %w", err)
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return fmt.Errorf("failed to schedule account deletion: %w", err)
+		} else if n == 0 {
+			return ErrNotFound
+		}
+		return tx.revokeUserRefreshTokens(ctx, userID)
+	})
+}
+
+func (s *SQLStore) CancelUserDeletion(ctx context.Context, userID int) error {
+	if _, err := s.exec(ctx, "UPDATE users SET delete_at = NULL WHERE id = $1", userID); err != nil {
+		return fmt.Errorf("failed to cancel account deletion: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) PurgeDeletedUsers(ctx context.Context, before time.Time) (int, error) {
+	rows, err := s.query(ctx, "SELECT id FROM users WHERE delete_at <= $1", before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to list deleted users: %w", err)
+	}
+	var userIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to list deleted users: %w", err)
+		}
+		userIDs = append(userIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to list deleted users: %w", err)
+	}
+
+	for i, userID := range userIDs {
+		if err := s.inTx(ctx, func(tx sqlConn) error { return tx.deleteUser(ctx, userID) }); err != nil {
+			return i, err
+		}
+	}
+	return len(userIDs), nil
+}
+
+// deleteUser deletes userID with their lists and todos. Like DeleteList it
+// deletes the todos and lists explicitly instead of relying on ON DELETE
+// CASCADE, which SQLite ignores without foreign keys on; the remaining
+// records of the user go with the cascade. It must run inside a
+// transaction.
+func (c sqlConn) deleteUser(ctx context.Context, userID int) error {
+	query := "DELETE FROM todos WHERE user_id = $1 OR list_id IN (SELECT id FROM lists WHERE user_id = $1)"
+	if _, err := c.exec(ctx, query, userID); err != nil {
+		return fmt.Errorf("failed to delete todos: %w", err)
+	}
+	query = "DELETE FROM list_members WHERE user_id = $1 OR list_id IN (SELECT id FROM lists WHERE user_id = $1)"
+	if _, err := c.exec(ctx, query, userID); err != nil {
+		return fmt.Errorf("failed to delete list members: %w", err)
+	}
+	if _, err := c.exec(ctx, "DELETE FROM lists WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("failed to delete lists: %w", err)
+	}
+	if _, err := c.exec(ctx, "DELETE FROM users WHERE id = $1", userID); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+func TestSQLiteUpdateProfile(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	ctx := context.Background()
+	jane := createTestUser(t, s, "jane@example.com")
+
+	user := models.ListCurator{ID: jane, Name: "Jane Roe", Timezone: "Europe/Berlin", Locale: "de-DE"}
+	if err := s.UpdateProfile(ctx, &user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != "jane@example.com" || user.Password != "hash" {
+		t.Errorf("UpdateProfile did not refresh the other fields: %+v", user)
+	}
+	got, err := s.GetUser(ctx, jane)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Jane Roe" || got.Timezone != "Europe/Berlin" || got.Locale != "de-DE" {
+		t.Errorf("stored profile %+v", got)
+	}
+	if err := s.UpdateProfile(ctx, &models.ListCurator{ID: jane + 100, Name: "Nobody"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateProfile of an unknown user: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestSQLiteChangePassword(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	ctx := context.Background()
+	jane := createTestUser(t, s, "jane@example.com")
+	loginRefreshToken(t, s, jane, "laptop", "a1")
+
+	if err := s.ChangePassword(ctx, jane, "new-hash"); err != nil {
+		t.Fatal(err)
+	}
+	if user, err := s.GetUser(ctx, jane); err != nil || user.Password != "new-hash" {
+		t.Errorf("password after ChangePassword: %+v, %v", user, err)
+	}
+	if _, err := rotate(s, "a1", "a2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("refresh token after ChangePassword: got %v, want ErrNotFound", err)
+	}
+	if err := s.ChangePassword(ctx, jane+100, "hash"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ChangePassword of an unknown user: got %v, want ErrNotFound", err)
+	}
+
+	// A rehash loses against a password change that happened meanwhile.
+	if err := s.RehashPassword(ctx, jane, "hash", "rehashed"); err != nil {
+		t.Fatal(err)
+	}
+	if user, _ := s.GetUser(ctx, jane); user.Password != "new-hash" {
+		t.Errorf("stale rehash replaced the password with %q", user.Password)
+	}
+	if err := s.RehashPassword(ctx, jane, "new-hash", "rehashed"); err != nil {
+		t.Fatal(err)
+	}
+	if user, _ := s.GetUser(ctx, jane); user.Password != "rehashed" {
+		t.Errorf("password after rehashing is %q", user.Password)
+	}
+}
+
+func TestSQLiteEmailChange(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	ctx := context.Background()
+	jane := createTestUser(t, s, "jane@example.com")
+	createTestUser(t, s, "john@example.com")
+	expires := time.Now().Add(time.Hour)
+
+	// Asking again replaces the earlier change.
+	first := models.EmailChange{UserID: jane, NewEmail: "jane@old-plan.example", TokenHash: "first", ExpiresAt: expires}
+	second := models.EmailChange{UserID: jane, NewEmail: "jane@work.example", TokenHash: "second", ExpiresAt: expires}
+	for _, change := range []*models.EmailChange{&first, &second} {
+		if err := s.CreateEmailChange(ctx, change); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.ConfirmEmailChange(ctx, "first"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("confirming a replaced change: got %v, want ErrNotFound", err)
+	}
+
+	// Tokens mailed to the old address die with the change.
+	if err := s.CreateEmailVerification(ctx, &models.EmailVerification{UserID: jane, TokenHash: "verify", ExpiresAt: expires}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreatePasswordReset(ctx, &models.PasswordReset{UserID: jane, TokenHash: "reset", ExpiresAt: expires}); err != nil {
+		t.Fatal(err)
+	}
+
+	userID, err := s.ConfirmEmailChange(ctx, "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID != jane || user.Email != "jane@work.example" || !user.EmailVerified {
+		t.Errorf("user after the change: %+v", user)
+	}
+	if _, err := s.ConfirmEmailChange(ctx, "second"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("confirming twice: got %v, want ErrNotFound", err)
+	}
+	if _, err := s.VerifyEmail(ctx, "verify"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("verification token for the old address: got %v, want ErrNotFound", err)
+	}
+	if _, err := s.FindPasswordReset(ctx, "reset"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("password reset sent to the old address: got %v, want ErrNotFound", err)
+	}
+
+	taken := models.EmailChange{UserID: jane, NewEmail: "john@example.com", TokenHash: "taken", ExpiresAt: expires}
+	if err := s.CreateEmailChange(ctx, &taken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ConfirmEmailChange(ctx, "taken"); !errors.Is(err, ErrConflict) {
+		t.Errorf("confirming an address taken meanwhile: got %v, want ErrConflict", err)
+	}
+	expired := models.EmailChange{UserID: jane, NewEmail: "jane@late.example", TokenHash: "expired", ExpiresAt: time.Now().Add(-time.Minute)}
+	if err := s.CreateEmailChange(ctx, &expired); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ConfirmEmailChange(ctx, "expired"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("confirming an expired change: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestSQLiteUserDeletion(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	ctx := context.Background()
+	jane := createTestUser(t, s, "jane@example.com")
+	john := createTestUser(t, s, "john@example.com")
+	loginRefreshToken(t, s, jane, "laptop", "a1")
+
+	// Jane has a todo of her own, one in John's shared list, and John has
+	// one in a list he shares with her.
+	team := models.TodoList{Name: "Team"}
+	if err := s.CreateList(ctx, john, &team); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddListMember(ctx, john, team.ID, jane, models.RoleEditor); err != nil {
+		t.Fatal(err)
+	}
+	createTestTodos(t, s, jane, models.TodoItem{Title: "jane's"}, models.TodoItem{Title: "jane's in team", ListID: team.ID})
+	createTestTodos(t, s, john, models.TodoItem{Title: "john's in team", ListID: team.ID})
+
+	deleteAt := time.Now().Add(time.Hour)
+	if err := s.ScheduleUserDeletion(ctx, jane, deleteAt); err != nil {
+		t.Fatal(err)
+	}
+	user, err := s.GetUser(ctx, jane)
+	if err != nil || user.DeleteAt == nil || !user.DeleteAt.Equal(deleteAt) {
+		t.Errorf("user after ScheduleUserDeletion: %+v, %v", user, err)
+	}
+	if _, err := rotate(s, "a1", "a2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("refresh token after ScheduleUserDeletion: got %v, want ErrNotFound", err)
+	}
+
+	// Nothing is deleted before the grace period is over, and cancelling
+	// keeps the account for good.
+	if n, err := s.PurgeDeletedUsers(ctx, time.Now()); err != nil || n != 0 {
+		t.Errorf("PurgeDeletedUsers before the grace period = %d, %v", n, err)
+	}
+	if err := s.CancelUserDeletion(ctx, jane); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.PurgeDeletedUsers(ctx, deleteAt.Add(time.Minute)); err != nil || n != 0 {
+		t.Errorf("PurgeDeletedUsers after cancelling = %d, %v", n, err)
+	}
+
+	if err := s.ScheduleUserDeletion(ctx, jane, deleteAt); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.PurgeDeletedUsers(ctx, deleteAt); err != nil || n != 1 {
+		t.Fatalf("PurgeDeletedUsers = %d, %v, want 1 user", n, err)
+	}
+	if _, err := s.GetUser(ctx, jane); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetUser after purging: got %v, want ErrNotFound", err)
+	}
+	todos, err := s.ListTodos(ctx, john, TodoFilter{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := todoTitles(todos); len(got) != 1 || got[0] != "john's in team" {
+		t.Errorf("John's todos after purging Jane: %q", got)
+	}
+	members, err := s.ListMembers(ctx, john, team.ID)
+	if err != nil || len(members) != 1 || members[0].UserID != john {
+		t.Errorf("members after purging Jane: %+v, %v", members, err)
+	}
+	var count int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM todos").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("%d todos left in the database, want only John's", count)
+	}
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+const apiKeyColumns = "id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, created_at"
+
+func scanAPIKey(row interface{ Scan(...any) error }, key *models.APIKey) error {
+	var scopes string
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.KeyHash, &scopes, &key.ExpiresAt, &key.LastUsedAt, &key.CreatedAt)
+	key.Scopes = strings.Fields(scopes)
+	return err
+}
+
+func (s *SQLStore) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	query := `
+		INSERT INTO api_keys (
+			user_id,
+			name,
+			prefix,
+			key_hash,
+			scopes,
+			expires_at,
+			created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7
+		) RETURNING ` + apiKeyColumns
+	row := s.queryRow(ctx, query, key.UserID, key.Name, key.Prefix, key.KeyHash, strings.Join(key.Scopes, " "), utc(key.ExpiresAt), now())
+	if err := scanAPIKey(row, key); err != nil {
+		if s.dialect.isUniqueViolation(err) {
+			return ErrConflict
+		}
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) ListAPIKeys(ctx context.Context, userID int) ([]models.APIKey, error) {
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE user_id = $1
+		ORDER BY id ASC`
+	rows, err := s.query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		var key models.APIKey
+		if err := scanAPIKey(rows, &key); err != nil {
+			return nil, fmt.Errorf("error scanning API key row: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating API key rows: %w", err)
+	}
+	return keys, nil
+}
+
+func (s *SQLStore) DeleteAPIKey(ctx context.Context, userID, keyID int) error {
+	result, err := s.exec(ctx, "DELETE FROM api_keys WHERE id = $1 AND user_id = $2", keyID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete API key: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete API key: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLStore) AuthenticateAPIKey(ctx context.Context, keyHash string) (models.APIKey, error) {
+	ts := now()
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE key_hash = $1 AND (expires_at IS NULL OR expires_at > $2)
+			AND user_id IN (SELECT id FROM users WHERE delete_at IS NULL)`
+	var key models.APIKey
+	err := scanAPIKey(s.queryRow(ctx, query, keyHash, ts), &key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.APIKey{}, ErrNotFound
+	}
+	if err != nil {
+		return models.APIKey{}, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	if key.LastUsedAt == nil || ts.Sub(*key.LastUsedAt) >= apiKeyUseInterval {
+		if _, err := s.exec(ctx, "UPDATE api_keys SET last_used_at = $1 WHERE id = $2", ts, key.ID); err != nil {
+			return models.APIKey{}, fmt.Errorf("failed to record API key use: %w", err)
+		}
+		key.LastUsedAt = &ts
+	}
+	return key, nil
+}
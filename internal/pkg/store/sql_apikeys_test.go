@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+func TestSQLiteAPIKeys(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	ctx := context.Background()
+	jane := createTestUser(t, s, "jane@example.com")
+	john := createTestUser(t, s, "john@example.com")
+
+	ci := models.APIKey{UserID: jane, Name: "CI", Prefix: "tdk_ci", KeyHash: "ci-hash", Scopes: []string{"todos:read", "todos:write"}}
+	if err := s.CreateAPIKey(ctx, &ci); err != nil {
+		t.Fatal(err)
+	}
+	if ci.ID == 0 || ci.CreatedAt.IsZero() || ci.LastUsedAt != nil {
+		t.Errorf("created key %+v", ci)
+	}
+	if err := s.CreateAPIKey(ctx, &models.APIKey{UserID: jane, Name: "CI", Prefix: "tdk_c2", KeyHash: "other-hash", Scopes: []string{"todos:read"}}); !errors.Is(err, ErrConflict) {
+		t.Errorf("creating a second key named CI: got %v, want ErrConflict", err)
+	}
+	past := time.Now().Add(-time.Minute)
+	expired := models.APIKey{UserID: jane, Name: "old", Prefix: "tdk_old", KeyHash: "old-hash", Scopes: []string{"todos:read"}, ExpiresAt: &past}
+	if err := s.CreateAPIKey(ctx, &expired); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := s.ListAPIKeys(ctx, jane)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0].Name != "CI" || !slices.Equal(keys[0].Scopes, ci.Scopes) || keys[1].ExpiresAt == nil {
+		t.Errorf("ListAPIKeys = %+v", keys)
+	}
+	if keys, err := s.ListAPIKeys(ctx, john); err != nil || len(keys) != 0 {
+		t.Errorf("another user's ListAPIKeys = %+v, %v", keys, err)
+	}
+
+	got, err := s.AuthenticateAPIKey(ctx, "ci-hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != ci.ID || got.UserID != jane || !slices.Equal(got.Scopes, ci.Scopes) || got.LastUsedAt == nil {
+		t.Errorf("AuthenticateAPIKey = %+v", got)
+	}
+	keys, err = s.ListAPIKeys(ctx, jane)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys[0].LastUsedAt == nil || !keys[0].LastUsedAt.Equal(*got.LastUsedAt) {
+		t.Errorf("last use stored as %v, want %v", keys[0].LastUsedAt, got.LastUsedAt)
+	}
+	for _, hash := range []string{"unknown-hash", "old-hash"} {
+		if _, err := s.AuthenticateAPIKey(ctx, hash); !errors.Is(err, ErrNotFound) {
+			t.Errorf("AuthenticateAPIKey(%s): got %v, want ErrNotFound", hash, err)
+		}
+	}
+
+	if err := s.DeleteAPIKey(ctx, john, ci.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleting another user's key: got %v, want ErrNotFound", err)
+	}
+	if err := s.DeleteAPIKey(ctx, jane, ci.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AuthenticateAPIKey(ctx, "ci-hash"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("AuthenticateAPIKey after DeleteAPIKey: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestSQLiteAPIKeysOfDeletedUsers(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	ctx := context.Background()
+	jane := createTestUser(t, s, "jane@example.com")
+	key := models.APIKey{UserID: jane, Name: "CI", Prefix: "tdk_ci", KeyHash: "ci-hash", Scopes: []string{"todos:read"}}
+	if err := s.CreateAPIKey(ctx, &key); err != nil {
+		t.Fatal(err)
+	}
+
+	// Keys stop working while the account waits to be deleted and work
+	// again when the deletion is cancelled.
+	if err := s.ScheduleUserDeletion(ctx, jane, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AuthenticateAPIKey(ctx, "ci-hash"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("key of an account being deleted: got %v, want ErrNotFound", err)
+	}
+	if err := s.CancelUserDeletion(ctx, jane); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AuthenticateAPIKey(ctx, "ci-hash"); err != nil {
+		t.Errorf("key after cancelling the deletion: %v", err)
+	}
+}
@@ -0,0 +1,256 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+const checklistColumns = "id, todo_id, title, done, position, created_at, updated_at"
+
+func scanChecklistItem(row interface{ Scan(...any) error }, item *models.ChecklistItem) error {
+	return row.Scan(&item.ID, &item.TodoID, &item.Title, &item.Done, &item.Position, &item.CreatedAt, &item.UpdatedAt)
+}
+
+func (s *SQLStore) ListChecklistItems(ctx context.Context, userID, todoID int) ([]models.ChecklistItem, error) {
+	if _, err := s.GetTodo(ctx, userID, todoID); err != nil {
+		return nil, err
+	}
+	return s.queryChecklist(ctx, todoID)
+}
+
+func (s *SQLStore) AddChecklistItem(ctx context.Context, userID, todoID int, item *models.ChecklistItem) error {
+	return s.inTx(ctx, func(tx sqlConn) error {
+		if _, err := tx.editableTodo(ctx, userID, todoID); err != nil {
+			return err
+		}
+
+		var count int
+		query := "SELECT COUNT(*) FROM checklist_items WHERE todo_id = $1"
+		if err := tx.queryRow(ctx, query, todoID).Scan(&count); err != nil {
+			return fmt.Errorf("failed to count checklist items: %w", err)
+		}
+		if item.Position < 0 || item.Position > count {
+			item.Position = count
+		}
+		query = "UPDATE checklist_items SET position = position + 1 WHERE todo_id = $1 AND position >= $2"
+		if _, err := tx.exec(ctx, query, todoID, item.Position); err != nil {
+			return fmt.Errorf("failed to move checklist items: %w", err)
+		}
+
+		query = `
+			INSERT INTO checklist_items (
+				todo_id,
+				title,
+				position,
+				created_at,
+				updated_at
+			) VALUES ($1, $2, $3, $4, $4
+			) RETURNING ` + checklistColumns
+		err := scanChecklistItem(tx.queryRow(ctx, query, todoID, item.Title, item.Position, now()), item)
+		if err != nil {
+			return fmt.Errorf("failed to create checklist item: %w", err)
+		}
+		return tx.syncChecklistCompletion(ctx, todoID)
+	})
+}
+
+func (s *SQLStore) RenameChecklistItem(ctx context.Context, userID, todoID int, item *models.ChecklistItem) error {
+	return s.inTx(ctx, func(tx sqlConn) error {
+		if _, err := tx.editableTodo(ctx, userID, todoID); err != nil {
+			return err
+		}
+		query := `
+			UPDATE checklist_items
+			SET title = $1, updated_at = $2
+			WHERE id = $3 AND todo_id = $4
+			RETURNING ` + checklistColumns
+		err := scanChecklistItem(tx.queryRow(ctx, query, item.Title, now(), item.ID, todoID), item)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrItemNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to rename checklist item: %w", err)
+		}
+		return tx.syncChecklistCompletion(ctx, todoID)
+	})
+}
+
+func (s *SQLStore) ToggleChecklistItem(ctx context.Context, userID, todoID, itemID int) (models.ChecklistItem, error) {
+	var item models.ChecklistItem
+	err := s.inTx(ctx, func(tx sqlConn) error {
+		if _, err := tx.editableTodo(ctx, userID, todoID); err != nil {
+			return err
+		}
+		query := `
+			UPDATE checklist_items
+			SET done = NOT done, updated_at = $1
+			WHERE id = $2 AND todo_id = $3
+			RETURNING ` + checklistColumns
+		err := scanChecklistItem(tx.queryRow(ctx, query, now(), itemID, todoID), &item)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrItemNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to toggle checklist item: %w", err)
+		}
+		return tx.syncChecklistCompletion(ctx, todoID)
+	})
+	return item, err
+}
+
+func (s *SQLStore) ReorderChecklist(ctx context.Context, userID, todoID int, itemIDs []int) ([]models.ChecklistItem, error) {
+	var items []models.ChecklistItem
+	err := s.inTx(ctx, func(tx sqlConn) error {
+		if _, err := tx.editableTodo(ctx, userID, todoID); err != nil {
+			return err
+		}
+		current, err := tx.queryChecklist(ctx, todoID)
+		if err != nil {
+			return err
+		}
+		if !isPermutation(current, itemIDs) {
+			return ErrChecklistOrder
+		}
+
+		ts := now()
+		query := "UPDATE checklist_items SET position = $1, updated_at = $2 WHERE id = $3"
+		for position, itemID := range itemIDs {
+			if _, err := tx.exec(ctx, query, position, ts, itemID); err != nil {
+				return fmt.Errorf("failed to move checklist item: %w", err)
+			}
+		}
+		if err := tx.syncChecklistCompletion(ctx, todoID); err != nil {
+			return err
+		}
+		items, err = tx.queryChecklist(ctx, todoID)
+		return err
+	})
+	return items, err
+}
+
+func (s *SQLStore) DeleteChecklistItem(ctx context.Context, userID, todoID, itemID int) error {
+	return s.inTx(ctx, func(tx sqlConn) error {
+		if _, err := tx.editableTodo(ctx, userID, todoID); err != nil {
+			return err
+		}
+		var position int
+		query := "DELETE FROM checklist_items WHERE id = $1 AND todo_id = $2 RETURNING position"
+		err := tx.queryRow(ctx, query, itemID, todoID).Scan(&position)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrItemNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to delete checklist item: %w", err)
+		}
+		query = "UPDATE checklist_items SET position = position - 1 WHERE todo_id = $1 AND position > $2"
+		if _, err := tx.exec(ctx, query, todoID, position); err != nil {
+			return fmt.Errorf("failed to move checklist items: %w", err)
+		}
+		return tx.syncChecklistCompletion(ctx, todoID)
+	})
+}
+
+func (c sqlConn) queryChecklist(ctx context.Context, todoID int) ([]models.ChecklistItem, error) {
+	query := `
+		SELECT ` + checklistColumns + `
+		FROM checklist_items
+		WHERE todo_id = $1
+		ORDER BY position ASC, id ASC`
+	rows, err := c.query(ctx, query, todoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve checklist items: %w", err)
+	}
+	defer rows.Close()
+
+	items := []models.ChecklistItem{}
+	for rows.Next() {
+		var item models.ChecklistItem
+		if err := scanChecklistItem(rows, &item); err != nil {
+			return nil, fmt.Errorf("error scanning checklist item row: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating checklist item rows: %w", err)
+	}
+	return items, nil
+}
+
+// syncChecklistCompletion refreshes updated_at of todoID after its checklist
+// changed and, if the todo completes automatically, marks it as completed
+// when every item is done and as open when one is not. Completing a
+// recurring todo this way also creates its next occurrence.
+func (c sqlConn) syncChecklistCompletion(ctx context.Context, todoID int) error {
+	query := `
+		SELECT t.auto_complete, COUNT(ci.id), COALESCE(SUM(CASE WHEN ci.done THEN 1 ELSE 0 END), 0)
+		FROM todos t
+		LEFT JOIN checklist_items ci ON ci.todo_id = t.id
+		WHERE t.id = $1
+		GROUP BY t.id, t.auto_complete`
+	var autoComplete bool
+	var progress models.ChecklistProgress
+	err := c.queryRow(ctx, query, todoID).Scan(&autoComplete, &progress.Total, &progress.Done)
+	if err != nil {
+		return fmt.Errorf("failed to count checklist items: %w", err)
+	}
+
+	if !autoComplete || progress.Total == 0 {
+		_, err = c.exec(ctx, "UPDATE todos SET updated_at = $1 WHERE id = $2", now(), todoID)
+	} else {
+		query = `
+			UPDATE todos
+			SET completed = $1, completed_at = CASE WHEN $1 THEN COALESCE(completed_at, $2) END, updated_at = $2
+			WHERE id = $3`
+		_, err = c.exec(ctx, query, progress.Done == progress.Total, now(), todoID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update todo: %w", err)
+	}
+	return c.advanceRecurrence(ctx, todoID)
+}
+
+// loadChecklistProgress sets the Checklist field of todos that have
+// checklist items.
+func (c sqlConn) loadChecklistProgress(ctx context.Context, todos []*models.TodoItem) error {
+	if len(todos) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*models.TodoItem, len(todos))
+	args := make([]any, 0, len(todos))
+	for _, todo := range todos {
+		todo.Checklist = nil
+		byID[todo.ID] = todo
+		args = append(args, todo.ID)
+	}
+
+	query := `
+		SELECT todo_id, COUNT(*), SUM(CASE WHEN done THEN 1 ELSE 0 END)
+		FROM checklist_items
+		WHERE todo_id IN (` + placeholders(1, len(args)) + `)
+		GROUP BY todo_id`
+	rows, err := c.query(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve checklist progress: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var todoID int
+		var progress models.ChecklistProgress
+		if err := rows.Scan(&todoID, &progress.Total, &progress.Done); err != nil {
+			return fmt.Errorf("error scanning checklist progress row: %w", err)
+		}
+		if todo, ok := byID[todoID]; ok {
+			todo.Checklist = &progress
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating checklist progress rows: %w", err)
+	}
+	return nil
+}
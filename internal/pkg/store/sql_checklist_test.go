@@ -0,0 +1,167 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+// addChecklist appends items titled titles to todoID and returns their IDs.
+func addChecklist(t *testing.T, s Store, userID, todoID int, titles ...string) []int {
+	t.Helper()
+	ids := make([]int, len(titles))
+	for i, title := range titles {
+		item := models.ChecklistItem{Title: title, Position: -1}
+		if err := s.AddChecklistItem(context.Background(), userID, todoID, &item); err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = item.ID
+	}
+	return ids
+}
+
+// checklistTitles returns the titles of the checklist of todoID in order.
+func checklistTitles(t *testing.T, s Store, userID, todoID int) []string {
+	t.Helper()
+	items, err := s.ListChecklistItems(context.Background(), userID, todoID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	titles := make([]string, len(items))
+	for i, item := range items {
+		if item.Position != i {
+			t.Errorf("item %q at position %d, want %d", item.Title, item.Position, i)
+		}
+		titles[i] = item.Title
+	}
+	return titles
+}
+
+func TestSQLiteChecklist(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	ctx := context.Background()
+	jane := createTestUser(t, s, "jane@example.com")
+	john := createTestUser(t, s, "john@example.com")
+	todo := createTestTodos(t, s, jane, models.TodoItem{Title: "pack"})[0]
+	other := createTestTodos(t, s, jane, models.TodoItem{Title: "other"})[0]
+
+	ids := addChecklist(t, s, jane, todo.ID, "passport", "charger", "socks")
+	first := models.ChecklistItem{Title: "tickets", Position: 0}
+	if err := s.AddChecklistItem(ctx, jane, todo.ID, &first); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := checklistTitles(t, s, jane, todo.ID), []string{"tickets", "passport", "charger", "socks"}; !slices.Equal(got, want) {
+		t.Errorf("checklist %q, want %q", got, want)
+	}
+
+	if _, err := s.ListChecklistItems(ctx, john, todo.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("another user's ListChecklistItems: got %v, want ErrNotFound", err)
+	}
+	// Items are addressed through their own todo only.
+	if _, err := s.ToggleChecklistItem(ctx, jane, other.ID, ids[0]); !errors.Is(err, ErrItemNotFound) {
+		t.Errorf("toggling an item of another todo: got %v, want ErrItemNotFound", err)
+	}
+	if err := s.DeleteChecklistItem(ctx, jane, other.ID, ids[0]); !errors.Is(err, ErrItemNotFound) {
+		t.Errorf("deleting an item of another todo: got %v, want ErrItemNotFound", err)
+	}
+
+	renamed := models.ChecklistItem{ID: ids[1], Title: "USB-C charger"}
+	if err := s.RenameChecklistItem(ctx, jane, todo.ID, &renamed); err != nil {
+		t.Fatal(err)
+	}
+	if renamed.Position != 2 || renamed.TodoID != todo.ID {
+		t.Errorf("renamed item %+v", renamed)
+	}
+
+	for _, order := range [][]int{
+		{ids[0], ids[1]},
+		{ids[0], ids[1], ids[2], ids[2]},
+		{ids[0], ids[1], ids[2], ids[2], first.ID},
+		{ids[0], ids[1], ids[2], other.ID},
+	} {
+		if _, err := s.ReorderChecklist(ctx, jane, todo.ID, order); !errors.Is(err, ErrChecklistOrder) {
+			t.Errorf("ReorderChecklist(%v): got %v, want ErrChecklistOrder", order, err)
+		}
+	}
+	if _, err := s.ReorderChecklist(ctx, jane, todo.ID, []int{ids[2], first.ID, ids[1], ids[0]}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := checklistTitles(t, s, jane, todo.ID), []string{"socks", "tickets", "USB-C charger", "passport"}; !slices.Equal(got, want) {
+		t.Errorf("reordered checklist %q, want %q", got, want)
+	}
+
+	// Deleting closes the gap it leaves.
+	if err := s.DeleteChecklistItem(ctx, jane, todo.ID, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := checklistTitles(t, s, jane, todo.ID), []string{"socks", "USB-C charger", "passport"}; !slices.Equal(got, want) {
+		t.Errorf("checklist after deleting %q, want %q", got, want)
+	}
+
+	if _, err := s.ToggleChecklistItem(ctx, jane, todo.ID, ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetTodo(ctx, jane, todo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Checklist == nil || *got.Checklist != (models.ChecklistProgress{Done: 1, Total: 3}) {
+		t.Errorf("progress %+v, want 1/3", got.Checklist)
+	}
+	// Without AutoComplete the todo stays open whatever its checklist says.
+	for _, id := range ids[1:] {
+		if _, err := s.ToggleChecklistItem(ctx, jane, todo.ID, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, err = s.GetTodo(ctx, jane, todo.ID); err != nil || got.Completed {
+		t.Errorf("todo without auto-completion: %+v, %v", got, err)
+	}
+}
+
+func TestSQLiteChecklistAutoComplete(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	ctx := context.Background()
+	jane := createTestUser(t, s, "jane@example.com")
+	todo := createTestTodos(t, s, jane, models.TodoItem{Title: "pack", AutoComplete: true})[0]
+	ids := addChecklist(t, s, jane, todo.ID, "passport", "charger")
+
+	completed := func(want bool) {
+		t.Helper()
+		got, err := s.GetTodo(ctx, jane, todo.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Completed != want || (got.CompletedAt != nil) != want {
+			t.Errorf("completed %v at %v, want completed %v", got.Completed, got.CompletedAt, want)
+		}
+	}
+
+	for _, id := range ids {
+		completed(false)
+		if _, err := s.ToggleChecklistItem(ctx, jane, todo.ID, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	completed(true)
+
+	// Unchecking an item or adding a new one reopens the todo, and deleting
+	// the last open item completes it again.
+	if _, err := s.ToggleChecklistItem(ctx, jane, todo.ID, ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	completed(false)
+	if _, err := s.ToggleChecklistItem(ctx, jane, todo.ID, ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	completed(true)
+	added := addChecklist(t, s, jane, todo.ID, "socks")
+	completed(false)
+	if err := s.DeleteChecklistItem(ctx, jane, todo.ID, added[0]); err != nil {
+		t.Fatal(err)
+	}
+	completed(true)
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+func (s *SQLStore) CreateEmailVerification(ctx context.Context, verification *models.EmailVerification) error {
+	return s.inTx(ctx, func(tx sqlConn) error {
+		query := "DELETE FROM email_verifications WHERE user_id = $1"
+		if _, err := tx.exec(ctx, query, verification.UserID); err != nil {
+			return fmt.Errorf("failed to replace email verifications: %w", err)
+		}
+		query = `
+			INSERT INTO email_verifications (
+				user_id,
+				token_hash,
+				expires_at,
+				created_at
+			) VALUES ($1, $2, $3, $4
+			) RETURNING id, created_at`
+		err := tx.queryRow(ctx, query, verification.UserID, verification.TokenHash, verification.ExpiresAt.UTC(), now()).
+			Scan(&verification.ID, &verification.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create email verification: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *SQLStore) VerifyEmail(ctx context.Context, tokenHash string) (int, error) {
+	var userID int
+	err := s.inTx(ctx, func(tx sqlConn) error {
+		query := "SELECT user_id FROM email_verifications WHERE token_hash = $1 AND expires_at > $2"
+		err := tx.queryRow(ctx, query, tokenHash, now()).Scan(&userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get email verification: %w", err)
+		}
+
+		if _, err := tx.exec(ctx, "UPDATE users SET email_verified = TRUE WHERE id = $1", userID); err != nil {
+			return fmt.Errorf("failed to verify email address: %w", err)
+		}
+		if _, err := tx.exec(ctx, "DELETE FROM email_verifications WHERE user_id = $1", userID); err != nil {
+			return fmt.Errorf("failed to delete email verifications: %w", err)
+		}
+		return nil
+	})
+	return userID, err
+}
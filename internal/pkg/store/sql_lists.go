@@ -0,0 +1,182 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+// listColumns selects a list as seen by the member m joined to it, see
+// listSource. A list only counts as default for the user owning it.
+const (
+	listColumns = "l.id, l.name, l.is_default AND l.user_id = m.user_id, m.role, l.created_at, l.updated_at"
+	listSource  = "lists l JOIN list_members m ON m.list_id = l.id"
+)
+
+func scanList(row interface{ Scan(...any) error }, list *models.TodoList) error {
+	return row.Scan(&list.ID, &list.Name, &list.IsDefault, &list.Role, &list.CreatedAt, &list.UpdatedAt)
+}
+
+func (s *SQLStore) CreateList(ctx context.Context, userID int, list *models.TodoList) error {
+	list.IsDefault = false
+	return s.inTx(ctx, func(tx sqlConn) error {
+		return tx.insertList(ctx, userID, list)
+	})
+}
+
+// insertList stores list as a new list owned by userID. It must run inside
+// a transaction since it also records the owner's membership.
+func (c sqlConn) insertList(ctx context.Context, userID int, list *models.TodoList) error {
+	query := `
+		INSERT INTO lists (
+			user_id,
+			name,
+			is_default,
+			created_at,
+			updated_at
+		) VALUES ($1, $2, $3, $4, $4
+		) RETURNING id, created_at, updated_at`
+	err := c.queryRow(ctx, query, userID, list.Name, list.IsDefault, now()).Scan(&list.ID, &list.CreatedAt, &list.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create list: %w", err)
+	}
+	list.Role = models.RoleOwner
+	return c.insertListMember(ctx, list.ID, userID, list.Role)
+}
+
+func (s *SQLStore) GetList(ctx context.Context, userID, listID int) (models.TodoList, error) {
+	return s.getList(ctx, userID, listID)
+}
+
+func (c sqlConn) getList(ctx context.Context, userID, listID int) (models.TodoList, error) {
+	query := `
+		SELECT ` + listColumns + `
+		FROM ` + listSource + `
+		WHERE l.id = $1 AND m.user_id = $2`
+	var list models.TodoList
+	err := scanList(c.queryRow(ctx, query, listID, userID), &list)
+	if errors.Is(err, sql.ErrNoRows) {
+		return list, ErrNotFound
+	}
+	if err != nil {
+		return list, fmt.Errorf("failed to get list: %w", err)
+	}
+	return list, nil
+}
+
+func (s *SQLStore) ListLists(ctx context.Context, userID int) ([]models.TodoList, error) {
+	query := `
+		SELECT ` + listColumns + `
+		FROM ` + listSource + `
+		WHERE m.user_id = $1
+		ORDER BY (l.is_default AND l.user_id = m.user_id) DESC, l.name ASC, l.id ASC`
+	rows, err := s.query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve lists: %w", err)
+	}
+	defer rows.Close()
+
+	var lists []models.TodoList
+	for rows.Next() {
+		var list models.TodoList
+		if err := scanList(rows, &list); err != nil {
+			return nil, fmt.Errorf("error scanning list row: %w", err)
+		}
+		lists = append(lists, list)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating list rows: %w", err)
+	}
+	return lists, nil
+}
+
+func (s *SQLStore) RenameList(ctx context.Context, userID int, list *models.TodoList) error {
+	return s.inTx(ctx, func(tx sqlConn) error {
+		if err := tx.requireListOwner(ctx, userID, list.ID); err != nil {
+			return err
+		}
+		query := "UPDATE lists SET name = $1, updated_at = $2 WHERE id = $3"
+		if _, err := tx.exec(ctx, query, list.Name, now(), list.ID); err != nil {
+			return fmt.Errorf("failed to rename list: %w", err)
+		}
+		renamed, err := tx.getList(ctx, userID, list.ID)
+		*list = renamed
+		return err
+	})
+}
+
+func (s *SQLStore) DeleteList(ctx context.Context, userID, listID, moveTo int) error {
+	return s.inTx(ctx, func(tx sqlConn) error {
+		if err := tx.requireListOwner(ctx, userID, listID); err != nil {
+			return err
+		}
+		var isDefault bool
+		query := "SELECT is_default FROM lists WHERE id = $1"
+		if err := tx.queryRow(ctx, query, listID).Scan(&isDefault); err != nil {
+			return fmt.Errorf("failed to get list: %w", err)
+		}
+		if isDefault {
+			return ErrDefaultList
+		}
+
+		if moveTo != 0 {
+			if moveTo == listID {
+				return ErrListNotFound
+			}
+			if _, err := tx.resolveList(ctx, userID, moveTo); errors.Is(err, ErrForbidden) {
+				return ErrListNotFound
+			} else if err != nil {
+				return err
+			}
+			query = "UPDATE todos SET list_id = $1, updated_at = $2 WHERE list_id = $3"
+			if _, err := tx.exec(ctx, query, moveTo, now(), listID); err != nil {
+				return fmt.Errorf("failed to move todos: %w", err)
+			}
+		}
+
+		// The remaining todos and members are deleted explicitly instead of
+		// relying on ON DELETE CASCADE, which SQLite ignores without foreign
+		// keys on.
+		if _, err := tx.exec(ctx, "DELETE FROM todos WHERE list_id = $1", listID); err != nil {
+			return fmt.Errorf("failed to delete todos: %w", err)
+		}
+		if _, err := tx.exec(ctx, "DELETE FROM list_members WHERE list_id = $1", listID); err != nil {
+			return fmt.Errorf("failed to delete list members: %w", err)
+		}
+		if _, err := tx.exec(ctx, "DELETE FROM lists WHERE id = $1", listID); err != nil {
+			return fmt.Errorf("failed to delete list: %w", err)
+		}
+		return nil
+	})
+}
+
+// resolveList returns listID if userID may add todos to it, or the ID of the
+// user's default list when listID is zero.
+func (c sqlConn) resolveList(ctx context.Context, userID, listID int) (int, error) {
+	if listID == 0 {
+		query := "SELECT id FROM lists WHERE user_id = $1 AND is_default = TRUE"
+		err := c.queryRow(ctx, query, userID).Scan(&listID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrListNotFound
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to get list: %w", err)
+		}
+		return listID, nil
+	}
+
+	role, err := c.listRole(ctx, userID, listID)
+	if errors.Is(err, ErrNotFound) {
+		return 0, ErrListNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if !role.CanEdit() {
+		return 0, ErrForbidden
+	}
+	return listID, nil
+}
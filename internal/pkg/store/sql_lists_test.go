@@ -0,0 +1,148 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+// defaultList returns the default list of userID.
+func defaultList(t *testing.T, s Store, userID int) models.TodoList {
+	t.Helper()
+	lists, err := s.ListLists(context.Background(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, list := range lists {
+		if list.IsDefault {
+			return list
+		}
+	}
+	t.Fatalf("user %d has no default list in %+v", userID, lists)
+	return models.TodoList{}
+}
+
+func TestSQLiteLists(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	ctx := context.Background()
+	jane := createTestUser(t, s, "jane@example.com")
+	john := createTestUser(t, s, "john@example.com")
+
+	inbox := defaultList(t, s, jane)
+	if inbox.Name != DefaultListName || inbox.Role != models.RoleOwner {
+		t.Errorf("default list %+v", inbox)
+	}
+
+	work := models.TodoList{Name: "Work"}
+	if err := s.CreateList(ctx, jane, &work); err != nil {
+		t.Fatal(err)
+	}
+	if work.ID == 0 || work.IsDefault || work.Role != models.RoleOwner || work.CreatedAt.IsZero() {
+		t.Errorf("created list %+v", work)
+	}
+	if _, err := s.GetList(ctx, john, work.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetList by another user: got %v, want ErrNotFound", err)
+	}
+	if err := s.RenameList(ctx, john, &models.TodoList{ID: work.ID, Name: "Mine"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RenameList by another user: got %v, want ErrNotFound", err)
+	}
+	work.Name = "Office"
+	if err := s.RenameList(ctx, jane, &work); err != nil {
+		t.Fatal(err)
+	}
+	if work.Name != "Office" || work.Role != models.RoleOwner {
+		t.Errorf("renamed list %+v", work)
+	}
+
+	// Todos go to the default list unless they name one of the user's lists.
+	created := createTestTodos(t, s, jane,
+		models.TodoItem{Title: "inbox"},
+		models.TodoItem{Title: "office", ListID: work.ID},
+	)
+	if created[0].ListID != inbox.ID || created[1].ListID != work.ID {
+		t.Errorf("created todos in lists %d and %d, want %d and %d", created[0].ListID, created[1].ListID, inbox.ID, work.ID)
+	}
+	if err := s.CreateTodo(ctx, john, &models.TodoItem{Title: "sneaky", Desc: "x", ListID: work.ID}); !errors.Is(err, ErrListNotFound) {
+		t.Errorf("creating a todo in another user's list: got %v, want ErrListNotFound", err)
+	}
+	moved := models.TodoItem{ID: created[0].ID, Title: "inbox", Desc: "moved", ListID: defaultList(t, s, john).ID}
+	if err := s.UpdateTodo(ctx, jane, &moved); !errors.Is(err, ErrListNotFound) {
+		t.Errorf("moving a todo to another user's list: got %v, want ErrListNotFound", err)
+	}
+
+	todos, err := s.ListTodos(ctx, jane, TodoFilter{ListID: work.ID, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := todoTitles(todos); !slices.Equal(got, []string{"office"}) {
+		t.Errorf("todos of the list: %q", got)
+	}
+}
+
+func TestSQLiteDeleteList(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	ctx := context.Background()
+	jane := createTestUser(t, s, "jane@example.com")
+	john := createTestUser(t, s, "john@example.com")
+	inbox := defaultList(t, s, jane)
+
+	if err := s.DeleteList(ctx, jane, inbox.ID, 0); !errors.Is(err, ErrDefaultList) {
+		t.Errorf("deleting the default list: got %v, want ErrDefaultList", err)
+	}
+
+	work := models.TodoList{Name: "Work"}
+	home := models.TodoList{Name: "Home"}
+	for _, list := range []*models.TodoList{&work, &home} {
+		if err := s.CreateList(ctx, jane, list); err != nil {
+			t.Fatal(err)
+		}
+	}
+	createTestTodos(t, s, jane,
+		models.TodoItem{Title: "report", ListID: work.ID},
+		models.TodoItem{Title: "slides", ListID: work.ID},
+		models.TodoItem{Title: "dishes", ListID: home.ID},
+	)
+
+	if err := s.DeleteList(ctx, john, work.ID, 0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleting another user's list: got %v, want ErrNotFound", err)
+	}
+	if err := s.DeleteList(ctx, jane, work.ID, work.ID); !errors.Is(err, ErrListNotFound) {
+		t.Errorf("moving todos into the deleted list: got %v, want ErrListNotFound", err)
+	}
+	if err := s.DeleteList(ctx, jane, work.ID, defaultList(t, s, john).ID); !errors.Is(err, ErrListNotFound) {
+		t.Errorf("moving todos into another user's list: got %v, want ErrListNotFound", err)
+	}
+
+	// Moving keeps the todos, deleting without a target drops them.
+	if err := s.DeleteList(ctx, jane, work.ID, inbox.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteList(ctx, jane, home.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetList(ctx, jane, work.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetList after DeleteList: got %v, want ErrNotFound", err)
+	}
+	todos, err := s.ListTodos(ctx, jane, TodoFilter{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := todoTitles(todos); !slices.Equal(got, []string{"report", "slides"}) {
+		t.Errorf("todos left: %q", got)
+	}
+	for _, todo := range todos {
+		if todo.ListID != inbox.ID {
+			t.Errorf("todo %q is in list %d, want the default list %d", todo.Title, todo.ListID, inbox.ID)
+		}
+	}
+	lists, err := s.ListLists(ctx, jane)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 1 || lists[0].ID != inbox.ID {
+		t.Errorf("lists left: %+v", lists)
+	}
+}
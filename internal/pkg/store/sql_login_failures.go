@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The login failures back auth.LoginThrottle. They are not part of Store
+// since the throttle can count them in memory as well.
+
+func (s *SQLStore) LoginFailures(ctx context.Context, key string) (int, time.Time, error) {
+	query := "SELECT failures, last_failure_at FROM login_failures WHERE throttle_key = $1"
+	var failures int
+	var last time.Time
+	err := s.queryRow(ctx, query, key).Scan(&failures, &last)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, time.Time{}, nil
+	}
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to get login failures: %w", err)
+	}
+	return failures, last, nil
+}
+
+func (s *SQLStore) RecordLoginFailure(ctx context.Context, key string, at time.Time, resetAfter time.Duration) error {
+	query := `
+		INSERT INTO login_failures (
+			throttle_key,
+			failures,
+			last_failure_at
+		) VALUES ($1, 1, $2
+		) ON CONFLICT (throttle_key) DO UPDATE SET
+			failures = CASE WHEN login_failures.last_failure_at < $3 THEN 1 ELSE login_failures.failures + 1 END,
+			last_failure_at = $2`
+	if _, err := s.exec(ctx, query, key, at.UTC(), at.Add(-resetAfter).UTC()); err != nil {
+		return fmt.Errorf("failed to record login failure: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) ResetLoginFailures(ctx context.Context, key string) error {
+	if _, err := s.exec(ctx, "DELETE FROM login_failures WHERE throttle_key = $1", key); err != nil {
+		return fmt.Errorf("failed to reset login failures: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) PruneLoginFailures(ctx context.Context, before time.Time) error {
+	if _, err := s.exec(ctx, "DELETE FROM login_failures WHERE last_failure_at < $1", before.UTC()); err != nil {
+		return fmt.Errorf("failed to prune login failures: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+const memberColumns = "u.id, u.email, u.name, m.role, m.created_at"
+
+func scanMember(row interface{ Scan(...any) error }, member *models.ListMember) error {
+	return row.Scan(&member.UserID, &member.Email, &member.Name, &member.Role, &member.CreatedAt)
+}
+
+func (s *SQLStore) ListMembers(ctx context.Context, userID, listID int) ([]models.ListMember, error) {
+	if _, err := s.listRole(ctx, userID, listID); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + memberColumns + `
+		FROM list_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.list_id = $1
+		ORDER BY CASE m.role WHEN 'owner' THEN 0 WHEN 'editor' THEN 1 ELSE 2 END, u.name ASC, u.id ASC`
+	rows, err := s.query(ctx, query, listID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve list members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []models.ListMember
+	for rows.Next() {
+		var member models.ListMember
+		if err := scanMember(rows, &member); err != nil {
+			return nil, fmt.Errorf("error scanning list member row: %w", err)
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating list member rows: %w", err)
+	}
+	return members, nil
+}
+
+func (s *SQLStore) AddListMember(ctx context.Context, userID, listID, memberID int, role models.ListRole) (models.ListMember, error) {
+	var member models.ListMember
+	err := s.inTx(ctx, func(tx sqlConn) error {
+		if err := tx.requireListOwner(ctx, userID, listID); err != nil {
+			return err
+		}
+		if err := tx.insertListMember(ctx, listID, memberID, role); err != nil {
+			return err
+		}
+		var err error
+		member, err = tx.getListMember(ctx, listID, memberID)
+		return err
+	})
+	return member, err
+}
+
+func (s *SQLStore) SetListMemberRole(ctx context.Context, userID, listID, memberID int, role models.ListRole) (models.ListMember, error) {
+	var member models.ListMember
+	err := s.inTx(ctx, func(tx sqlConn) error {
+		if err := tx.requireListOwner(ctx, userID, listID); err != nil {
+			return err
+		}
+		if err := tx.requireNonOwnerMember(ctx, listID, memberID); err != nil {
+			return err
+		}
+		query := "UPDATE list_members SET role = $1 WHERE list_id = $2 AND user_id = $3"
+		if _, err := tx.exec(ctx, query, role, listID, memberID); err != nil {
+			return fmt.Errorf("failed to update list member: %w", err)
+		}
+		var err error
+		member, err = tx.getListMember(ctx, listID, memberID)
+		return err
+	})
+	return member, err
+}
+
+func (s *SQLStore) RemoveListMember(ctx context.Context, userID, listID, memberID int) error {
+	return s.inTx(ctx, func(tx sqlConn) error {
+		// Members may leave a list on their own, everybody else needs the
+		// owner to remove them.
+		if memberID == userID {
+			if _, err := tx.listRole(ctx, userID, listID); err != nil {
+				return err
+			}
+		} else if err := tx.requireListOwner(ctx, userID, listID); err != nil {
+			return err
+		}
+		if err := tx.requireNonOwnerMember(ctx, listID, memberID); err != nil {
+			return err
+		}
+		query := "DELETE FROM list_members WHERE list_id = $1 AND user_id = $2"
+		if _, err := tx.exec(ctx, query, listID, memberID); err != nil {
+			return fmt.Errorf("failed to remove list member: %w", err)
+		}
+		return nil
+	})
+}
+
+func (c sqlConn) insertListMember(ctx context.Context, listID, userID int, role models.ListRole) error {
+	query := `
+		INSERT INTO list_members (
+			list_id,
+			user_id,
+			role,
+			created_at
+		) VALUES ($1, $2, $3, $4)`
+	if _, err := c.exec(ctx, query, listID, userID, role, now()); err != nil {
+		if c.dialect.isUniqueViolation(err) {
+			return ErrConflict
+		}
+		return fmt.Errorf("failed to add list member: %w", err)
+	}
+	return nil
+}
+
+func (c sqlConn) getListMember(ctx context.Context, listID, userID int) (models.ListMember, error) {
+	query := `
+		SELECT ` + memberColumns + `
+		FROM list_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.list_id = $1 AND m.user_id = $2`
+	var member models.ListMember
+	err := scanMember(c.queryRow(ctx, query, listID, userID), &member)
+	if errors.Is(err, sql.ErrNoRows) {
+		return member, ErrMemberNotFound
+	}
+	if err != nil {
+		return member, fmt.Errorf("failed to get list member: %w", err)
+	}
+	return member, nil
+}
+
+// listRole returns the role userID has on listID, or ErrNotFound when the
+// list does not exist or is not shared with them.
+func (c sqlConn) listRole(ctx context.Context, userID, listID int) (models.ListRole, error) {
+	query := "SELECT role FROM list_members WHERE list_id = $1 AND user_id = $2"
+	var role models.ListRole
+	err := c.queryRow(ctx, query, listID, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get list role: %w", err)
+	}
+	return role, nil
+}
+
+// requireListOwner fails with ErrNotFound unless userID is a member of
+// listID, and with ErrForbidden unless they own it.
+func (c sqlConn) requireListOwner(ctx context.Context, userID, listID int) error {
+	role, err := c.listRole(ctx, userID, listID)
+	if err != nil {
+		return err
+	}
+	if role != models.RoleOwner {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// requireNonOwnerMember fails unless memberID is a member of listID other
+// than its owner.
+func (c sqlConn) requireNonOwnerMember(ctx context.Context, listID, memberID int) error {
+	role, err := c.listRole(ctx, memberID, listID)
+	if errors.Is(err, ErrNotFound) {
+		return ErrMemberNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if role == models.RoleOwner {
+		return ErrListOwner
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+func TestSQLiteListRoles(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	ctx := context.Background()
+	jane := createTestUser(t, s, "jane@example.com")
+	john := createTestUser(t, s, "john@example.com")
+	mary := createTestUser(t, s, "mary@example.com")
+
+	team := models.TodoList{Name: "Team"}
+	if err := s.CreateList(ctx, jane, &team); err != nil {
+		t.Fatal(err)
+	}
+	todo := createTestTodos(t, s, jane, models.TodoItem{Title: "plan sprint", ListID: team.ID})[0]
+
+	viewer, err := s.AddListMember(ctx, jane, team.ID, john, models.RoleViewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if viewer.UserID != john || viewer.Email != "john@example.com" || viewer.Role != models.RoleViewer {
+		t.Errorf("added member %+v", viewer)
+	}
+	if _, err := s.AddListMember(ctx, jane, team.ID, mary, models.RoleEditor); err != nil {
+		t.Fatal(err)
+	}
+
+	// Viewers see the list and its todos but cannot change anything.
+	list, err := s.GetList(ctx, john, team.ID)
+	if err != nil || list.Role != models.RoleViewer {
+		t.Errorf("viewer's GetList = %+v, %v", list, err)
+	}
+	if _, err := s.GetTodo(ctx, john, todo.ID); err != nil {
+		t.Errorf("viewer's GetTodo: %v", err)
+	}
+	todos, err := s.ListTodos(ctx, john, TodoFilter{Limit: 10})
+	if err != nil || len(todos) != 1 || todos[0].ID != todo.ID {
+		t.Errorf("viewer's ListTodos = %+v, %v", todos, err)
+	}
+	edit := models.TodoItem{ID: todo.ID, Title: "skip sprint", Desc: "viewer edit"}
+	if err := s.UpdateTodo(ctx, john, &edit); !errors.Is(err, ErrForbidden) {
+		t.Errorf("viewer's UpdateTodo: got %v, want ErrForbidden", err)
+	}
+	if _, err := s.SetTodoCompleted(ctx, john, todo.ID, true); !errors.Is(err, ErrForbidden) {
+		t.Errorf("viewer's SetTodoCompleted: got %v, want ErrForbidden", err)
+	}
+	if err := s.DeleteTodo(ctx, john, todo.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("viewer's DeleteTodo: got %v, want ErrForbidden", err)
+	}
+	if err := s.CreateTodo(ctx, john, &models.TodoItem{Title: "extra", Desc: "x", ListID: team.ID}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("viewer's CreateTodo: got %v, want ErrForbidden", err)
+	}
+	if err := s.AddChecklistItem(ctx, john, todo.ID, &models.ChecklistItem{Title: "agenda"}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("viewer's AddChecklistItem: got %v, want ErrForbidden", err)
+	}
+	got, err := s.GetTodo(ctx, jane, todo.ID)
+	if err != nil || got.Title != "plan sprint" || got.Completed {
+		t.Errorf("todo after the viewer's attempts: %+v, %v", got, err)
+	}
+
+	// Editors change todos but not the list or its members.
+	edit = models.TodoItem{ID: todo.ID, Title: "plan sprint 2", Desc: "editor edit"}
+	if err := s.UpdateTodo(ctx, mary, &edit); err != nil {
+		t.Errorf("editor's UpdateTodo: %v", err)
+	}
+	if err := s.CreateTodo(ctx, mary, &models.TodoItem{Title: "retro", Desc: "x", ListID: team.ID}); err != nil {
+		t.Errorf("editor's CreateTodo: %v", err)
+	}
+	if err := s.RenameList(ctx, mary, &models.TodoList{ID: team.ID, Name: "Mary's"}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("editor's RenameList: got %v, want ErrForbidden", err)
+	}
+	if err := s.DeleteList(ctx, mary, team.ID, 0); !errors.Is(err, ErrForbidden) {
+		t.Errorf("editor's DeleteList: got %v, want ErrForbidden", err)
+	}
+	if _, err := s.SetListMemberRole(ctx, mary, team.ID, john, models.RoleEditor); !errors.Is(err, ErrForbidden) {
+		t.Errorf("editor's SetListMemberRole: got %v, want ErrForbidden", err)
+	}
+
+	// Promoting the viewer lets them edit.
+	if _, err := s.SetListMemberRole(ctx, jane, team.ID, john, models.RoleEditor); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SetTodoCompleted(ctx, john, todo.ID, true); err != nil {
+		t.Errorf("promoted member's SetTodoCompleted: %v", err)
+	}
+}
+
+func TestSQLiteListMembers(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	ctx := context.Background()
+	jane := createTestUser(t, s, "jane@example.com")
+	john := createTestUser(t, s, "john@example.com")
+	mary := createTestUser(t, s, "mary@example.com")
+
+	team := models.TodoList{Name: "Team"}
+	if err := s.CreateList(ctx, jane, &team); err != nil {
+		t.Fatal(err)
+	}
+	todo := createTestTodos(t, s, jane, models.TodoItem{Title: "plan sprint", ListID: team.ID})[0]
+
+	if _, err := s.ListMembers(ctx, john, team.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ListMembers by a stranger: got %v, want ErrNotFound", err)
+	}
+	if _, err := s.AddListMember(ctx, john, team.ID, john, models.RoleEditor); !errors.Is(err, ErrNotFound) {
+		t.Errorf("joining a list uninvited: got %v, want ErrNotFound", err)
+	}
+	if _, err := s.AddListMember(ctx, jane, team.ID, john, models.RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddListMember(ctx, jane, team.ID, john, models.RoleEditor); !errors.Is(err, ErrConflict) {
+		t.Errorf("adding a member twice: got %v, want ErrConflict", err)
+	}
+	if _, err := s.AddListMember(ctx, jane, team.ID, mary, models.RoleEditor); err != nil {
+		t.Fatal(err)
+	}
+
+	members, err := s.ListMembers(ctx, john, team.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		userID int
+		role   models.ListRole
+	}{{jane, models.RoleOwner}, {mary, models.RoleEditor}, {john, models.RoleViewer}}
+	if len(members) != len(want) {
+		t.Fatalf("members %+v", members)
+	}
+	for i, w := range want {
+		if members[i].UserID != w.userID || members[i].Role != w.role {
+			t.Errorf("member %d is %+v, want user %d as %s", i, members[i], w.userID, w.role)
+		}
+	}
+
+	// The owner stays the owner.
+	if _, err := s.SetListMemberRole(ctx, jane, team.ID, jane, models.RoleViewer); !errors.Is(err, ErrListOwner) {
+		t.Errorf("demoting the owner: got %v, want ErrListOwner", err)
+	}
+	if err := s.RemoveListMember(ctx, jane, team.ID, jane); !errors.Is(err, ErrListOwner) {
+		t.Errorf("removing the owner: got %v, want ErrListOwner", err)
+	}
+	stranger := createTestUser(t, s, "stranger@example.com")
+	if _, err := s.SetListMemberRole(ctx, jane, team.ID, stranger, models.RoleEditor); !errors.Is(err, ErrMemberNotFound) {
+		t.Errorf("changing the role of a non-member: got %v, want ErrMemberNotFound", err)
+	}
+
+	// Members may leave on their own but not remove each other.
+	if err := s.RemoveListMember(ctx, mary, team.ID, john); !errors.Is(err, ErrForbidden) {
+		t.Errorf("an editor removing a member: got %v, want ErrForbidden", err)
+	}
+	if err := s.RemoveListMember(ctx, john, team.ID, john); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetTodo(ctx, john, todo.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetTodo after leaving the list: got %v, want ErrNotFound", err)
+	}
+	if err := s.RemoveListMember(ctx, jane, team.ID, mary); err != nil {
+		t.Fatal(err)
+	}
+	if lists, err := s.ListLists(ctx, mary); err != nil || len(lists) != 1 || !lists[0].IsDefault {
+		t.Errorf("lists of a removed member: %+v, %v", lists, err)
+	}
+}
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+func (s *SQLStore) CreateOIDCLogin(ctx context.Context, login *models.OIDCLogin) error {
+	return s.inTx(ctx, func(tx sqlConn) error {
+		ts := now()
+		if _, err := tx.exec(ctx, "DELETE FROM oidc_logins WHERE expires_at < $1", ts); err != nil {
+			return fmt.Errorf("failed to prune OIDC logins: %w", err)
+		}
+		query := `
+			INSERT INTO oidc_logins (
+				state_hash,
+				nonce,
+				code_verifier,
+				expires_at,
+				created_at
+			) VALUES ($1, $2, $3, $4, $5
+			) RETURNING id, created_at`
+		err := tx.queryRow(ctx, query, login.StateHash, login.Nonce, login.CodeVerifier, login.ExpiresAt.UTC(), ts).Scan(&login.ID, &login.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create OIDC login: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *SQLStore) ConsumeOIDCLogin(ctx context.Context, stateHash string) (models.OIDCLogin, error) {
+	query := `
+		DELETE FROM oidc_logins
+		WHERE state_hash = $1
+		RETURNING id, state_hash, nonce, code_verifier, expires_at, created_at`
+	var login models.OIDCLogin
+	err := s.queryRow(ctx, query, stateHash).Scan(&login.ID, &login.StateHash, &login.Nonce, &login.CodeVerifier, &login.ExpiresAt, &login.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.OIDCLogin{}, ErrNotFound
+	}
+	if err != nil {
+		return models.OIDCLogin{}, fmt.Errorf("failed to consume OIDC login: %w", err)
+	}
+	if !login.ExpiresAt.After(now()) {
+		return models.OIDCLogin{}, ErrNotFound
+	}
+	return login, nil
+}
+
+func (s *SQLStore) FindUserByIdentity(ctx context.Context, issuer, subject string) (models.ListCurator, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE id = (SELECT user_id FROM user_identities WHERE issuer = $1 AND subject = $2)`
+	var user models.ListCurator
+	err := scanUser(s.queryRow(ctx, query, issuer, subject), &user)
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, ErrNotFound
+	}
+	if err != nil {
+		return user, fmt.Errorf("failed to find user: %w", err)
+	}
+	return user, nil
+}
+
+func (s *SQLStore) LinkIdentity(ctx context.Context, identity *models.UserIdentity) error {
+	return s.inTx(ctx, func(tx sqlConn) error {
+		return tx.insertIdentity(ctx, identity)
+	})
+}
+
+func (s *SQLStore) CreateUserWithIdentity(ctx context.Context, user *models.ListCurator, identity *models.UserIdentity) error {
+	return s.inTx(ctx, func(tx sqlConn) error {
+		if err := tx.insertUser(ctx, user); err != nil {
+			return err
+		}
+		identity.UserID = user.ID
+		return tx.insertIdentity(ctx, identity)
+	})
+}
+
+func (c sqlConn) insertIdentity(ctx context.Context, identity *models.UserIdentity) error {
+	query := `
+		INSERT INTO user_identities (
+			user_id,
+			issuer,
+			subject,
+			email,
+			created_at
+		) VALUES ($1, $2, $3, $4, $5
+		) RETURNING id, created_at`
+	err := c.queryRow(ctx, query, identity.UserID, identity.Issuer, identity.Subject, identity.Email, now()).Scan(&identity.ID, &identity.CreatedAt)
+	if err != nil {
+		if c.dialect.isUniqueViolation(err) {
+			return ErrConflict
+		}
+		return fmt.Errorf("failed to link identity: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+func (s *SQLStore) CreatePasswordReset(ctx context.Context, reset *models.PasswordReset) error {
+	return s.inTx(ctx, func(tx sqlConn) error {
+		query := "DELETE FROM password_resets WHERE user_id = $1 AND used_at IS NULL"
+		if _, err := tx.exec(ctx, query, reset.UserID); err != nil {
+			return fmt.Errorf("failed to replace password resets: %w", err)
+		}
+		query = `
+			INSERT INTO password_resets (
+				user_id,
+				token_hash,
+				expires_at,
+				created_at
+			) VALUES ($1, $2, $3, $4
+			) RETURNING id, created_at`
+		err := tx.queryRow(ctx, query, reset.UserID, reset.TokenHash, reset.ExpiresAt.UTC(), now()).Scan(&reset.ID, &reset.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create password reset: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *SQLStore) FindPasswordReset(ctx context.Context, tokenHash string) (models.PasswordReset, error) {
+	query := `
+		SELECT id, user_id, token_hash, expires_at, created_at
+		FROM password_resets
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2`
+	var reset models.PasswordReset
+	err := s.queryRow(ctx, query, tokenHash, now()).Scan(&reset.ID, &reset.UserID, &reset.TokenHash, &reset.ExpiresAt, &reset.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.PasswordReset{}, ErrNotFound
+	}
+	if err != nil {
+		return models.PasswordReset{}, fmt.Errorf("failed to find password reset: %w", err)
+	}
+	return reset, nil
+}
+
+func (s *SQLStore) ResetPassword(ctx context.Context, tokenHash, passwordHash string) (int, error) {
+	var userID int
+	err := s.inTx(ctx, func(tx sqlConn) error {
+		ts := now()
+		query := `
+			UPDATE password_resets
+			SET used_at = $1
+			WHERE token_hash = $2 AND used_at IS NULL AND expires_at > $1
+			RETURNING user_id`
+		err := tx.queryRow(ctx, query, ts, tokenHash).Scan(&userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to use password reset: %w", err)
+		}
+
+		if _, err := tx.exec(ctx, "UPDATE users SET password_hash = $1 WHERE id = $2", passwordHash, userID); err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+		query = "UPDATE refresh_tokens SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL"
+		if _, err := tx.exec(ctx, query, ts, userID); err != nil {
+			return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+		}
+		return nil
+	})
+	return userID, err
+}
@@ -0,0 +1,43 @@
+package store
+
+import (
+	"context"
+	"fmt"
+)
+
+// advanceRecurrence creates the next occurrence of todoID if it is a
+// completed recurring todo that has not been advanced yet. The occurrence
+// copies the todo, including its tags and an unchecked copy of its
+// checklist, and is due at the next date of the recurrence rule.
+func (c sqlConn) advanceRecurrence(ctx context.Context, todoID int) error {
+	query := "SELECT " + todoColumns + " FROM todos WHERE id = $1"
+	todos, err := c.queryTodos(ctx, query, todoID)
+	if err != nil {
+		return fmt.Errorf("failed to get todo: %w", err)
+	}
+	next, ok, err := nextOccurrence(todos[0])
+	if err != nil || !ok {
+		return err
+	}
+
+	var creatorID int
+	query = "SELECT user_id FROM todos WHERE id = $1"
+	if err := c.queryRow(ctx, query, todoID).Scan(&creatorID); err != nil {
+		return fmt.Errorf("failed to get todo: %w", err)
+	}
+	if err := c.insertTodo(ctx, creatorID, &next); err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO checklist_items (todo_id, title, position, created_at, updated_at)
+		SELECT $1, title, position, $2, $2 FROM checklist_items WHERE todo_id = $3`
+	if _, err := c.exec(ctx, query, next.ID, now(), todoID); err != nil {
+		return fmt.Errorf("failed to copy checklist: %w", err)
+	}
+	query = "UPDATE todos SET next_occurrence_id = $1 WHERE id = $2"
+	if _, err := c.exec(ctx, query, next.ID, todoID); err != nil {
+		return fmt.Errorf("failed to link next occurrence: %w", err)
+	}
+	return nil
+}
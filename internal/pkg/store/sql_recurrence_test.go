@@ -0,0 +1,144 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+// createTestUserIn stores a user living in the time zone tz and returns
+// their ID.
+func createTestUserIn(t *testing.T, s Store, email, tz string) int {
+	t.Helper()
+	userID := createTestUser(t, s, email)
+	user := models.ListCurator{ID: userID, Name: "Test User", Timezone: tz, Locale: DefaultLocale}
+	if err := s.UpdateProfile(context.Background(), &user); err != nil {
+		t.Fatal(err)
+	}
+	return userID
+}
+
+// completeOccurrence completes the recurring todo todoID and returns its
+// next occurrence.
+func completeOccurrence(t *testing.T, s Store, userID, todoID int) models.TodoItem {
+	t.Helper()
+	ctx := context.Background()
+	completed, err := s.SetTodoCompleted(ctx, userID, todoID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completed.NextOccurrenceID == nil {
+		t.Fatalf("completing todo %d created no next occurrence", todoID)
+	}
+	next, err := s.GetTodo(ctx, userID, *completed.NextOccurrenceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return next
+}
+
+func TestSQLiteRecurrenceTimeZones(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	brisbane, err := time.LoadLocation("Australia/Brisbane")
+	if err != nil {
+		t.Fatal(err)
+	}
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		tz         string
+		due        time.Time
+		recurrence string
+		want       []time.Time
+	}{
+		{
+			// 09:00 on Monday in Brisbane is still Sunday in UTC, where
+			// BYDAY=MO would land on Tuesday morning in Brisbane.
+			name:       "weekly on Mondays ahead of UTC",
+			tz:         "Australia/Brisbane",
+			due:        time.Date(2024, 1, 8, 9, 0, 0, 0, brisbane),
+			recurrence: "FREQ=WEEKLY;BYDAY=MO",
+			want: []time.Time{
+				time.Date(2024, 1, 15, 9, 0, 0, 0, brisbane),
+				time.Date(2024, 1, 22, 9, 0, 0, 0, brisbane),
+			},
+		},
+		{
+			// Daily at 09:00 stays at 09:00 when Berlin moves to summer
+			// time, an hour earlier in UTC.
+			name:       "daily across daylight saving time",
+			tz:         "Europe/Berlin",
+			due:        time.Date(2024, 3, 30, 9, 0, 0, 0, berlin),
+			recurrence: "FREQ=DAILY",
+			want: []time.Time{
+				time.Date(2024, 3, 31, 9, 0, 0, 0, berlin),
+				time.Date(2024, 4, 1, 9, 0, 0, 0, berlin),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := createTestUserIn(t, s, tt.tz+"@example.com", tt.tz)
+			created := createTestTodos(t, s, userID, models.TodoItem{Title: tt.name, DueAt: &tt.due, Recurrence: tt.recurrence})
+			if created[0].RecurrenceTZ != tt.tz {
+				t.Errorf("recurrence time zone %q, want %q", created[0].RecurrenceTZ, tt.tz)
+			}
+
+			todoID := created[0].ID
+			for i, want := range tt.want {
+				next := completeOccurrence(t, s, userID, todoID)
+				if next.DueAt == nil || !next.DueAt.Equal(want) {
+					t.Errorf("occurrence %d due %v, want %v", i+1, next.DueAt, want)
+				}
+				if next.RecurrenceTZ != tt.tz {
+					t.Errorf("occurrence %d has recurrence time zone %q, want %q", i+1, next.RecurrenceTZ, tt.tz)
+				}
+				todoID = next.ID
+			}
+		})
+	}
+}
+
+func TestSQLiteRecurrenceTimeZoneUpdates(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	ctx := context.Background()
+	jane := createTestUserIn(t, s, "jane@example.com", "Europe/Berlin")
+
+	due := time.Date(2024, 3, 30, 8, 0, 0, 0, time.UTC)
+	todo := createTestTodos(t, s, jane, models.TodoItem{Title: "Stretch", DueAt: &due, Recurrence: "FREQ=DAILY"})[0]
+
+	// The series keeps its time zone when the user moves, until its rule
+	// changes.
+	user := models.ListCurator{ID: jane, Name: "Jane", Timezone: "America/New_York", Locale: DefaultLocale}
+	if err := s.UpdateProfile(ctx, &user); err != nil {
+		t.Fatal(err)
+	}
+	update := models.TodoItem{ID: todo.ID, Title: "Stretch more", Desc: "test", DueAt: &due, Recurrence: "FREQ=DAILY"}
+	if err := s.UpdateTodo(ctx, jane, &update); err != nil {
+		t.Fatal(err)
+	}
+	if update.RecurrenceTZ != "Europe/Berlin" {
+		t.Errorf("after an update keeping the rule got time zone %q, want Europe/Berlin", update.RecurrenceTZ)
+	}
+	update.Recurrence = "FREQ=WEEKLY"
+	if err := s.UpdateTodo(ctx, jane, &update); err != nil {
+		t.Fatal(err)
+	}
+	if update.RecurrenceTZ != "America/New_York" {
+		t.Errorf("after changing the rule got time zone %q, want America/New_York", update.RecurrenceTZ)
+	}
+
+	update.Recurrence = ""
+	if err := s.UpdateTodo(ctx, jane, &update); err != nil {
+		t.Fatal(err)
+	}
+	if update.RecurrenceTZ != "" {
+		t.Errorf("todo without recurrence has time zone %q", update.RecurrenceTZ)
+	}
+}
@@ -0,0 +1,166 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+func (s *SQLStore) CreateTag(ctx context.Context, userID int, tag *models.Tag) error {
+	query := `
+		INSERT INTO tags (
+			user_id,
+			name,
+			created_at
+		) VALUES ($1, $2, $3
+		) RETURNING id`
+	err := s.queryRow(ctx, query, userID, tag.Name, now()).Scan(&tag.ID)
+	if err != nil {
+		if s.dialect.isUniqueViolation(err) {
+			return ErrConflict
+		}
+		return fmt.Errorf("failed to create tag: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) ListTags(ctx context.Context, userID int) ([]models.Tag, error) {
+	query := `
+		SELECT id, name
+		FROM tags
+		WHERE user_id = $1
+		ORDER BY name ASC`
+	rows, err := s.query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []models.Tag
+	for rows.Next() {
+		var tag models.Tag
+		if err := rows.Scan(&tag.ID, &tag.Name); err != nil {
+			return nil, fmt.Errorf("error scanning tag row: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tag rows: %w", err)
+	}
+	return tags, nil
+}
+
+func (s *SQLStore) RenameTag(ctx context.Context, userID int, tag *models.Tag) error {
+	query := `
+		UPDATE tags
+		SET name = $1
+		WHERE id = $2 AND user_id = $3
+		RETURNING id`
+	err := s.queryRow(ctx, query, tag.Name, tag.ID, userID).Scan(&tag.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		if s.dialect.isUniqueViolation(err) {
+			return ErrConflict
+		}
+		return fmt.Errorf("failed to rename tag: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) DeleteTag(ctx context.Context, userID, tagID int) error {
+	query := `
+		DELETE FROM tags
+		WHERE id = $1 AND user_id = $2
+		RETURNING id`
+	var deletedTagID int
+	err := s.queryRow(ctx, query, tagID, userID).Scan(&deletedTagID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete tag: %w", err)
+	}
+	return nil
+}
+
+// setTodoTags replaces the tags of todoID with the tags called names,
+// creating the ones userID does not have yet, and returns the new tag names.
+func (c sqlConn) setTodoTags(ctx context.Context, userID, todoID int, names []string) ([]string, error) {
+	names = normalizeTagNames(names)
+
+	if _, err := c.exec(ctx, "DELETE FROM todo_tags WHERE todo_id = $1", todoID); err != nil {
+		return nil, fmt.Errorf("failed to clear todo tags: %w", err)
+	}
+
+	for _, name := range names {
+		query := `
+			INSERT INTO tags (
+				user_id,
+				name,
+				created_at
+			) VALUES ($1, $2, $3
+			) ON CONFLICT (user_id, name) DO NOTHING`
+		if _, err := c.exec(ctx, query, userID, name, now()); err != nil {
+			return nil, fmt.Errorf("failed to create tag %q: %w", name, err)
+		}
+
+		query = `
+			INSERT INTO todo_tags (todo_id, tag_id)
+			SELECT $1, id FROM tags WHERE user_id = $2 AND name = $3`
+		if _, err := c.exec(ctx, query, todoID, userID, name); err != nil {
+			return nil, fmt.Errorf("failed to tag todo with %q: %w", name, err)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, nil
+	}
+	return names, nil
+}
+
+// loadTodoTags fills in the Tags field of every todo with a single query.
+func (c sqlConn) loadTodoTags(ctx context.Context, todos []*models.TodoItem) error {
+	if len(todos) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*models.TodoItem, len(todos))
+	args := make([]any, 0, len(todos))
+	for _, todo := range todos {
+		todo.Tags = nil
+		byID[todo.ID] = todo
+		args = append(args, todo.ID)
+	}
+
+	query := `
+		SELECT tt.todo_id, t.name
+		FROM todo_tags tt
+		JOIN tags t ON t.id = tt.tag_id
+		WHERE tt.todo_id IN (` + placeholders(1, len(args)) + `)
+		ORDER BY t.name ASC`
+	rows, err := c.query(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve todo tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var todoID int
+		var name string
+		if err := rows.Scan(&todoID, &name); err != nil {
+			return fmt.Errorf("error scanning todo tag row: %w", err)
+		}
+		if todo, ok := byID[todoID]; ok {
+			todo.Tags = append(todo.Tags, name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating todo tag rows: %w", err)
+	}
+	return nil
+}
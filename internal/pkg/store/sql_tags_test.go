@@ -0,0 +1,144 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+func TestSQLiteTags(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	ctx := context.Background()
+	jane := createTestUser(t, s, "jane@example.com")
+	john := createTestUser(t, s, "john@example.com")
+
+	work := models.Tag{Name: "work"}
+	if err := s.CreateTag(ctx, jane, &work); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateTag(ctx, jane, &models.Tag{Name: "work"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("creating a tag twice: got %v, want ErrConflict", err)
+	}
+	// Tag names are unique per user only.
+	if err := s.CreateTag(ctx, john, &models.Tag{Name: "work"}); err != nil {
+		t.Errorf("another user's tag with the same name: %v", err)
+	}
+
+	// Tagging a todo creates the tags that do not exist yet.
+	todo := models.TodoItem{Title: "Report", Desc: "Q3", Tags: []string{"urgent", " work ", "urgent"}}
+	if err := s.CreateTodo(ctx, jane, &todo); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"urgent", "work"}; !slices.Equal(todo.Tags, want) {
+		t.Errorf("created todo with tags %q, want %q", todo.Tags, want)
+	}
+	tags, err := s.ListTags(ctx, jane)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 2 || tags[0].Name != "urgent" || tags[1] != work {
+		t.Errorf("ListTags = %+v", tags)
+	}
+
+	// Renaming is scoped to the owner and renames the tag on every todo.
+	if err := s.RenameTag(ctx, john, &models.Tag{ID: work.ID, Name: "stolen"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("renaming another user's tag: got %v, want ErrNotFound", err)
+	}
+	if err := s.RenameTag(ctx, jane, &models.Tag{ID: work.ID, Name: "urgent"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("renaming onto an existing tag: got %v, want ErrConflict", err)
+	}
+	if err := s.RenameTag(ctx, jane, &models.Tag{ID: work.ID, Name: "office"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetTodo(ctx, jane, todo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"office", "urgent"}; !slices.Equal(got.Tags, want) {
+		t.Errorf("todo tags after rename: %q, want %q", got.Tags, want)
+	}
+
+	// Nil tags leave the todo's tags alone, an empty list clears them.
+	update := models.TodoItem{ID: todo.ID, Title: "Report", Desc: "Q4"}
+	if err := s.UpdateTodo(ctx, jane, &update); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"office", "urgent"}; !slices.Equal(update.Tags, want) {
+		t.Errorf("tags after update without tags: %q, want %q", update.Tags, want)
+	}
+	update.Tags = []string{}
+	if err := s.UpdateTodo(ctx, jane, &update); err != nil {
+		t.Fatal(err)
+	}
+	if update.Tags != nil {
+		t.Errorf("tags after clearing them: %q", update.Tags)
+	}
+
+	if err := s.DeleteTag(ctx, john, work.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleting another user's tag: got %v, want ErrNotFound", err)
+	}
+	if err := s.DeleteTag(ctx, jane, work.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteTag(ctx, jane, work.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleting a tag twice: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestSQLiteTagFilters(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	ctx := context.Background()
+	jane := createTestUser(t, s, "jane@example.com")
+	createTestTodos(t, s, jane,
+		models.TodoItem{Title: "home", Tags: []string{"home"}},
+		models.TodoItem{Title: "work", Tags: []string{"work"}},
+		models.TodoItem{Title: "both", Tags: []string{"home", "work"}},
+		models.TodoItem{Title: "untagged"},
+	)
+
+	tests := []struct {
+		tags []string
+		all  bool
+		want []string
+	}{
+		{[]string{"home"}, false, []string{"home", "both"}},
+		{[]string{"home", "work"}, false, []string{"home", "work", "both"}},
+		{[]string{"home", "work"}, true, []string{"both"}},
+		// Repeating a tag does not make all-matching impossible.
+		{[]string{"work", " work"}, true, []string{"work", "both"}},
+		{[]string{"garden"}, false, nil},
+		{[]string{"home", "garden"}, true, nil},
+	}
+	for _, tt := range tests {
+		todos, err := s.ListTodos(ctx, jane, TodoFilter{Tags: tt.tags, MatchAllTags: tt.all, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := todoTitles(todos); !slices.Equal(got, tt.want) {
+			t.Errorf("tags %q (all %v): got %q, want %q", tt.tags, tt.all, got, tt.want)
+		}
+	}
+
+	// Deleting a tag unlabels its todos but keeps them.
+	tags, err := s.ListTags(ctx, jane)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tag := range tags {
+		if tag.Name == "home" {
+			if err := s.DeleteTag(ctx, jane, tag.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	todos, err := s.ListTodos(ctx, jane, TodoFilter{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(todos) != 4 || todos[0].Tags != nil || !slices.Equal(todos[2].Tags, []string{"work"}) {
+		t.Errorf("todos after deleting the tag: %+v", todos)
+	}
+}
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+// createTestTodos stores todos for userID in order and returns them with
+// their stored fields filled in.
+func createTestTodos(t *testing.T, s Store, userID int, todos ...models.TodoItem) []models.TodoItem {
+	t.Helper()
+	for i := range todos {
+		if todos[i].Desc == "" {
+			todos[i].Desc = "test"
+		}
+		if err := s.CreateTodo(context.Background(), userID, &todos[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return todos
+}
+
+// todoTitles returns the titles of todos in order.
+func todoTitles(todos []models.TodoItem) []string {
+	titles := make([]string, len(todos))
+	for i, todo := range todos {
+		titles[i] = todo.Title
+	}
+	return titles
+}
+
+func TestSQLiteDueDates(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	ctx := context.Background()
+	jane := createTestUser(t, s, "jane@example.com")
+
+	sydney, err := time.LoadLocation("Australia/Sydney")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Due dates are stored in UTC however they were given, so the filters
+	// compare instants and not the text of the offset.
+	due := func(s string) *time.Time {
+		at, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &at
+	}
+	local := time.Date(2024, 3, 1, 8, 0, 0, 0, sydney) // 2024-02-29T21:00:00Z
+	created := createTestTodos(t, s, jane,
+		models.TodoItem{Title: "no due date"},
+		models.TodoItem{Title: "sydney", DueAt: &local},
+		models.TodoItem{Title: "morning", DueAt: due("2024-02-29T09:00:00Z")},
+		models.TodoItem{Title: "half second", DueAt: due("2024-02-29T09:00:00.5Z")},
+		models.TodoItem{Title: "evening", DueAt: due("2024-02-29T23:30:00+01:00")},
+	)
+
+	got, err := s.GetTodo(ctx, jane, created[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.DueAt == nil || !got.DueAt.Equal(local) || got.DueAt.Location() != time.UTC {
+		t.Errorf("due date stored as %v, want %v in UTC", got.DueAt, local)
+	}
+	if got.CreatedAt.IsZero() || !got.UpdatedAt.Equal(got.CreatedAt) {
+		t.Errorf("new todo has created_at %v and updated_at %v", got.CreatedAt, got.UpdatedAt)
+	}
+
+	tests := []struct {
+		name   string
+		filter TodoFilter
+		want   []string
+	}{
+		{"all", TodoFilter{}, []string{"no due date", "sydney", "morning", "half second", "evening"}},
+		{"due before", TodoFilter{DueBefore: due("2024-02-29T21:00:00Z")}, []string{"morning", "half second"}},
+		{"due before is strict", TodoFilter{DueBefore: due("2024-02-29T09:00:00.5Z")}, []string{"morning"}},
+		{"due after", TodoFilter{DueAfter: due("2024-02-29T09:00:00Z")}, []string{"sydney", "half second", "evening"}},
+		{"due between", TodoFilter{DueAfter: due("2024-02-29T11:30:00+02:00"), DueBefore: due("2024-03-01T09:00:00+11:00")}, []string{"sydney"}},
+	}
+	for _, tt := range tests {
+		tt.filter.Limit = 10
+		todos, err := s.ListTodos(ctx, jane, tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := todoTitles(todos); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// Updating a todo keeps created_at, moves updated_at and can clear the
+	// due date.
+	update := models.TodoItem{ID: created[1].ID, Title: "sydney", Desc: "no longer due"}
+	if err := s.UpdateTodo(ctx, jane, &update); err != nil {
+		t.Fatal(err)
+	}
+	if update.DueAt != nil || !update.CreatedAt.Equal(got.CreatedAt) || update.UpdatedAt.Before(got.UpdatedAt) {
+		t.Errorf("updated %+v, created %+v", update, got)
+	}
+}
+
+func TestSQLiteSortTodos(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	ctx := context.Background()
+	jane := createTestUser(t, s, "jane@example.com")
+
+	monday := time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)
+	tuesday := monday.AddDate(0, 0, 1)
+	createTestTodos(t, s, jane,
+		models.TodoItem{Title: "b", Priority: models.PriorityLow, DueAt: &tuesday},
+		models.TodoItem{Title: "a", Priority: models.PriorityUrgent},
+		models.TodoItem{Title: "c", Priority: models.PriorityLow, DueAt: &monday},
+		models.TodoItem{Title: "d", Priority: models.PriorityUrgent, DueAt: &tuesday},
+		models.TodoItem{Title: "e"},
+	)
+
+	tests := []struct {
+		spec string
+		want []string
+	}{
+		{"", []string{"b", "a", "c", "d", "e"}},
+		{"title", []string{"a", "b", "c", "d", "e"}},
+		{"-title", []string{"e", "d", "c", "b", "a"}},
+		// Priorities sort by rank, not by name.
+		{"-priority,title", []string{"a", "d", "b", "c", "e"}},
+		// Todos without a due date come last either way.
+		{"due_at", []string{"c", "b", "d", "a", "e"}},
+		{"-due_at", []string{"b", "d", "c", "a", "e"}},
+		{"-priority,due_at", []string{"d", "a", "c", "b", "e"}},
+	}
+	for _, tt := range tests {
+		sorts, err := ParseTodoSort(tt.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		todos, err := s.ListTodos(ctx, jane, TodoFilter{Sort: sorts, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := todoTitles(todos); !slices.Equal(got, tt.want) {
+			t.Errorf("sort=%s: got %q, want %q", tt.spec, got, tt.want)
+		}
+	}
+
+	// The ID tie-breaker keeps pages stable.
+	sorts, _ := ParseTodoSort("-priority")
+	var paged []string
+	for offset := 0; offset < 5; offset += 2 {
+		todos, err := s.ListTodos(ctx, jane, TodoFilter{Sort: sorts, Limit: 2, Offset: offset})
+		if err != nil {
+			t.Fatal(err)
+		}
+		paged = append(paged, todoTitles(todos)...)
+	}
+	if want := []string{"a", "d", "b", "c", "e"}; !slices.Equal(paged, want) {
+		t.Errorf("paging through sort=-priority: got %q, want %q", paged, want)
+	}
+}
@@ -0,0 +1,193 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+const refreshTokenColumns = "id, user_id, family_id, token_hash, expires_at, used_at, revoked_at, created_at"
+
+func scanRefreshToken(row interface{ Scan(...any) error }, token *models.RefreshToken) error {
+	return row.Scan(&token.ID, &token.UserID, &token.FamilyID, &token.TokenHash, &token.ExpiresAt, &token.UsedAt, &token.RevokedAt, &token.CreatedAt)
+}
+
+func (s *SQLStore) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	return s.inTx(ctx, func(tx sqlConn) error {
+		query := "DELETE FROM refresh_tokens WHERE user_id = $1 AND expires_at < $2"
+		if _, err := tx.exec(ctx, query, token.UserID, now()); err != nil {
+			return fmt.Errorf("failed to prune refresh tokens: %w", err)
+		}
+		return tx.insertRefreshToken(ctx, token)
+	})
+}
+
+func (s *SQLStore) RotateRefreshToken(ctx context.Context, tokenHash string, next *models.RefreshToken) error {
+	reused := false
+	err := s.inTx(ctx, func(tx sqlConn) error {
+		query := `
+			SELECT ` + refreshTokenColumns + `
+			FROM refresh_tokens
+			WHERE token_hash = $1`
+		var current models.RefreshToken
+		err := scanRefreshToken(tx.queryRow(ctx, query, tokenHash), &current)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get refresh token: %w", err)
+		}
+
+		ts := now()
+		if current.RevokedAt != nil || !current.ExpiresAt.After(ts) {
+			return ErrNotFound
+		}
+		if current.UsedAt == nil {
+			// Mark the token as used only if no concurrent refresh got to
+			// it first, which would make this request a replay as well.
+			query = "UPDATE refresh_tokens SET used_at = $1 WHERE id = $2 AND used_at IS NULL"
+			result, err := tx.exec(ctx, query, ts, current.ID)
+			if err != nil {
+				return fmt.Errorf("failed to use refresh token: %w", err)
+			}
+			rows, err := result.RowsAffected()
+			if err != nil {
+				return fmt.Errorf("failed to use refresh token: %w", err)
+			}
+			if rows == 1 {
+				next.UserID = current.UserID
+				next.FamilyID = current.FamilyID
+				return tx.insertRefreshToken(ctx, next)
+			}
+		}
+
+		// The revocation has to be committed, so the transaction succeeds
+		// and the error is reported afterwards.
+		reused = true
+		query = "UPDATE refresh_tokens SET revoked_at = $1 WHERE family_id = $2 AND revoked_at IS NULL"
+		if _, err := tx.exec(ctx, query, ts, current.FamilyID); err != nil {
+			return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+		}
+		return nil
+	})
+	if err == nil && reused {
+		return ErrTokenReused
+	}
+	return err
+}
+
+func (c sqlConn) insertRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (
+			user_id,
+			family_id,
+			token_hash,
+			expires_at,
+			created_at
+		) VALUES ($1, $2, $3, $4, $5
+		) RETURNING ` + refreshTokenColumns
+	err := scanRefreshToken(c.queryRow(ctx, query, token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt.UTC(), now()), token)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) RevokeRefreshToken(ctx context.Context, userID int, tokenHash string) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = $1
+		WHERE revoked_at IS NULL AND family_id IN (
+			SELECT family_id FROM refresh_tokens WHERE token_hash = $2 AND user_id = $3
+		)`
+	result, err := s.exec(ctx, query, now(), tokenHash, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLStore) RevokeUserRefreshTokens(ctx context.Context, userID int) error {
+	return s.revokeUserRefreshTokens(ctx, userID)
+}
+
+func (c sqlConn) revokeUserRefreshTokens(ctx context.Context, userID int) error {
+	query := "UPDATE refresh_tokens SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL"
+	if _, err := c.exec(ctx, query, now(), userID); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	return nil
+}
+
+const tokenRevocationColumns = "id, token_id, user_id, expires_at, created_at"
+
+func scanTokenRevocation(row interface{ Scan(...any) error }, revocation *models.TokenRevocation) error {
+	var tokenID sql.NullString
+	err := row.Scan(&revocation.ID, &tokenID, &revocation.UserID, &revocation.ExpiresAt, &revocation.CreatedAt)
+	revocation.TokenID = tokenID.String
+	return err
+}
+
+func (s *SQLStore) RevokeAccessTokens(ctx context.Context, revocation *models.TokenRevocation) error {
+	var tokenID *string
+	if revocation.TokenID != "" {
+		tokenID = &revocation.TokenID
+	}
+	query := `
+		INSERT INTO token_revocations (
+			token_id,
+			user_id,
+			expires_at,
+			created_at
+		) VALUES ($1, $2, $3, $4
+		) RETURNING ` + tokenRevocationColumns
+	err := scanTokenRevocation(s.queryRow(ctx, query, tokenID, revocation.UserID, revocation.ExpiresAt.UTC(), now()), revocation)
+	if err != nil {
+		return fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) ListTokenRevocations(ctx context.Context, since time.Time) ([]models.TokenRevocation, error) {
+	query := `
+		SELECT ` + tokenRevocationColumns + `
+		FROM token_revocations
+		WHERE created_at >= $1 AND expires_at > $2
+		ORDER BY id ASC`
+	rows, err := s.query(ctx, query, since.UTC(), now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve token revocations: %w", err)
+	}
+	defer rows.Close()
+
+	var revocations []models.TokenRevocation
+	for rows.Next() {
+		var revocation models.TokenRevocation
+		if err := scanTokenRevocation(rows, &revocation); err != nil {
+			return nil, fmt.Errorf("error scanning token revocation row: %w", err)
+		}
+		revocations = append(revocations, revocation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating token revocation rows: %w", err)
+	}
+	return revocations, nil
+}
+
+func (s *SQLStore) PruneTokenRevocations(ctx context.Context, before time.Time) error {
+	if _, err := s.exec(ctx, "DELETE FROM token_revocations WHERE expires_at < $1", before.UTC()); err != nil {
+		return fmt.Errorf("failed to prune token revocations: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+// loginRefreshToken stores the first token of family for userID.
+func loginRefreshToken(t *testing.T, s Store, userID int, family, hash string) models.RefreshToken {
+	t.Helper()
+	token := models.RefreshToken{UserID: userID, FamilyID: family, TokenHash: hash, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := s.CreateRefreshToken(context.Background(), &token); err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// rotate exchanges the token stored as hash for one stored as nextHash.
+func rotate(s Store, hash, nextHash string) (models.RefreshToken, error) {
+	next := models.RefreshToken{TokenHash: nextHash, ExpiresAt: time.Now().Add(time.Hour)}
+	err := s.RotateRefreshToken(context.Background(), hash, &next)
+	return next, err
+}
+
+func TestSQLiteRotateRefreshToken(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	jane := createTestUser(t, s, "jane@example.com")
+	loginRefreshToken(t, s, jane, "laptop", "a1")
+	loginRefreshToken(t, s, jane, "phone", "b1")
+
+	next, err := rotate(s, "a1", "a2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.UserID != jane || next.FamilyID != "laptop" || next.ID == 0 {
+		t.Errorf("rotated to %+v, want the next token of the laptop family", next)
+	}
+	if _, err := rotate(s, "a2", "a3"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replaying a used token revokes its whole family, including the token
+	// the attacker or the victim would use next.
+	if _, err := rotate(s, "a1", "x"); !errors.Is(err, ErrTokenReused) {
+		t.Fatalf("replaying a used token: got %v, want ErrTokenReused", err)
+	}
+	if _, err := rotate(s, "a3", "a4"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("rotating the newest token of a revoked family: got %v, want ErrNotFound", err)
+	}
+	if _, err := rotate(s, "x", "x2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("the replay got a token: %v", err)
+	}
+	// Other logins of the user are left alone.
+	if _, err := rotate(s, "b1", "b2"); err != nil {
+		t.Errorf("rotating another family after the replay: %v", err)
+	}
+
+	if _, err := rotate(s, "unknown", "c2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("rotating an unknown token: got %v, want ErrNotFound", err)
+	}
+	expired := models.RefreshToken{UserID: jane, FamilyID: "old", TokenHash: "d1", ExpiresAt: time.Now().Add(-time.Minute)}
+	if err := s.CreateRefreshToken(context.Background(), &expired); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rotate(s, "d1", "d2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("rotating an expired token: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestSQLiteRevokeRefreshTokens(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	ctx := context.Background()
+	jane := createTestUser(t, s, "jane@example.com")
+	john := createTestUser(t, s, "john@example.com")
+	loginRefreshToken(t, s, jane, "laptop", "a1")
+	loginRefreshToken(t, s, jane, "phone", "b1")
+	loginRefreshToken(t, s, jane, "tablet", "c1")
+	loginRefreshToken(t, s, john, "desktop", "d1")
+	if _, err := rotate(s, "a1", "a2"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Logging out with an old token of the family still ends the session.
+	if err := s.RevokeRefreshToken(ctx, john, "a1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("revoking another user's token: got %v, want ErrNotFound", err)
+	}
+	if err := s.RevokeRefreshToken(ctx, jane, "a1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rotate(s, "a2", "a3"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("rotating after logout: got %v, want ErrNotFound", err)
+	}
+	if err := s.RevokeRefreshToken(ctx, jane, "a2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("logging out twice: got %v, want ErrNotFound", err)
+	}
+
+	if err := s.RevokeUserRefreshTokens(ctx, jane); err != nil {
+		t.Fatal(err)
+	}
+	for _, hash := range []string{"b1", "c1"} {
+		if _, err := rotate(s, hash, hash+"-next"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("rotating %s after revoking every token: got %v, want ErrNotFound", hash, err)
+		}
+	}
+	if _, err := rotate(s, "d1", "d2"); err != nil {
+		t.Errorf("another user's token after revoking Jane's: %v", err)
+	}
+}
+
+func TestSQLiteCreateRefreshTokenPrunes(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	ctx := context.Background()
+	jane := createTestUser(t, s, "jane@example.com")
+	expired := models.RefreshToken{UserID: jane, FamilyID: "old", TokenHash: "a1", ExpiresAt: time.Now().Add(-time.Minute)}
+	if err := s.CreateRefreshToken(ctx, &expired); err != nil {
+		t.Fatal(err)
+	}
+	loginRefreshToken(t, s, jane, "new", "b1")
+
+	var hashes []string
+	rows, err := s.db.QueryContext(ctx, "SELECT token_hash FROM refresh_tokens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, hash)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes) != 1 || hashes[0] != "b1" {
+		t.Errorf("stored tokens %q, want the expired one pruned", hashes)
+	}
+}
+
+func TestSQLiteTokenRevocations(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	ctx := context.Background()
+	jane := createTestUser(t, s, "jane@example.com")
+	start := time.Now().Add(-time.Second)
+
+	token := models.TokenRevocation{TokenID: "abc", UserID: jane, ExpiresAt: time.Now().Add(time.Hour)}
+	user := models.TokenRevocation{UserID: jane, ExpiresAt: time.Now().Add(2 * time.Hour)}
+	expired := models.TokenRevocation{TokenID: "old", UserID: jane, ExpiresAt: time.Now().Add(-time.Minute)}
+	for _, revocation := range []*models.TokenRevocation{&token, &user, &expired} {
+		if err := s.RevokeAccessTokens(ctx, revocation); err != nil {
+			t.Fatal(err)
+		}
+		if revocation.ID == 0 || revocation.CreatedAt.Before(start) {
+			t.Errorf("stored revocation %+v", revocation)
+		}
+	}
+
+	revocations, err := s.ListTokenRevocations(ctx, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revocations) != 2 || revocations[0].TokenID != "abc" || revocations[1].TokenID != "" || revocations[1].UserID != jane {
+		t.Errorf("ListTokenRevocations = %+v, want the unexpired revocations", revocations)
+	}
+	if !revocations[1].CreatedAt.Equal(user.CreatedAt) {
+		t.Errorf("cutoff read back as %v, stored as %v", revocations[1].CreatedAt, user.CreatedAt)
+	}
+	if revocations, err := s.ListTokenRevocations(ctx, time.Now().Add(time.Minute)); err != nil || len(revocations) != 0 {
+		t.Errorf("ListTokenRevocations since a later time = %+v, %v", revocations, err)
+	}
+
+	if err := s.PruneTokenRevocations(ctx, time.Now().Add(90*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM token_revocations").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("%d revocations left after pruning, want only the user revocation", count)
+	}
+}
@@ -0,0 +1,208 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+func (s *SQLStore) GetTOTP(ctx context.Context, userID int) (models.TOTPEnrollment, error) {
+	query := `
+		SELECT user_id, secret, confirmed_at, last_step, created_at, (
+			SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL
+		)
+		FROM user_totp
+		WHERE user_id = $1`
+	var enrollment models.TOTPEnrollment
+	err := s.queryRow(ctx, query, userID).Scan(&enrollment.UserID, &enrollment.Secret, &enrollment.ConfirmedAt,
+		&enrollment.LastStep, &enrollment.CreatedAt, &enrollment.RecoveryCodesLeft)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.TOTPEnrollment{}, ErrNotFound
+	}
+	if err != nil {
+		return models.TOTPEnrollment{}, fmt.Errorf("failed to get TOTP enrollment: %w", err)
+	}
+	return enrollment, nil
+}
+
+func (s *SQLStore) BeginTOTPEnrollment(ctx context.Context, userID int, secret string) error {
+	return s.inTx(ctx, func(tx sqlConn) error {
+		var confirmed bool
+		query := "SELECT confirmed_at IS NOT NULL FROM user_totp WHERE user_id = $1"
+		err := tx.queryRow(ctx, query, userID).Scan(&confirmed)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to get TOTP enrollment: %w", err)
+		}
+		if confirmed {
+			return ErrConflict
+		}
+
+		if _, err := tx.exec(ctx, "DELETE FROM user_totp WHERE user_id = $1", userID); err != nil {
+			return fmt.Errorf("failed to replace TOTP enrollment: %w", err)
+		}
+		query = "INSERT INTO user_totp (user_id, secret, created_at) VALUES ($1, $2, $3)"
+		if _, err := tx.exec(ctx, query, userID, secret, now()); err != nil {
+			return fmt.Errorf("failed to create TOTP enrollment: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *SQLStore) ConfirmTOTP(ctx context.Context, userID int, step int64, codeHashes []string) error {
+	return s.inTx(ctx, func(tx sqlConn) error {
+		query := "UPDATE user_totp SET confirmed_at = $1, last_step = $2 WHERE user_id = $3 AND confirmed_at IS NULL"
+		result, err := tx.exec(ctx, query, now(), step, userID)
+		if err != nil {
+			return fmt.Errorf("failed to confirm TOTP enrollment: %w", err)
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to confirm TOTP enrollment: %w", err)
+		}
+		if rows == 0 {
+			return ErrNotFound
+		}
+		return tx.replaceRecoveryCodes(ctx, userID, codeHashes)
+	})
+}
+
+func (s *SQLStore) UseTOTPStep(ctx context.Context, userID int, step int64) error {
+	// The step only moves forward if no concurrent request used it first.
+	query := "UPDATE user_totp SET last_step = $1 WHERE user_id = $2 AND confirmed_at IS NOT NULL AND last_step < $1"
+	result, err := s.exec(ctx, query, step, userID)
+	if err != nil {
+		return fmt.Errorf("failed to use TOTP code: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to use TOTP code: %w", err)
+	}
+	if rows == 0 {
+		return ErrCodeReused
+	}
+	return nil
+}
+
+func (s *SQLStore) UseRecoveryCode(ctx context.Context, userID int, codeHash string) error {
+	query := "UPDATE recovery_codes SET used_at = $1 WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL"
+	result, err := s.exec(ctx, query, now(), userID, codeHash)
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLStore) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	return s.inTx(ctx, func(tx sqlConn) error {
+		return tx.replaceRecoveryCodes(ctx, userID, codeHashes)
+	})
+}
+
+func (c sqlConn) replaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	if _, err := c.exec(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("failed to replace recovery codes: %w", err)
+	}
+	ts := now()
+	for _, hash := range codeHashes {
+		query := "INSERT INTO recovery_codes (user_id, code_hash, created_at) VALUES ($1, $2, $3)"
+		if _, err := c.exec(ctx, query, userID, hash, ts); err != nil {
+			return fmt.Errorf("failed to create recovery code: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *SQLStore) DisableTOTP(ctx context.Context, userID int) error {
+	return s.inTx(ctx, func(tx sqlConn) error {
+		if _, err := tx.exec(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+			return fmt.Errorf("failed to delete recovery codes: %w", err)
+		}
+		if _, err := tx.exec(ctx, "DELETE FROM user_totp WHERE user_id = $1", userID); err != nil {
+			return fmt.Errorf("failed to delete TOTP enrollment: %w", err)
+		}
+		return nil
+	})
+}
+
+const mfaChallengeColumns = "id, user_id, token_hash, attempts, expires_at, created_at"
+
+func scanMFAChallenge(row interface{ Scan(...any) error }, challenge *models.MFAChallenge) error {
+	return row.Scan(&challenge.ID, &challenge.UserID, &challenge.TokenHash, &challenge.Attempts, &challenge.ExpiresAt, &challenge.CreatedAt)
+}
+
+func (s *SQLStore) CreateMFAChallenge(ctx context.Context, challenge *models.MFAChallenge) error {
+	return s.inTx(ctx, func(tx sqlConn) error {
+		ts := now()
+		query := "DELETE FROM mfa_challenges WHERE user_id = $1 AND expires_at < $2"
+		if _, err := tx.exec(ctx, query, challenge.UserID, ts); err != nil {
+			return fmt.Errorf("failed to prune login challenges: %w", err)
+		}
+		query = `
+			INSERT INTO mfa_challenges (
+				user_id,
+				token_hash,
+				expires_at,
+				created_at
+			) VALUES ($1, $2, $3, $4
+			) RETURNING ` + mfaChallengeColumns
+		err := scanMFAChallenge(tx.queryRow(ctx, query, challenge.UserID, challenge.TokenHash, challenge.ExpiresAt.UTC(), ts), challenge)
+		if err != nil {
+			return fmt.Errorf("failed to create login challenge: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *SQLStore) GetMFAChallenge(ctx context.Context, tokenHash string) (models.MFAChallenge, error) {
+	query := `
+		SELECT ` + mfaChallengeColumns + `
+		FROM mfa_challenges
+		WHERE token_hash = $1 AND expires_at > $2`
+	var challenge models.MFAChallenge
+	err := scanMFAChallenge(s.queryRow(ctx, query, tokenHash, now()), &challenge)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.MFAChallenge{}, ErrNotFound
+	}
+	if err != nil {
+		return models.MFAChallenge{}, fmt.Errorf("failed to get login challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+func (s *SQLStore) FailMFAChallenge(ctx context.Context, challengeID, maxAttempts int) error {
+	return s.inTx(ctx, func(tx sqlConn) error {
+		if _, err := tx.exec(ctx, "UPDATE mfa_challenges SET attempts = attempts + 1 WHERE id = $1", challengeID); err != nil {
+			return fmt.Errorf("failed to update login challenge: %w", err)
+		}
+		query := "DELETE FROM mfa_challenges WHERE id = $1 AND attempts >= $2"
+		if _, err := tx.exec(ctx, query, challengeID, maxAttempts); err != nil {
+			return fmt.Errorf("failed to delete login challenge: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *SQLStore) DeleteMFAChallenge(ctx context.Context, challengeID int) error {
+	result, err := s.exec(ctx, "DELETE FROM mfa_challenges WHERE id = $1", challengeID)
+	if err != nil {
+		return fmt.Errorf("failed to delete login challenge: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete login challenge: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// NewSQLiteStore returns a Store backed by a go-sqlite3 database. SQLite
+// (3.35 or later) understands the RETURNING clauses used by SQLStore, so only
+// the placeholders need translating.
+func NewSQLiteStore(db *sql.DB) *SQLStore {
+	return newSQLStore(db, sqliteDialect{})
+}
+
+type sqliteDialect struct{}
+
+// rebind turns PostgreSQL's $N placeholders into SQLite's ?N, which keep
+// the same numbering so an argument can be referenced more than once.
+func (sqliteDialect) rebind(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	for i := 0; i < len(query); i++ {
+		if query[i] == '$' && i+1 < len(query) && query[i+1] >= '0' && query[i+1] <= '9' {
+			b.WriteByte('?')
+			continue
+		}
+		b.WriteByte(query[i])
+	}
+	return b.String()
+}
+
+func (sqliteDialect) isUniqueViolation(err error) bool {
+	var dbError sqlite3.Error
+	return errors.As(err, &dbError) &&
+		(dbError.ExtendedCode == sqlite3.ErrConstraintUnique || dbError.ExtendedCode == sqlite3.ErrConstraintPrimaryKey)
+}
@@ -0,0 +1,219 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/db"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+// newTestSQLiteStore returns a store on a fresh, fully migrated SQLite
+// database, opened the way db.Open does.
+func newTestSQLiteStore(t *testing.T) *SQLStore {
+	t.Helper()
+	conn, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "todo.db")+"?_foreign_keys=on&_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetMaxOpenConns(1)
+	t.Cleanup(func() { conn.Close() })
+
+	migrator, err := db.NewMigrator(conn, db.DriverSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return NewSQLiteStore(conn)
+}
+
+// createTestUser stores a user with the given email address and returns
+// their ID.
+func createTestUser(t *testing.T, s Store, email string) int {
+	t.Helper()
+	user := models.ListCurator{Email: email, Name: "Test User", Password: "hash"}
+	if err := s.CreateUser(context.Background(), &user); err != nil {
+		t.Fatal(err)
+	}
+	return user.ID
+}
+
+func TestSQLiteRebind(t *testing.T) {
+	tests := []struct {
+		query, want string
+	}{
+		{"SELECT 1", "SELECT 1"},
+		{"WHERE id = $1 AND user_id = $2", "WHERE id = ?1 AND user_id = ?2"},
+		// Placeholders keep their number, so one argument can be used twice.
+		{"VALUES ($1, $2, $10, $10)", "VALUES (?1, ?2, ?10, ?10)"},
+		{"SELECT '$' || price, $1", "SELECT '$' || price, ?1"},
+		{"trailing $", "trailing $"},
+	}
+	for _, tt := range tests {
+		if got := (sqliteDialect{}).rebind(tt.query); got != tt.want {
+			t.Errorf("rebind(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSQLiteCreateUser(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	ctx := context.Background()
+
+	user := models.ListCurator{Email: "jane@example.com", Name: "Jane", Password: "hash"}
+	if err := s.CreateUser(ctx, &user); err != nil {
+		t.Fatal(err)
+	}
+	if user.ID == 0 || user.Timezone != DefaultTimezone || user.Locale != DefaultLocale {
+		t.Errorf("got %+v, want an ID and the default profile settings", user)
+	}
+
+	// The unique constraint is reported as ErrConflict by the dialect.
+	duplicate := models.ListCurator{Email: "jane@example.com", Name: "Other Jane", Password: "hash"}
+	if err := s.CreateUser(ctx, &duplicate); !errors.Is(err, ErrConflict) {
+		t.Errorf("registering the address twice: got %v, want ErrConflict", err)
+	}
+
+	got, err := s.FindUserByEmail(ctx, "jane@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != user.ID || got.Name != "Jane" || got.Password != "hash" {
+		t.Errorf("got %+v, want %+v", got, user)
+	}
+	if _, err := s.GetUser(ctx, user.ID+1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown user: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestSQLiteTodos(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	ctx := context.Background()
+	jane := createTestUser(t, s, "jane@example.com")
+	john := createTestUser(t, s, "john@example.com")
+
+	todo := models.TodoItem{Title: "Buy milk", Desc: "2 litres"}
+	if err := s.CreateTodo(ctx, jane, &todo); err != nil {
+		t.Fatal(err)
+	}
+	if todo.ID == 0 || todo.ListID == 0 || todo.CreatedAt.IsZero() {
+		t.Errorf("created %+v, want the stored fields filled in", todo)
+	}
+
+	got, err := s.GetTodo(ctx, jane, todo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "Buy milk" || got.Desc != "2 litres" || !got.CreatedAt.Equal(todo.CreatedAt) {
+		t.Errorf("got %+v, want %+v", got, todo)
+	}
+
+	// Other users cannot see, change or delete the todo.
+	if _, err := s.GetTodo(ctx, john, todo.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetTodo by another user: got %v, want ErrNotFound", err)
+	}
+	stolen := models.TodoItem{ID: todo.ID, Title: "Mine", Desc: "now"}
+	if err := s.UpdateTodo(ctx, john, &stolen); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateTodo by another user: got %v, want ErrNotFound", err)
+	}
+	if err := s.DeleteTodo(ctx, john, todo.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteTodo by another user: got %v, want ErrNotFound", err)
+	}
+
+	update := models.TodoItem{ID: todo.ID, Title: "Buy oat milk", Desc: "1 litre"}
+	if err := s.UpdateTodo(ctx, jane, &update); err != nil {
+		t.Fatal(err)
+	}
+	if update.Title != "Buy oat milk" || update.ListID != todo.ListID || update.UpdatedAt.Before(todo.UpdatedAt) {
+		t.Errorf("updated %+v", update)
+	}
+
+	other := models.TodoItem{Title: "Call mum", Desc: "Sunday"}
+	if err := s.CreateTodo(ctx, john, &other); err != nil {
+		t.Fatal(err)
+	}
+	todos, err := s.ListTodos(ctx, jane, TodoFilter{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(todos) != 1 || todos[0].ID != todo.ID || todos[0].Title != "Buy oat milk" {
+		t.Errorf("ListTodos returned %+v, want only Jane's todo", todos)
+	}
+
+	if err := s.DeleteTodo(ctx, jane, todo.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetTodo(ctx, jane, todo.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetTodo after DeleteTodo: got %v, want ErrNotFound", err)
+	}
+	if err := s.DeleteTodo(ctx, jane, todo.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleting twice: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestSQLiteForeignKeys(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	ctx := context.Background()
+	jane := createTestUser(t, s, "jane@example.com")
+	todo := models.TodoItem{Title: "Buy milk", Desc: "2 litres"}
+	if err := s.CreateTodo(ctx, jane, &todo); err != nil {
+		t.Fatal(err)
+	}
+
+	// Deleting the user cascades to their todos only when foreign keys are
+	// enforced, which SQLite leaves off unless asked.
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM users WHERE id = ?", jane); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM todos").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("%d todos outlived their user", count)
+	}
+}
+
+func TestSQLiteUpdateTodoModified(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	ctx := context.Background()
+	jane := createTestUser(t, s, "jane@example.com")
+	todo := models.TodoItem{Title: "Buy milk", Desc: "2 litres"}
+	if err := s.CreateTodo(ctx, jane, &todo); err != nil {
+		t.Fatal(err)
+	}
+	read := todo.UpdatedAt
+
+	// An update based on the stored version goes through and moves it on.
+	first := models.TodoItem{ID: todo.ID, Title: "Buy oat milk", Desc: "2 litres", UpdatedAt: read}
+	if err := s.UpdateTodo(ctx, jane, &first); err != nil {
+		t.Fatal(err)
+	}
+	// A second update based on the same version would lose the first one.
+	second := models.TodoItem{ID: todo.ID, Title: "Buy soy milk", Desc: "2 litres", UpdatedAt: read}
+	if err := s.UpdateTodo(ctx, jane, &second); !errors.Is(err, ErrModified) {
+		t.Errorf("update of a stale version: got %v, want ErrModified", err)
+	}
+	got, err := s.GetTodo(ctx, jane, todo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "Buy oat milk" || !got.UpdatedAt.Equal(first.UpdatedAt) {
+		t.Errorf("got %+v after the refused update, want %+v", got, first)
+	}
+
+	// The version just read matches, and a zero one skips the check.
+	second.UpdatedAt = got.UpdatedAt
+	if err := s.UpdateTodo(ctx, jane, &second); err != nil {
+		t.Errorf("update of the current version: %v", err)
+	}
+	unconditional := models.TodoItem{ID: todo.ID, Title: "Buy milk", Desc: "any"}
+	if err := s.UpdateTodo(ctx, jane, &unconditional); err != nil {
+		t.Errorf("update without a version: %v", err)
+	}
+}
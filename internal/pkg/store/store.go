@@ -0,0 +1,364 @@
+// Package store defines the persistence interfaces used by the HTTP handlers
+// together with SQL (PostgreSQL and SQLite) and in-memory implementations of
+// them.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Kwagmire/go-todo-api/internal/pkg/models"
+)
+
+var (
+	// ErrNotFound is returned when the requested record does not exist or is
+	// not visible to the requesting user.
+	ErrNotFound = errors.New("store: record not found")
+	// ErrConflict is returned when a write would violate a uniqueness rule,
+	// such as registering an email address twice.
+	ErrConflict = errors.New("store: record already exists")
+	// ErrListNotFound is returned when a todo is created in or moved to a
+	// list that does not exist or is not visible to the requesting user.
+	ErrListNotFound = errors.New("store: list not found")
+	// ErrDefaultList is returned when deleting a user's default list.
+	ErrDefaultList = errors.New("store: the default list cannot be deleted")
+	// ErrForbidden is returned when the requesting user can see a record
+	// but their role on its list does not allow the change.
+	ErrForbidden = errors.New("store: insufficient permissions")
+	// ErrMemberNotFound is returned when changing the role of, or removing,
+	// a user that is not a member of the list.
+	ErrMemberNotFound = errors.New("store: list member not found")
+	// ErrListOwner is returned when changing the role of, or removing, the
+	// owner of a list.
+	ErrListOwner = errors.New("store: the list owner cannot be changed or removed")
+	// ErrItemNotFound is returned when a checklist item does not exist or
+	// belongs to another todo.
+	ErrItemNotFound = errors.New("store: checklist item not found")
+	// ErrChecklistOrder is returned when reordering a checklist with IDs
+	// that do not name each of its items exactly once.
+	ErrChecklistOrder = errors.New("store: item IDs must name every checklist item exactly once")
+	// ErrTokenReused is returned when a refresh token that was already
+	// exchanged is presented again.
+	ErrTokenReused = errors.New("store: refresh token reused")
+	// ErrCodeReused is returned when a one-time code is presented again
+	// within its time step, or after a later code was accepted.
+	ErrCodeReused = errors.New("store: one-time code already used")
+	// ErrModified is returned when a todo was changed after the version an
+	// update was based on.
+	ErrModified = errors.New("store: record modified concurrently")
+)
+
+const (
+	// DefaultListName is the name of the list every user starts with.
+	DefaultListName = "Inbox"
+	// DefaultTimezone and DefaultLocale are the profile settings of users
+	// that did not choose their own.
+	DefaultTimezone = "UTC"
+	DefaultLocale   = "en"
+)
+
+// TodoFilter narrows down the todos returned by TodoStore.ListTodos.
+type TodoFilter struct {
+	// ListID restricts the result to one list. Zero returns the todos of
+	// every list.
+	ListID int
+	// Completed restricts the result to completed (true) or open (false)
+	// todos. A nil value returns both.
+	Completed *bool
+	// DueBefore and DueAfter, when set, only keep todos with a due date
+	// strictly before or after the given instant.
+	DueBefore *time.Time
+	DueAfter  *time.Time
+	// Tags keeps todos carrying any of the named tags, or all of them when
+	// MatchAllTags is set.
+	Tags         []string
+	MatchAllTags bool
+	// Sort orders the result, see ParseTodoSort. Todos are ordered by ID
+	// when it is empty.
+	Sort   []TodoSort
+	Limit  int
+	Offset int
+}
+
+// TodoStore persists the to-do items of every user. All methods are scoped
+// to the lists userID is a member of: any role may read their todos, while
+// changes need the owner or editor role and fail with ErrForbidden for
+// viewers. Todos outside those lists are reported as ErrNotFound.
+type TodoStore interface {
+	// CreateTodo stores todo in list todo.ListID, or in the user's default
+	// list when it is zero, and tags it with todo.Tags, creating tags the
+	// todo's creator does not have yet.
+	CreateTodo(ctx context.Context, userID int, todo *models.TodoItem) error
+	GetTodo(ctx context.Context, userID, todoID int) (models.TodoItem, error)
+	// UpdateTodo overwrites the editable fields of todo.ID and refreshes the
+	// remaining fields of todo. Nil tags and a zero ListID are left alone.
+	// A non-zero todo.UpdatedAt must match the stored one, or the todo is
+	// left alone and ErrModified returned, so read-modify-write cycles do
+	// not lose concurrent changes.
+	UpdateTodo(ctx context.Context, userID int, todo *models.TodoItem) error
+	SetTodoCompleted(ctx context.Context, userID, todoID int, completed bool) (models.TodoItem, error)
+	DeleteTodo(ctx context.Context, userID, todoID int) error
+	ListTodos(ctx context.Context, userID int, filter TodoFilter) ([]models.TodoItem, error)
+}
+
+// UserStore persists registered users. The Password field of
+// models.ListCurator always carries the password hash, never the plain text.
+type UserStore interface {
+	// CreateUser stores user together with their default list.
+	// user.EmailVerified is stored as well, so users whose address is
+	// already known to be theirs need no verification.
+	CreateUser(ctx context.Context, user *models.ListCurator) error
+	GetUser(ctx context.Context, userID int) (models.ListCurator, error)
+	FindUserByEmail(ctx context.Context, email string) (models.ListCurator, error)
+}
+
+// TagStore persists the tags each user labels their todos with. Tag names
+// are unique per user.
+type TagStore interface {
+	CreateTag(ctx context.Context, userID int, tag *models.Tag) error
+	ListTags(ctx context.Context, userID int) ([]models.Tag, error)
+	// RenameTag sets the name of tag.ID to tag.Name.
+	RenameTag(ctx context.Context, userID int, tag *models.Tag) error
+	// DeleteTag removes the tag from every todo carrying it.
+	DeleteTag(ctx context.Context, userID, tagID int) error
+}
+
+// ListStore persists the named lists that group each user's todos. Every
+// user has exactly one default list, which cannot be deleted. Lists shared
+// with userID are returned alongside their own ones with models.TodoList.Role
+// set to the user's role, but only the owner may rename or delete a list.
+type ListStore interface {
+	CreateList(ctx context.Context, userID int, list *models.TodoList) error
+	GetList(ctx context.Context, userID, listID int) (models.TodoList, error)
+	ListLists(ctx context.Context, userID int) ([]models.TodoList, error)
+	// RenameList sets the name of list.ID to list.Name and refreshes the
+	// remaining fields of list.
+	RenameList(ctx context.Context, userID int, list *models.TodoList) error
+	// DeleteList removes listID. Its todos are moved to moveTo when it is
+	// non-zero and deleted along with the list otherwise. A moveTo list the
+	// user cannot edit is reported as ErrListNotFound.
+	DeleteList(ctx context.Context, userID, listID, moveTo int) error
+}
+
+// ListMemberStore persists who a list is shared with. The owner of a list
+// is always one of its members; every other member is an editor or viewer.
+// Any member may see the member list, only the owner may change it, and
+// members may remove themselves to leave a list.
+type ListMemberStore interface {
+	ListMembers(ctx context.Context, userID, listID int) ([]models.ListMember, error)
+	// AddListMember shares listID with memberID, failing with ErrConflict
+	// when they are already a member.
+	AddListMember(ctx context.Context, userID, listID, memberID int, role models.ListRole) (models.ListMember, error)
+	SetListMemberRole(ctx context.Context, userID, listID, memberID int, role models.ListRole) (models.ListMember, error)
+	RemoveListMember(ctx context.Context, userID, listID, memberID int) error
+}
+
+// ChecklistStore persists the ordered checklist items nested under a todo.
+// Access follows the todo's list like in TodoStore. Every change refreshes
+// the todo's updated_at and, when the todo has AutoComplete set, completes
+// it once all items are done or reopens it otherwise.
+type ChecklistStore interface {
+	ListChecklistItems(ctx context.Context, userID, todoID int) ([]models.ChecklistItem, error)
+	// AddChecklistItem inserts item at item.Position, shifting the items
+	// after it down. Negative positions and positions past the end append
+	// the item.
+	AddChecklistItem(ctx context.Context, userID, todoID int, item *models.ChecklistItem) error
+	// RenameChecklistItem sets the title of item.ID to item.Title and
+	// refreshes the remaining fields of item.
+	RenameChecklistItem(ctx context.Context, userID, todoID int, item *models.ChecklistItem) error
+	ToggleChecklistItem(ctx context.Context, userID, todoID, itemID int) (models.ChecklistItem, error)
+	// ReorderChecklist moves the items of todoID into the order of itemIDs,
+	// which must name each of them exactly once.
+	ReorderChecklist(ctx context.Context, userID, todoID int, itemIDs []int) ([]models.ChecklistItem, error)
+	DeleteChecklistItem(ctx context.Context, userID, todoID, itemID int) error
+}
+
+// RefreshTokenStore persists the refresh tokens handed out at login. Only
+// the hash of a token is stored. Every login starts a new token family that
+// grows by one token per refresh, and only its newest token can be used.
+type RefreshTokenStore interface {
+	// CreateRefreshToken stores token as the first token of its family and
+	// forgets the expired tokens of token.UserID.
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	// RotateRefreshToken exchanges the token stored as tokenHash for next,
+	// which joins the same family with its UserID and FamilyID filled in.
+	// Unknown, expired and revoked tokens are reported as ErrNotFound. A
+	// token that was already exchanged revokes its whole family and fails
+	// with ErrTokenReused.
+	RotateRefreshToken(ctx context.Context, tokenHash string, next *models.RefreshToken) error
+	// RevokeRefreshToken revokes the family of the token stored as
+	// tokenHash, which must belong to userID.
+	RevokeRefreshToken(ctx context.Context, userID int, tokenHash string) error
+	// RevokeUserRefreshTokens revokes every refresh token of userID.
+	RevokeUserRefreshTokens(ctx context.Context, userID int) error
+}
+
+// TokenRevocationStore persists revoked access tokens until they expire, see
+// models.TokenRevocation.
+type TokenRevocationStore interface {
+	// RevokeAccessTokens stores revocation and sets its ID and CreatedAt.
+	RevokeAccessTokens(ctx context.Context, revocation *models.TokenRevocation) error
+	// ListTokenRevocations returns the unexpired revocations created at or
+	// after since.
+	ListTokenRevocations(ctx context.Context, since time.Time) ([]models.TokenRevocation, error)
+	// PruneTokenRevocations deletes the revocations that expired before
+	// before.
+	PruneTokenRevocations(ctx context.Context, before time.Time) error
+}
+
+// PasswordResetStore persists the tokens that let users who forgot their
+// password choose a new one. Only the hash of a token is stored.
+type PasswordResetStore interface {
+	// CreatePasswordReset stores reset, replacing the unused tokens
+	// reset.UserID asked for earlier.
+	CreatePasswordReset(ctx context.Context, reset *models.PasswordReset) error
+	// FindPasswordReset returns the token stored as tokenHash without using
+	// it. Unknown, expired and used tokens are reported as ErrNotFound.
+	FindPasswordReset(ctx context.Context, tokenHash string) (models.PasswordReset, error)
+	// ResetPassword uses the token stored as tokenHash to set the password
+	// hash of its user and revokes their refresh tokens. It returns the ID
+	// of the user. Unknown, expired and used tokens are reported as
+	// ErrNotFound.
+	ResetPassword(ctx context.Context, tokenHash, passwordHash string) (int, error)
+}
+
+// EmailVerificationStore persists the tokens that verify the email address
+// of a user. Only the hash of a token is stored.
+type EmailVerificationStore interface {
+	// CreateEmailVerification stores verification, replacing the tokens
+	// sent to verification.UserID earlier.
+	CreateEmailVerification(ctx context.Context, verification *models.EmailVerification) error
+	// VerifyEmail marks the email address of the user the token stored as
+	// tokenHash was sent to as verified and returns the ID of the user.
+	// Unknown and expired tokens are reported as ErrNotFound.
+	VerifyEmail(ctx context.Context, tokenHash string) (int, error)
+}
+
+// TwoFactorStore persists the second factor of users: their TOTP secret,
+// the hashes of their recovery codes and the challenges of logins waiting
+// for a code.
+type TwoFactorStore interface {
+	// GetTOTP returns the TOTP enrollment of userID, confirmed or not.
+	GetTOTP(ctx context.Context, userID int) (models.TOTPEnrollment, error)
+	// BeginTOTPEnrollment stores secret as the unconfirmed TOTP secret of
+	// userID, replacing an earlier unconfirmed one. It fails with
+	// ErrConflict when userID already has a confirmed secret.
+	BeginTOTPEnrollment(ctx context.Context, userID int, secret string) error
+	// ConfirmTOTP turns on the unconfirmed secret of userID, records step
+	// as used and stores codeHashes as the recovery codes. Users without an
+	// unconfirmed secret are reported as ErrNotFound.
+	ConfirmTOTP(ctx context.Context, userID int, step int64, codeHashes []string) error
+	// UseTOTPStep records that a code of time step step was used. It fails
+	// with ErrCodeReused unless step is later than every step used before.
+	UseTOTPStep(ctx context.Context, userID int, step int64) error
+	// UseRecoveryCode marks the unused recovery code stored as codeHash as
+	// used. Unknown and used codes are reported as ErrNotFound.
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string) error
+	// ReplaceRecoveryCodes stores codeHashes as the only recovery codes of
+	// userID.
+	ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error
+	// DisableTOTP forgets the TOTP secret and recovery codes of userID.
+	DisableTOTP(ctx context.Context, userID int) error
+
+	// CreateMFAChallenge stores challenge and forgets the expired
+	// challenges of challenge.UserID.
+	CreateMFAChallenge(ctx context.Context, challenge *models.MFAChallenge) error
+	// GetMFAChallenge returns the challenge stored as tokenHash. Unknown and
+	// expired challenges are reported as ErrNotFound.
+	GetMFAChallenge(ctx context.Context, tokenHash string) (models.MFAChallenge, error)
+	// FailMFAChallenge counts a wrong code for challengeID and deletes the
+	// challenge once maxAttempts have been made.
+	FailMFAChallenge(ctx context.Context, challengeID, maxAttempts int) error
+	// DeleteMFAChallenge deletes challengeID, failing with ErrNotFound when
+	// it is already gone, so a challenge can only be completed once.
+	DeleteMFAChallenge(ctx context.Context, challengeID int) error
+}
+
+// APIKeyStore persists the personal API keys of users. Only the hash of a
+// key is stored. Key names are unique per user.
+type APIKeyStore interface {
+	// CreateAPIKey stores key and sets its ID and CreatedAt, failing with
+	// ErrConflict when key.UserID already has a key of the same name.
+	CreateAPIKey(ctx context.Context, key *models.APIKey) error
+	ListAPIKeys(ctx context.Context, userID int) ([]models.APIKey, error)
+	DeleteAPIKey(ctx context.Context, userID, keyID int) error
+	// AuthenticateAPIKey returns the key stored as keyHash and records that
+	// it was used. Unknown and expired keys, and the keys of users whose
+	// account is scheduled for deletion, are reported as ErrNotFound.
+	AuthenticateAPIKey(ctx context.Context, keyHash string) (models.APIKey, error)
+}
+
+// OIDCStore persists the links between users and the accounts they sign in
+// with at OpenID Connect providers, and the logins waiting for a provider to
+// redirect back. Only the hash of a login's state parameter is stored.
+type OIDCStore interface {
+	// CreateOIDCLogin stores login and forgets the expired logins.
+	CreateOIDCLogin(ctx context.Context, login *models.OIDCLogin) error
+	// ConsumeOIDCLogin deletes and returns the login stored as stateHash,
+	// so every login completes at most once. Unknown and expired logins are
+	// reported as ErrNotFound.
+	ConsumeOIDCLogin(ctx context.Context, stateHash string) (models.OIDCLogin, error)
+	// FindUserByIdentity returns the user linked to the account subject at
+	// the provider issuer.
+	FindUserByIdentity(ctx context.Context, issuer, subject string) (models.ListCurator, error)
+	// LinkIdentity links identity to identity.UserID and sets its ID and
+	// CreatedAt, failing with ErrConflict when the account is linked
+	// already.
+	LinkIdentity(ctx context.Context, identity *models.UserIdentity) error
+	// CreateUserWithIdentity stores user like UserStore.CreateUser and links
+	// identity to them in the same transaction. It fails with ErrConflict
+	// when the email address is taken or the account is linked already.
+	CreateUserWithIdentity(ctx context.Context, user *models.ListCurator, identity *models.UserIdentity) error
+}
+
+// AccountStore persists the changes users make to their own account. Only
+// the hash of an email change token is stored.
+type AccountStore interface {
+	// UpdateProfile stores the name, time zone and locale of user.ID and
+	// refreshes the remaining fields of user.
+	UpdateProfile(ctx context.Context, user *models.ListCurator) error
+	// ChangePassword sets the password hash of userID and revokes their
+	// refresh tokens.
+	ChangePassword(ctx context.Context, userID int, passwordHash string) error
+	// RehashPassword replaces the password hash of userID with newHash, a
+	// hash of the same password, unless it changed from oldHash meanwhile.
+	// Sessions are left alone.
+	RehashPassword(ctx context.Context, userID int, oldHash, newHash string) error
+	// CreateEmailChange stores change, replacing the changes
+	// change.UserID asked for earlier.
+	CreateEmailChange(ctx context.Context, change *models.EmailChange) error
+	// ConfirmEmailChange makes the address the token stored as tokenHash
+	// was sent to the verified email address of its user, and returns the
+	// ID of the user. Tokens sent to the previous address stop working.
+	// Unknown and expired tokens are reported as ErrNotFound, addresses
+	// another user took in the meantime as ErrConflict.
+	ConfirmEmailChange(ctx context.Context, tokenHash string) (int, error)
+	// ScheduleUserDeletion marks userID for deletion at deleteAt and
+	// revokes their refresh tokens.
+	ScheduleUserDeletion(ctx context.Context, userID int, deleteAt time.Time) error
+	// CancelUserDeletion clears a deletion scheduled for userID.
+	CancelUserDeletion(ctx context.Context, userID int) error
+	// PurgeDeletedUsers deletes the users whose deletion was scheduled at or
+	// before before, together with their lists, the todos in them and the
+	// todos they created elsewhere. It returns how many users it deleted.
+	PurgeDeletedUsers(ctx context.Context, before time.Time) (int, error)
+}
+
+// Store is the complete storage backend the API runs on.
+type Store interface {
+	TodoStore
+	UserStore
+	TagStore
+	ListStore
+	ListMemberStore
+	ChecklistStore
+	RefreshTokenStore
+	TokenRevocationStore
+	PasswordResetStore
+	EmailVerificationStore
+	TwoFactorStore
+	APIKeyStore
+	OIDCStore
+	AccountStore
+}
@@ -0,0 +1,26 @@
+package store
+
+import (
+	"sort"
+	"strings"
+)
+
+// MaxTagNameLength is the longest tag name the tags table accepts.
+const MaxTagNameLength = 64
+
+// normalizeTagNames trims the given tag names and drops empty and duplicate
+// entries. The result is sorted, matching the order tags are returned in.
+func normalizeTagNames(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	normalized := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		normalized = append(normalized, name)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
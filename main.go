@@ -6,12 +6,17 @@
 // @securityDefinitions.apikey ApiKeyAuth
 // @in header
 // @name Authorization
+// @description "Bearer" followed by an access token or a personal API key.
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"time"
+	_ "time/tzdata"
 
 	"github.com/joho/godotenv"
 	"github.com/rs/cors"
@@ -19,18 +24,99 @@ import (
 	"github.com/Kwagmire/go-todo-api/internal/app/handlers"
 	"github.com/Kwagmire/go-todo-api/internal/pkg/auth"
 	"github.com/Kwagmire/go-todo-api/internal/pkg/db"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/mail"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/oidc"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/password"
+	"github.com/Kwagmire/go-todo-api/internal/pkg/store"
 
 	_ "github.com/Kwagmire/go-todo-api/docs"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
 func main() {
+	migrateCmd := flag.String("migrate", "", "run database migrations and exit: up, down or status")
+	steps := flag.Int("steps", 1, "number of migrations to roll back with -migrate down")
+	flag.Parse()
+
 	err := godotenv.Load()
 	if err != nil {
 		log.Println("Warning: Could not load .env file. Assuming environment variables are set in the environment.")
 	}
 
-	db.InitDB()
+	if *migrateCmd != "" {
+		if err := runMigrations(*migrateCmd, *steps); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if _, _, err := auth.TokenLifetimes(); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := auth.PasswordResetTTL(); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := auth.EmailVerificationTTL(); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := auth.AccountDeletionGrace(); err != nil {
+		log.Fatal(err)
+	}
+	keyring, err := auth.LoadKeyring()
+	if err != nil {
+		log.Fatal(err)
+	}
+	auth.UseKeyring(keyring)
+	policy, err := auth.VerificationPolicyFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	auth.UseVerificationPolicy(policy)
+	if err := db.InitDB(); err != nil {
+		log.Fatal(err)
+	}
+	st := store.NewPostgresStore(db.DB)
+	if db.Driver == db.DriverSQLite {
+		st = store.NewSQLiteStore(db.DB)
+	}
+	mailer, err := mail.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	revocations := auth.NewRevocations(st, time.Minute)
+	auth.UseRevocations(revocations)
+	auth.UseAPIKeys(st)
+	oidcConfig, oidcEnabled, err := oidc.ConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var provider *oidc.Provider
+	if oidcEnabled {
+		provider, err = oidc.Discover(context.Background(), oidcConfig)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	throttle, err := auth.LoginThrottleFromEnv(st)
+	if err != nil {
+		log.Fatal(err)
+	}
+	passwords, err := password.PolicyFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	hasher, err := password.HasherFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	h := handlers.NewHandler(st, revocations, mailer, provider, throttle, passwords, hasher)
+	go purgeDeletedUsers(st)
+
+	// The scopes each route needs, see auth.RequireScopes.
+	readTodos := auth.RequireScopes(auth.ScopeTodosRead)
+	writeTodos := auth.RequireScopes(auth.ScopeTodosWrite)
+	adminLists := auth.RequireScopes(auth.ScopeListsAdmin)
+	manageAccount := auth.RequireScopes(auth.ScopeAccountManage)
 
 	mux := http.NewServeMux()
 
@@ -38,19 +124,73 @@ func main() {
 		httpSwagger.URL("http://localhost:8080/swagger/doc.json"), //The url pointing to API definition
 	))
 
-	mux.HandleFunc("POST /register", handlers.RegisterUser)
-	mux.HandleFunc("POST /login", handlers.LoginUser)
+	mux.HandleFunc("GET /.well-known/jwks.json", h.GetJWKS)
+	mux.HandleFunc("POST /register", h.RegisterUser)
+	mux.HandleFunc("POST /login", h.LoginUser)
+	mux.HandleFunc("POST /login/2fa", h.LoginTwoFactor)
+	mux.HandleFunc("GET /oidc/login", h.OIDCLogin)
+	mux.HandleFunc("GET /oidc/callback", h.OIDCCallback)
+	mux.HandleFunc("POST /token/refresh", h.RefreshToken)
+	mux.HandleFunc("POST /password/forgot", h.ForgotPassword)
+	mux.HandleFunc("POST /password/reset", h.ResetPassword)
+	mux.HandleFunc("POST /logout", auth.AuthMiddleware(h.Logout, auth.AllowUnverified, auth.TokensOnly))
+	mux.HandleFunc("POST /logout-all", auth.AuthMiddleware(h.LogoutAll, auth.AllowUnverified, auth.TokensOnly))
+	mux.HandleFunc("GET /verify", h.VerifyEmail)
+	mux.HandleFunc("POST /verify/resend", h.ResendVerification)
+	mux.HandleFunc("GET /2fa", auth.AuthMiddleware(h.GetTwoFactorStatus, auth.TokensOnly, manageAccount))
+	mux.HandleFunc("POST /2fa/totp", auth.AuthMiddleware(h.EnrollTOTP, auth.TokensOnly, manageAccount))
+	mux.HandleFunc("POST /2fa/totp/confirm", auth.AuthMiddleware(h.ConfirmTOTP, auth.TokensOnly, manageAccount))
+	mux.HandleFunc("DELETE /2fa/totp", auth.AuthMiddleware(h.DisableTOTP, auth.TokensOnly, manageAccount))
+	mux.HandleFunc("POST /2fa/recovery-codes", auth.AuthMiddleware(h.RegenerateRecoveryCodes, auth.TokensOnly, manageAccount))
+	mux.HandleFunc("GET /me", auth.AuthMiddleware(h.GetAccount, manageAccount))
+	mux.HandleFunc("PATCH /me", auth.AuthMiddleware(h.UpdateAccount, manageAccount))
+	mux.HandleFunc("DELETE /me", auth.AuthMiddleware(h.DeleteAccount, auth.AllowUnverified, auth.TokensOnly, manageAccount))
+	mux.HandleFunc("POST /me/password", auth.AuthMiddleware(h.ChangePassword, auth.TokensOnly, manageAccount))
+	mux.HandleFunc("POST /me/email", auth.AuthMiddleware(h.ChangeEmail, auth.AllowUnverified, auth.TokensOnly, manageAccount))
+	mux.HandleFunc("GET /me/email/confirm", h.ConfirmEmailChange)
+	mux.HandleFunc("GET /api-keys", auth.AuthMiddleware(h.GetAPIKeys, auth.TokensOnly, manageAccount))
+	mux.HandleFunc("POST /api-keys", auth.AuthMiddleware(h.CreateAPIKey, auth.TokensOnly, manageAccount))
+	mux.HandleFunc("DELETE /api-keys/{id}", auth.AuthMiddleware(h.DeleteAPIKey, auth.TokensOnly, manageAccount))
+
+	mux.HandleFunc("POST /todos", auth.AuthMiddleware(h.AddTodo, writeTodos))
+	mux.HandleFunc("GET /todos", auth.AuthMiddleware(h.GetTodos, readTodos))
+	mux.HandleFunc("GET /todos/today", auth.AuthMiddleware(h.GetTodayTodos, readTodos))
+
+	mux.HandleFunc("GET /todos/{id}", auth.AuthMiddleware(h.GetTodo, readTodos))
+	mux.HandleFunc("PUT /todos/{id}", auth.AuthMiddleware(h.UpdateTodo, writeTodos))
+	mux.HandleFunc("PATCH /todos/{id}", auth.AuthMiddleware(h.PatchTodo, writeTodos))
+	mux.HandleFunc("DELETE /todos/{id}", auth.AuthMiddleware(h.DeleteTodo, writeTodos))
+	mux.HandleFunc("POST /todos/{id}/complete", auth.AuthMiddleware(h.CompleteTodo, writeTodos))
+	mux.HandleFunc("POST /todos/{id}/reopen", auth.AuthMiddleware(h.ReopenTodo, writeTodos))
+	mux.HandleFunc("GET /todos/{id}/occurrences", auth.AuthMiddleware(h.GetOccurrences, readTodos))
+	mux.HandleFunc("GET /todos/{id}/items", auth.AuthMiddleware(h.GetChecklist, readTodos))
+	mux.HandleFunc("POST /todos/{id}/items", auth.AuthMiddleware(h.AddChecklistItem, writeTodos))
+	mux.HandleFunc("PUT /todos/{id}/items/order", auth.AuthMiddleware(h.ReorderChecklist, writeTodos))
+	mux.HandleFunc("PUT /todos/{id}/items/{item_id}", auth.AuthMiddleware(h.RenameChecklistItem, writeTodos))
+	mux.HandleFunc("POST /todos/{id}/items/{item_id}/toggle", auth.AuthMiddleware(h.ToggleChecklistItem, writeTodos))
+	mux.HandleFunc("DELETE /todos/{id}/items/{item_id}", auth.AuthMiddleware(h.DeleteChecklistItem, writeTodos))
 
-	mux.HandleFunc("POST /todos", auth.AuthMiddleware(handlers.AddTodo))
-	mux.HandleFunc("GET /todos", auth.AuthMiddleware(handlers.GetTodos))
+	mux.HandleFunc("GET /lists", auth.AuthMiddleware(h.GetLists, readTodos))
+	mux.HandleFunc("POST /lists", auth.AuthMiddleware(h.CreateList, adminLists))
+	mux.HandleFunc("GET /lists/{id}", auth.AuthMiddleware(h.GetList, readTodos))
+	mux.HandleFunc("PUT /lists/{id}", auth.AuthMiddleware(h.RenameList, adminLists))
+	mux.HandleFunc("DELETE /lists/{id}", auth.AuthMiddleware(h.DeleteList, adminLists))
+	mux.HandleFunc("GET /lists/{id}/todos", auth.AuthMiddleware(h.GetListTodos, readTodos))
+	mux.HandleFunc("GET /lists/{id}/members", auth.AuthMiddleware(h.GetListMembers, readTodos))
+	mux.HandleFunc("POST /lists/{id}/members", auth.AuthMiddleware(h.InviteListMember, adminLists))
+	mux.HandleFunc("PUT /lists/{id}/members/{user_id}", auth.AuthMiddleware(h.UpdateListMember, adminLists))
+	mux.HandleFunc("DELETE /lists/{id}/members/{user_id}", auth.AuthMiddleware(h.RemoveListMember, adminLists))
 
-	mux.HandleFunc("PUT /todos/", auth.AuthMiddleware(handlers.UpdateTodo))
-	mux.HandleFunc("DELETE /todos/", auth.AuthMiddleware(handlers.DeleteTodo))
+	mux.HandleFunc("GET /tags", auth.AuthMiddleware(h.GetTags, readTodos))
+	mux.HandleFunc("POST /tags", auth.AuthMiddleware(h.CreateTag, writeTodos))
+	mux.HandleFunc("PUT /tags/{id}", auth.AuthMiddleware(h.RenameTag, writeTodos))
+	mux.HandleFunc("DELETE /tags/{id}", auth.AuthMiddleware(h.DeleteTag, writeTodos))
 
 	c := cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE"},
-		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type", "If-Match"},
+		ExposedHeaders:   []string{"ETag"},
 		AllowCredentials: true,
 	})
 
@@ -60,3 +200,66 @@ func main() {
 	fmt.Printf("Todo API server starting on http://localhost%s...", serverPort)
 	log.Fatal(http.ListenAndServe(serverPort, handler))
 }
+
+// purgeDeletedUsers deletes the accounts whose deletion grace period is
+// over, once an hour.
+func purgeDeletedUsers(st store.Store) {
+	for ; ; time.Sleep(time.Hour) {
+		purged, err := st.PurgeDeletedUsers(context.Background(), time.Now())
+		if err != nil {
+			log.Printf("purging deleted users: %v", err)
+			continue
+		}
+		if purged > 0 {
+			log.Printf("purged %d deleted user(s)", purged)
+		}
+	}
+}
+
+// runMigrations handles the -migrate flag: it applies, rolls back or lists
+// the schema migrations without starting the server.
+func runMigrations(command string, steps int) error {
+	if err := db.Open(); err != nil {
+		return err
+	}
+	defer db.DB.Close()
+
+	migrator, err := db.NewMigrator(db.DB, db.Driver)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migration(s)\n", applied)
+	case "down":
+		if steps < 1 {
+			return fmt.Errorf("-steps must be at least 1")
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Rolled back %d migration(s)\n", reverted)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied"
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, state)
+		}
+	default:
+		return fmt.Errorf("unknown -migrate command %q (expected up, down or status)", command)
+	}
+	return nil
+}
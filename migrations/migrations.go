@@ -0,0 +1,13 @@
+// Package migrations holds the versioned database schema so it can be
+// compiled into the binary.
+//
+// Every database driver has its own directory of numbered scripts named
+// <version>_<name>.up.sql and <version>_<name>.down.sql. Versions are shared
+// between the drivers: a change to the schema adds the same version to every
+// directory.
+package migrations
+
+import "embed"
+
+//go:embed postgres/*.sql sqlite/*.sql
+var FS embed.FS
@@ -14,7 +14,7 @@ CREATE TABLE IF NOT EXISTS todos (
 	title VARCHAR(255) NOT NULL,
 	description TEXT NOT NULL,
 	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
-	);
+);
 
 -- Add index for user_id on todos table for faster lookups
 CREATE INDEX IF NOT EXISTS idx_todos_user_id ON todos(user_id);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_todos_user_id_due_at;
+
+ALTER TABLE todos DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE todos DROP COLUMN IF EXISTS created_at;
+ALTER TABLE todos DROP COLUMN IF EXISTS due_at;
@@ -0,0 +1,20 @@
+-- Create 'tags' table, tag names are unique per user
+CREATE TABLE IF NOT EXISTS tags (
+	id SERIAL PRIMARY KEY,
+	user_id INT NOT NULL,
+	name VARCHAR(64) NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+	UNIQUE (user_id, name)
+);
+
+-- Create 'todo_tags' table linking todos to their tags
+CREATE TABLE IF NOT EXISTS todo_tags (
+	todo_id INT NOT NULL,
+	tag_id INT NOT NULL,
+	PRIMARY KEY (todo_id, tag_id),
+	FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE,
+	FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_todo_tags_tag_id ON todo_tags(tag_id);
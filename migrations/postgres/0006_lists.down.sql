@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_todos_list_id;
+ALTER TABLE todos DROP COLUMN IF EXISTS list_id;
+
+DROP TABLE IF EXISTS lists;
@@ -0,0 +1,27 @@
+-- Create 'lists' table grouping each user's todos
+CREATE TABLE IF NOT EXISTS lists (
+	id SERIAL PRIMARY KEY,
+	user_id INT NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	is_default BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Every user has exactly one default list
+CREATE UNIQUE INDEX IF NOT EXISTS idx_lists_user_id_default ON lists(user_id) WHERE is_default;
+
+-- Give every existing user an Inbox
+INSERT INTO lists (user_id, name, is_default)
+SELECT id, 'Inbox', TRUE FROM users
+WHERE NOT EXISTS (SELECT 1 FROM lists WHERE lists.user_id = users.id AND lists.is_default);
+
+-- Put every todo in a list, starting with its owner's Inbox
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS list_id INT REFERENCES lists(id) ON DELETE CASCADE;
+UPDATE todos SET list_id = (
+	SELECT id FROM lists WHERE lists.user_id = todos.user_id AND lists.is_default
+) WHERE list_id IS NULL;
+ALTER TABLE todos ALTER COLUMN list_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_todos_list_id ON todos(list_id);
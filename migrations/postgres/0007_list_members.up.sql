@@ -0,0 +1,17 @@
+-- Create 'list_members' table recording who can access each list
+CREATE TABLE IF NOT EXISTS list_members (
+	list_id INT NOT NULL,
+	user_id INT NOT NULL,
+	role VARCHAR(16) NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (list_id, user_id),
+	FOREIGN KEY (list_id) REFERENCES lists(id) ON DELETE CASCADE,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_list_members_user_id ON list_members(user_id);
+
+-- Every list is owned by the user that created it
+INSERT INTO list_members (list_id, user_id, role)
+SELECT id, user_id, 'owner' FROM lists
+ON CONFLICT (list_id, user_id) DO NOTHING;
@@ -0,0 +1,4 @@
+-- Let todos repeat following an RFC 5545 recurrence rule
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS recurrence VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS recurrence_start TIMESTAMP WITH TIME ZONE;
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS next_occurrence_id INT REFERENCES todos(id) ON DELETE SET NULL;
@@ -0,0 +1,16 @@
+-- Track whether users verified their email address. Accounts registered
+-- before verification existed are trusted as verified.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE users SET email_verified = TRUE;
+
+-- Create 'email_verifications' table holding the hashes of verification tokens
+CREATE TABLE IF NOT EXISTS email_verifications (
+	id SERIAL PRIMARY KEY,
+	user_id INT NOT NULL,
+	token_hash VARCHAR(64) UNIQUE NOT NULL,
+	expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_verifications_user_id ON email_verifications(user_id);
@@ -0,0 +1,36 @@
+-- Create 'user_totp' table holding the TOTP secret of users enrolled in
+-- two-factor authentication. Enrollment is pending until confirmed_at is set.
+CREATE TABLE IF NOT EXISTS user_totp (
+	user_id INT PRIMARY KEY,
+	secret VARCHAR(64) NOT NULL,
+	confirmed_at TIMESTAMP WITH TIME ZONE,
+	last_step BIGINT NOT NULL DEFAULT 0,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Create 'recovery_codes' table holding the hashes of two-factor recovery codes
+CREATE TABLE IF NOT EXISTS recovery_codes (
+	id SERIAL PRIMARY KEY,
+	user_id INT NOT NULL,
+	code_hash VARCHAR(64) NOT NULL,
+	used_at TIMESTAMP WITH TIME ZONE,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes(user_id);
+
+-- Create 'mfa_challenges' table holding the hashes of the tokens that finish
+-- a login with a second factor
+CREATE TABLE IF NOT EXISTS mfa_challenges (
+	id SERIAL PRIMARY KEY,
+	user_id INT NOT NULL,
+	token_hash VARCHAR(64) UNIQUE NOT NULL,
+	attempts INT NOT NULL DEFAULT 0,
+	expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_mfa_challenges_user_id ON mfa_challenges(user_id);
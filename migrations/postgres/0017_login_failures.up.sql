@@ -0,0 +1,9 @@
+-- Create 'login_failures' table counting the failed logins per account and
+-- client address
+CREATE TABLE IF NOT EXISTS login_failures (
+	throttle_key VARCHAR(320) PRIMARY KEY,
+	failures INT NOT NULL,
+	last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_failures_last_failure_at ON login_failures(last_failure_at);
@@ -0,0 +1,6 @@
+-- Expand recurrence rules in the time zone of the user who set them, starting
+-- with the recurring todos that exist already
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS recurrence_tz VARCHAR(64) NOT NULL DEFAULT '';
+
+UPDATE todos SET recurrence_tz = (SELECT timezone FROM users WHERE users.id = todos.user_id)
+WHERE recurrence <> '';
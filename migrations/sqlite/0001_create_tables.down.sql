@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS todos;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_todos_user_id_completed;
+
+ALTER TABLE todos DROP COLUMN completed_at;
+ALTER TABLE todos DROP COLUMN completed;
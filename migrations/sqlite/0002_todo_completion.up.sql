@@ -0,0 +1,5 @@
+-- Track whether and when a todo was completed
+ALTER TABLE todos ADD COLUMN completed BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE todos ADD COLUMN completed_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_todos_user_id_completed ON todos(user_id, completed);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_todos_user_id_due_at;
+
+ALTER TABLE todos DROP COLUMN updated_at;
+ALTER TABLE todos DROP COLUMN created_at;
+ALTER TABLE todos DROP COLUMN due_at;
@@ -0,0 +1,12 @@
+-- Track when a todo is due and when it was created and last changed.
+-- SQLite cannot add columns with a non-constant default, so existing rows
+-- are stamped afterwards in the format the application writes.
+ALTER TABLE todos ADD COLUMN due_at TIMESTAMP;
+ALTER TABLE todos ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00+00:00';
+ALTER TABLE todos ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00+00:00';
+
+UPDATE todos SET
+	created_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'),
+	updated_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now');
+
+CREATE INDEX IF NOT EXISTS idx_todos_user_id_due_at ON todos(user_id, due_at);
@@ -0,0 +1,2 @@
+-- Rank todos by importance: 0 none, 1 low, 2 medium, 3 high, 4 urgent
+ALTER TABLE todos ADD COLUMN priority SMALLINT NOT NULL DEFAULT 0;
@@ -0,0 +1,32 @@
+-- Create 'lists' table grouping each user's todos
+CREATE TABLE IF NOT EXISTS lists (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	is_default BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Every user has exactly one default list
+CREATE UNIQUE INDEX IF NOT EXISTS idx_lists_user_id_default ON lists(user_id) WHERE is_default;
+
+-- Give every existing user an Inbox
+INSERT INTO lists (user_id, name, is_default, created_at, updated_at)
+SELECT id, 'Inbox', TRUE,
+	strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'),
+	strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+FROM users
+WHERE NOT EXISTS (SELECT 1 FROM lists WHERE lists.user_id = users.id AND lists.is_default);
+
+-- Put every todo in a list, starting with its owner's Inbox. SQLite cannot
+-- drop a column that is part of a foreign key, so to keep this migration
+-- reversible list_id has no REFERENCES clause here; the store deletes a
+-- list's todos itself.
+ALTER TABLE todos ADD COLUMN list_id INTEGER;
+UPDATE todos SET list_id = (
+	SELECT id FROM lists WHERE lists.user_id = todos.user_id AND lists.is_default
+) WHERE list_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_todos_list_id ON todos(list_id);
@@ -0,0 +1,16 @@
+-- Create 'list_members' table recording who can access each list
+CREATE TABLE IF NOT EXISTS list_members (
+	list_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	role VARCHAR(16) NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (list_id, user_id),
+	FOREIGN KEY (list_id) REFERENCES lists(id) ON DELETE CASCADE,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_list_members_user_id ON list_members(user_id);
+
+-- Every list is owned by the user that created it
+INSERT OR IGNORE INTO list_members (list_id, user_id, role, created_at)
+SELECT id, user_id, 'owner', strftime('%Y-%m-%d %H:%M:%f+00:00', 'now') FROM lists;
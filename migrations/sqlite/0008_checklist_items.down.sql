@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS checklist_items;
+
+ALTER TABLE todos DROP COLUMN auto_complete;
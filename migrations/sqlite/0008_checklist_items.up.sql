@@ -0,0 +1,16 @@
+-- Let todos complete themselves once their checklist is done
+ALTER TABLE todos ADD COLUMN auto_complete BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Create 'checklist_items' table holding the ordered sub-items of a todo
+CREATE TABLE IF NOT EXISTS checklist_items (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	todo_id INTEGER NOT NULL,
+	title VARCHAR(255) NOT NULL,
+	done BOOLEAN NOT NULL DEFAULT FALSE,
+	position INTEGER NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	FOREIGN KEY (todo_id) REFERENCES todos(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_checklist_items_todo_id ON checklist_items(todo_id, position);
@@ -0,0 +1,3 @@
+ALTER TABLE todos DROP COLUMN next_occurrence_id;
+ALTER TABLE todos DROP COLUMN recurrence_start;
+ALTER TABLE todos DROP COLUMN recurrence;
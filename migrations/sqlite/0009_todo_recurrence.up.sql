@@ -0,0 +1,5 @@
+-- Let todos repeat following an RFC 5545 recurrence rule. next_occurrence_id
+-- has no REFERENCES clause so the column can be dropped again.
+ALTER TABLE todos ADD COLUMN recurrence VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE todos ADD COLUMN recurrence_start TIMESTAMP;
+ALTER TABLE todos ADD COLUMN next_occurrence_id INTEGER;
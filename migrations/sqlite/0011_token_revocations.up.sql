@@ -0,0 +1,14 @@
+-- Create 'token_revocations' table holding access tokens revoked before
+-- they expire. A row without token_id revokes every token of the user
+-- issued before created_at.
+CREATE TABLE IF NOT EXISTS token_revocations (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	token_id VARCHAR(64),
+	user_id INTEGER NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_token_revocations_created_at ON token_revocations(created_at);
+CREATE INDEX IF NOT EXISTS idx_token_revocations_expires_at ON token_revocations(expires_at);
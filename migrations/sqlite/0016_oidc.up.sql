@@ -0,0 +1,25 @@
+-- Create 'user_identities' table linking users to the accounts they sign in
+-- with at an OpenID Connect provider
+CREATE TABLE IF NOT EXISTS user_identities (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	issuer VARCHAR(255) NOT NULL,
+	subject VARCHAR(255) NOT NULL,
+	email VARCHAR(255) NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	UNIQUE (issuer, subject),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+
+-- Create 'oidc_logins' table holding the state of OpenID Connect logins
+-- waiting for the provider to redirect back
+CREATE TABLE IF NOT EXISTS oidc_logins (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	state_hash VARCHAR(64) UNIQUE NOT NULL,
+	nonce VARCHAR(64) NOT NULL,
+	code_verifier VARCHAR(128) NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	created_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS email_changes;
+
+DROP INDEX IF EXISTS idx_users_delete_at;
+ALTER TABLE users DROP COLUMN delete_at;
+ALTER TABLE users DROP COLUMN locale;
+ALTER TABLE users DROP COLUMN timezone;
@@ -0,0 +1,21 @@
+-- Add the profile settings of users and when accounts scheduled for deletion
+-- are deleted
+ALTER TABLE users ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+ALTER TABLE users ADD COLUMN locale VARCHAR(35) NOT NULL DEFAULT 'en';
+ALTER TABLE users ADD COLUMN delete_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_users_delete_at ON users(delete_at);
+
+-- Create 'email_changes' table holding the hashes of the tokens that confirm
+-- a new email address
+CREATE TABLE IF NOT EXISTS email_changes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	new_email VARCHAR(255) NOT NULL,
+	token_hash VARCHAR(64) UNIQUE NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_changes_user_id ON email_changes(user_id);